@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-players-data/internal/archive"
+	"go-players-data/internal/config"
+	"go-players-data/internal/filter"
+	"go-players-data/internal/logger"
+	"go-players-data/internal/model"
+	"go-players-data/internal/player"
+)
+
+// errBackfillRange is returned when a backfill bound is neither a date nor an RFC 3339 time, or the range is empty.
+var errBackfillRange = errors.New("invalid backfill range")
+
+// BackfillReport is the outcome of a backfill.
+type BackfillReport struct {
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	Runs    int       `json:"runs"`    // Archived runs whose offline set was rebuilt
+	Players int       `json:"players"` // Players parsed from their payloads
+	Offline int       `json:"offline"` // Offline players written to their offline sets
+}
+
+// backfill rebuilds the offline sets of the runs archived within [from, to] from their raw payloads,
+// so the history read by the weekly report and run comparison covers runs archived before those features were enabled
+// or under other filter settings. Every payload is parsed and filtered as of the time it was fetched.
+// Nothing is sent and no other state is touched.
+func backfill(ctx context.Context, cfg config.Config, from, to string) (*BackfillReport, error) {
+	start := time.Now()
+	defer func() { logger.Debug("main.backfill: Time spent", "time", time.Since(start).String()) }()
+
+	fromTime, err := parseBound(from, false)
+	if err != nil {
+		return nil, err
+	}
+	toTime := start
+	if to != "" {
+		if toTime, err = parseBound(to, true); err != nil {
+			return nil, err
+		}
+	}
+	if toTime.Before(fromTime) {
+		return nil, fmt.Errorf("main.backfill: %w: %s is after %s", errBackfillRange, from, to)
+	}
+
+	criteria, err := newCriteria(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("main.backfill: %w", err)
+	}
+
+	var (
+		parser   = player.New(cfg.Data)
+		archiver = archive.New(newStorage(cfg), cfg.Archive)
+		report   = &BackfillReport{From: fromTime, To: toTime}
+	)
+
+	err = archiver.Payloads(ctx, fromTime, toTime, func(p archive.Payload) error {
+		var players []*model.Player
+		for name, data := range p.Sources {
+			parsed, err := payloadPlayers(parser, name, data)
+			if err != nil {
+				return fmt.Errorf("main.backfill: run %s: %w", p.ID, err)
+			}
+			players = append(players, parsed...)
+		}
+
+		offline := offlineAt(criteria, players, p.At)
+		if err = archiver.Restore(ctx, p, offline); err != nil {
+			return fmt.Errorf("main.backfill: run %s: %w", p.ID, err)
+		}
+
+		report.Runs++
+		report.Players += len(players)
+		report.Offline += len(offline)
+		logger.Info("main.backfill: Run rebuilt", "run_id", p.ID, "at", p.At, "players", len(players), "offline", len(offline))
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	return report, nil
+}
+
+// payloadPlayers parses the archived payload of a source and tags its players with the source name.
+// Runs archived before every source got its own object keep the payloads of several sources
+// in a single JSON object keyed by source name, told apart from a payload of one source by its layout,
+// since a payload of players is a JSON array, an XML report or CSV rows, never a JSON object.
+func payloadPlayers(parser player.Parser, source string, data []byte) ([]*model.Player, error) {
+	if source != "" || !combined(data) {
+		players, err := parser.Players(data)
+		if err != nil {
+			return nil, fmt.Errorf("source %q: %w", source, err)
+		}
+		for _, p := range players {
+			p.Source = source
+		}
+		return players, nil
+	}
+
+	var payloads map[string]json.RawMessage
+	if err := json.Unmarshal(data, &payloads); err != nil {
+		return nil, fmt.Errorf("failed to decode payload of several sources: %w", err)
+	}
+
+	var players []*model.Player
+	for name, payload := range payloads {
+		parsed, err := parser.Players(payload)
+		if err != nil {
+			return nil, fmt.Errorf("source %q: %w", name, err)
+		}
+		for _, p := range parsed {
+			p.Source = name
+		}
+		players = append(players, parsed...)
+	}
+
+	return players, nil
+}
+
+// offlineAt returns the players passing the criteria at the time.
+func offlineAt(criteria filter.Criteria, players []*model.Player, at time.Time) []*model.Player {
+	var offline []*model.Player
+	for _, p := range players {
+		if criteria.KeepAt(p, at) {
+			offline = append(offline, p)
+		}
+	}
+	return offline
+}
+
+// combined reports whether an archived payload is a JSON object of the payloads of several sources.
+func combined(data []byte) bool {
+	data = bytes.TrimLeft(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), " \t\r\n")
+	return len(data) > 0 && data[0] == '{'
+}
+
+// parseBound parses a backfill bound as an RFC 3339 time or a date in UTC.
+// A date means the start of that day, or its end when it is the upper bound.
+func parseBound(ref string, upper bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, ref); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse(time.DateOnly, ref)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("main.parseBound: %w: %q", errBackfillRange, ref)
+	}
+	if upper {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return t, nil
+}
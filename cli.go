@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"go-players-data/internal/cluster"
+	"go-players-data/internal/config"
+	"go-players-data/internal/fetcher"
+	"go-players-data/internal/logger"
+	"go-players-data/internal/mailer"
+	"go-players-data/internal/model"
+	"go-players-data/internal/player"
+)
+
+// errNoFile is returned by the stage subcommands that read a saved payload when -file is not set.
+var errNoFile = errors.New("-file is required")
+
+// stageCommands are the CLI subcommands running a single pipeline stage locally against a saved payload.
+var stageCommands = map[string]func(ctx context.Context, cfg config.Config, args []string) error{
+	"fetch":  fetchCommand,
+	"parse":  parseCommand,
+	"filter": filterCommand,
+	"send":   sendCommand,
+}
+
+// StageSend is the outcome of a cluster sent by the send subcommand.
+type StageSend struct {
+	StoreNumber int    `json:"store_number"`
+	Players     int    `json:"players"`
+	Error       string `json:"error,omitempty"`
+}
+
+// StageSendResult is the output of the send subcommand: the outcome of every cluster and the emails of a dry run.
+type StageSendResult struct {
+	Sends  []StageSend      `json:"sends"`
+	Emails []mailer.Message `json:"emails,omitempty"`
+}
+
+// fetchCommand writes the raw payload of a data source to -out, or to stdout, for the other stages to read.
+// -source names a DATA_SOURCE_URLS source; the first configured source is fetched otherwise.
+func fetchCommand(ctx context.Context, cfg config.Config, args []string) error {
+	fs := flag.NewFlagSet("fetch", flag.ContinueOnError)
+	name := fs.String("source", "", "name of the DATA_SOURCE_URLS source, the first source when empty")
+	out := fs.String("out", "", "file the payload is written to, stdout when empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	client, err := fetcher.NewClient(cfg.Data)
+	if err != nil {
+		return err
+	}
+
+	sources, err := newSources(client, cfg.Data)
+	if err != nil {
+		return err
+	}
+
+	source := sources[0]
+	if *name != "" {
+		found := false
+		for _, s := range sources {
+			if s.Name == *name {
+				source, found = s, true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("main.fetchCommand: unknown source %q", *name)
+		}
+	}
+
+	data, err := source.Fetcher.Data(ctx)
+	if err != nil {
+		return fmt.Errorf("main.fetchCommand: %w", err)
+	}
+
+	if *out == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err = os.WriteFile(*out, data, 0o644); err != nil {
+		return fmt.Errorf("main.fetchCommand: %w", err)
+	}
+
+	logger.Info("main.fetchCommand: Payload saved", "file", *out, "bytes", len(data))
+	return nil
+}
+
+// parseCommand prints every player of the saved payload as parsed, before filtering.
+func parseCommand(_ context.Context, cfg config.Config, args []string) error {
+	fs := flag.NewFlagSet("parse", flag.ContinueOnError)
+	file := fs.String("file", "", "saved payload: a JSON array, CSV rows or an XML report; - reads stdin")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	players, err := parseFile(cfg, *file)
+	if err != nil {
+		return err
+	}
+
+	printBody(&Response{Body: players})
+	return nil
+}
+
+// filterCommand prints the offline players of the saved payload, measured at -at or now.
+func filterCommand(_ context.Context, cfg config.Config, args []string) error {
+	fs := flag.NewFlagSet("filter", flag.ContinueOnError)
+	file := fs.String("file", "", "saved payload: a JSON array, CSV rows or an XML report; - reads stdin")
+	at := fs.String("at", "", "RFC 3339 time the offline duration is measured at, now when empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	players, err := offlineFromFile(cfg, *file, *at)
+	if err != nil {
+		return err
+	}
+
+	printBody(&Response{Body: players})
+	return nil
+}
+
+// sendCommand emails the clusters of the offline players of the saved payload through the configured mailer.
+// With -dry-run the emails are rendered and printed instead of sent.
+func sendCommand(ctx context.Context, cfg config.Config, args []string) error {
+	fs := flag.NewFlagSet("send", flag.ContinueOnError)
+	file := fs.String("file", "", "saved payload: a JSON array, CSV rows or an XML report; - reads stdin")
+	at := fs.String("at", "", "RFC 3339 time the offline duration is measured at, now when empty")
+	dryRun := fs.Bool("dry-run", false, "render the emails and print them instead of sending")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	players, err := offlineFromFile(cfg, *file, *at)
+	if err != nil {
+		return err
+	}
+
+	m, _, err := dependencies(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("main.sendCommand: %w", err)
+	}
+
+	var outbox *mailer.Outbox
+	if *dryRun || cfg.App.DryRun {
+		outbox = &mailer.Outbox{}
+		m = mailer.DryRun(m, outbox)
+	}
+
+	clusters, err := cluster.New().ByMode(players, cfg.Cluster.Mode, cfg.Cluster.GroupDepth)
+	if err != nil {
+		return fmt.Errorf("main.sendCommand: %w", err)
+	}
+
+	storeNumbers := make([]int, 0, len(clusters))
+	for sn := range clusters {
+		storeNumbers = append(storeNumbers, sn)
+	}
+	sort.Ints(storeNumbers)
+
+	// In digest mode every cluster shares the outcome of the single digest email
+	var digestErr error
+	if cfg.Mail.Mode == mailer.ModeDigest {
+		digestErr = m.SendDigest(clusters)
+	}
+
+	result := StageSendResult{Sends: make([]StageSend, 0, len(clusters))}
+	failed := false
+	for _, sn := range storeNumbers {
+		s := StageSend{StoreNumber: sn, Players: len(clusters[sn])}
+		err = digestErr
+		if cfg.Mail.Mode != mailer.ModeDigest {
+			err = m.Send(sn, clusters[sn])
+		}
+		if err != nil {
+			s.Error, failed = err.Error(), true
+		}
+		result.Sends = append(result.Sends, s)
+	}
+	if outbox != nil {
+		result.Emails = outbox.Messages()
+	}
+
+	printBody(&Response{Body: result})
+	if failed {
+		return errors.New("main.sendCommand: some clusters failed")
+	}
+	return nil
+}
+
+// parseFile parses the saved payload, or stdin when file is "-", with the configured parser.
+func parseFile(cfg config.Config, file string) ([]*model.Player, error) {
+	if file == "" {
+		return nil, errNoFile
+	}
+
+	f, err := fetcher.NewFile(file).Stream(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("main.parseFile: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	players, err := parsePayload(cfg, f)
+	if err != nil {
+		return nil, fmt.Errorf("main.parseFile: %w", err)
+	}
+
+	return players, nil
+}
+
+// parsePayload parses the payload of r, logging the entries skipped for invalid data.
+func parsePayload(cfg config.Config, r io.Reader) ([]*model.Player, error) {
+	p := player.New(cfg.Data)
+
+	var players []*model.Player
+	err := p.Stream(r, func(player *model.Player) error {
+		players = append(players, player)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("main.parsePayload: Payload parsed", "players", len(players), "skipped", p.Skipped())
+	return players, nil
+}
+
+// offlineFromFile parses the saved payload and keeps the players passing the filter rules at the RFC 3339 time,
+// or now when it is empty. Severity tiers are assigned at the same time.
+func offlineFromFile(cfg config.Config, file, at string) ([]*model.Player, error) {
+	now := time.Now()
+	if at != "" {
+		var err error
+		if now, err = time.Parse(time.RFC3339, at); err != nil {
+			return nil, fmt.Errorf("main.offlineFromFile: invalid -at: %w", err)
+		}
+	}
+
+	players, err := parseFile(cfg, file)
+	if err != nil {
+		return nil, err
+	}
+
+	criteria, err := newCriteria(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("main.offlineFromFile: %w", err)
+	}
+
+	offline := make([]*model.Player, 0, len(players))
+	for _, p := range players {
+		if criteria.KeepAt(p, now) {
+			offline = append(offline, p)
+		}
+	}
+
+	if len(cfg.Data.SeverityTiers) > 0 {
+		model.NewTiers(cfg.Data.SeverityTiers).Assign(offline, now)
+	}
+
+	logger.Info("main.offlineFromFile: Players filtered", "offline", len(offline), "total", len(players))
+	return offline, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+
+	"go-players-data/internal/fetcher"
+	"go-players-data/internal/logger"
+	"go-players-data/internal/player"
+)
+
+// stagePipeline is the stage fetching, parsing and filtering players.
+const stagePipeline = "pipeline"
+
+// Error classes of failed runs.
+const (
+	errorClassUpstream = "upstream"
+	errorClassAuth     = "auth"
+	errorClassInternal = "internal"
+)
+
+// ErrorBody is the response body of a run that failed at a stage.
+type ErrorBody struct {
+	Status    string `json:"status"`
+	RunID     string `json:"run_id,omitempty"`
+	Stage     string `json:"stage"`
+	Class     string `json:"class"`
+	Error     string `json:"error"`
+	Retriable bool   `json:"retriable"`
+}
+
+// failed reports a run that failed at a stage with a structured error body and a status code matching its class.
+// HTTP-trigger callers get the body with a nil error, since the runtime replaces the response of a failed invocation;
+// other triggers get the error too, so the invocation is marked as failed.
+func failed(event interface{}, stage, runID string, err error) (*Response, error) {
+	class, status, retriable := classify(stage, err)
+	logger.Error("main.Handler: Run failed", "stage", stage, "class", class, "err", err, "run_id", runID)
+
+	res := &Response{
+		StatusCode: status,
+		Body: ErrorBody{
+			Status:    "error",
+			RunID:     runID,
+			Stage:     stage,
+			Class:     class,
+			Error:     err.Error(),
+			Retriable: retriable,
+		},
+	}
+
+	if _, ok := asHTTPEvent(event); ok {
+		return res, nil
+	}
+	return res, err
+}
+
+// classify maps an error of a stage to its class, the status code answered for it and whether retrying the run may help.
+// Rejected credentials are auth errors; other upstream answers, and network failures and invalid payloads
+// of the pipeline stage, are upstream errors. Network failures of other stages are retriable internal errors.
+func classify(stage string, err error) (string, int, bool) {
+	var httpErr *fetcher.HTTPError
+	if errors.As(err, &httpErr) {
+		switch {
+		case httpErr.Code == http.StatusUnauthorized || httpErr.Code == http.StatusForbidden:
+			return errorClassAuth, http.StatusUnauthorized, false
+		case httpErr.Code == http.StatusTooManyRequests || httpErr.Code >= http.StatusInternalServerError:
+			return errorClassUpstream, http.StatusBadGateway, true
+		default:
+			return errorClassUpstream, http.StatusBadGateway, false
+		}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if stage != stagePipeline {
+			return errorClassInternal, http.StatusInternalServerError, true
+		}
+		return errorClassUpstream, http.StatusBadGateway, true
+	}
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) || errors.Is(err, player.ErrPayload) || errors.Is(err, player.ErrCSVHeader) {
+		return errorClassUpstream, http.StatusBadGateway, false
+	}
+
+	return errorClassInternal, http.StatusInternalServerError, false
+}
@@ -2,27 +2,76 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"embed"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"go-players-data/internal/ack"
+	"go-players-data/internal/alertmanager"
+	"go-players-data/internal/archive"
+	"go-players-data/internal/chaos"
+	"go-players-data/internal/chunk"
+	"go-players-data/internal/chwriter"
 	"go-players-data/internal/cluster"
+	"go-players-data/internal/codec"
+	"go-players-data/internal/compare"
 	"go-players-data/internal/config"
+	"go-players-data/internal/daily"
+	"go-players-data/internal/dedupe"
+	"go-players-data/internal/delta"
+	"go-players-data/internal/diag"
+	"go-players-data/internal/escalation"
+	"go-players-data/internal/export"
+	"go-players-data/internal/fault"
 	"go-players-data/internal/fetcher"
 	"go-players-data/internal/filter"
+	"go-players-data/internal/guard"
+	"go-players-data/internal/inventory"
 	"go-players-data/internal/logger"
 	"go-players-data/internal/mailer"
+	"go-players-data/internal/metrics"
 	"go-players-data/internal/model"
+	"go-players-data/internal/pipeline"
 	"go-players-data/internal/player"
+	"go-players-data/internal/promwrite"
+	"go-players-data/internal/registry"
+	"go-players-data/internal/report"
+	"go-players-data/internal/retry"
+	"go-players-data/internal/rollup"
+	"go-players-data/internal/runlog"
+	"go-players-data/internal/search"
+	"go-players-data/internal/sink"
+	"go-players-data/internal/snapshot"
+	"go-players-data/internal/stage"
+	"go-players-data/internal/storage"
 	"go-players-data/internal/templateloader"
+	"go-players-data/internal/throttle"
+	"go-players-data/internal/ticket"
+	"go-players-data/internal/tracker"
+	"go-players-data/internal/trend"
+	"go-players-data/internal/tuning"
 )
 
+// errNoSources is returned when neither DATA_URL nor DATA_SOURCE_URLS is set.
+var errNoSources = errors.New("no data source configured")
+
 // TimerEvent represents the structure of an event from a Yandex Cloud timer trigger.
 type TimerEvent struct {
 	ID          string `json:"id"`
 	TriggerType string `json:"trigger_type"`
 	TriggeredAt string `json:"triggered_at"`
+	Payload     string `json:"payload"`
 }
 
 // HTTPEvent represents the structure of an event from a Yandex Cloud HTTP trigger.
@@ -32,109 +81,1332 @@ type HTTPEvent struct {
 	Headers         map[string]string `json:"headers"`
 	Body            string            `json:"body"`
 	IsBase64Encoded bool              `json:"is_base64_encoded"`
+	Query           map[string]string `json:"queryStringParameters"`
 }
 
 // Response defines the response format for the Yandex Cloud Function.
 // Used for HTTP triggers; ignored for timer triggers.
 type Response struct {
-	StatusCode int         `json:"statusCode"`
-	Body       interface{} `json:"body"`
+	StatusCode int               `json:"statusCode"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Body       interface{}       `json:"body"`
 }
 
+// flushTimeout bounds the saving of state once the context of a run is done.
+const flushTimeout = 10 * time.Second
+
+// serverTemplateLoader is a long-lived, hot-reloaded template loader, serverSnapshots keeps the results
+// of recent runs, and serverIndex is the searchable view of every player of the last run.
+// All are set in server mode and stay nil in the Cloud Function.
+var (
+	serverTemplateLoader *templateloader.Loader
+	serverSnapshots      snapshot.Store
+	serverIndex          search.Index
+)
+
 // Handler is the entry point for the Yandex Cloud Function.
 // Processes events from timer or HTTP triggers, fetches player data,
 // filters it, and sends notifications by clusters.
-func Handler(ctx context.Context, event interface{}) (*Response, error) {
+func Handler(ctx context.Context, event interface{}) (res *Response, err error) {
 	start := time.Now()
-	defer func() { logger.Info("main.Handler: Time spent", "time", time.Since(start).String()) }()
+	runID := newRunID(start)
+	stages := stage.New()
+	defer func() {
+		logger.Info("main.Handler: Run report", "run_id", runID, "time", time.Since(start).String(), "stages", stages.Stages())
+	}()
 
 	cfg := config.Must()
 	triggerType := detectTriggerType(event)
 	logger.Init(cfg.App.LogLevel)
-	logger.Info("main.Handler: Starting", "trigger_type", triggerType)
+	logger.Info("main.Handler: Starting", "trigger_type", triggerType, "run_id", runID)
+
+	// Profile runs and keep the profiles of slow ones
+	if cfg.Profile.Threshold > 0 {
+		profiler := stage.StartProfiler(newStorage(cfg), cfg.Profile.Prefix, cfg.Profile.Threshold)
+		defer func() {
+			if err := profiler.Stop(ctx, runID, time.Since(start)); err != nil {
+				logger.Error("main.Handler: Failed to upload profiles", "err", err)
+			}
+		}()
+	}
+
+	cfg, err = withRegistry(ctx, cfg)
+	if err != nil {
+		return failed(event, "registry", runID, err)
+	}
 
 	if cfg.App.Mode == config.Dev {
 		logger.Debug("main.Handler: Config", "cfg", cfg)
 	}
 
-	// Initialize dependencies for data processing
-	dataFetcher := fetcher.New(http.DefaultClient, cfg.Data.Url, cfg.Data.ApiKey)
-	playerParser := player.New(cfg.Data)
-	filterCriteria := filter.New(cfg.Data.IgnoredGroups, cfg.Data.AllowedCompanies, cfg.Data.MaxOffline)
+	// Acknowledgment links are handled without running the pipeline
+	if httpEvent, ok := asHTTPEvent(event); ok && strings.HasPrefix(httpEvent.Path, ack.PathPrefix) {
+		return handleAck(ctx, cfg, httpEvent)
+	}
+
+	// Test notifications verify the channel configuration without running the pipeline
+	if httpEvent, ok := asHTTPEvent(event); ok && httpEvent.Path == "/send-test" {
+		return handleSendTest(ctx, cfg, httpEvent)
+	}
+
+	// Egress diagnostics test the connectivity to the data sources and the SMTP relay without running the pipeline
+	if httpEvent, ok := asHTTPEvent(event); ok && httpEvent.Path == "/diagnostics" {
+		return handleDiagnostics(ctx, cfg, httpEvent)
+	}
+
+	// Run comparisons are served from the archive without running the pipeline
+	if httpEvent, ok := asHTTPEvent(event); ok && httpEvent.Path == "/compare" {
+		return handleCompare(ctx, cfg, httpEvent)
+	}
+
+	// Single-store re-notifications are served from the last archived run without running the pipeline
+	if httpEvent, ok := asHTTPEvent(event); ok && httpEvent.Path == "/resend" {
+		return handleResend(ctx, cfg, httpEvent)
+	}
+
+	// Player uptime is served from the PostgreSQL history without running the pipeline
+	if httpEvent, ok := asHTTPEvent(event); ok && httpEvent.Path == "/uptime" {
+		return handleUptime(ctx, cfg, httpEvent)
+	}
+
+	// Previews are dry runs that persist nothing and compare the offline set with the previous archived run
+	preview := false
+	if httpEvent, ok := asHTTPEvent(event); ok && httpEvent.Path == "/preview" {
+		preview = true
+		cfg.App.DryRun = true
+	}
+	persist := !preview
+
+	// Append every run to the JSON Lines run log, failed ones included
+	if cfg.RunLog.Prefix != "" && persist {
+		defer func() {
+			appendRunLog(ctx, cfg, runlog.Record{
+				RunID:      runID,
+				Trigger:    triggerType,
+				StartedAt:  start,
+				DurationMs: time.Since(start).Milliseconds(),
+				Stages:     stages.Stages(),
+			}, res)
+		}()
+	}
+
+	// Initialize dependencies for data processing.
+	// Templates and filter criteria are reused by warm invocations with the same configuration.
+	mailProcessor, filterCriteria, err := dependencies(ctx, cfg)
+	if err != nil {
+		return failed(event, "dependencies", runID, err)
+	}
+
+	// Dry runs render every email into the outbox instead of sending it and leave notification state untouched
+	var outbox *mailer.Outbox
+	if cfg.App.DryRun {
+		logger.Warn("main.Handler: Dry run, notifications are rendered but not sent")
+		outbox = &mailer.Outbox{}
+		mailProcessor = mailer.DryRun(mailProcessor, outbox)
+	}
+
+	dataClient, err := fetcher.NewClient(cfg.Data)
+	if err != nil {
+		return failed(event, "sources", runID, err)
+	}
+	// Failures that do not stop the run, such as skipped rows and failed sends, are counted by stage and kind
+	faults := fault.New()
+	playerParser := player.New(cfg.Data).WithCollector(faults)
+
+	// Inject failures in dev mode to exercise retries and partial-failure handling
+	if chaosEnabled(cfg) {
+		logger.Warn("main.Handler: Chaos mode is on", "chaos", cfg.Chaos)
+		dataClient.Transport = chaos.Transport(dataClient.Transport, cfg.Chaos.FetchTimeout)
+		playerParser = chaos.Parser(playerParser, cfg.Chaos.MalformedRecords)
+		mailProcessor = chaos.Mailer(mailProcessor, cfg.Chaos.SMTPError)
+	} else if cfg.Chaos.Enabled {
+		logger.Warn("main.Handler: Chaos mode is ignored outside the dev mode")
+	}
+
+	sources, err := newSources(dataClient, cfg.Data)
+	if err != nil {
+		return failed(event, "sources", runID, err)
+	}
+
+	// Ad-hoc HTTP runs reuse a recent payload; scheduled runs revalidate it, so an unchanged report is not downloaded again
+	if cfg.Data.CacheTTL > 0 {
+		ttl := time.Duration(0)
+		if _, ok := asHTTPEvent(event); ok {
+			ttl = cfg.Data.CacheTTL
+		}
+		sources = cachedSources(cfg, sources, ttl)
+	}
 	clusterProcessor := cluster.New()
 
-	// Load email templates
-	templateLoader, err := templateloader.New()
+	// Weekly report runs are served from the archive without fetching player data
+	if isReportEvent(event) {
+		return handleReport(ctx, cfg, event, mailProcessor, start)
+	}
+
+	// End-of-day summaries are compiled from the archived runs of the day instead of alerting
+	if isDailyEvent(event) {
+		return handleDaily(ctx, cfg, event, mailProcessor, start)
+	}
+
+	channels, err := newChannels(ctx, cfg, mailProcessor, runID, start)
+	if err != nil {
+		return failed(event, "channels", runID, err)
+	}
+
+	// Notifications that failed in previous invocations are redelivered before new data is processed
+	retryQueue := &retry.Queue{}
+	if cfg.Retry.QueueEnabled && !cfg.App.DryRun {
+		done := stages.Start("redeliver")
+		retryQueue = redeliver(ctx, cfg, channels, start)
+		done(retryQueue.Len())
+	}
+
+	// A cheap ping spares the heavy report request, and its retries, while the upstream is down
+	if cfg.Data.HealthCheck {
+		if err = checkUpstream(ctx, cfg.Data, sources); err != nil {
+			return upstreamUnavailable(cfg, mailProcessor, runID, err), nil
+		}
+	}
+
+	// Delta feeds are requested since the last merge unless the snapshot is due for a full refresh
+	var (
+		deltaState    delta.State
+		deltaSnapshot *delta.Snapshot
+	)
+	if cfg.Delta.Enabled {
+		deltaState = delta.NewState(newStorage(cfg), cfg.Delta.StateKey)
+		if deltaSnapshot, err = deltaState.Load(ctx); err != nil {
+			return failed(event, "delta", runID, err)
+		}
+
+		if deltaSnapshot != nil && !deltaSnapshot.Stale(start, cfg.Delta.FullRefresh) {
+			for i := range sources {
+				sources[i].Fetcher = sources[i].Fetcher.WithSince(deltaSnapshot.UpdatedAt)
+			}
+		} else {
+			deltaSnapshot = nil
+		}
+	}
+
+	// Fetch, parse and filter players in a single streaming pass.
+	// The raw payload and the full player list are kept only for the sinks that need them.
+	done := stages.Start("pipeline")
+	result, err := pipeline.New(sources, playerParser, filterCriteria).Run(ctx, pipeline.Options{
+		KeepPayload: cfg.Archive.Prefix != "",
+		KeepAll:     cfg.YDB.DSN != "" || cfg.Postgres.DSN != "" || cfg.ClickHouse.URL.Host != "" || cfg.Delta.Enabled || serverIndex != nil,
+	})
+	if err != nil && ctx.Err() != nil {
+		return canceledRun(ctx, cfg, runID, retryQueue, nil), nil
+	}
+	if err != nil {
+		return failed(event, stagePipeline, runID, err)
+	}
+	allPlayers, players := result.All, result.Players
+	done(result.Total)
+	logger.Info("main.Handler: Player versions", "versions", result.Versions, "outdated", result.Outdated)
+
+	// Merge the delta onto the full snapshot and filter the merged player list
+	if cfg.Delta.Enabled {
+		done = stages.Start("delta")
+		mergeState := deltaState
+		if !persist {
+			mergeState = nil
+		}
+		allPlayers, players = mergeDelta(ctx, mergeState, deltaSnapshot, result.All, start, filterCriteria)
+		result.Total = len(allPlayers)
+		done(len(result.All))
+	}
+
+	// A run far below the recent player count is most likely a truncated upstream response;
+	// notifying it would tell every store that all its screens are offline
+	if guard.Enabled(cfg.Guard) && persist {
+		anomaly, err := guard.New(newStorage(cfg), cfg.Guard).Check(ctx, result.Total, start)
+		if err != nil {
+			logger.Error("main.Handler: Failed to check player count", "err", err)
+		}
+		if anomaly != nil {
+			return countAnomaly(cfg, mailProcessor, runID, anomaly), nil
+		}
+	}
+
+	summary := newRunReport(runID, result, len(players), faults)
+
+	// Rank the offline players by severity tier, so templates group them and escalation routes the worst tier
+	if len(cfg.Data.SeverityTiers) > 0 {
+		model.NewTiers(cfg.Data.SeverityTiers).Assign(players, start)
+	}
+
+	// Join the offline players with the device inventory, so notifications name the screen instead of its serial
+	if cfg.Inventory.Key != "" {
+		done = stages.Start("inventory")
+		inv, err := inventory.Load(ctx, newStorage(cfg), cfg.Inventory.Key)
+		if err != nil {
+			logger.Error("main.Handler: Failed to load inventory", "err", err)
+			summary.fail("inventory", err)
+		} else {
+			logger.Debug("main.Handler: Players enriched from inventory", "enriched", inv.Enrich(players), "devices", inv.Len())
+		}
+		done(len(players))
+	}
+
+	// Keep the run result for the server mode endpoints
+	if serverSnapshots != nil && persist {
+		serverSnapshots.Add(&snapshot.Snapshot{
+			RunID:   runID,
+			TakenAt: start,
+			Total:   result.Total,
+			Players: players,
+		})
+	}
+	if serverIndex != nil && persist {
+		serverIndex.Update(runID, start, allPlayers)
+	}
+
+	// Archive the raw payload and the offline set of the run
+	if cfg.Archive.Prefix != "" && persist {
+		done = stages.Start("archive")
+		archiveRun(ctx, cfg, runID, start, result.Payloads, players)
+		done(len(players))
+	}
+
+	// Export the filtered players to object storage
+	if cfg.Export.CSVPath != "" && persist {
+		done = stages.Start("export")
+		exportCSV(ctx, cfg, runID, players)
+		done(len(players))
+	}
+
+	// Persist the status of every player to YDB
+	if cfg.YDB.DSN != "" && persist {
+		done = stages.Start("ydb")
+		ydbWriter, _ := databaseWriters(ctx, cfg)
+		if err = ydbWriter.Write(ctx, runID, start, allPlayers); err != nil {
+			logger.Error("main.Handler: Failed to persist players to YDB", "err", err)
+			summary.fail("ydb", err)
+		}
+		done(len(allPlayers))
+	}
+
+	// Upsert the current status of every player to PostgreSQL
+	if cfg.Postgres.DSN != "" && persist {
+		done = stages.Start("postgres")
+		_, pgWriter := databaseWriters(ctx, cfg)
+		if err = pgWriter.Write(ctx, runID, start, allPlayers); err != nil {
+			logger.Error("main.Handler: Failed to persist players to PostgreSQL", "err", err)
+			summary.fail("postgres", err)
+		}
+		done(len(allPlayers))
+	}
+
+	// Insert per-run player status events to ClickHouse
+	if cfg.ClickHouse.URL.Host != "" && persist {
+		done = stages.Start("clickhouse")
+		if err = chwriter.New(http.DefaultClient, cfg.ClickHouse, cfg.Data.MaxOffline).Write(ctx, runID, start, allPlayers); err != nil {
+			logger.Error("main.Handler: Failed to persist players to ClickHouse", "err", err)
+			summary.fail("clickhouse", err)
+		}
+		done(len(allPlayers))
+	}
+
+	// Group players by store number, or into company or group digests
+	done = stages.Start("cluster")
+	clusters, err := clusterProcessor.ByMode(players, cfg.Cluster.Mode, cfg.Cluster.GroupDepth)
+	if err != nil {
+		return failed(event, "cluster", runID, err)
+	}
+	done(len(clusters))
+
+	// Offline players carry the start of their offline streak and their earlier reports from the offline history
+	var offlineTrend trend.Trend
+	if cfg.Trend.Enabled {
+		offlineTrend = trend.New(newStorage(cfg), cfg.Trend)
+		offlineTrend.Annotate(ctx, players)
+	}
+
+	// Players reported by an earlier run are left out, only newly offline ones are notified
+	notifyClusters := clusters
+	var notifyDedupe dedupe.Dedupe
+	var recovered []dedupe.Entry
+	if cfg.Dedupe.Enabled {
+		notifyDedupe = dedupe.New(newStorage(cfg), cfg.Dedupe)
+		notifyClusters, recovered = notifyDedupe.Filter(ctx, clusters)
+		summary.suppress("deduplicated", clusters, notifyClusters)
+	}
+
+	// Leave acknowledged players out of notifications while their snooze lasts
+	if cfg.Ack.Secret != "" {
+		acknowledged := suppressAcknowledged(ctx, cfg, notifyClusters, start)
+		summary.suppress("acknowledged", notifyClusters, acknowledged)
+		notifyClusters = acknowledged
+	}
+
+	// Stores notified within their throttling window are left out, to match each brand's tolerance for alert volume
+	var notifyThrottle throttle.Throttle
+	if throttle.Enabled(cfg.Throttle) {
+		notifyThrottle = throttle.New(newStorage(cfg), cfg.Throttle)
+		throttled := notifyThrottle.Filter(ctx, notifyClusters, start)
+		summary.suppress("throttled", notifyClusters, throttled)
+		notifyClusters = throttled
+	}
+
+	// Large tenants are notified in chunks with checkpoints, so a run can continue in the next invocation
+	done = stages.Start("notify")
+	notified := newDelivery(faults)
+	if cfg.Chunk.Size > 0 && !cfg.App.DryRun {
+		complete, err := notifyInChunks(ctx, cfg, runID, start, channels, retryQueue, notifyClusters, notified)
+		if err != nil {
+			return failed(event, "notify", runID, err)
+		}
+		if !complete {
+			done(len(notifyClusters))
+			if ctx.Err() != nil {
+				return canceledRun(ctx, cfg, runID, retryQueue, notified), nil
+			}
+			saveRetryQueue(ctx, cfg, retryQueue)
+			return &Response{
+				StatusCode: http.StatusAccepted,
+				Body:       "Partial run, the next invocation resumes it",
+			}, nil
+		}
+	} else {
+		notifyChannels(ctx, cfg, runID, channels, retryQueue, notifyClusters, notified)
+	}
+	if cfg.Mail.Enabled && cfg.Mail.Mode == mailer.ModeDigest && ctx.Err() == nil {
+		sendDigest(mailProcessor, notifyClusters, notified)
+	}
+	done(len(notifyClusters))
+
+	// A canceled run stops here: throttling and deduplication record nothing, so pending stores are not lost
+	if ctx.Err() != nil {
+		return canceledRun(ctx, cfg, runID, retryQueue, notified), nil
+	}
+	saveRetryQueue(ctx, cfg, retryQueue)
+
+	// Stores pending on a channel are left to the retry queue and recorded once it delivers them
+	delivered := notified.delivered(notifyClusters)
+
+	// Throttling windows start once every cluster is notified, partial chunked runs record nothing
+	if notifyThrottle != nil && !cfg.App.DryRun {
+		if err = notifyThrottle.Record(ctx, delivered, start); err != nil {
+			logger.Error("main.Handler: Failed to record notified stores", "err", err)
+			summary.fail("throttle", err)
+		}
+	}
+
+	// Reported players are recorded once every cluster is notified, recoveries are announced after that
+	if notifyDedupe != nil {
+		if cfg.App.DryRun {
+			logger.Debug("main.Handler: Dry run, reported players are not recorded")
+		} else if err = notifyDedupe.Record(ctx, clusters, delivered, start); err != nil {
+			logger.Error("main.Handler: Failed to record reported players", "err", err)
+			summary.fail("dedupe", err)
+		}
+		if cfg.Dedupe.Recovery {
+			notifyRecovered(cfg, mailProcessor, runID, recovered)
+		}
+	}
+
+	// The offline history is recorded once every cluster is notified
+	if offlineTrend != nil && !cfg.App.DryRun {
+		if err = offlineTrend.Record(ctx, clusters, delivered, start); err != nil {
+			logger.Error("main.Handler: Failed to record offline history", "err", err)
+			summary.fail("trend", err)
+		}
+	}
+
+	// Email every company's headquarters a rollup of its stores
+	if len(cfg.Rollup.To) > 0 {
+		done = stages.Start("rollup")
+		if err = rollup.New(mailProcessor, cfg.Rollup, cfg.Mail.MailStores).Send(runID, clusters, start); err != nil {
+			logger.Error("main.Handler: Failed to send company rollups", "err", err)
+			summary.fail("rollup", err)
+		}
+		done(len(clusters))
+	}
+
+	// Emit alerts to Alertmanager
+	if cfg.Alertmanager.URL.Host != "" && !cfg.App.DryRun {
+		done = stages.Start("alertmanager")
+		if err = alertmanager.New(http.DefaultClient, cfg.Alertmanager).Notify(ctx, notifyClusters); err != nil {
+			logger.Error("main.Handler: Failed to emit alerts", "err", err)
+			summary.fail("alertmanager", err)
+		}
+		done(len(notifyClusters))
+	}
+
+	// Open and close Yandex Tracker issues for critical clusters
+	if cfg.Tracker.Token != "" && !cfg.App.DryRun {
+		issueTracker := tracker.New(http.DefaultClient, cfg.Tracker, newStorage(cfg), cfg.Mail.MailStores)
+		done = stages.Start("tracker")
+		if err = issueTracker.Sync(ctx, clusters); err != nil {
+			logger.Error("main.Handler: Failed to sync Tracker issues", "err", err)
+			summary.fail("tracker", err)
+		}
+		done(len(clusters))
+	}
+
+	// Open and close Jira issues or ServiceNow incidents for offline clusters
+	if cfg.Ticket.System != "" && !cfg.App.DryRun {
+		done = stages.Start("ticket")
+		ticketer, err := ticket.New(http.DefaultClient, cfg.Ticket, newStorage(cfg), cfg.Mail.MailStores)
+		if err == nil {
+			err = ticketer.Sync(ctx, clusters)
+		}
+		if err != nil {
+			logger.Error("main.Handler: Failed to sync tickets", "err", err)
+			summary.fail("ticket", err)
+		}
+		done(len(clusters))
+	}
+
+	// Push per-store and per-company offline gauges to Prometheus
+	if cfg.RemoteWrite.URL.Host != "" && persist {
+		stats := promwrite.RunStats{Parsed: result.Total, Skipped: result.Skipped, Clusters: clusters, StateBytes: codec.Sizes()}
+		done = stages.Start("remote_write")
+		if err = promwrite.New(http.DefaultClient, cfg.RemoteWrite).Write(ctx, start, stats); err != nil {
+			logger.Error("main.Handler: Failed to push gauges", "err", err)
+			summary.fail("remote_write", err)
+		}
+		done(len(clusters))
+	}
+
+	logger.Debug("main.Handler", "offline_players", len(players), "all_players", result.Total)
+
+	summary.sends(clusters, notifyClusters, notified, cfg.Mail)
+	summary.collect()
+	if outbox != nil {
+		summary.dryRun(outbox)
+	}
+
+	// Export the metrics of the run to graph player health trends: pushed to the Pushgateway and/or returned in the response
+	if (cfg.Metrics.PushgatewayURL.Host != "" && persist) || cfg.Metrics.Response {
+		runMetrics := summary.metrics(stages.Stages(), result.Skipped, time.Since(start))
+		if cfg.Metrics.PushgatewayURL.Host != "" && persist {
+			if err = metrics.New(http.DefaultClient, cfg.Metrics).Push(ctx, runMetrics, start); err != nil {
+				logger.Error("main.Handler: Failed to push metrics", "err", err)
+				summary.fail("metrics", err)
+			}
+		}
+		if cfg.Metrics.Response {
+			var b strings.Builder
+			if err = metrics.Write(&b, runMetrics, start); err == nil {
+				summary.Metrics = b.String()
+			}
+		}
+	}
+	if preview {
+		summary.Preview = previewRun(ctx, cfg, runID, start, players, recovered)
+	}
+
+	return &Response{
+		StatusCode: http.StatusOK,
+		Body:       summary,
+	}, nil
+}
+
+// bundledTemplates are the templates compiled into the binary, served with MAIL_TEMPLATE_SOURCE=embed.
+//
+//go:embed templates/*.tmpl
+var bundledTemplates embed.FS
+
+// newTemplateLoader returns the shared server-mode loader when it is set, otherwise a loader of MAIL_TEMPLATE_SOURCE:
+// pinned remote templates, the templates bundled into the binary, or the local templates directory.
+// Without a source, remote templates are used when MAIL_TEMPLATE_REMOTE_URL is set.
+func newTemplateLoader(ctx context.Context, cfg config.Mail) (*templateloader.Loader, error) {
+	if serverTemplateLoader != nil {
+		return serverTemplateLoader, nil
+	}
+
+	var (
+		templateLoader *templateloader.Loader
+		err            error
+	)
+	source := cfg.TemplateSource
+	if source == "" && cfg.TemplateRemoteURL.Host != "" {
+		source = templateloader.SourceRemote
+	}
+
+	switch source {
+	case templateloader.SourceRemote:
+		templateLoader, err = templateloader.NewRemote(ctx, http.DefaultClient, cfg.TemplateRemoteURL, cfg.TemplateChecksums)
+	case templateloader.SourceEmbed:
+		var bundled fs.FS
+		if bundled, err = fs.Sub(bundledTemplates, "templates"); err == nil {
+			templateLoader, err = templateloader.NewFS(bundled)
+		}
+	case "", templateloader.SourceDir:
+		templateLoader, err = templateloader.New()
+	default:
+		err = fmt.Errorf("main.newTemplateLoader: unknown template source %q", source)
+	}
 	if err != nil {
+		return nil, err
+	}
+
+	return templateLoader.WithSprig(cfg.TemplateSprig), nil
+}
+
+// handleAck verifies a signed acknowledgment link and snoozes its target.
+// GET only renders a confirmation page, so link scanners and previews do not acknowledge alerts;
+// its form POSTs to the same link to record the snooze.
+func handleAck(ctx context.Context, cfg config.Config, event HTTPEvent) (*Response, error) {
+	if cfg.Ack.Secret == "" {
 		return &Response{
-			StatusCode: http.StatusInternalServerError,
-			Body:       nil,
-		}, err
+			StatusCode: http.StatusNotFound,
+			Body:       http.StatusText(http.StatusNotFound),
+		}, nil
 	}
-	// Initialize mail processor
-	mailProcessor, err := mailer.New(cfg.Mail, templateLoader)
+
+	signer := ack.NewSigner(cfg.Ack.Secret, cfg.Ack.BaseURL, cfg.Ack.LinkTTL)
+	target, err := signer.Verify(strings.TrimPrefix(event.Path, ack.PathPrefix))
 	if err != nil {
+		logger.Warn("main.handleAck: Rejected acknowledgment", "err", err)
 		return &Response{
-			StatusCode: http.StatusInternalServerError,
-			Body:       nil,
-		}, err
+			StatusCode: http.StatusForbidden,
+			Body:       err.Error(),
+		}, nil
 	}
 
-	// Fetch player data from an external source
-	body, err := dataFetcher.Data(ctx)
+	switch event.HTTPMethod {
+	case http.MethodGet:
+		page, err := ack.ConfirmPage(target, cfg.Ack.Snooze)
+		if err != nil {
+			return failed(event, "ack", "", err)
+		}
+		return &Response{
+			StatusCode: http.StatusOK,
+			Headers:    map[string]string{"Content-Type": "text/html; charset=utf-8"},
+			Body:       page,
+		}, nil
+	case http.MethodPost:
+	default:
+		return &Response{
+			StatusCode: http.StatusMethodNotAllowed,
+			Headers:    map[string]string{"Allow": "GET, POST"},
+			Body:       http.StatusText(http.StatusMethodNotAllowed),
+		}, nil
+	}
+
+	until := time.Now().Add(cfg.Ack.Snooze)
+	state := ack.NewState(newStorage(cfg), cfg.Ack.StateKey)
+	if err = state.Acknowledge(ctx, target, until); err != nil {
+		return failed(event, "ack", "", err)
+	}
+
+	return &Response{
+		StatusCode: http.StatusOK,
+		Body:       fmt.Sprintf("Acknowledged until %s", until.Format(time.DateTime)),
+	}, nil
+}
+
+// handleReport generates the management report of the archived runs and delivers it.
+func handleReport(ctx context.Context, cfg config.Config, event interface{}, mailProcessor mailer.Mailer, now time.Time) (*Response, error) {
+	if cfg.Archive.Prefix == "" {
+		return &Response{
+			StatusCode: http.StatusNotFound,
+			Body:       "Archive is disabled",
+		}, nil
+	}
+
+	store := newStorage(cfg)
+	reporter, err := report.New(archive.New(store, cfg.Archive), mailProcessor, store, cfg.Report)
 	if err != nil {
+		return failed(event, "report", "", err)
+	}
+
+	if err = reporter.Generate(ctx, now); err != nil {
+		return failed(event, "report", "", err)
+	}
+
+	return &Response{
+		StatusCode: http.StatusOK,
+		Body:       "Report delivered",
+	}, nil
+}
+
+// handleDaily sends the end-of-day summary of every company, compiled from the runs archived during the day.
+func handleDaily(ctx context.Context, cfg config.Config, event interface{}, mailProcessor mailer.Mailer, now time.Time) (*Response, error) {
+	if cfg.Archive.Prefix == "" {
 		return &Response{
-			StatusCode: http.StatusInternalServerError,
-			Body:       nil,
-		}, err
+			StatusCode: http.StatusNotFound,
+			Body:       "Archive is disabled",
+		}, nil
 	}
 
-	// Parse all players from the fetched data
-	allPlayers, err := playerParser.Players(body)
+	summary, err := daily.New(archive.New(newStorage(cfg), cfg.Archive), mailProcessor, cfg.Daily)
 	if err != nil {
+		return failed(event, "daily", "", err)
+	}
+
+	if err = summary.Send(ctx, now); err != nil {
+		return failed(event, "daily", "", err)
+	}
+
+	return &Response{
+		StatusCode: http.StatusOK,
+		Body:       "Daily summary delivered",
+	}, nil
+}
+
+// handleCompare diffs the offline sets of the archived runs in the from and to query parameters,
+// each a run ID, a date or an RFC 3339 time; to defaults to the last run. format=text renders a plain text summary.
+func handleCompare(ctx context.Context, cfg config.Config, event HTTPEvent) (*Response, error) {
+	query := event.Query
+	if cfg.Archive.Prefix == "" {
 		return &Response{
-			StatusCode: http.StatusInternalServerError,
-			Body:       nil,
-		}, err
+			StatusCode: http.StatusNotFound,
+			Body:       "Archive is disabled",
+		}, nil
+	}
+	if query["from"] == "" {
+		return &Response{
+			StatusCode: http.StatusBadRequest,
+			Body:       "from is required",
+		}, nil
 	}
 
-	// Filter players based on specified criteria
-	players, err := filterCriteria.Filter(allPlayers)
+	diff, err := compareRuns(ctx, cfg, query["from"], query["to"])
+	if errors.Is(err, compare.ErrRunNotFound) {
+		return &Response{
+			StatusCode: http.StatusNotFound,
+			Body:       err.Error(),
+		}, nil
+	}
 	if err != nil {
+		return failed(event, "compare", "", err)
+	}
+
+	if query["format"] == "text" {
+		var b strings.Builder
+		if err = compare.Text(&b, diff); err != nil {
+			return failed(event, "compare", "", err)
+		}
 		return &Response{
-			StatusCode: http.StatusInternalServerError,
-			Body:       nil,
-		}, err
+			StatusCode: http.StatusOK,
+			Body:       b.String(),
+		}, nil
 	}
 
-	// Group players by store number
-	clusters := clusterProcessor.ByStoreNumber(players)
+	return &Response{
+		StatusCode: http.StatusOK,
+		Body:       diff,
+	}, nil
+}
+
+// uptimeWindowDefault is the period /uptime covers when from is not given.
+const uptimeWindowDefault = 30 * 24 * time.Hour
 
-	mailByCluster(
-		mailProcessor,
-		clusters,
-		cfg.App.MaxGoroutines,
-	)
+// handleUptime returns the uptime of every player between the RFC 3339 from and to query parameters,
+// the last 30 days by default, optionally limited to the players of the store parameter.
+func handleUptime(ctx context.Context, cfg config.Config, event HTTPEvent) (*Response, error) {
+	query := event.Query
+	if cfg.Postgres.DSN == "" || cfg.Postgres.HistoryTable == "" {
+		return &Response{
+			StatusCode: http.StatusNotFound,
+			Body:       "Player history is disabled",
+		}, nil
+	}
+
+	to := time.Now()
+	var storeNumber int
+	var err error
+	if query["to"] != "" {
+		if to, err = time.Parse(time.RFC3339, query["to"]); err != nil {
+			return &Response{StatusCode: http.StatusBadRequest, Body: "to must be an RFC 3339 time"}, nil
+		}
+	}
+	from := to.Add(-uptimeWindowDefault)
+	if query["from"] != "" {
+		if from, err = time.Parse(time.RFC3339, query["from"]); err != nil {
+			return &Response{StatusCode: http.StatusBadRequest, Body: "from must be an RFC 3339 time"}, nil
+		}
+	}
+	if query["store"] != "" {
+		if storeNumber, err = strconv.Atoi(query["store"]); err != nil {
+			return &Response{StatusCode: http.StatusBadRequest, Body: "store must be a number"}, nil
+		}
+	}
 
-	logger.Debug("main.Handler", "offline_players", len(players), "all_players", len(allPlayers))
+	_, pgWriter := databaseWriters(ctx, cfg)
+	uptimes, err := pgWriter.Uptime(ctx, from, to, storeNumber)
+	if err != nil {
+		return failed(event, "uptime", "", err)
+	}
 
 	return &Response{
-		StatusCode: 200,
-		Body:       "Successful response",
+		StatusCode: http.StatusOK,
+		Body:       uptimes,
 	}, nil
 }
 
-// mailByCluster sends notifications for player clusters in parallel goroutines.
-// Uses semaphore to limit the number of concurrent tasks.
-func mailByCluster(m mailer.Mailer, clusters map[int][]*model.Player, maxGoroutines int) {
+// compareRuns resolves both run references in the archive and diffs their offline sets.
+// An empty to compares against the last archived run.
+func compareRuns(ctx context.Context, cfg config.Config, from, to string) (compare.Diff, error) {
+	archiver := archive.New(newStorage(cfg), cfg.Archive)
+
+	if to == "" {
+		to = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	fromRun, err := compare.Resolve(ctx, archiver, from)
+	if err != nil {
+		return compare.Diff{}, err
+	}
+	toRun, err := compare.Resolve(ctx, archiver, to)
+	if err != nil {
+		return compare.Diff{}, err
+	}
+
+	return compare.Compare(fromRun, toRun), nil
+}
+
+// suppressAcknowledged returns the clusters without acknowledged players whose snooze has not ended.
+// Clusters left without players are dropped. On state errors all clusters are returned, so alerts are never lost.
+func suppressAcknowledged(ctx context.Context, cfg config.Config, clusters map[int][]*model.Player, now time.Time) map[int][]*model.Player {
+	snoozes, err := ack.NewState(newStorage(cfg), cfg.Ack.StateKey).Load(ctx)
+	if err != nil {
+		logger.Error("main.suppressAcknowledged: Failed to load snooze state", "err", err)
+		return clusters
+	}
+
+	notify := make(map[int][]*model.Player, len(clusters))
+	for storeNumber, players := range clusters {
+		kept, snoozed := snoozes.Suppress(storeNumber, players, now)
+		if len(snoozed) > 0 {
+			logger.Debug("main.suppressAcknowledged: Acknowledged players suppressed", "cluster", storeNumber, "players", len(snoozed))
+		}
+		if len(kept) > 0 {
+			notify[storeNumber] = kept
+		}
+	}
+
+	return notify
+}
+
+// notifyRecovered emails MAIL_TO, when email is enabled, a summary of the reported players that are back online.
+func notifyRecovered(cfg config.Config, mailProcessor mailer.Mailer, runID string, recovered []dedupe.Entry) {
+	if len(recovered) == 0 || !cfg.Mail.Enabled || len(cfg.Mail.To) == 0 {
+		return
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Run %s: %d players are back online.\n\n", runID, len(recovered))
+	for _, e := range recovered {
+		fmt.Fprintf(&b, "Store %d (%s): %s, reported %s\n", e.StoreNumber, e.CompanyName, e.PlayerName, e.ReportedAt.Format(time.DateTime))
+	}
+
+	if err := mailProcessor.SendText("Players recovered", b.String(), cfg.Mail.To); err != nil {
+		logger.Error("main.notifyRecovered: Failed to send recovery summary", "err", err)
+	}
+}
+
+// previewRun compares the offline players of a previewed run with the last archived run, when runs are archived,
+// and lists the reported players a deduplicated run would announce as recovered.
+func previewRun(ctx context.Context, cfg config.Config, runID string, at time.Time, players []*model.Player, recovered []dedupe.Entry) *Preview {
+	p := &Preview{Recovered: recovered}
+	if cfg.Archive.Prefix == "" {
+		return p
+	}
+
+	prev, err := compare.Resolve(ctx, archive.New(newStorage(cfg), cfg.Archive), at.Format(time.RFC3339))
+	if err != nil {
+		logger.Warn("main.previewRun: No previous run to compare with", "err", err)
+		return p
+	}
+
+	diff := compare.Compare(prev, archive.Run{ID: runID, At: at, Offline: players})
+	p.Diff = &diff
+	return p
+}
+
+// appendRunLog completes the record with the response of the run and appends it to the run log, even once ctx is done.
+// Failures are logged and do not fail the run.
+func appendRunLog(ctx context.Context, cfg config.Config, record runlog.Record, res *Response) {
+	if res != nil {
+		record.StatusCode, record.Result = res.StatusCode, res.Body
+	}
+
+	ctx, cancel := flushContext(ctx)
+	defer cancel()
+
+	if err := runlog.New(newStorage(cfg), cfg.RunLog).Append(ctx, record); err != nil {
+		logger.Error("main.appendRunLog: Failed to append run record", "err", err, "run_id", record.RunID)
+	}
+}
+
+// archiveRun keeps the raw payload and the offline players of the run in object storage
+// and removes archives past the retention window. Failures are logged and do not fail the run.
+func archiveRun(ctx context.Context, cfg config.Config, runID string, at time.Time, payloads map[string][]byte, players []*model.Player) {
+	archiver := archive.New(newStorage(cfg), cfg.Archive)
+
+	if err := archiver.Archive(ctx, runID, at, payloads, players); err != nil {
+		logger.Error("main.archiveRun: Failed to archive run", "err", err)
+	}
+
+	if err := archiver.Cleanup(ctx); err != nil {
+		logger.Error("main.archiveRun: Failed to clean up expired archives", "err", err)
+	}
+}
+
+// exportCSV writes the filtered players as CSV to object storage and removes expired exports.
+// Failures are logged and do not fail the run.
+func exportCSV(ctx context.Context, cfg config.Config, runID string, players []*model.Player) {
+	csvExporter, err := export.New(newStorage(cfg), cfg.Export)
+	if err != nil {
+		logger.Error("main.exportCSV: Failed to initialize exporter", "err", err)
+		return
+	}
+
+	if err = csvExporter.Export(ctx, runID, players); err != nil {
+		logger.Error("main.exportCSV: Failed to export players", "err", err)
+	}
+
+	if err = csvExporter.Cleanup(ctx); err != nil {
+		logger.Error("main.exportCSV: Failed to clean up expired exports", "err", err)
+	}
+}
+
+// mergeDelta applies the fetched changes onto the snapshot, or starts a new snapshot from a full refresh when it is nil,
+// saves it, and returns the merged player list with the players that pass the filter.
+// A failed save is logged: the next delta is requested since the previous merge and applied again.
+func mergeDelta(
+	ctx context.Context,
+	state delta.State,
+	snap *delta.Snapshot,
+	changes []*model.Player,
+	now time.Time,
+	criteria filter.Criteria,
+) ([]*model.Player, []*model.Player) {
+	if snap == nil {
+		snap = delta.NewSnapshot(changes, now)
+		logger.Info("main.mergeDelta: Full refresh", "players", len(snap.Players))
+	} else {
+		stats := snap.Apply(changes, now)
+		logger.Info("main.mergeDelta: Delta applied",
+			"inserted", stats.Inserted,
+			"updated", stats.Updated,
+			"deleted", stats.Deleted,
+			"unknown", stats.Unknown,
+			"players", len(snap.Players),
+		)
+	}
+
+	if state == nil {
+		logger.Debug("main.mergeDelta: Snapshot not saved")
+	} else if err := state.Save(ctx, snap); err != nil {
+		logger.Error("main.mergeDelta: Failed to save snapshot", "err", err)
+	}
+
+	allPlayers := snap.List()
+	players, _ := criteria.Filter(allPlayers)
+
+	return allPlayers, players
+}
+
+// newChannels returns every notification channel of the run enabled in the default sink registry.
+// Dry runs keep only the mail channel, whose emails go to the outbox.
+func newChannels(ctx context.Context, cfg config.Config, mailProcessor mailer.Mailer, runID string, runAt time.Time) ([]sink.Sink, error) {
+	sinks := sink.Default()
+	if cfg.App.DryRun {
+		logger.Info("main.newChannels: Dry run, only the mail channel is kept", "registered", sinks.Names())
+		sinks = sinks.Only("mail")
+	}
+
+	return sinks.Build(ctx, cfg, sink.Deps{
+		Client: http.DefaultClient,
+		Mailer: mailProcessor,
+		Templates: func(ctx context.Context) (*templateloader.Loader, error) {
+			return newTemplateLoader(ctx, cfg.Mail)
+		},
+		RunID: runID,
+		RunAt: runAt,
+	})
+}
+
+// notifyChannels sends the clusters to every channel and records the outcome of every cluster in report.
+// With an escalation matrix, every channel gets only the clusters routed to it by their severity and company.
+// Channels are sent concurrently, each with its own concurrency limit and rate, so a slow channel does not hold back the others.
+// Clusters failing on every attempt, or left unsent because the context is done, are added to the retry queue.
+func notifyChannels(
+	ctx context.Context,
+	cfg config.Config,
+	runID string,
+	channels []sink.Sink,
+	queue *retry.Queue,
+	clusters map[int][]*model.Player,
+	report *delivery,
+) {
+	var matrix escalation.Matrix
+	if escalation.Enabled(cfg.Escalation) {
+		matrix = escalation.New(cfg.Escalation)
+	}
+
+	var wg sync.WaitGroup
+	for _, c := range channels {
+		wg.Add(1)
+		go func(c sink.Sink) {
+			defer wg.Done()
+			clusters := clusters
+			if matrix != nil {
+				clusters = matrix.Clusters(c.Name(), clusters, time.Now())
+			}
+			result := func(sn int, players []*model.Player, err error) {
+				report.add(c.Name(), sn, err)
+				if err == nil {
+					return
+				}
+				queue.Add(retry.Entry{
+					Channel:     c.Name(),
+					StoreNumber: sn,
+					Players:     players,
+					Recipients:  sink.FailedRecipients(err),
+					RunID:       runID,
+					FailedAt:    time.Now(),
+					Invocations: 1,
+					LastError:   err.Error(),
+				})
+			}
+			send, limit := c.Send, newChannelLimit(cfg.App, c.Name())
+			if cfg.App.Adaptive {
+				tuner := sendTuner(cfg.App)
+				limit.concurrency = tuner.Concurrency(ctx, c.Name(), len(clusters), limit.concurrency)
+				send = func(ctx context.Context, sn int, players []*model.Player) error {
+					start := time.Now()
+					defer func() { tuner.Observe(c.Name(), time.Since(start)) }()
+					return c.Send(ctx, sn, players)
+				}
+			}
+			sendByCluster(ctx, c.Name(), send, clusters, limit, cfg.Retry, result)
+		}(c)
+	}
+	wg.Wait()
+}
+
+// sendDigest emails every cluster in a single digest and records its outcome as the mail channel of each cluster.
+// A failed digest is not queued for retry, the next run sends a new one.
+func sendDigest(mailProcessor mailer.Mailer, clusters map[int][]*model.Player, report *delivery) {
+	err := mailProcessor.SendDigest(clusters)
+	if err != nil {
+		logger.Error("main.sendDigest: Failed to send digest", "err", err)
+	}
+	for sn := range clusters {
+		report.add("mail", sn, err)
+	}
+}
+
+// delivery lists per channel the stores notified by an invocation and the stores left pending for redelivery.
+type delivery struct {
+	mu       sync.Mutex
+	Notified map[string][]int `json:"notified"`
+	Pending  map[string][]int `json:"pending"`
+	faults   fault.Collector
+}
+
+// newDelivery creates an empty delivery report counting the send failures in faults, which may be nil.
+func newDelivery(faults fault.Collector) *delivery {
+	return &delivery{
+		Notified: make(map[string][]int),
+		Pending:  make(map[string][]int),
+		faults:   faults,
+	}
+}
+
+// add records the outcome of a cluster sent to a channel; err is nil when it was sent.
+func (d *delivery) add(channel string, storeNumber int, err error) {
+	if err != nil && d.faults != nil {
+		d.faults.Add("notify/"+channel, err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err == nil {
+		d.Notified[channel] = append(d.Notified[channel], storeNumber)
+	} else {
+		d.Pending[channel] = append(d.Pending[channel], storeNumber)
+	}
+}
+
+// delivered returns the clusters sent to every channel, leaving out the stores pending on any of them.
+func (d *delivery) delivered(clusters map[int][]*model.Player) map[int][]*model.Player {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	sent := make(map[int][]*model.Player, len(clusters))
+	for sn, players := range clusters {
+		sent[sn] = players
+	}
+	for _, stores := range d.Pending {
+		for _, sn := range stores {
+			delete(sent, sn)
+		}
+	}
+	return sent
+}
+
+// canceledRun reports a run stopped because its context was canceled or its deadline passed.
+// The undelivered notifications are already in the retry queue, which is flushed here;
+// the response lists the stores notified and left pending per channel.
+func canceledRun(ctx context.Context, cfg config.Config, runID string, queue *retry.Queue, report *delivery) *Response {
+	logger.Warn("main.Handler: Run canceled", "err", ctx.Err(), "run_id", runID)
+
+	saveRetryQueue(ctx, cfg, queue)
+
+	body := map[string]interface{}{
+		"status": "canceled",
+		"run_id": runID,
+		"error":  context.Cause(ctx).Error(),
+	}
+	if report != nil {
+		for _, stores := range report.Notified {
+			sort.Ints(stores)
+		}
+		for _, stores := range report.Pending {
+			sort.Ints(stores)
+		}
+		body["notified"] = report.Notified
+		body["pending"] = report.Pending
+	}
+
+	return &Response{
+		StatusCode: http.StatusGatewayTimeout,
+		Body:       body,
+	}
+}
+
+// redeliver loads the retry queue and sends every queued notification to its channel again, to the recipients it failed for.
+// Entries failing again stay queued until they reach RETRY_QUEUE_MAX_ATTEMPTS invocations or get older than RETRY_QUEUE_MAX_AGE;
+// entries of channels that are no longer enabled are dropped. The remaining queue is saved and returned,
+// so failures of the current run are added to it. A queue that cannot be loaded is logged and replaced by an empty one.
+func redeliver(ctx context.Context, cfg config.Config, channels []sink.Sink, now time.Time) *retry.Queue {
+	queue, err := retry.NewState(newStorage(cfg), cfg.Retry.StateKey).Load(ctx)
+	if err != nil {
+		logger.Error("main.redeliver: Failed to load retry queue", "err", err)
+		return &retry.Queue{}
+	}
+
+	entries := queue.Take()
+	if len(entries) == 0 {
+		return queue
+	}
+
+	byName := make(map[string]sink.Sink, len(channels))
+	for _, c := range channels {
+		byName[c.Name()] = c
+	}
+
+	var delivered, dropped int
+	for _, e := range entries {
+		c, ok := byName[e.Channel]
+		if !ok {
+			logger.Warn("main.redeliver: Channel is disabled, dropping notification", "channel", e.Channel, "cluster", e.StoreNumber, "run_id", e.RunID)
+			dropped++
+			continue
+		}
+
+		err = retry.Do(ctx, cfg.Retry.Attempts, cfg.Retry.Backoff, func() error {
+			err := c.Send(sink.WithRecipients(ctx, e.Recipients), e.StoreNumber, e.Players)
+			if failed := sink.FailedRecipients(err); failed != nil {
+				e.Recipients = failed
+			}
+			return err
+		})
+		if err == nil {
+			delivered++
+			continue
+		}
+
+		e.Invocations++
+		e.LastError = err.Error()
+		if e.Invocations >= cfg.Retry.MaxAttempts || now.Sub(e.FailedAt) > cfg.Retry.MaxAge {
+			logger.Error("main.redeliver: Giving up on notification",
+				"err", err,
+				"channel", e.Channel,
+				"cluster", e.StoreNumber,
+				"run_id", e.RunID,
+				"invocations", e.Invocations,
+			)
+			dropped++
+			continue
+		}
+		queue.Add(e)
+	}
+
+	logger.Info("main.redeliver: Retry queue processed", "delivered", delivered, "dropped", dropped, "queued", queue.Len())
+	saveRetryQueue(ctx, cfg, queue)
+
+	return queue
+}
+
+// saveRetryQueue saves the retry queue when it is enabled outside dry runs, even once ctx is done. Failures are logged and do not fail the run.
+func saveRetryQueue(ctx context.Context, cfg config.Config, queue *retry.Queue) {
+	if !cfg.Retry.QueueEnabled || cfg.App.DryRun {
+		return
+	}
+
+	ctx, cancel := flushContext(ctx)
+	defer cancel()
+
+	if err := retry.NewState(newStorage(cfg), cfg.Retry.StateKey).Save(ctx, queue); err != nil {
+		logger.Error("main.saveRetryQueue: Failed to save retry queue", "err", err, "entries", queue.Len())
+	}
+}
+
+// notifyInChunks notifies the clusters in chunks of CHUNK_SIZE players, saving a checkpoint after every chunk.
+// A checkpoint younger than CHUNK_RESUME_WINDOW is resumed: stores notified by the previous invocation are skipped.
+// Stops before a chunk when less than CHUNK_MARGIN is left until the context deadline and reports false;
+// reports true and clears the checkpoint once every cluster is notified.
+func notifyInChunks(
+	ctx context.Context,
+	cfg config.Config,
+	runID string,
+	now time.Time,
+	channels []sink.Sink,
+	queue *retry.Queue,
+	clusters map[int][]*model.Player,
+	report *delivery,
+) (bool, error) {
+	state := chunk.NewState(newStorage(cfg), cfg.Chunk.StateKey)
+
+	checkpoint, err := state.Load(ctx)
+	if err != nil {
+		return false, err
+	}
+	if checkpoint == nil || now.Sub(checkpoint.StartedAt) > cfg.Chunk.ResumeWindow {
+		checkpoint = &chunk.Checkpoint{RunID: runID, StartedAt: now}
+	} else {
+		logger.Info("main.notifyInChunks: Resuming run", "run_id", checkpoint.RunID, "notified", len(checkpoint.Notified))
+	}
+
+	chunks := chunk.Split(checkpoint.Pending(clusters), cfg.Chunk.Size)
+	for i, c := range chunks {
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < cfg.Chunk.Margin {
+			logger.Warn("main.notifyInChunks: Deadline is near, stopping", "run_id", checkpoint.RunID, "chunks_left", len(chunks)-i)
+			return false, nil
+		}
+
+		notifyChannels(ctx, cfg, runID, channels, queue, c, report)
+
+		// Clusters left unsent by a cancellation are in the retry queue, so the chunk is checkpointed as a whole
+		flushCtx, cancel := flushContext(ctx)
+		for sn := range c {
+			checkpoint.Notified = append(checkpoint.Notified, sn)
+		}
+		err = state.Save(flushCtx, checkpoint)
+		cancel()
+		if err != nil {
+			return false, err
+		}
+
+		if ctx.Err() != nil {
+			logger.Warn("main.notifyInChunks: Canceled, stopping", "run_id", checkpoint.RunID, "chunks_left", len(chunks)-i-1)
+			return false, nil
+		}
+	}
+
+	if err = state.Clear(ctx); err != nil {
+		logger.Error("main.notifyInChunks: Failed to clear checkpoint", "err", err)
+	}
+
+	return true, nil
+}
+
+// tuner sizes the channel worker pools in the adaptive mode. It is shared by warm invocations,
+// so the measured latencies carry over, and rebuilt when its settings change.
+var (
+	tunerMu       sync.Mutex
+	tuner         tuning.Tuner
+	tunerSettings [2]time.Duration
+)
+
+// sendTuner returns the shared tuner for the adaptive mode settings.
+func sendTuner(cfg config.App) tuning.Tuner {
+	tunerMu.Lock()
+	defer tunerMu.Unlock()
+
+	settings := [2]time.Duration{cfg.AdaptiveTarget, cfg.AdaptiveLatency}
+	if tuner == nil || tunerSettings != settings {
+		tuner, tunerSettings = tuning.New(cfg.AdaptiveTarget, cfg.AdaptiveLatency), settings
+	}
+
+	return tuner
+}
+
+// channelLimit bounds the delivery of a single notification channel.
+type channelLimit struct {
+	concurrency int           // Sends in flight
+	interval    time.Duration // Minimum time between the starts of two sends, zero for no rate limit
+}
+
+// newChannelLimit returns the limit configured for the channel, falling back to APP_MAX_GOROUTINES without a rate limit.
+func newChannelLimit(cfg config.App, channel string) channelLimit {
+	limit := channelLimit{
+		concurrency: cfg.MaxGoroutines,
+		interval:    cfg.ChannelIntervals[channel],
+	}
+
+	if n := cfg.ChannelConcurrency[channel]; n > 0 {
+		limit.concurrency = n
+	}
+	if limit.concurrency <= 0 {
+		limit.concurrency = 1
+	}
+
+	return limit
+}
+
+// sendByCluster sends notifications of a channel for player clusters in parallel goroutines.
+// Uses a semaphore of the channel to limit the number of concurrent tasks and a ticker to limit their rate.
+// Every send is attempted up to RETRY_ATTEMPTS times with a growing backoff, limited to the recipients the previous attempt
+// failed for; clusters failing on every attempt are passed to failed.
+func sendByCluster(
+	ctx context.Context,
+	channel string,
+	send func(ctx context.Context, storeNumber int, players []*model.Player) error,
+	clusters map[int][]*model.Player,
+	limit channelLimit,
+	retries config.Retry,
+	result func(storeNumber int, players []*model.Player, err error),
+) {
 	start := time.Now()
-	defer func() { logger.Debug("main.mailByCluster: Time spent", "time", time.Since(start).String()) }()
+	defer func() {
+		logger.Debug("main.sendByCluster: Time spent", "channel", channel, "time", time.Since(start).String())
+	}()
 
-	sem := make(chan struct{}, maxGoroutines)
+	sem := make(chan struct{}, limit.concurrency)
 	var wg sync.WaitGroup
 
+	var rate <-chan time.Time
+	if limit.interval > 0 {
+		ticker := time.NewTicker(limit.interval)
+		defer ticker.Stop()
+		rate = ticker.C
+	}
+
+	first, canceled := true, 0
 	for storeNumber, clusterPlayers := range clusters {
-		sem <- struct{}{}
+		if rate != nil && !first && ctx.Err() == nil {
+			select {
+			case <-rate:
+			case <-ctx.Done():
+			}
+		}
+		first = false
+
+		// Clusters left once the context is done are not sent, they wait in the retry queue for the next invocation
+		err := ctx.Err()
+		if err == nil {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+		}
+		if err != nil {
+			canceled++
+			result(storeNumber, clusterPlayers, err)
+			continue
+		}
 		wg.Add(1)
 
 		go func(sn int, players []*model.Player) {
@@ -143,17 +1415,300 @@ func mailByCluster(m mailer.Mailer, clusters map[int][]*model.Player, maxGorouti
 				wg.Done()
 			}()
 
-			if err := m.Send(sn, players); err != nil {
-				logger.Error("main.Handler: Failed to send mail",
+			var failed []string
+			err := retry.Do(ctx, retries.Attempts, retries.Backoff, func() error {
+				err := send(sink.WithRecipients(ctx, failed), sn, players)
+				if to := sink.FailedRecipients(err); to != nil {
+					failed = to
+				}
+				return err
+			})
+			if err != nil {
+				logger.Error("main.Handler: Failed to send notification",
 					"err", err,
+					"channel", channel,
 					"cluster", sn,
 					"players", len(players),
 				)
 			}
+			result(sn, players, err)
 		}(storeNumber, clusterPlayers)
 	}
 
 	wg.Wait()
+
+	if canceled > 0 {
+		logger.Warn("main.sendByCluster: Canceled", "channel", channel, "err", ctx.Err(), "unsent", canceled)
+	}
+}
+
+// newSources returns the default DATA_URL source, when it is set, followed by the per-company sources
+// ordered by name. Every company source carries its own URL and API key, and may override the auth mode with DATA_SOURCE_AUTH.
+// DATA_FILE replaces every source with the local file or stdin.
+func newSources(c *http.Client, cfg config.Data) ([]pipeline.Source, error) {
+	if cfg.File != "" {
+		return []pipeline.Source{{Fetcher: fetcher.NewFile(cfg.File)}}, nil
+	}
+
+	var sources []pipeline.Source
+	if cfg.Url.Host != "" {
+		auth, err := fetcher.NewAuth(c, cfg.Auth, cfg.ApiKey, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("main.newSources: %w", err)
+		}
+		sources = append(sources, pipeline.Source{Fetcher: fetcher.New(c, cfg.Url, cfg.ApiKey, auth, cfg)})
+	}
+
+	names := make([]string, 0, len(cfg.SourceURLs))
+	for name := range cfg.SourceURLs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		u, err := url.Parse(cfg.SourceURLs[name])
+		if err != nil {
+			return nil, fmt.Errorf("main.newSources: invalid URL of source %q: %w", name, err)
+		}
+
+		mode := cfg.Auth
+		if m, ok := cfg.SourceAuth[name]; ok {
+			mode = m
+		}
+		auth, err := fetcher.NewAuth(c, mode, cfg.SourceAPIKeys[name], cfg)
+		if err != nil {
+			return nil, fmt.Errorf("main.newSources: source %q: %w", name, err)
+		}
+
+		sources = append(sources, pipeline.Source{Name: name, Fetcher: fetcher.New(c, *u, cfg.SourceAPIKeys[name], auth, cfg)})
+	}
+
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("main.newSources: %w", errNoSources)
+	}
+
+	return sources, nil
+}
+
+// cachedSources returns the sources with their payloads cached in DATA_CACHE_DIR, or in Object Storage when it is not set.
+func cachedSources(cfg config.Config, sources []pipeline.Source, ttl time.Duration) []pipeline.Source {
+	var store fetcher.CacheStore
+	if cfg.Data.CacheDir != "" {
+		store = fetcher.NewDirCache(cfg.Data.CacheDir)
+	} else {
+		store = newStorage(cfg)
+	}
+
+	cached := make([]pipeline.Source, len(sources))
+	for i, s := range sources {
+		cached[i] = pipeline.Source{Name: s.Name, Fetcher: fetcher.NewCached(s.Fetcher, store, cfg.Data.CachePrefix, ttl)}
+	}
+	return cached
+}
+
+// handleDiagnostics runs the egress diagnostics for a request authorized with the resend token.
+// The report reveals the egress address and the data source hosts, so the endpoint is disabled without the token.
+func handleDiagnostics(ctx context.Context, cfg config.Config, event HTTPEvent) (*Response, error) {
+	if cfg.Resend.Token == "" {
+		return &Response{
+			StatusCode: http.StatusNotFound,
+			Body:       "Diagnostics are disabled",
+		}, nil
+	}
+
+	if !authorized(event, cfg.Resend.Token) {
+		logger.Warn("main.handleDiagnostics: Rejected diagnostics request")
+		return &Response{
+			StatusCode: http.StatusUnauthorized,
+			Body:       http.StatusText(http.StatusUnauthorized),
+		}, nil
+	}
+
+	report := diagnose(ctx, cfg)
+	status := http.StatusOK
+	if !report.OK {
+		status = http.StatusServiceUnavailable
+	}
+	return &Response{
+		StatusCode: status,
+		Body:       report,
+	}, nil
+}
+
+// diagnose runs the egress diagnostics against DATA_URL, every DATA_SOURCE_URLS source and the SMTP relay.
+func diagnose(ctx context.Context, cfg config.Config) *diag.Report {
+	var sources []diag.Source
+	if cfg.Data.Url.Host != "" {
+		sources = append(sources, diag.Source{URL: cfg.Data.Url, APIKey: cfg.Data.ApiKey})
+	}
+
+	names := make([]string, 0, len(cfg.Data.SourceURLs))
+	for name := range cfg.Data.SourceURLs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		u, err := url.Parse(cfg.Data.SourceURLs[name])
+		if err != nil {
+			logger.Warn("main.diagnose: Invalid source URL", "source", name, "err", err)
+			continue
+		}
+		sources = append(sources, diag.Source{Name: name, URL: *u, APIKey: cfg.Data.SourceAPIKeys[name]})
+	}
+
+	return diag.New(cfg.Diag, cfg.Mail).Run(ctx, sources)
+}
+
+// checkUpstream pings the DATA_HEALTH_URL, or every source when it is not set, within DATA_HEALTH_TIMEOUT.
+func checkUpstream(ctx context.Context, cfg config.Data, sources []pipeline.Source) error {
+	ctx, cancel := context.WithTimeout(ctx, cfg.HealthTimeout)
+	defer cancel()
+
+	for _, s := range sources {
+		if err := s.Fetcher.Ping(ctx, cfg.HealthURL); err != nil {
+			return fmt.Errorf("main.checkUpstream: source %q: %w", s.Name, err)
+		}
+
+		if cfg.HealthURL.Host != "" {
+			break
+		}
+	}
+
+	return nil
+}
+
+// upstreamUnavailable reports a run skipped because the upstream did not answer the ping
+// and alerts the DATA_HEALTH_ALERT_TO recipients when they are set.
+func upstreamUnavailable(cfg config.Config, mailProcessor mailer.Mailer, runID string, cause error) *Response {
+	logger.Error("main.Handler: Upstream unavailable, skipping run", "err", cause, "run_id", runID)
+
+	if len(cfg.Data.HealthAlertTo) > 0 {
+		text := fmt.Sprintf("Run %s was skipped: the data source did not answer the health check.\n\n%s", runID, cause)
+		if err := mailProcessor.SendText("Upstream unavailable", text, cfg.Data.HealthAlertTo); err != nil {
+			logger.Error("main.upstreamUnavailable: Failed to send admin alert", "err", err)
+		}
+	}
+
+	return &Response{
+		StatusCode: http.StatusServiceUnavailable,
+		Body: map[string]string{
+			"status": "upstream_unavailable",
+			"run_id": runID,
+			"error":  cause.Error(),
+		},
+	}
+}
+
+// countAnomaly reports a run aborted before any notification because its player count dropped below the recent average,
+// and alerts the GUARD_ALERT_TO recipients when they are set.
+func countAnomaly(cfg config.Config, mailProcessor mailer.Mailer, runID string, anomaly *guard.Anomaly) *Response {
+	logger.Error("main.Handler: Player count anomaly, skipping notifications", "anomaly", anomaly.String(), "run_id", runID)
+
+	if len(cfg.Guard.AlertTo) > 0 {
+		text := fmt.Sprintf("Run %s sent no notifications: it %s.\n\nThe upstream response was probably truncated.", runID, anomaly)
+		if err := mailProcessor.SendText("Player count anomaly", text, cfg.Guard.AlertTo); err != nil {
+			logger.Error("main.countAnomaly: Failed to send admin alert", "err", err)
+		}
+	}
+
+	return &Response{
+		StatusCode: http.StatusBadGateway,
+		Body: map[string]interface{}{
+			"status":  "player_count_anomaly",
+			"run_id":  runID,
+			"anomaly": anomaly,
+		},
+	}
+}
+
+// withRegistry fills the store and company master data of the registry into cfg, when a registry is configured.
+func withRegistry(ctx context.Context, cfg config.Config) (config.Config, error) {
+	if cfg.Registry.File == "" && cfg.Registry.Key == "" {
+		return cfg, nil
+	}
+
+	reg, err := registry.Load(ctx, newStorage(cfg), cfg.Registry)
+	if err != nil {
+		return cfg, err
+	}
+
+	return reg.Apply(cfg), nil
+}
+
+// flushContext returns a context for saving state at the end of a run that outlives the cancellation of ctx,
+// bounded by flushTimeout, so partial results are persisted even after the deadline of the run.
+func flushContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), flushTimeout)
+}
+
+// newStorage creates the object storage client; in the chaos mode its writes fail at the configured rate.
+func newStorage(cfg config.Config) storage.Storage {
+	store := storage.New(http.DefaultClient, cfg.Storage)
+	if chaosEnabled(cfg) {
+		return chaos.Storage(store, cfg.Chaos.StorageWrite)
+	}
+	return store
+}
+
+// chaosEnabled reports whether failures are injected: the chaos mode only works together with the dev mode.
+func chaosEnabled(cfg config.Config) bool {
+	return cfg.Chaos.Enabled && cfg.App.Mode == config.Dev
+}
+
+// newRunID builds a unique, time-sortable identifier of a single run, e.g. 20240102T150405-1a2b3c4d.
+func newRunID(start time.Time) string {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%s-%s", start.UTC().Format("20060102T150405"), hex.EncodeToString(b))
+}
+
+// asHTTPEvent converts the event to an HTTPEvent if it comes from an HTTP trigger.
+func asHTTPEvent(event interface{}) (HTTPEvent, bool) {
+	var httpEvent HTTPEvent
+
+	eventBytes, err := json.Marshal(event)
+	if err != nil {
+		return httpEvent, false
+	}
+
+	if json.Unmarshal(eventBytes, &httpEvent) != nil || httpEvent.HTTPMethod == "" {
+		return httpEvent, false
+	}
+
+	return httpEvent, true
+}
+
+// isReportEvent reports whether the event asks for the management report:
+// a timer with the report payload or an HTTP request to /report.
+func isReportEvent(event interface{}) bool {
+	if httpEvent, ok := asHTTPEvent(event); ok {
+		return httpEvent.Path == "/report"
+	}
+
+	eventBytes, err := json.Marshal(event)
+	if err != nil {
+		return false
+	}
+
+	var timerEvent TimerEvent
+	return json.Unmarshal(eventBytes, &timerEvent) == nil && timerEvent.Payload == report.Payload
+}
+
+// isDailyEvent reports whether the event asks for the end-of-day summary:
+// a timer with the daily summary payload or an HTTP request to /daily.
+func isDailyEvent(event interface{}) bool {
+	if httpEvent, ok := asHTTPEvent(event); ok {
+		return httpEvent.Path == "/daily"
+	}
+
+	eventBytes, err := json.Marshal(event)
+	if err != nil {
+		return false
+	}
+
+	var timerEvent TimerEvent
+	return json.Unmarshal(eventBytes, &timerEvent) == nil && timerEvent.Payload == daily.Payload
 }
 
 // detectTriggerType determines the type of trigger that invoked the function (timer or HTTP).
@@ -0,0 +1,210 @@
+package ack
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"go-players-data/internal/codec"
+	"go-players-data/internal/logger"
+	"go-players-data/internal/model"
+	"go-players-data/internal/storage"
+)
+
+// PathPrefix is the HTTP path prefix of acknowledgment links: <base URL>/ack/<token>.
+const PathPrefix = "/ack/"
+
+// Errors returned by Verify.
+var (
+	ErrInvalidToken = errors.New("invalid acknowledgment token")
+	ErrExpiredToken = errors.New("expired acknowledgment token")
+)
+
+// Target is what an acknowledgment applies to: a single player of a store, or the whole store if PlayerKey is empty.
+type Target struct {
+	StoreNumber int    `json:"s"`
+	PlayerKey   string `json:"p,omitempty"`
+	Expires     int64  `json:"e,omitempty"`
+}
+
+// key returns the state key of the target.
+func (t Target) key() string {
+	return fmt.Sprintf("%d/%s", t.StoreNumber, t.PlayerKey)
+}
+
+// signer is a struct that builds and verifies HMAC-signed acknowledgment links.
+type signer struct {
+	secret  []byte
+	baseURL url.URL
+	linkTTL time.Duration
+}
+
+// Signer is an interface for building acknowledgment links embedded in notifications and verifying their tokens.
+type Signer interface {
+	Link(storeNumber int, playerKey string) string
+	Verify(token string) (Target, error)
+}
+
+// NewSigner creates a new Signer producing links under baseURL that stay valid for linkTTL.
+func NewSigner(secret string, baseURL url.URL, linkTTL time.Duration) Signer {
+	return &signer{
+		secret:  []byte(secret),
+		baseURL: baseURL,
+		linkTTL: linkTTL,
+	}
+}
+
+// Link returns a signed acknowledgment link for the player, or for the whole store if playerKey is empty.
+// The token only contains URL-safe characters, so the link survives template escaping.
+func (s *signer) Link(storeNumber int, playerKey string) string {
+	payload, _ := json.Marshal(Target{
+		StoreNumber: storeNumber,
+		PlayerKey:   playerKey,
+		Expires:     time.Now().Add(s.linkTTL).Unix(),
+	})
+
+	token := base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(s.sign(payload))
+
+	return strings.TrimSuffix(s.baseURL.String(), "/") + PathPrefix + token
+}
+
+// Verify checks the token signature and expiration and returns its target.
+func (s *signer) Verify(token string) (Target, error) {
+	encoded, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return Target{}, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return Target{}, ErrInvalidToken
+	}
+
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, s.sign(payload)) {
+		return Target{}, ErrInvalidToken
+	}
+
+	var t Target
+	if err = json.Unmarshal(payload, &t); err != nil {
+		return Target{}, ErrInvalidToken
+	}
+
+	if time.Now().Unix() > t.Expires {
+		return Target{}, ErrExpiredToken
+	}
+
+	return t, nil
+}
+
+// sign computes the HMAC-SHA256 of the payload.
+func (s *signer) sign(payload []byte) []byte {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write(payload)
+	return h.Sum(nil)
+}
+
+// Snoozes maps acknowledged targets to the time their snooze ends.
+type Snoozes map[string]time.Time
+
+// Suppress splits players into those to notify about and those acknowledged and still snoozed at now.
+func (s Snoozes) Suppress(storeNumber int, players []*model.Player, now time.Time) (notify, snoozed []*model.Player) {
+	if now.Before(s[Target{StoreNumber: storeNumber}.key()]) {
+		return nil, players
+	}
+
+	for _, p := range players {
+		if now.Before(s[Target{StoreNumber: storeNumber, PlayerKey: p.Key()}.key()]) {
+			snoozed = append(snoozed, p)
+			continue
+		}
+		notify = append(notify, p)
+	}
+
+	return notify, snoozed
+}
+
+// state is a struct that keeps the snooze state as a JSON object in object storage.
+type state struct {
+	store storage.Storage
+	key   string
+}
+
+// State is an interface for reading the snooze state and recording acknowledgments.
+type State interface {
+	Load(ctx context.Context) (Snoozes, error)
+	Acknowledge(ctx context.Context, t Target, until time.Time) error
+}
+
+// NewState creates a new State stored under the key.
+func NewState(store storage.Storage, key string) State {
+	return &state{
+		store: store,
+		key:   key,
+	}
+}
+
+// Load reads the snooze state. A missing state object means nothing is snoozed.
+func (s *state) Load(ctx context.Context) (Snoozes, error) {
+	data, err := s.store.Get(ctx, s.key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return make(Snoozes), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ack.Load: failed to load state: %w", err)
+	}
+
+	return s.decode(data)
+}
+
+// Acknowledge snoozes the target until the given time and drops expired snoozes from the state.
+// The state is written conditionally and recomputed from the fresh state when a concurrent acknowledgment changed it.
+func (s *state) Acknowledge(ctx context.Context, t Target, until time.Time) error {
+	err := storage.Update(ctx, s.store, s.key, codec.ContentType, func(data []byte) ([]byte, error) {
+		snoozes, err := s.decode(data)
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		for k, v := range snoozes {
+			if v.Before(now) {
+				delete(snoozes, k)
+			}
+		}
+		snoozes[t.key()] = until
+
+		data, err = codec.Marshal(s.key, snoozes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode state: %w", err)
+		}
+		return data, nil
+	})
+	if err != nil {
+		return fmt.Errorf("ack.Acknowledge: %w", err)
+	}
+
+	logger.Info("ack.Acknowledge: Alert acknowledged", "store", t.StoreNumber, "player", t.PlayerKey, "until", until)
+	return nil
+}
+
+// decode decodes the snoozes of the state object, nil for a missing one.
+func (s *state) decode(data []byte) (Snoozes, error) {
+	snoozes := make(Snoozes)
+	if data == nil {
+		return snoozes, nil
+	}
+
+	if err := codec.Unmarshal(data, &snoozes); err != nil {
+		return nil, fmt.Errorf("ack.decode: failed to decode state: %w", err)
+	}
+
+	return snoozes, nil
+}
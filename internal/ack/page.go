@@ -0,0 +1,35 @@
+package ack
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"time"
+)
+
+// confirmPage asks to confirm an acknowledgment. Link scanners and previews only GET the link,
+// so the snooze is recorded by the form POST to the same URL.
+var confirmPage = template.Must(template.New("confirm").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Acknowledge alert</title></head>
+<body>
+<p>Acknowledge {{if .PlayerKey}}player {{.PlayerKey}} of store {{.StoreNumber}}{{else}}every player of store {{.StoreNumber}}{{end}}?
+Notifications about it are paused for {{.Snooze}}.</p>
+<form method="post"><button type="submit">Acknowledge</button></form>
+</body>
+</html>
+`))
+
+// ConfirmPage renders the HTML page confirming the acknowledgment of the target for the snooze duration.
+func ConfirmPage(t Target, snooze time.Duration) (string, error) {
+	var buf bytes.Buffer
+	err := confirmPage.Execute(&buf, struct {
+		Target
+		Snooze time.Duration
+	}{t, snooze})
+	if err != nil {
+		return "", fmt.Errorf("ack.ConfirmPage: %w", err)
+	}
+
+	return buf.String(), nil
+}
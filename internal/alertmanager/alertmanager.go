@@ -0,0 +1,184 @@
+package alertmanager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"go-players-data/internal/config"
+	"go-players-data/internal/logger"
+	"go-players-data/internal/model"
+)
+
+// Alert grouping modes.
+const (
+	GroupByPlayer  = "player"
+	GroupByCluster = "cluster"
+)
+
+// Alert names used for each grouping mode.
+const (
+	alertNamePlayer  = "PlayerOffline"
+	alertNameCluster = "StorePlayersOffline"
+)
+
+// alert is a single alert of the Alertmanager v2 API.
+type alert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	StartsAt    time.Time         `json:"startsAt"`
+	EndsAt      time.Time         `json:"endsAt"`
+}
+
+// notifier is a struct that posts offline players as alerts to a Prometheus Alertmanager.
+type notifier struct {
+	client       *http.Client
+	url          url.URL
+	groupBy      string
+	severity     string
+	resolveAfter time.Duration
+}
+
+// Notifier is an interface for emitting alerts for offline players grouped by store number.
+type Notifier interface {
+	Notify(ctx context.Context, clusters map[int][]*model.Player) error
+}
+
+// New creates a new Notifier posting to the configured Alertmanager.
+func New(c *http.Client, cfg config.Alertmanager) Notifier {
+	return &notifier{
+		client:       c,
+		url:          cfg.URL,
+		groupBy:      cfg.GroupBy,
+		severity:     cfg.Severity,
+		resolveAfter: cfg.ResolveAfter,
+	}
+}
+
+// Notify posts one alert per offline player or per cluster.
+// Every alert ends resolveAfter from now: alerts are refreshed by each run while players stay offline,
+// and Alertmanager resolves them on its own once a recovered player is no longer reported.
+func (n *notifier) Notify(ctx context.Context, clusters map[int][]*model.Player) error {
+	start := time.Now()
+	defer func() { logger.Debug("alertmanager.Notify: Time spent", "time", time.Since(start).String()) }()
+
+	endsAt := start.Add(n.resolveAfter)
+
+	var alerts []alert
+	for storeNumber, players := range clusters {
+		if n.groupBy == GroupByCluster {
+			alerts = append(alerts, n.clusterAlert(storeNumber, players, endsAt))
+			continue
+		}
+
+		for _, p := range players {
+			alerts = append(alerts, n.playerAlert(p, endsAt))
+		}
+	}
+
+	if len(alerts) == 0 {
+		return nil
+	}
+
+	if err := n.post(ctx, alerts); err != nil {
+		return fmt.Errorf("alertmanager.Notify: %w", err)
+	}
+
+	logger.Info("alertmanager.Notify: Alerts posted", "alerts", len(alerts))
+	return nil
+}
+
+// playerAlert builds the alert for a single offline player.
+func (n *notifier) playerAlert(p *model.Player, endsAt time.Time) alert {
+	return alert{
+		Labels: map[string]string{
+			"alertname": alertNamePlayer,
+			"severity":  n.severity,
+			"store":     strconv.Itoa(p.StoreNumber),
+			"company":   p.CompanyName,
+			"player":    p.PlayerName,
+			"mac":       p.MAC,
+		},
+		Annotations: map[string]string{
+			"summary":     fmt.Sprintf("Player %s of store %d is offline", p.PlayerName, p.StoreNumber),
+			"last_online": p.LastOnline.Format(time.DateTime),
+			"ip":          p.Addresses(),
+		},
+		StartsAt: p.LastOnline,
+		EndsAt:   endsAt,
+	}
+}
+
+// clusterAlert builds the alert for all offline players of a store.
+// The alert starts when the most recently seen player of the store went offline.
+func (n *notifier) clusterAlert(storeNumber int, players []*model.Player, endsAt time.Time) alert {
+	var company string
+	var startsAt time.Time
+
+	for _, p := range players {
+		if company == "" {
+			company = p.CompanyName
+		}
+		if p.LastOnline.After(startsAt) {
+			startsAt = p.LastOnline
+		}
+	}
+
+	return alert{
+		Labels: map[string]string{
+			"alertname": alertNameCluster,
+			"severity":  n.severity,
+			"store":     strconv.Itoa(storeNumber),
+			"company":   company,
+		},
+		Annotations: map[string]string{
+			"summary": fmt.Sprintf("%d players of store %d are offline", len(players), storeNumber),
+		},
+		StartsAt: startsAt,
+		EndsAt:   endsAt,
+	}
+}
+
+// post sends the alerts to the Alertmanager v2 API.
+func (n *notifier) post(ctx context.Context, alerts []alert) error {
+	data, err := json.Marshal(alerts)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url.JoinPath("api", "v2", "alerts").String(), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		logger.Error("alertmanager.post: Invalid status code", "statusCode", resp.StatusCode, "body", string(msg))
+		return &HTTPError{Code: resp.StatusCode}
+	}
+
+	return nil
+}
+
+// HTTPError represents an error response from Alertmanager with a specific status code.
+type HTTPError struct {
+	Code int
+}
+
+// Error returns the text representation of the HTTP status code associated with the HTTPError.
+func (e *HTTPError) Error() string {
+	return http.StatusText(e.Code)
+}
@@ -0,0 +1,322 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"go-players-data/internal/codec"
+	"go-players-data/internal/config"
+	"go-players-data/internal/logger"
+	"go-players-data/internal/model"
+	"go-players-data/internal/storage"
+)
+
+// Object names written for every run under <prefix>/<date>/<run ID>/.
+// The payload of the default source is PayloadObject; the payload of a named source is PayloadsDir/<source name>.
+const (
+	PayloadObject = "payload.json"
+	PayloadsDir   = "payloads"
+	OfflineObject = "offline.json"
+)
+
+// ErrNoRuns is returned when no run is archived.
+var ErrNoRuns = errors.New("no archived runs")
+
+// archiver is a struct that keeps the raw payload and the computed offline set of every run in object storage.
+type archiver struct {
+	store     storage.Storage
+	prefix    string
+	retention time.Duration
+}
+
+// Run is an archived run read back by Runs.
+type Run struct {
+	ID      string
+	At      time.Time
+	Offline []*model.Player
+}
+
+// Payload is the archived raw payloads of a run read back by Payloads, keyed by source name;
+// the default source has an empty name.
+type Payload struct {
+	ID      string
+	At      time.Time
+	Sources map[string][]byte
+	dir     string
+}
+
+// Archiver is an interface for archiving the data of a run, reading archived runs back, and cleaning up expired archives.
+type Archiver interface {
+	Archive(ctx context.Context, runID string, at time.Time, payloads map[string][]byte, offline []*model.Player) error
+	Runs(ctx context.Context, from, to time.Time) ([]Run, error)
+	Latest(ctx context.Context) (Run, error)
+	Payloads(ctx context.Context, from, to time.Time, fn func(p Payload) error) error
+	Restore(ctx context.Context, p Payload, offline []*model.Player) error
+	Cleanup(ctx context.Context) error
+}
+
+// New creates a new Archiver writing under the configured prefix.
+func New(store storage.Storage, cfg config.Archive) Archiver {
+	return &archiver{
+		store:     store,
+		prefix:    strings.Trim(cfg.Prefix, "/"),
+		retention: cfg.Retention,
+	}
+}
+
+// Archive writes the raw payload of every source exactly as fetched, each as its own object, and the offline players as JSON.
+func (a *archiver) Archive(ctx context.Context, runID string, at time.Time, payloads map[string][]byte, offline []*model.Player) error {
+	start := time.Now()
+	defer func() { logger.Debug("archive.Archive: Time spent", "time", time.Since(start).String()) }()
+
+	dir := a.dir(runID, at)
+
+	size := 0
+	for name, payload := range payloads {
+		if err := a.store.Put(ctx, payloadKey(dir, name), payload, "application/octet-stream"); err != nil {
+			return fmt.Errorf("archive.Archive: failed to archive payload of source %q: %w", name, err)
+		}
+		size += len(payload)
+	}
+
+	data, err := codec.Marshal(path.Join(a.prefix, OfflineObject), offline)
+	if err != nil {
+		return fmt.Errorf("archive.Archive: failed to encode offline players: %w", err)
+	}
+
+	if err = a.store.Put(ctx, path.Join(dir, OfflineObject), data, codec.ContentType); err != nil {
+		return fmt.Errorf("archive.Archive: failed to archive offline players: %w", err)
+	}
+
+	logger.Info("archive.Archive: Run archived", "dir", dir, "payload_bytes", size, "offline", len(offline))
+	return nil
+}
+
+// dir returns the directory of the run archive.
+func (a *archiver) dir(runID string, at time.Time) string {
+	return path.Join(a.prefix, at.UTC().Format(time.DateOnly), runID)
+}
+
+// payloadKey returns the object key of the payload of the source in the run directory.
+func payloadKey(dir, source string) string {
+	if source == "" {
+		return path.Join(dir, PayloadObject)
+	}
+	return path.Join(dir, PayloadsDir, url.PathEscape(source))
+}
+
+// payloadSource returns the run directory and the source name of a payload object key, or false for other objects.
+func payloadSource(key string) (dir, source string, ok bool) {
+	if path.Base(key) == PayloadObject {
+		return path.Dir(key), "", true
+	}
+	if path.Base(path.Dir(key)) != PayloadsDir {
+		return "", "", false
+	}
+
+	source, err := url.PathUnescape(path.Base(key))
+	if err != nil {
+		return "", "", false
+	}
+	return path.Dir(path.Dir(key)), source, true
+}
+
+// fetchedAt returns the time the first payload of every run directory was written.
+func fetchedAt(objects []storage.Object) map[string]time.Time {
+	at := make(map[string]time.Time)
+	for _, o := range objects {
+		dir, _, ok := payloadSource(o.Key)
+		if !ok {
+			continue
+		}
+		if t, seen := at[dir]; !seen || o.LastModified.Before(t) {
+			at[dir] = o.LastModified
+		}
+	}
+	return at
+}
+
+// Runs reads the offline sets of the runs archived within [from, to], oldest first.
+// The run time is the time its payload was written, so offline sets restored by a backfill keep their run time,
+// or the time its offline set was written when there is no payload.
+func (a *archiver) Runs(ctx context.Context, from, to time.Time) ([]Run, error) {
+	start := time.Now()
+	defer func() { logger.Debug("archive.Runs: Time spent", "time", time.Since(start).String()) }()
+
+	objects, err := a.store.List(ctx, a.prefix+"/")
+	if err != nil {
+		return nil, fmt.Errorf("archive.Runs: failed to list archives: %w", err)
+	}
+
+	runAt := fetchedAt(objects)
+
+	var runs []Run
+	for _, o := range objects {
+		if path.Base(o.Key) != OfflineObject {
+			continue
+		}
+
+		at, ok := runAt[path.Dir(o.Key)]
+		if !ok {
+			at = o.LastModified
+		}
+		if at.Before(from) || at.After(to) {
+			continue
+		}
+
+		data, err := a.store.Get(ctx, o.Key)
+		if err != nil {
+			return nil, fmt.Errorf("archive.Runs: failed to read %s: %w", o.Key, err)
+		}
+
+		run := Run{ID: path.Base(path.Dir(o.Key)), At: at}
+		if err = codec.Unmarshal(data, &run.Offline); err != nil {
+			return nil, fmt.Errorf("archive.Runs: failed to decode %s: %w", o.Key, err)
+		}
+
+		runs = append(runs, run)
+	}
+
+	sort.Slice(runs, func(i, j int) bool { return runs[i].At.Before(runs[j].At) })
+
+	return runs, nil
+}
+
+// Latest reads the offline set of the last archived run, timed like in Runs. Returns ErrNoRuns when nothing is archived.
+func (a *archiver) Latest(ctx context.Context) (Run, error) {
+	start := time.Now()
+	defer func() { logger.Debug("archive.Latest: Time spent", "time", time.Since(start).String()) }()
+
+	objects, err := a.store.List(ctx, a.prefix+"/")
+	if err != nil {
+		return Run{}, fmt.Errorf("archive.Latest: failed to list archives: %w", err)
+	}
+
+	runAt := fetchedAt(objects)
+
+	var latest storage.Object
+	var latestAt time.Time
+	for _, o := range objects {
+		if path.Base(o.Key) != OfflineObject {
+			continue
+		}
+
+		at, ok := runAt[path.Dir(o.Key)]
+		if !ok {
+			at = o.LastModified
+		}
+		if at.After(latestAt) {
+			latest, latestAt = o, at
+		}
+	}
+	if latest.Key == "" {
+		return Run{}, fmt.Errorf("archive.Latest: %w", ErrNoRuns)
+	}
+
+	data, err := a.store.Get(ctx, latest.Key)
+	if err != nil {
+		return Run{}, fmt.Errorf("archive.Latest: failed to read %s: %w", latest.Key, err)
+	}
+
+	run := Run{ID: path.Base(path.Dir(latest.Key)), At: latestAt}
+	if err = codec.Unmarshal(data, &run.Offline); err != nil {
+		return Run{}, fmt.Errorf("archive.Latest: failed to decode %s: %w", latest.Key, err)
+	}
+
+	return run, nil
+}
+
+// Payloads calls fn for the raw payloads of every run archived within [from, to], oldest first, reading one run at a time.
+// Stops and returns the error of fn if it fails.
+func (a *archiver) Payloads(ctx context.Context, from, to time.Time, fn func(p Payload) error) error {
+	start := time.Now()
+	defer func() { logger.Debug("archive.Payloads: Time spent", "time", time.Since(start).String()) }()
+
+	objects, err := a.store.List(ctx, a.prefix+"/")
+	if err != nil {
+		return fmt.Errorf("archive.Payloads: failed to list archives: %w", err)
+	}
+
+	runAt := fetchedAt(objects)
+	keys := make(map[string]map[string]string)
+	var dirs []string
+	for _, o := range objects {
+		dir, source, ok := payloadSource(o.Key)
+		if !ok || runAt[dir].Before(from) || runAt[dir].After(to) {
+			continue
+		}
+		if _, seen := keys[dir]; !seen {
+			keys[dir] = make(map[string]string)
+			dirs = append(dirs, dir)
+		}
+		keys[dir][source] = o.Key
+	}
+	sort.Slice(dirs, func(i, j int) bool { return runAt[dirs[i]].Before(runAt[dirs[j]]) })
+
+	for _, dir := range dirs {
+		if err = ctx.Err(); err != nil {
+			return fmt.Errorf("archive.Payloads: %w", err)
+		}
+
+		p := Payload{ID: path.Base(dir), At: runAt[dir], Sources: make(map[string][]byte, len(keys[dir])), dir: dir}
+		for source, key := range keys[dir] {
+			if p.Sources[source], err = a.store.Get(ctx, key); err != nil {
+				return fmt.Errorf("archive.Payloads: failed to read %s: %w", key, err)
+			}
+		}
+
+		if err = fn(p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Restore replaces the offline set of the archived run the payload belongs to.
+func (a *archiver) Restore(ctx context.Context, p Payload, offline []*model.Player) error {
+	data, err := codec.Marshal(path.Join(a.prefix, OfflineObject), offline)
+	if err != nil {
+		return fmt.Errorf("archive.Restore: failed to encode offline players: %w", err)
+	}
+
+	if err = a.store.Put(ctx, path.Join(p.dir, OfflineObject), data, codec.ContentType); err != nil {
+		return fmt.Errorf("archive.Restore: failed to restore offline players: %w", err)
+	}
+
+	return nil
+}
+
+// Cleanup deletes archived objects older than the retention period. Does nothing when retention is not configured.
+func (a *archiver) Cleanup(ctx context.Context) error {
+	if a.retention <= 0 {
+		return nil
+	}
+
+	objects, err := a.store.List(ctx, a.prefix+"/")
+	if err != nil {
+		return fmt.Errorf("archive.Cleanup: failed to list archives: %w", err)
+	}
+
+	deadline := time.Now().Add(-a.retention)
+	deleted := 0
+	for _, o := range objects {
+		if o.LastModified.After(deadline) {
+			continue
+		}
+
+		if err = a.store.Delete(ctx, o.Key); err != nil {
+			return fmt.Errorf("archive.Cleanup: failed to delete %s: %w", o.Key, err)
+		}
+		deleted++
+	}
+
+	logger.Debug("archive.Cleanup: Expired archives deleted", "objects", deleted)
+	return nil
+}
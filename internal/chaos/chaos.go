@@ -0,0 +1,153 @@
+package chaos
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/textproto"
+
+	"go-players-data/internal/logger"
+	"go-players-data/internal/mailer"
+	"go-players-data/internal/model"
+	"go-players-data/internal/player"
+	"go-players-data/internal/storage"
+)
+
+// ErrInjected is wrapped by every failure injected by the chaos mode.
+var ErrInjected = errors.New("chaos: injected failure")
+
+// hit reports whether a failure with the given rate (0..1) is injected this time.
+func hit(rate float64) bool {
+	return rate > 0 && rand.Float64() < rate
+}
+
+// transport is an http.RoundTripper that fails requests as if they timed out.
+type transport struct {
+	next http.RoundTripper
+	rate float64
+}
+
+// Transport wraps next so that requests fail with an injected timeout at the given rate.
+func Transport(next http.RoundTripper, rate float64) http.RoundTripper {
+	return &transport{next: next, rate: rate}
+}
+
+// RoundTrip fails the request with a wrapped context.DeadlineExceeded at the configured rate.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if hit(t.rate) {
+		logger.Warn("chaos.RoundTrip: Injecting timeout", "url", req.URL.Redacted())
+		return nil, fmt.Errorf("%w: %w", ErrInjected, context.DeadlineExceeded)
+	}
+	return t.next.RoundTrip(req)
+}
+
+// parser is a player.Parser that drops players as if their records were malformed.
+type parser struct {
+	player.Parser
+	rate    float64
+	dropped int
+}
+
+// Parser wraps p so that parsed players are dropped and counted as skipped at the given rate.
+func Parser(p player.Parser, rate float64) player.Parser {
+	return &parser{Parser: p, rate: rate}
+}
+
+// Players parses the body, dropping players at the configured rate.
+func (p *parser) Players(body []byte) ([]*model.Player, error) {
+	var players []*model.Player
+	err := p.Stream(bytes.NewReader(body), func(pl *model.Player) error {
+		players = append(players, pl)
+		return nil
+	})
+	return players, err
+}
+
+// Stream streams players from r, dropping players at the configured rate.
+func (p *parser) Stream(r io.Reader, fn func(player *model.Player) error) error {
+	p.dropped = 0
+	return p.Parser.Stream(r, func(pl *model.Player) error {
+		if hit(p.rate) {
+			logger.Warn("chaos.Stream: Injecting malformed record", "player", pl.Key())
+			p.dropped++
+			return nil
+		}
+		return fn(pl)
+	})
+}
+
+// Skipped returns the players skipped by the wrapped parser and the ones dropped by the chaos mode.
+func (p *parser) Skipped() int {
+	return p.Parser.Skipped() + p.dropped
+}
+
+// smtpMailer is a mailer.Mailer that fails sends with a transient SMTP error.
+type smtpMailer struct {
+	mailer.Mailer
+	rate float64
+}
+
+// Mailer wraps m so that sends fail with an SMTP 421 reply at the given rate.
+func Mailer(m mailer.Mailer, rate float64) mailer.Mailer {
+	return &smtpMailer{Mailer: m, rate: rate}
+}
+
+// Send fails with an injected SMTP 421 reply at the configured rate.
+func (m *smtpMailer) Send(storeNumber int, players []*model.Player) error {
+	if hit(m.rate) {
+		logger.Warn("chaos.Send: Injecting SMTP error", "cluster", storeNumber)
+		return fmt.Errorf("%w: %w", ErrInjected, &textproto.Error{Code: 421, Msg: "Service not available"})
+	}
+	return m.Mailer.Send(storeNumber, players)
+}
+
+// SendOnly fails with a transient SMTP error at the configured rate.
+func (m *smtpMailer) SendOnly(storeNumber int, players []*model.Player, to []string) error {
+	if hit(m.rate) {
+		logger.Warn("chaos.SendOnly: Injecting SMTP error", "cluster", storeNumber)
+		return fmt.Errorf("%w: %w", ErrInjected, &textproto.Error{Code: 421, Msg: "Service not available"})
+	}
+	return m.Mailer.SendOnly(storeNumber, players, to)
+}
+
+// store is a storage.Storage that fails writes.
+type store struct {
+	storage.Storage
+	rate float64
+}
+
+// Storage wraps s so that Put, PutIfMatch and Delete fail at the given rate.
+func Storage(s storage.Storage, rate float64) storage.Storage {
+	return &store{Storage: s, rate: rate}
+}
+
+// Put fails with an injected server error at the configured rate.
+func (s *store) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	if hit(s.rate) {
+		logger.Warn("chaos.Put: Injecting storage write error", "key", key)
+		return fmt.Errorf("%w: %w", ErrInjected, &storage.HTTPError{Code: http.StatusServiceUnavailable})
+	}
+	return s.Storage.Put(ctx, key, body, contentType)
+}
+
+// PutIfMatch fails with an injected server error at the configured rate.
+func (s *store) PutIfMatch(ctx context.Context, key string, body []byte, contentType, etag string) error {
+	if hit(s.rate) {
+		logger.Warn("chaos.PutIfMatch: Injecting storage write error", "key", key)
+		return fmt.Errorf("%w: %w", ErrInjected, &storage.HTTPError{Code: http.StatusServiceUnavailable})
+	}
+	return s.Storage.PutIfMatch(ctx, key, body, contentType, etag)
+}
+
+// Delete fails with an injected server error at the configured rate.
+func (s *store) Delete(ctx context.Context, key string) error {
+	if hit(s.rate) {
+		logger.Warn("chaos.Delete: Injecting storage write error", "key", key)
+		return fmt.Errorf("%w: %w", ErrInjected, &storage.HTTPError{Code: http.StatusServiceUnavailable})
+	}
+	return s.Storage.Delete(ctx, key)
+}
@@ -0,0 +1,131 @@
+package chunk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go-players-data/internal/codec"
+	"go-players-data/internal/logger"
+	"go-players-data/internal/model"
+	"go-players-data/internal/storage"
+)
+
+// Checkpoint records the progress of a run processed in chunks, so a later invocation can resume it.
+type Checkpoint struct {
+	RunID     string    `json:"run_id"`
+	StartedAt time.Time `json:"started_at"`
+	Notified  []int     `json:"notified"` // Store numbers whose notifications were sent
+}
+
+// Pending returns the clusters not notified yet.
+func (c *Checkpoint) Pending(clusters map[int][]*model.Player) map[int][]*model.Player {
+	notified := make(map[int]struct{}, len(c.Notified))
+	for _, sn := range c.Notified {
+		notified[sn] = struct{}{}
+	}
+
+	pending := make(map[int][]*model.Player, len(clusters))
+	for sn, players := range clusters {
+		if _, ok := notified[sn]; !ok {
+			pending[sn] = players
+		}
+	}
+
+	return pending
+}
+
+// Split divides the clusters into chunks of at least size players, keeping each store in a single chunk.
+// Chunks follow the store number order, so the same clusters are always split the same way.
+func Split(clusters map[int][]*model.Player, size int) []map[int][]*model.Player {
+	storeNumbers := make([]int, 0, len(clusters))
+	for sn := range clusters {
+		storeNumbers = append(storeNumbers, sn)
+	}
+	sort.Ints(storeNumbers)
+
+	var (
+		chunks  []map[int][]*model.Player
+		current = make(map[int][]*model.Player)
+		players int
+	)
+	for _, sn := range storeNumbers {
+		current[sn] = clusters[sn]
+		players += len(clusters[sn])
+
+		if players >= size {
+			chunks = append(chunks, current)
+			current, players = make(map[int][]*model.Player), 0
+		}
+	}
+	if len(current) > 0 {
+		chunks = append(chunks, current)
+	}
+
+	return chunks
+}
+
+// state is a struct that keeps the checkpoint as a JSON object in object storage.
+type state struct {
+	store storage.Storage
+	key   string
+}
+
+// State is an interface for reading, saving and clearing the checkpoint of a chunked run.
+type State interface {
+	Load(ctx context.Context) (*Checkpoint, error)
+	Save(ctx context.Context, c *Checkpoint) error
+	Clear(ctx context.Context) error
+}
+
+// NewState creates a new State stored under the key.
+func NewState(store storage.Storage, key string) State {
+	return &state{
+		store: store,
+		key:   key,
+	}
+}
+
+// Load reads the checkpoint. Returns nil without an error when there is no run in progress.
+func (s *state) Load(ctx context.Context) (*Checkpoint, error) {
+	data, err := s.store.Get(ctx, s.key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("chunk.Load: failed to load checkpoint: %w", err)
+	}
+
+	var c Checkpoint
+	if err = codec.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("chunk.Load: failed to decode checkpoint: %w", err)
+	}
+
+	return &c, nil
+}
+
+// Save writes the checkpoint.
+func (s *state) Save(ctx context.Context, c *Checkpoint) error {
+	data, err := codec.Marshal(s.key, c)
+	if err != nil {
+		return fmt.Errorf("chunk.Save: failed to encode checkpoint: %w", err)
+	}
+
+	if err = s.store.Put(ctx, s.key, data, codec.ContentType); err != nil {
+		return fmt.Errorf("chunk.Save: failed to save checkpoint: %w", err)
+	}
+
+	logger.Debug("chunk.Save: Checkpoint saved", "run_id", c.RunID, "notified", len(c.Notified))
+	return nil
+}
+
+// Clear removes the checkpoint once the run is complete.
+func (s *state) Clear(ctx context.Context) error {
+	if err := s.store.Delete(ctx, s.key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("chunk.Clear: failed to remove checkpoint: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,177 @@
+package chwriter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"go-players-data/internal/config"
+	"go-players-data/internal/logger"
+	"go-players-data/internal/model"
+)
+
+// batchSizeDefault is used when the configured batch size is not positive.
+const batchSizeDefault = 1000
+
+// row is a single player status event. The table is expected to be created as:
+//
+//	CREATE TABLE player_events (
+//	    run_id String, run_at DateTime, player_id Int64, player_name String, group_name String,
+//	    store_number Int32, company_name String, status LowCardinality(String), offline_hours Float64,
+//	    last_online DateTime, time_zone_diff Int32, tags Array(String), schedule_name String,
+//	    serial String, mac String, ip String, type LowCardinality(String), model LowCardinality(String), version String
+//	) ENGINE = MergeTree PARTITION BY toYYYYMM(run_at) ORDER BY (store_number, run_at);
+type row struct {
+	RunID        string   `json:"run_id"`
+	RunAt        string   `json:"run_at"`
+	PlayerID     int      `json:"player_id"`
+	PlayerName   string   `json:"player_name"`
+	GroupName    string   `json:"group_name"`
+	StoreNumber  int      `json:"store_number"`
+	CompanyName  string   `json:"company_name"`
+	Status       string   `json:"status"`
+	OfflineHours float64  `json:"offline_hours"`
+	LastOnline   string   `json:"last_online"`
+	TimeZoneDiff int      `json:"time_zone_diff"`
+	Tags         []string `json:"tags"`
+	ScheduleName string   `json:"schedule_name"`
+	Serial       string   `json:"serial"`
+	MAC          string   `json:"mac"`
+	IP           string   `json:"ip"`
+	Type         string   `json:"type"`
+	Model        string   `json:"model"`
+	Version      string   `json:"version"`
+}
+
+// writer is a struct that inserts per-run player status rows into ClickHouse over its HTTP interface.
+type writer struct {
+	client     *http.Client
+	url        url.URL
+	user       string
+	password   string
+	database   string
+	table      string
+	batchSize  int
+	maxOffline time.Duration
+}
+
+// Writer is an interface for persisting the player rows of a single run.
+type Writer interface {
+	Write(ctx context.Context, runID string, runAt time.Time, players []*model.Player) error
+}
+
+// New creates a new Writer for the configured ClickHouse table.
+func New(c *http.Client, cfg config.ClickHouse, maxOffline time.Duration) Writer {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = batchSizeDefault
+	}
+
+	return &writer{
+		client:     c,
+		url:        cfg.URL,
+		user:       cfg.User,
+		password:   cfg.Password,
+		database:   cfg.Database,
+		table:      cfg.Table,
+		batchSize:  cfg.BatchSize,
+		maxOffline: maxOffline,
+	}
+}
+
+// Write inserts one row per player, sending batches of JSONEachRow lines.
+func (w *writer) Write(ctx context.Context, runID string, runAt time.Time, players []*model.Player) error {
+	start := time.Now()
+	defer func() { logger.Debug("chwriter.Write: Time spent", "time", time.Since(start).String()) }()
+
+	for i := 0; i < len(players); i += w.batchSize {
+		batch := players[i:min(i+w.batchSize, len(players))]
+
+		if err := w.insert(ctx, runID, runAt, batch); err != nil {
+			return fmt.Errorf("chwriter.Write: failed to insert batch at %d: %w", i, err)
+		}
+	}
+
+	logger.Info("chwriter.Write: Players stored", "table", w.table, "players", len(players))
+	return nil
+}
+
+// insert sends a single INSERT ... FORMAT JSONEachRow request for the batch.
+func (w *writer) insert(ctx context.Context, runID string, runAt time.Time, batch []*model.Player) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+
+	for _, p := range batch {
+		if err := enc.Encode(w.row(runID, runAt, p)); err != nil {
+			return err
+		}
+	}
+
+	u := w.url
+	q := u.Query()
+	q.Set("query", fmt.Sprintf("INSERT INTO %s FORMAT JSONEachRow", w.table))
+	if w.database != "" {
+		q.Set("database", w.database)
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-ClickHouse-User", w.user)
+	req.Header.Set("X-ClickHouse-Key", w.password)
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		logger.Error("chwriter.insert: Invalid status code", "statusCode", resp.StatusCode, "body", string(msg))
+		return &HTTPError{Code: resp.StatusCode}
+	}
+
+	return nil
+}
+
+// row converts a player to a ClickHouse row at the run time.
+func (w *writer) row(runID string, runAt time.Time, p *model.Player) row {
+	return row{
+		RunID:        runID,
+		RunAt:        runAt.UTC().Format(time.DateTime),
+		PlayerID:     p.ID,
+		PlayerName:   p.PlayerName,
+		GroupName:    p.GroupName,
+		StoreNumber:  p.StoreNumber,
+		CompanyName:  p.CompanyName,
+		Status:       p.Status(runAt, w.maxOffline),
+		OfflineHours: runAt.Sub(p.LastOnline).Hours(),
+		LastOnline:   p.LastOnline.UTC().Format(time.DateTime),
+		TimeZoneDiff: p.TimeZoneDiff,
+		Tags:         p.Tags,
+		ScheduleName: p.ScheduleName,
+		Serial:       p.Serial,
+		MAC:          p.MAC,
+		IP:           p.IP,
+		Type:         p.Type,
+		Model:        p.Model,
+		Version:      p.Version,
+	}
+}
+
+// HTTPError represents an error response from ClickHouse with a specific status code.
+type HTTPError struct {
+	Code int
+}
+
+// Error returns the text representation of the HTTP status code associated with the HTTPError.
+func (e *HTTPError) Error() string {
+	return http.StatusText(e.Code)
+}
@@ -1,9 +1,24 @@
 package cluster
 
 import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strings"
+
 	"go-players-data/internal/model"
 )
 
+// Grouping modes of the notification clusters: one cluster per store, per company or per group subtree.
+const (
+	ModeStore   = "store"
+	ModeCompany = "company"
+	ModeGroup   = "group"
+)
+
+// ErrUnknownMode is returned when the cluster mode is none of store, company and group.
+var ErrUnknownMode = errors.New("unknown cluster mode")
+
 // cluster is an unexported type implementing the Cluster interface for grouping and managing players by store numbers.
 type cluster struct {
 }
@@ -11,6 +26,9 @@ type cluster struct {
 // Cluster defines an interface for grouping players by their store number.
 type Cluster interface {
 	ByStoreNumber(players []*model.Player) map[int][]*model.Player
+	ByGroup(players []*model.Player, depth int) map[string][]*model.Player
+	ByCompany(players []*model.Player) map[string][]*model.Player
+	ByMode(players []*model.Player, mode string, depth int) (map[int][]*model.Player, error)
 }
 
 // New creates a new Cluster instance.
@@ -33,3 +51,117 @@ func (c *cluster) ByStoreNumber(players []*model.Player) map[int][]*model.Player
 
 	return byStoreNumber
 }
+
+// ByGroup groups players by the subtree of their group path cut to depth segments, e.g. "Retail/Closed" for depth 2.
+// Players in shallower groups are keyed by their full path, and players without a group by an empty key.
+// A depth of zero or less keys every player by its full group path.
+func (c *cluster) ByGroup(players []*model.Player, depth int) map[string][]*model.Player {
+	byGroup := make(map[string][]*model.Player)
+
+	for _, p := range players {
+		path := p.Path()
+		if depth > 0 && len(path) > depth {
+			path = path[:depth]
+		}
+
+		key := strings.Join(path, "/")
+		byGroup[key] = append(byGroup[key], p)
+	}
+
+	return byGroup
+}
+
+// ByCompany groups players by their company name. Players without a company are keyed by an empty name.
+func (c *cluster) ByCompany(players []*model.Player) map[string][]*model.Player {
+	byCompany := make(map[string][]*model.Player)
+
+	for _, p := range players {
+		byCompany[p.CompanyName] = append(byCompany[p.CompanyName], p)
+	}
+
+	return byCompany
+}
+
+// ByMode groups players into notification clusters by the mode: by store number, or into company-level
+// or group-level digests cut to depth segments. Digests are keyed by Key of their name, so they flow through
+// deduplication, throttling and the channels like store clusters do.
+func (c *cluster) ByMode(players []*model.Player, mode string, depth int) (map[int][]*model.Player, error) {
+	var byName map[string][]*model.Player
+
+	switch mode {
+	case ModeStore, "":
+		return c.ByStoreNumber(players), nil
+	case ModeCompany:
+		byName = c.ByCompany(players)
+	case ModeGroup:
+		byName = c.ByGroup(players, depth)
+	default:
+		return nil, fmt.Errorf("cluster.ByMode: %w: %q", ErrUnknownMode, mode)
+	}
+
+	clusters := make(map[int][]*model.Player, len(byName))
+	for name, named := range byName {
+		clusters[Key(name)] = named
+	}
+
+	return clusters, nil
+}
+
+// Key returns the stable cluster key of a company or group digest. Keys are negative,
+// so they never collide with store numbers, and the same name gets the same key in every run.
+func Key(name string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name))
+	return -1 - int(h.Sum32()&0x7fffffff)
+}
+
+// Name returns the name of a digest cluster created by ByMode, or false for store numbers.
+// See digest for how the name is derived from the players, so keys read back from storage resolve too.
+func Name(key int, players []*model.Player) (string, bool) {
+	name, _, ok := digest(key, players)
+	return name, ok
+}
+
+// Company returns the company whose overrides apply to the cluster: the name of a company cluster,
+// or the company shared by every player of a store cluster. Returns false for group clusters,
+// which may span companies, and for store clusters whose players belong to different companies.
+func Company(key int, players []*model.Player) (string, bool) {
+	if name, company, ok := digest(key, players); ok {
+		return name, company
+	}
+
+	if key < 0 || len(players) == 0 {
+		return "", false
+	}
+	company := players[0].CompanyName
+	for _, p := range players[1:] {
+		if p.CompanyName != company {
+			return "", false
+		}
+	}
+
+	return company, true
+}
+
+// digest derives the name of a digest cluster from its key and any of its players, which share the name:
+// the company name of a company digest, or the group path prefix of a group digest whose Key is the cluster key.
+// company reports whether the name is a company. Returns false for store numbers and empty clusters.
+func digest(key int, players []*model.Player) (name string, company, ok bool) {
+	if key >= 0 || len(players) == 0 {
+		return "", false, false
+	}
+
+	p := players[0]
+	if p.CompanyName != "" && Key(p.CompanyName) == key {
+		return p.CompanyName, true, true
+	}
+
+	path := p.Path()
+	for i := len(path); i >= 0; i-- {
+		if name = strings.Join(path[:i], "/"); Key(name) == key {
+			return name, false, true
+		}
+	}
+
+	return "", false, false
+}
@@ -0,0 +1,109 @@
+package codec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"go-players-data/internal/logger"
+)
+
+// Version is the schema version written in the header of encoded state objects.
+// Objects without a header are plain JSON written before versioning and decode as version 0.
+const Version = 1
+
+// ContentType is the content type of encoded state objects.
+const ContentType = "application/octet-stream"
+
+// Codecs of the state payload.
+const (
+	none    byte = 0
+	gzipped byte = 1
+)
+
+// magic starts the header of encoded state objects: magic, version byte, codec byte.
+var magic = []byte("GPDS")
+
+// headerSize is the length of the header.
+var headerSize = len(magic) + 2
+
+// ErrVersion is returned when a state object was written by a newer schema version.
+// ErrCodec is returned when a state object uses an unknown codec.
+var (
+	ErrVersion = errors.New("unsupported state version")
+	ErrCodec   = errors.New("unsupported state codec")
+)
+
+// sizes keeps the encoded size of every state object written by this process, keyed by object key.
+var sizes sync.Map
+
+// Marshal encodes v as gzip-compressed JSON behind a versioned header and records the encoded size under key.
+func Marshal(key string, v interface{}) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("codec.Marshal: %w", err)
+	}
+
+	var buf bytes.Buffer
+	buf.Write(magic)
+	buf.WriteByte(Version)
+	buf.WriteByte(gzipped)
+
+	zw := gzip.NewWriter(&buf)
+	if _, err = zw.Write(raw); err != nil {
+		return nil, fmt.Errorf("codec.Marshal: %w", err)
+	}
+	if err = zw.Close(); err != nil {
+		return nil, fmt.Errorf("codec.Marshal: %w", err)
+	}
+
+	sizes.Store(key, buf.Len())
+	logger.Debug("codec.Marshal: Encoded", "key", key, "json_bytes", len(raw), "bytes", buf.Len())
+
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes a state object written by Marshal or a plain JSON object written before versioning.
+func Unmarshal(data []byte, v interface{}) error {
+	if !bytes.HasPrefix(data, magic) || len(data) < headerSize {
+		return json.Unmarshal(data, v)
+	}
+
+	version, codec := data[len(magic)], data[len(magic)+1]
+	if version > Version {
+		return fmt.Errorf("codec.Unmarshal: %w: %d", ErrVersion, version)
+	}
+
+	payload := data[headerSize:]
+	switch codec {
+	case none:
+	case gzipped:
+		zr, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return fmt.Errorf("codec.Unmarshal: %w", err)
+		}
+		defer func() { _ = zr.Close() }()
+
+		if payload, err = io.ReadAll(zr); err != nil {
+			return fmt.Errorf("codec.Unmarshal: %w", err)
+		}
+	default:
+		return fmt.Errorf("codec.Unmarshal: %w: %d", ErrCodec, codec)
+	}
+
+	return json.Unmarshal(payload, v)
+}
+
+// Sizes returns the encoded sizes of the state objects written by this process, keyed by object key.
+func Sizes() map[string]int {
+	m := make(map[string]int)
+	sizes.Range(func(k, v interface{}) bool {
+		m[k.(string)] = v.(int)
+		return true
+	})
+	return m
+}
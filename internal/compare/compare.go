@@ -0,0 +1,143 @@
+package compare
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"go-players-data/internal/archive"
+	"go-players-data/internal/model"
+)
+
+// ErrRunNotFound is returned when no archived run matches a reference.
+var ErrRunNotFound = errors.New("archived run not found")
+
+// RunRef identifies a compared run.
+type RunRef struct {
+	ID string    `json:"id"`
+	At time.Time `json:"at"`
+}
+
+// Diff is the change of the offline set between two runs.
+// New players went offline after the first run, Recovered came back online, and Still stayed offline in both.
+type Diff struct {
+	From      RunRef          `json:"from"`
+	To        RunRef          `json:"to"`
+	New       []*model.Player `json:"new"`
+	Recovered []*model.Player `json:"recovered"`
+	Still     []*model.Player `json:"still"`
+}
+
+// Compare diffs the offline sets of two runs by player key. Players in every list are ordered by store and name.
+func Compare(from, to archive.Run) Diff {
+	before := byKey(from.Offline)
+	after := byKey(to.Offline)
+
+	d := Diff{
+		From: RunRef{ID: from.ID, At: from.At},
+		To:   RunRef{ID: to.ID, At: to.At},
+	}
+	for key, p := range after {
+		if _, ok := before[key]; ok {
+			d.Still = append(d.Still, p)
+		} else {
+			d.New = append(d.New, p)
+		}
+	}
+	for key, p := range before {
+		if _, ok := after[key]; !ok {
+			d.Recovered = append(d.Recovered, p)
+		}
+	}
+
+	for _, players := range [][]*model.Player{d.New, d.Recovered, d.Still} {
+		sort.Slice(players, func(i, j int) bool {
+			if players[i].StoreNumber != players[j].StoreNumber {
+				return players[i].StoreNumber < players[j].StoreNumber
+			}
+			return players[i].PlayerName < players[j].PlayerName
+		})
+	}
+
+	return d
+}
+
+// byKey indexes players by model.Player.Key.
+func byKey(players []*model.Player) map[string]*model.Player {
+	m := make(map[string]*model.Player, len(players))
+	for _, p := range players {
+		m[p.Key()] = p
+	}
+	return m
+}
+
+// Resolve finds the archived run of a reference: a run ID, or a date (2006-01-02) or time (RFC 3339)
+// resolved to the last run at or before it. A date means the end of that day in UTC.
+func Resolve(ctx context.Context, archiver archive.Archiver, ref string) (archive.Run, error) {
+	at, byTime := parseTime(ref)
+
+	to := time.Now()
+	if byTime {
+		to = at
+	}
+
+	runs, err := archiver.Runs(ctx, time.Time{}, to)
+	if err != nil {
+		return archive.Run{}, fmt.Errorf("compare.Resolve: %w", err)
+	}
+
+	if byTime {
+		if len(runs) == 0 {
+			return archive.Run{}, fmt.Errorf("compare.Resolve: %w: no run before %s", ErrRunNotFound, ref)
+		}
+		return runs[len(runs)-1], nil
+	}
+
+	for _, run := range runs {
+		if run.ID == ref {
+			return run, nil
+		}
+	}
+
+	return archive.Run{}, fmt.Errorf("compare.Resolve: %w: %s", ErrRunNotFound, ref)
+}
+
+// parseTime parses a reference as an RFC 3339 time or a date, reporting whether it is one.
+func parseTime(ref string) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, ref); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse(time.DateOnly, ref); err == nil {
+		return t.Add(24*time.Hour - time.Nanosecond), true
+	}
+	return time.Time{}, false
+}
+
+// Text renders the diff as a plain text summary followed by the new and recovered players.
+func Text(w io.Writer, d Diff) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Offline changes from %s (%s) to %s (%s)\n",
+		d.From.ID, d.From.At.Format(time.DateTime), d.To.ID, d.To.At.Format(time.DateTime))
+	fmt.Fprintf(&b, "New: %d, recovered: %d, still offline: %d\n", len(d.New), len(d.Recovered), len(d.Still))
+
+	section := func(title string, players []*model.Player) {
+		if len(players) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n%s:\n", title)
+		for _, p := range players {
+			fmt.Fprintf(&b, "  %d\t%s\t%s\t%s\tlast online %s\n",
+				p.StoreNumber, p.CompanyName, p.PlayerName, p.MAC, p.LastOnline.Format(time.DateTime))
+		}
+	}
+	section("New offline", d.New)
+	section("Recovered", d.Recovered)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,221 @@
+package daily
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go-players-data/internal/archive"
+	"go-players-data/internal/config"
+	"go-players-data/internal/logger"
+	"go-players-data/internal/mailer"
+	"go-players-data/internal/model"
+)
+
+// Payload is the timer trigger payload that sends the end-of-day summary instead of running the pipeline.
+const Payload = "daily-summary"
+
+// Company is the day of a company: players that went offline, recovered, and are still offline at the end of the day.
+type Company struct {
+	Name         string
+	WentOffline  []*model.Player
+	Recovered    []*model.Player
+	StillOffline []*model.Player
+}
+
+// summary is a struct that compiles the day's offline and recovery events of the archived runs into per-company emails.
+type summary struct {
+	archiver   archive.Archiver
+	mailer     mailer.Mailer
+	recipients map[string][]string
+	location   *time.Location
+	subject    string
+}
+
+// Summary is an interface for sending the end-of-day summary of every company.
+type Summary interface {
+	Send(ctx context.Context, now time.Time) error
+}
+
+// New creates a new Summary reading runs from the archiver. Companies without DAILY_TO recipients are summarized to MAIL_TO.
+func New(archiver archive.Archiver, m mailer.Mailer, cfg config.Daily) (Summary, error) {
+	location, err := time.LoadLocation(cfg.Location)
+	if err != nil {
+		return nil, fmt.Errorf("daily.New: invalid location %q: %w", cfg.Location, err)
+	}
+
+	recipients := make(map[string][]string, len(cfg.To))
+	for company, list := range cfg.To {
+		for _, to := range strings.Split(list, ";") {
+			if to = strings.TrimSpace(to); to != "" {
+				recipients[company] = append(recipients[company], to)
+			}
+		}
+	}
+
+	return &summary{
+		archiver:   archiver,
+		mailer:     m,
+		recipients: recipients,
+		location:   location,
+		subject:    cfg.Subject,
+	}, nil
+}
+
+// Send emails the summary of the day of now, from local midnight to now, to every company with events.
+// A failed company does not stop the others; the errors are joined.
+func (s *summary) Send(ctx context.Context, now time.Time) error {
+	start := time.Now()
+	defer func() { logger.Debug("daily.Send: Time spent", "time", time.Since(start).String()) }()
+
+	local := now.In(s.location)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, s.location)
+
+	// The last run of the previous day is the baseline of who was already offline
+	runs, err := s.archiver.Runs(ctx, midnight.AddDate(0, 0, -1), now)
+	if err != nil {
+		return fmt.Errorf("daily.Send: %w", err)
+	}
+
+	var baseline []*model.Player
+	var day []archive.Run
+	for _, run := range runs {
+		if run.At.Before(midnight) {
+			baseline = run.Offline
+			continue
+		}
+		day = append(day, run)
+	}
+
+	if len(day) == 0 {
+		logger.Info("daily.Send: No runs archived today", "date", midnight.Format(time.DateOnly))
+		return nil
+	}
+
+	companies := Build(baseline, day)
+
+	var errs []string
+	for _, c := range companies {
+		subject := fmt.Sprintf("%s: %s, %s", s.subject, c.Name, midnight.Format(time.DateOnly))
+		if err = s.mailer.SendText(subject, s.text(c, midnight, len(day)), s.recipients[c.Name]); err != nil {
+			logger.Error("daily.Send: Failed to send summary", "company", c.Name, "err", err)
+			errs = append(errs, fmt.Sprintf("%s: %v", c.Name, err))
+			continue
+		}
+		logger.Debug("daily.Send: Summary sent", "company", c.Name,
+			"went_offline", len(c.WentOffline), "recovered", len(c.Recovered), "still_offline", len(c.StillOffline))
+	}
+
+	logger.Info("daily.Send: Daily summary sent", "companies", len(companies), "runs", len(day))
+
+	if len(errs) > 0 {
+		return fmt.Errorf("daily.Send: failed companies: %s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// Build compiles the events of the day runs, oldest first, against the offline set of the baseline run.
+// A player went offline when a day run has it and the baseline does not; it recovered when it is missing from the last run.
+// Companies without events are left out; companies and players are sorted.
+func Build(baseline []*model.Player, day []archive.Run) []Company {
+	before := make(map[string]bool, len(baseline))
+	for _, p := range baseline {
+		before[p.Key()] = true
+	}
+
+	last := make(map[string]*model.Player)
+	for _, p := range day[len(day)-1].Offline {
+		last[p.Key()] = p
+	}
+
+	byCompany := make(map[string]*Company)
+	company := func(name string) *Company {
+		c, ok := byCompany[name]
+		if !ok {
+			c = &Company{Name: name}
+			byCompany[name] = c
+		}
+		return c
+	}
+
+	// Every player offline at some point of the day, at its latest state
+	seen := make(map[string]*model.Player)
+	for _, p := range baseline {
+		seen[p.Key()] = p
+	}
+	wentOffline := make(map[string]bool)
+	for _, run := range day {
+		for _, p := range run.Offline {
+			seen[p.Key()] = p
+			if !before[p.Key()] {
+				wentOffline[p.Key()] = true
+			}
+		}
+	}
+
+	for key, p := range seen {
+		c := company(p.CompanyName)
+		if wentOffline[key] {
+			c.WentOffline = append(c.WentOffline, p)
+		}
+		if _, ok := last[key]; ok {
+			c.StillOffline = append(c.StillOffline, p)
+		} else {
+			c.Recovered = append(c.Recovered, p)
+		}
+	}
+
+	companies := make([]Company, 0, len(byCompany))
+	for _, c := range byCompany {
+		if len(c.WentOffline) == 0 && len(c.Recovered) == 0 {
+			continue
+		}
+		sortPlayers(c.WentOffline)
+		sortPlayers(c.Recovered)
+		sortPlayers(c.StillOffline)
+		companies = append(companies, *c)
+	}
+	sort.Slice(companies, func(i, j int) bool { return companies[i].Name < companies[j].Name })
+
+	return companies
+}
+
+// text renders the summary of a company as plain text.
+func (s *summary) text(c Company, midnight time.Time, runs int) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s, %s, %d runs\n", c.Name, midnight.Format(time.DateOnly), runs)
+	fmt.Fprintf(&b, "Went offline: %d, recovered: %d, still offline: %d\n",
+		len(c.WentOffline), len(c.Recovered), len(c.StillOffline))
+
+	section(&b, "Went offline", c.WentOffline, s.location)
+	section(&b, "Recovered", c.Recovered, s.location)
+	section(&b, "Still offline", c.StillOffline, s.location)
+
+	return b.String()
+}
+
+// section writes a titled list of players with their store and last online time in the location.
+func section(b *strings.Builder, title string, players []*model.Player, location *time.Location) {
+	if len(players) == 0 {
+		return
+	}
+
+	fmt.Fprintf(b, "\n%s\n", title)
+	for _, p := range players {
+		fmt.Fprintf(b, "  Store %d, %s, last online %s\n", p.StoreNumber, p.PlayerName, p.LastOnline.In(location).Format(time.DateTime))
+	}
+}
+
+// sortPlayers orders players by store number, then by player name.
+func sortPlayers(players []*model.Player) {
+	sort.Slice(players, func(i, j int) bool {
+		if players[i].StoreNumber != players[j].StoreNumber {
+			return players[i].StoreNumber < players[j].StoreNumber
+		}
+		return players[i].PlayerName < players[j].PlayerName
+	})
+}
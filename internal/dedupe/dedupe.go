@@ -0,0 +1,196 @@
+package dedupe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go-players-data/internal/codec"
+	"go-players-data/internal/config"
+	"go-players-data/internal/logger"
+	"go-players-data/internal/model"
+	"go-players-data/internal/storage"
+)
+
+// Entry is an offline player that was already reported.
+type Entry struct {
+	StoreNumber int       `json:"store_number"`
+	CompanyName string    `json:"company_name"`
+	PlayerName  string    `json:"player_name"`
+	ReportedAt  time.Time `json:"reported_at"`
+}
+
+// Reported maps player keys to the offline players already reported.
+type Reported map[string]Entry
+
+// dedupe is a struct that keeps the players already reported in object storage,
+// so every offline player is notified once and its recovery is noticed.
+type dedupe struct {
+	store storage.Storage
+	key   string
+}
+
+// Dedupe is an interface for leaving already reported players out of a run and recording the reported ones.
+type Dedupe interface {
+	Filter(ctx context.Context, clusters map[int][]*model.Player) (map[int][]*model.Player, []Entry)
+	Record(ctx context.Context, clusters, notified map[int][]*model.Player, now time.Time) error
+	Add(ctx context.Context, notified map[int][]*model.Player, now time.Time) error
+}
+
+// New creates a new Dedupe keeping its state under the configured object key.
+func New(store storage.Storage, cfg config.Dedupe) Dedupe {
+	return &dedupe{
+		store: store,
+		key:   cfg.StateKey,
+	}
+}
+
+// Filter returns the clusters reduced to the players not reported yet, dropping clusters left empty,
+// and the reported players that are no longer offline, ordered by store number and player name.
+// On state errors all clusters are returned and no player is recovered, so alerts are never lost.
+func (d *dedupe) Filter(ctx context.Context, clusters map[int][]*model.Player) (map[int][]*model.Player, []Entry) {
+	reported, err := d.load(ctx)
+	if err != nil {
+		logger.Error("dedupe.Filter: Failed to load state", "err", err)
+		return clusters, nil
+	}
+
+	offline := make(map[string]struct{})
+	result := make(map[int][]*model.Player, len(clusters))
+	for sn, players := range clusters {
+		var fresh []*model.Player
+		for _, p := range players {
+			offline[p.Key()] = struct{}{}
+			if _, ok := reported[p.Key()]; !ok {
+				fresh = append(fresh, p)
+			}
+		}
+
+		if len(fresh) == 0 {
+			logger.Debug("dedupe.Filter: Cluster already reported", "cluster", sn, "players", len(players))
+			continue
+		}
+		result[sn] = fresh
+	}
+
+	var recovered []Entry
+	for key, e := range reported {
+		if _, ok := offline[key]; !ok {
+			recovered = append(recovered, e)
+		}
+	}
+	sort.Slice(recovered, func(i, j int) bool {
+		if recovered[i].StoreNumber != recovered[j].StoreNumber {
+			return recovered[i].StoreNumber < recovered[j].StoreNumber
+		}
+		return recovered[i].PlayerName < recovered[j].PlayerName
+	})
+
+	return result, recovered
+}
+
+// Record saves the reported players: those still offline in clusters keep their report time
+// and the notified ones are added with now. Recovered players are dropped, so they are reported again
+// when they go offline next time; new players left out of the notification stay unreported.
+// The state is written conditionally and recomputed from the fresh state when a concurrent run changed it.
+func (d *dedupe) Record(ctx context.Context, clusters, notified map[int][]*model.Player, now time.Time) error {
+	err := storage.Update(ctx, d.store, d.key, codec.ContentType, func(data []byte) ([]byte, error) {
+		reported, err := d.decode(data)
+		if err != nil {
+			return nil, err
+		}
+
+		next := make(Reported)
+		for _, players := range clusters {
+			for _, p := range players {
+				if e, ok := reported[p.Key()]; ok {
+					next[p.Key()] = e
+				}
+			}
+		}
+
+		for sn, players := range notified {
+			for _, p := range players {
+				if _, ok := next[p.Key()]; !ok {
+					next[p.Key()] = Entry{StoreNumber: sn, CompanyName: p.CompanyName, PlayerName: p.PlayerName, ReportedAt: now}
+				}
+			}
+		}
+
+		return d.encode(next)
+	})
+	if err != nil {
+		return fmt.Errorf("dedupe.Record: %w", err)
+	}
+
+	return nil
+}
+
+// Add saves the notified players as reported with now and keeps every other reported player,
+// for notifications sent between full runs, which do not see every offline player.
+// The state is written conditionally like in Record.
+func (d *dedupe) Add(ctx context.Context, notified map[int][]*model.Player, now time.Time) error {
+	if len(notified) == 0 {
+		return nil
+	}
+
+	err := storage.Update(ctx, d.store, d.key, codec.ContentType, func(data []byte) ([]byte, error) {
+		reported, err := d.decode(data)
+		if err != nil {
+			return nil, err
+		}
+
+		for sn, players := range notified {
+			for _, p := range players {
+				if _, ok := reported[p.Key()]; !ok {
+					reported[p.Key()] = Entry{StoreNumber: sn, CompanyName: p.CompanyName, PlayerName: p.PlayerName, ReportedAt: now}
+				}
+			}
+		}
+
+		return d.encode(reported)
+	})
+	if err != nil {
+		return fmt.Errorf("dedupe.Add: %w", err)
+	}
+
+	return nil
+}
+
+// load reads the reported players. A missing state object means no player was reported yet.
+func (d *dedupe) load(ctx context.Context) (Reported, error) {
+	data, err := d.store.Get(ctx, d.key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return make(Reported), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("dedupe.load: failed to load state: %w", err)
+	}
+
+	return d.decode(data)
+}
+
+// decode decodes the reported players of the state object, nil for a missing one.
+func (d *dedupe) decode(data []byte) (Reported, error) {
+	reported := make(Reported)
+	if data == nil {
+		return reported, nil
+	}
+
+	if err := codec.Unmarshal(data, &reported); err != nil {
+		return nil, fmt.Errorf("dedupe.decode: failed to decode state: %w", err)
+	}
+
+	return reported, nil
+}
+
+// encode encodes the reported players into the state object.
+func (d *dedupe) encode(reported Reported) ([]byte, error) {
+	data, err := codec.Marshal(d.key, reported)
+	if err != nil {
+		return nil, fmt.Errorf("dedupe.encode: failed to encode state: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,159 @@
+package delta
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go-players-data/internal/codec"
+	"go-players-data/internal/logger"
+	"go-players-data/internal/model"
+	"go-players-data/internal/storage"
+)
+
+// Snapshot is the full player list maintained from delta feeds.
+// RefreshedAt is the time of the last full refresh and UpdatedAt the time of the last applied delta.
+// A zero RefreshedAt forces a full refresh on the next run.
+type Snapshot struct {
+	RefreshedAt time.Time                `json:"refreshed_at"`
+	UpdatedAt   time.Time                `json:"updated_at"`
+	Players     map[string]*model.Player `json:"players"` // Keyed by model.Player.Key
+}
+
+// Stats counts the changes applied by a delta.
+type Stats struct {
+	Inserted int
+	Updated  int
+	Deleted  int
+	Unknown  int // Tombstones of players missing from the snapshot
+}
+
+// NewSnapshot builds a snapshot from a full player list.
+func NewSnapshot(players []*model.Player, at time.Time) *Snapshot {
+	s := &Snapshot{
+		RefreshedAt: at,
+		UpdatedAt:   at,
+		Players:     make(map[string]*model.Player, len(players)),
+	}
+
+	for _, p := range players {
+		if !p.Deleted {
+			s.Players[p.Key()] = p
+		}
+	}
+
+	return s
+}
+
+// Apply merges the delta onto the snapshot: tombstones remove players, other records insert or replace them by key.
+// Tombstones of unknown players mean the snapshot drifted from the upstream; the snapshot is then marked
+// for a full refresh on the next run.
+func (s *Snapshot) Apply(changes []*model.Player, at time.Time) Stats {
+	var stats Stats
+
+	for _, p := range changes {
+		key := p.Key()
+		_, exists := s.Players[key]
+
+		switch {
+		case p.Deleted && exists:
+			delete(s.Players, key)
+			stats.Deleted++
+		case p.Deleted:
+			stats.Unknown++
+		case exists:
+			s.Players[key] = p
+			stats.Updated++
+		default:
+			s.Players[key] = p
+			stats.Inserted++
+		}
+	}
+
+	s.UpdatedAt = at
+
+	if stats.Unknown > 0 {
+		logger.Warn("delta.Apply: Tombstones of unknown players, full refresh scheduled", "unknown", stats.Unknown)
+		s.RefreshedAt = time.Time{}
+	}
+
+	return stats
+}
+
+// Stale reports whether the snapshot needs a full refresh: it is older than every or was marked inconsistent by Apply.
+func (s *Snapshot) Stale(now time.Time, every time.Duration) bool {
+	return s.RefreshedAt.IsZero() || now.Sub(s.RefreshedAt) > every
+}
+
+// List returns the players of the snapshot ordered by key, so runs over the same snapshot are reproducible.
+func (s *Snapshot) List() []*model.Player {
+	keys := make([]string, 0, len(s.Players))
+	for k := range s.Players {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	players := make([]*model.Player, 0, len(keys))
+	for _, k := range keys {
+		players = append(players, s.Players[k])
+	}
+
+	return players
+}
+
+// state is a struct that keeps the snapshot as a JSON object in object storage.
+type state struct {
+	store storage.Storage
+	key   string
+}
+
+// State is an interface for reading and saving the merged snapshot.
+type State interface {
+	Load(ctx context.Context) (*Snapshot, error)
+	Save(ctx context.Context, s *Snapshot) error
+}
+
+// NewState creates a new State stored under the key.
+func NewState(store storage.Storage, key string) State {
+	return &state{
+		store: store,
+		key:   key,
+	}
+}
+
+// Load reads the snapshot. Returns nil without an error when there is no snapshot yet.
+func (s *state) Load(ctx context.Context) (*Snapshot, error) {
+	data, err := s.store.Get(ctx, s.key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("delta.Load: failed to load snapshot: %w", err)
+	}
+
+	var snapshot Snapshot
+	if err = codec.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("delta.Load: failed to decode snapshot: %w", err)
+	}
+	if snapshot.Players == nil {
+		snapshot.Players = make(map[string]*model.Player)
+	}
+
+	return &snapshot, nil
+}
+
+// Save writes the snapshot.
+func (s *state) Save(ctx context.Context, snapshot *Snapshot) error {
+	data, err := codec.Marshal(s.key, snapshot)
+	if err != nil {
+		return fmt.Errorf("delta.Save: failed to encode snapshot: %w", err)
+	}
+
+	if err = s.store.Put(ctx, s.key, data, codec.ContentType); err != nil {
+		return fmt.Errorf("delta.Save: failed to save snapshot: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,345 @@
+package diag
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-players-data/internal/config"
+	"go-players-data/internal/fetcher"
+	"go-players-data/internal/logger"
+	"go-players-data/internal/mailer"
+)
+
+// Steps of a connectivity check, run in order until one fails.
+const (
+	StepDNS      = "dns"
+	StepTCP      = "tcp"
+	StepTLS      = "tls"
+	StepGreeting = "greeting"
+	StepAuth     = "auth"
+	StepEgressIP = "egress_ip"
+)
+
+// Check is the outcome of a single step against a target.
+type Check struct {
+	Target     string `json:"target"`
+	Step       string `json:"step"`
+	OK         bool   `json:"ok"`
+	DurationMs int64  `json:"duration_ms"`
+	Detail     string `json:"detail,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Report is the structured outcome of the diagnostics. OK is false when any step failed.
+type Report struct {
+	OK       bool    `json:"ok"`
+	EgressIP string  `json:"egress_ip,omitempty"` // Public address the function connects from, to compare with upstream allowlists
+	Checks   []Check `json:"checks"`
+}
+
+// Source is a data source to check, with the API key its report requests carry.
+type Source struct {
+	Name   string
+	URL    url.URL
+	APIKey string
+}
+
+// diagnoser is a struct that tests egress connectivity to the data sources and the SMTP relay step by step.
+type diagnoser struct {
+	timeout time.Duration
+	echoURL url.URL
+	mail    config.Mail
+}
+
+// Diagnoser is an interface for running the egress diagnostics.
+type Diagnoser interface {
+	Run(ctx context.Context, sources []Source) *Report
+}
+
+// New creates a new Diagnoser bounding every step by cfg.Timeout.
+func New(cfg config.Diag, mail config.Mail) Diagnoser {
+	return &diagnoser{
+		timeout: cfg.Timeout,
+		echoURL: cfg.EgressURL,
+		mail:    mail,
+	}
+}
+
+// Run resolves the egress address and checks DNS, TCP, TLS and authentication of every source and of the SMTP relay.
+// The checks of a target stop at its first failed step, so the failed step tells where the connection breaks:
+// DNS and TCP failures point at egress or allowlist misconfiguration, auth failures at credentials or the upstream.
+func (d *diagnoser) Run(ctx context.Context, sources []Source) *Report {
+	start := time.Now()
+	defer func() { logger.Debug("diag.Run: Time spent", "time", time.Since(start).String()) }()
+
+	r := &Report{OK: true}
+
+	if d.echoURL.Host != "" {
+		r.step("egress", StepEgressIP, func() (string, error) {
+			ip, err := d.egressIP(ctx)
+			r.EgressIP = ip
+			return ip, err
+		})
+	}
+
+	for _, s := range sources {
+		d.source(ctx, r, s)
+	}
+
+	if d.mail.Host != "" {
+		d.smtp(ctx, r)
+	}
+
+	logger.Info("diag.Run: Diagnostics done", "ok", r.OK, "checks", len(r.Checks))
+	return r
+}
+
+// step runs fn as a step of the target and records its outcome. Returns whether it succeeded.
+func (r *Report) step(target, name string, fn func() (string, error)) bool {
+	start := time.Now()
+	detail, err := fn()
+
+	c := Check{Target: target, Step: name, OK: err == nil, DurationMs: time.Since(start).Milliseconds(), Detail: detail}
+	if err != nil {
+		c.Error = err.Error()
+		r.OK = false
+		logger.Warn("diag.Run: Check failed", "target", target, "step", name, "err", err)
+	}
+	r.Checks = append(r.Checks, c)
+
+	return err == nil
+}
+
+// source checks a data source: resolving its host, connecting, the TLS handshake of https URLs,
+// and a report request with its API key. The report body is never read.
+func (d *diagnoser) source(ctx context.Context, r *Report, s Source) {
+	target := s.Name
+	if target == "" {
+		target = "data"
+	}
+	target += " " + s.URL.Host
+
+	port := s.URL.Port()
+	if port == "" {
+		port = "80"
+		if s.URL.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	conn, ok := d.connect(ctx, r, target, s.URL.Hostname(), port)
+	if !ok {
+		return
+	}
+	defer func() { _ = conn.Close() }()
+
+	if s.URL.Scheme == "https" && !r.step(target, StepTLS, func() (string, error) {
+		return d.handshake(ctx, tls.Client(conn, d.tlsConfig(s.URL.Hostname(), false)))
+	}) {
+		return
+	}
+
+	r.step(target, StepAuth, func() (string, error) {
+		return d.report(ctx, s)
+	})
+}
+
+// smtp checks the SMTP relay: resolving its host, connecting, TLS in the configured mode and authentication.
+func (d *diagnoser) smtp(ctx context.Context, r *Report) {
+	target := "smtp " + net.JoinHostPort(d.mail.Host, strconv.Itoa(d.mail.Port))
+
+	conn, ok := d.connect(ctx, r, target, d.mail.Host, strconv.Itoa(d.mail.Port))
+	if !ok {
+		return
+	}
+	defer func() { _ = conn.Close() }()
+
+	// The SMTP session has no context, the deadline bounds it instead
+	if d.timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(d.timeout))
+	}
+
+	if d.mail.TLS == mailer.TLSImplicit {
+		var tlsConn *tls.Conn
+		if !r.step(target, StepTLS, func() (string, error) {
+			tlsConn = tls.Client(conn, d.tlsConfig(d.mail.Host, d.mail.TLSSkipVerify))
+			return d.handshake(ctx, tlsConn)
+		}) {
+			return
+		}
+		conn = tlsConn
+	}
+
+	var c *smtp.Client
+	if !r.step(target, StepGreeting, func() (string, error) {
+		var err error
+		c, err = smtp.NewClient(conn, d.mail.Host)
+		return "", err
+	}) {
+		return
+	}
+	defer func() { _ = c.Close() }()
+
+	if d.mail.TLS != mailer.TLSImplicit && d.mail.TLS != mailer.TLSNone {
+		if !r.step(target, StepTLS, func() (string, error) {
+			if ok, _ := c.Extension("STARTTLS"); !ok {
+				if d.mail.TLS == mailer.TLSStartTLS {
+					return "", mailer.ErrNoStartTLS
+				}
+				return "STARTTLS not offered, plain text", nil
+			}
+			if err := c.StartTLS(d.tlsConfig(d.mail.Host, d.mail.TLSSkipVerify)); err != nil {
+				return "", fmt.Errorf("starttls: %w", err)
+			}
+			state, _ := c.TLSConnectionState()
+			return tlsDetail(state), nil
+		}) {
+			return
+		}
+	}
+
+	r.step(target, StepAuth, func() (string, error) {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return "AUTH not offered", nil
+		}
+		if err := c.Auth(smtp.PlainAuth("", d.mail.From, d.mail.Password, d.mail.Host)); err != nil {
+			return "", err
+		}
+		return "authenticated as " + d.mail.From, nil
+	})
+}
+
+// connect resolves the host and dials it, recording both steps. Returns the connection when both succeeded.
+func (d *diagnoser) connect(ctx context.Context, r *Report, target, host, port string) (net.Conn, bool) {
+	var addrs []string
+	if !r.step(target, StepDNS, func() (string, error) {
+		ctx, cancel := d.context(ctx)
+		defer cancel()
+
+		var err error
+		addrs, err = net.DefaultResolver.LookupHost(ctx, host)
+		return strings.Join(addrs, ", "), err
+	}) {
+		return nil, false
+	}
+
+	var conn net.Conn
+	if !r.step(target, StepTCP, func() (string, error) {
+		ctx, cancel := d.context(ctx)
+		defer cancel()
+
+		var err error
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+		if err != nil {
+			return "", err
+		}
+		return "connected from " + conn.LocalAddr().String() + " to " + conn.RemoteAddr().String(), nil
+	}) {
+		return nil, false
+	}
+
+	return conn, true
+}
+
+// handshake runs the handshake of the TLS connection and describes the negotiated session.
+func (d *diagnoser) handshake(ctx context.Context, conn *tls.Conn) (string, error) {
+	ctx, cancel := d.context(ctx)
+	defer cancel()
+
+	if err := conn.HandshakeContext(ctx); err != nil {
+		return "", err
+	}
+	return tlsDetail(conn.ConnectionState()), nil
+}
+
+// report sends the report request of the source with its API key and checks the status, closing the body unread.
+func (d *diagnoser) report(ctx context.Context, s Source) (string, error) {
+	ctx, cancel := d.context(ctx)
+	defer cancel()
+
+	data, err := json.Marshal(fetcher.Request{APIKey: s.APIKey})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL.String(), bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	_ = resp.Body.Close()
+
+	detail := "HTTP " + resp.Status
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return detail, fmt.Errorf("rejected credentials: %s", resp.Status)
+	case resp.StatusCode >= http.StatusBadRequest:
+		return detail, fmt.Errorf("upstream answered %s", resp.Status)
+	}
+	return detail, nil
+}
+
+// egressIP asks the echo service for the public address requests leave from.
+func (d *diagnoser) egressIP(ctx context.Context) (string, error) {
+	ctx, cancel := d.context(ctx)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.echoURL.String(), nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 256))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("echo service answered %s", resp.Status)
+	}
+
+	return strings.TrimSpace(string(body)), nil
+}
+
+// context bounds a step by the configured timeout.
+func (d *diagnoser) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if d.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d.timeout)
+}
+
+// tlsConfig returns the TLS configuration of a handshake with the server.
+func (d *diagnoser) tlsConfig(serverName string, skipVerify bool) *tls.Config {
+	return &tls.Config{ServerName: serverName, InsecureSkipVerify: skipVerify}
+}
+
+// tlsDetail describes the negotiated TLS version and the expiry of the server certificate.
+func tlsDetail(state tls.ConnectionState) string {
+	detail := tls.VersionName(state.Version)
+	if len(state.PeerCertificates) > 0 {
+		detail += ", certificate valid until " + state.PeerCertificates[0].NotAfter.UTC().Format(time.DateOnly)
+	}
+	return detail
+}
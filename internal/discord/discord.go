@@ -0,0 +1,174 @@
+package discord
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-players-data/internal/cluster"
+	"go-players-data/internal/config"
+	"go-players-data/internal/logger"
+	"go-players-data/internal/model"
+)
+
+// maxFields is the Discord limit of fields per embed.
+// embedColor highlights offline embeds, and username is shown as the message author.
+const (
+	maxFields  = 25
+	embedColor = 0xc62828
+	username   = "Players monitor"
+)
+
+// message is a Discord webhook execution payload.
+type message struct {
+	Username string  `json:"username,omitempty"`
+	Embeds   []embed `json:"embeds"`
+}
+
+type embed struct {
+	Title       string  `json:"title"`
+	Description string  `json:"description,omitempty"`
+	Color       int     `json:"color"`
+	Fields      []field `json:"fields"`
+	Timestamp   string  `json:"timestamp"`
+}
+
+type field struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+// notifier is a struct that posts embed-formatted offline lists to Discord channels via webhooks.
+type notifier struct {
+	client     *http.Client
+	webhook    string
+	byStore    map[int]string
+	byCompany  map[string]string
+	storeNames map[int]string
+}
+
+// Notifier defines an interface for sending Discord notifications to players grouped by store number.
+type Notifier interface {
+	Send(ctx context.Context, storeNumber int, players []*model.Player) error
+}
+
+// New creates a new Notifier routing clusters by store first, then by company, then to the default webhook.
+func New(c *http.Client, cfg config.Discord, storeNames map[int]string) Notifier {
+	return &notifier{
+		client:     c,
+		webhook:    cfg.WebhookURL,
+		byStore:    cfg.WebhooksByStore,
+		byCompany:  cfg.WebhooksByCompany,
+		storeNames: storeNames,
+	}
+}
+
+// Send posts an embed listing the offline players of the store.
+// Clusters without a matching webhook are skipped.
+func (n *notifier) Send(ctx context.Context, storeNumber int, players []*model.Player) error {
+	start := time.Now()
+	defer func() { logger.Debug("discord.Send: Time spent", "time", time.Since(start).String()) }()
+
+	webhook := n.route(storeNumber, players)
+	if webhook == "" {
+		logger.Debug("discord.Send: No webhook for cluster", "cluster", storeNumber)
+		return nil
+	}
+
+	data, err := json.Marshal(n.message(storeNumber, players, start))
+	if err != nil {
+		return fmt.Errorf("discord.Send: failed to build message: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("discord.Send: failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("discord.Send: failed to send message: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		logger.Error("discord.Send: Invalid status code", "statusCode", resp.StatusCode, "body", string(msg))
+		return &HTTPError{Code: resp.StatusCode}
+	}
+
+	return nil
+}
+
+// route returns the webhook of the store, of the cluster company, or the default one.
+func (n *notifier) route(storeNumber int, players []*model.Player) string {
+	if webhook, ok := n.byStore[storeNumber]; ok {
+		return webhook
+	}
+
+	if company, ok := cluster.Company(storeNumber, players); ok {
+		if webhook, ok := n.byCompany[company]; ok {
+			return webhook
+		}
+	}
+
+	return n.webhook
+}
+
+// message builds an embed with one field per offline player, within the Discord field limit.
+func (n *notifier) message(storeNumber int, players []*model.Player, now time.Time) *message {
+	storeID := strconv.Itoa(storeNumber)
+	if name := n.storeNames[storeNumber]; name != "" {
+		storeID = name
+	}
+
+	var description string
+	if len(players) > 0 {
+		description = players[0].CompanyName
+	}
+
+	fields := make([]field, 0, min(len(players), maxFields))
+	for i, p := range players {
+		if i == maxFields-1 && len(players) > maxFields {
+			fields = append(fields, field{
+				Name:  "…",
+				Value: fmt.Sprintf("and %d more", len(players)-i),
+			})
+			break
+		}
+
+		fields = append(fields, field{
+			Name:   p.PlayerName,
+			Value:  fmt.Sprintf("Last online: %s\nIP: %s\nMAC: %s\nType: %s", p.LastOnline.Format(time.DateTime), p.Addresses(), p.MAC, p.Type),
+			Inline: true,
+		})
+	}
+
+	return &message{
+		Username: username,
+		Embeds: []embed{{
+			Title:       fmt.Sprintf("Store %s: %d players offline", storeID, len(players)),
+			Description: description,
+			Color:       embedColor,
+			Fields:      fields,
+			Timestamp:   now.UTC().Format(time.RFC3339),
+		}},
+	}
+}
+
+// HTTPError represents an error response from Discord with a specific status code.
+type HTTPError struct {
+	Code int
+}
+
+// Error returns the text representation of the HTTP status code associated with the HTTPError.
+func (e *HTTPError) Error() string {
+	return http.StatusText(e.Code)
+}
@@ -0,0 +1,137 @@
+package escalation
+
+import (
+	"strings"
+	"time"
+
+	"go-players-data/internal/cluster"
+	"go-players-data/internal/config"
+	"go-players-data/internal/model"
+	"go-players-data/internal/prefs"
+)
+
+// Channel is the name of the sink emailing the escalation recipients of a cluster.
+// It is never restricted by the channel routes, since its recipients are routed themselves.
+const Channel = "escalation"
+
+// Route lists the channels and the extra recipients of a cluster.
+type Route struct {
+	Channels   []string
+	Recipients []string
+}
+
+// matrix is a struct that routes clusters by severity and company.
+// Routes are keyed by severity or by severity and company name as "severity/company".
+type matrix struct {
+	channels      map[string][]string
+	recipients    map[string][]string
+	criticalAfter time.Duration
+}
+
+// Matrix is an interface for routing a cluster to channels and extra recipients by its severity and company.
+type Matrix interface {
+	Severity(players []*model.Player, now time.Time) string
+	Route(severity, company string) Route
+	Clusters(channel string, clusters map[int][]*model.Player, now time.Time) map[int][]*model.Player
+	Recipients(storeNumber int, players []*model.Player, now time.Time) []string
+}
+
+// New creates a Matrix from the configured routes.
+func New(cfg config.Escalation) Matrix {
+	return &matrix{
+		channels:      split(cfg.Channels),
+		recipients:    split(cfg.Recipients),
+		criticalAfter: cfg.CriticalAfter,
+	}
+}
+
+// Enabled reports whether any route is configured.
+func Enabled(cfg config.Escalation) bool {
+	return len(cfg.Channels) > 0 || len(cfg.Recipients) > 0
+}
+
+// Severity returns the severity of the cluster: the most severe tier of its players when DATA_SEVERITY_TIERS is set,
+// or else critical if any player has been offline for longer than ESCALATION_CRITICAL_AFTER.
+func (m *matrix) Severity(players []*model.Player, now time.Time) string {
+	if severity := model.ClusterSeverity(players); severity != "" {
+		return severity
+	}
+	return prefs.Severity(players, now, m.criticalAfter)
+}
+
+// Route returns the route of the severity and company. A company route wins over the route of the severity.
+// Channels are nil when neither routes channels, meaning every channel.
+func (m *matrix) Route(severity, company string) Route {
+	return Route{
+		Channels:   lookup(m.channels, severity, company),
+		Recipients: lookup(m.recipients, severity, company),
+	}
+}
+
+// Clusters returns the clusters routed to the channel. Clusters whose severity and company have no channel route
+// go to every channel; the escalation channel gets every cluster.
+func (m *matrix) Clusters(channel string, clusters map[int][]*model.Player, now time.Time) map[int][]*model.Player {
+	if len(m.channels) == 0 || channel == Channel {
+		return clusters
+	}
+
+	routed := make(map[int][]*model.Player, len(clusters))
+	for sn, players := range clusters {
+		channels := m.Route(m.Severity(players, now), company(sn, players)).Channels
+		if channels == nil || contains(channels, channel) {
+			routed[sn] = players
+		}
+	}
+
+	return routed
+}
+
+// Recipients returns the extra recipients of the cluster by its severity and company.
+func (m *matrix) Recipients(storeNumber int, players []*model.Player, now time.Time) []string {
+	return m.Route(m.Severity(players, now), company(storeNumber, players)).Recipients
+}
+
+// lookup returns the list of the company route of the severity, or of the severity route when there is none.
+func lookup(routes map[string][]string, severity, company string) []string {
+	if list, ok := routes[severity+"/"+company]; ok {
+		return list
+	}
+	return routes[severity]
+}
+
+// split parses ';'-separated lists of the routes, lower-casing the severity of every key.
+func split(routes map[string]string) map[string][]string {
+	m := make(map[string][]string, len(routes))
+	for key, list := range routes {
+		severity, company, found := strings.Cut(key, "/")
+		key = strings.ToLower(strings.TrimSpace(severity))
+		if found {
+			key += "/" + company
+		}
+
+		m[key] = []string{}
+		for _, item := range strings.Split(list, ";") {
+			if item = strings.TrimSpace(item); item != "" {
+				m[key] = append(m[key], item)
+			}
+		}
+	}
+	return m
+}
+
+// company returns the company of the cluster for its company routes, see cluster.Company,
+// or an empty name when none applies, so only the severity routes match.
+func company(storeNumber int, players []*model.Player) string {
+	name, _ := cluster.Company(storeNumber, players)
+	return name
+}
+
+// contains reports whether the list has the item.
+func contains(list []string, item string) bool {
+	for _, v := range list {
+		if v == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,207 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"go-players-data/internal/config"
+	"go-players-data/internal/logger"
+	"go-players-data/internal/model"
+	"go-players-data/internal/storage"
+)
+
+// csvHeader lists the columns of the exported CSV file.
+var csvHeader = []string{
+	"store_number", "company_name", "group_name", "player_name", "last_online", "offline_hours",
+	"ip", "mac", "serial", "type", "model", "version",
+}
+
+// ErrNoPrefix is returned when retention is set and the path template has no static prefix to limit the cleanup to.
+var ErrNoPrefix = errors.New("path template must start with a static prefix when retention is set")
+
+// Placeholders rendered into the path template to build the pattern of the export keys, see keyPattern.
+const (
+	datePlaceholder  = "\x00date\x00"
+	timePlaceholder  = "\x00time\x00"
+	runIDPlaceholder = "\x00run\x00"
+)
+
+// pathData is the data available to the export path template.
+type pathData struct {
+	Date  string
+	Time  string
+	RunID string
+}
+
+// exporter is a struct that writes the filtered players of each run as CSV to object storage.
+type exporter struct {
+	store     storage.Storage
+	path      *template.Template
+	prefix    string
+	pattern   *regexp.Regexp
+	retention time.Duration
+}
+
+// Exporter is an interface for exporting the players of a run and cleaning up expired exports.
+type Exporter interface {
+	Export(ctx context.Context, runID string, players []*model.Player) error
+	Cleanup(ctx context.Context) error
+}
+
+// New creates a new Exporter writing to the store under the configured path template.
+// The static part of the path before the first action is used as the prefix for retention cleanup,
+// and only keys the path template can render are deleted. With retention, the prefix must not be empty.
+func New(store storage.Storage, cfg config.Export) (Exporter, error) {
+	path, err := template.New("path").Option("missingkey=error").Parse(cfg.CSVPath)
+	if err != nil {
+		return nil, fmt.Errorf("export.New: invalid path template: %w", err)
+	}
+
+	prefix, _, _ := strings.Cut(cfg.CSVPath, "{{")
+	if prefix == "" && cfg.CSVRetention > 0 {
+		return nil, fmt.Errorf("export.New: %w", ErrNoPrefix)
+	}
+
+	pattern, err := keyPattern(path)
+	if err != nil {
+		return nil, fmt.Errorf("export.New: %w", err)
+	}
+
+	return &exporter{
+		store:     store,
+		path:      path,
+		prefix:    prefix,
+		pattern:   pattern,
+		retention: cfg.CSVRetention,
+	}, nil
+}
+
+// keyPattern returns the pattern of the keys the path template renders: the template is executed with placeholders
+// that are then replaced by the formats of the date, the time and the run ID.
+func keyPattern(path *template.Template) (*regexp.Regexp, error) {
+	var buf bytes.Buffer
+	if err := path.Execute(&buf, pathData{Date: datePlaceholder, Time: timePlaceholder, RunID: runIDPlaceholder}); err != nil {
+		return nil, fmt.Errorf("failed to execute path template: %w", err)
+	}
+
+	expr := strings.NewReplacer(
+		regexp.QuoteMeta(datePlaceholder), `\d{4}-\d{2}-\d{2}`,
+		regexp.QuoteMeta(timePlaceholder), `\d{6}`,
+		regexp.QuoteMeta(runIDPlaceholder), `[^/]+`,
+	).Replace(regexp.QuoteMeta(buf.String()))
+
+	return regexp.Compile("^" + expr + "$")
+}
+
+// Export renders the players as CSV and uploads them under the path built from the run date and ID.
+func (e *exporter) Export(ctx context.Context, runID string, players []*model.Player) error {
+	start := time.Now()
+	defer func() { logger.Debug("export.Export: Time spent", "time", time.Since(start).String()) }()
+
+	key, err := e.key(start, runID)
+	if err != nil {
+		return err
+	}
+
+	body, err := e.csv(players, start)
+	if err != nil {
+		return err
+	}
+
+	if err = e.store.Put(ctx, key, body, "text/csv; charset=utf-8"); err != nil {
+		return fmt.Errorf("export.Export: failed to upload %s: %w", key, err)
+	}
+
+	logger.Info("export.Export: Players exported", "key", key, "players", len(players))
+	return nil
+}
+
+// Cleanup deletes exports under the path prefix that are older than the retention period.
+// Keys under the prefix that the path template does not render, such as the state of other packages, are kept.
+// Does nothing when retention is not configured.
+func (e *exporter) Cleanup(ctx context.Context) error {
+	if e.retention <= 0 {
+		return nil
+	}
+
+	objects, err := e.store.List(ctx, e.prefix)
+	if err != nil {
+		return fmt.Errorf("export.Cleanup: failed to list exports: %w", err)
+	}
+
+	deadline := time.Now().Add(-e.retention)
+	for _, o := range objects {
+		if o.LastModified.After(deadline) || !e.pattern.MatchString(o.Key) {
+			continue
+		}
+
+		if err = e.store.Delete(ctx, o.Key); err != nil {
+			return fmt.Errorf("export.Cleanup: failed to delete %s: %w", o.Key, err)
+		}
+		logger.Debug("export.Cleanup: Expired export deleted", "key", o.Key)
+	}
+
+	return nil
+}
+
+// key builds the object key from the path template.
+func (e *exporter) key(t time.Time, runID string) (string, error) {
+	var buf bytes.Buffer
+
+	data := pathData{
+		Date:  t.UTC().Format(time.DateOnly),
+		Time:  t.UTC().Format("150405"),
+		RunID: runID,
+	}
+
+	if err := e.path.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("export.key: failed to execute path template: %w", err)
+	}
+
+	return buf.String(), nil
+}
+
+// csv renders the players as a CSV document with a header row.
+func (e *exporter) csv(players []*model.Player, now time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	if err := w.Write(csvHeader); err != nil {
+		return nil, fmt.Errorf("export.csv: %w", err)
+	}
+
+	for _, p := range players {
+		record := []string{
+			strconv.Itoa(p.StoreNumber),
+			p.CompanyName,
+			p.GroupName,
+			p.PlayerName,
+			p.LastOnline.Format(time.DateTime),
+			strconv.FormatFloat(now.Sub(p.LastOnline).Hours(), 'f', 1, 64),
+			p.Addresses(),
+			p.MAC,
+			p.Serial,
+			p.Type,
+			p.Model,
+			p.Version,
+		}
+		if err := w.Write(record); err != nil {
+			return nil, fmt.Errorf("export.csv: %w", err)
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("export.csv: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,137 @@
+package fault
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Count is the number of failures of a kind in a stage, with the message of the first one as a sample.
+type Count struct {
+	Stage  string `json:"stage"`
+	Kind   string `json:"kind"`
+	Count  int    `json:"count"`
+	Sample string `json:"sample"`
+}
+
+// key identifies the failures counted together.
+type key struct {
+	stage string
+	kind  string
+}
+
+// collector is a struct that aggregates the failures of a run that do not stop it, such as skipped rows or failed sends.
+type collector struct {
+	mu     sync.Mutex
+	counts map[key]*Count
+}
+
+// Collector is an interface for aggregating failures by stage and kind. It is safe for concurrent use.
+type Collector interface {
+	Add(stage string, err error)
+	Counts() []Count
+	Total() int
+}
+
+// New creates an empty Collector.
+func New() Collector {
+	return &collector{counts: make(map[key]*Count)}
+}
+
+// Add counts the failure in the stage under its kind, see Kind. A nil error is ignored.
+func (c *collector) Add(stage string, err error) {
+	if err == nil {
+		return
+	}
+
+	k := key{stage: stage, kind: Kind(err)}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if n, ok := c.counts[k]; ok {
+		n.Count++
+		return
+	}
+	c.counts[k] = &Count{Stage: stage, Kind: k.kind, Count: 1, Sample: err.Error()}
+}
+
+// Counts returns the failures ordered by stage, then by count, most frequent first.
+func (c *collector) Counts() []Count {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	counts := make([]Count, 0, len(c.counts))
+	for _, n := range c.counts {
+		counts = append(counts, *n)
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Stage != counts[j].Stage {
+			return counts[i].Stage < counts[j].Stage
+		}
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Kind < counts[j].Kind
+	})
+
+	return counts
+}
+
+// Total returns the number of failures of every stage.
+func (c *collector) Total() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	total := 0
+	for _, n := range c.counts {
+		total += n.Count
+	}
+	return total
+}
+
+// Kind classifies the error: timeouts, SMTP reply codes, or else the message of the innermost wrapped error,
+// such as the sentinel errors of the parser, so the same failure of different players is counted once.
+func Kind(err error) string {
+	var smtpErr *textproto.Error
+	if errors.As(err, &smtpErr) {
+		return "smtp " + strconv.Itoa(smtpErr.Code)
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "deadline exceeded"
+	}
+	if errors.Is(err, context.Canceled) {
+		return "canceled"
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return "timeout"
+	}
+
+	return root(err).Error()
+}
+
+// root returns the innermost wrapped error, following the first error of joined ones.
+func root(err error) error {
+	for {
+		var next error
+		switch e := err.(type) {
+		case interface{ Unwrap() error }:
+			next = e.Unwrap()
+		case interface{ Unwrap() []error }:
+			if list := e.Unwrap(); len(list) > 0 {
+				next = list[0]
+			}
+		}
+		if next == nil {
+			return err
+		}
+		err = next
+	}
+}
@@ -0,0 +1,161 @@
+package feed
+
+import (
+	"crypto/subtle"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go-players-data/internal/logger"
+	"go-players-data/internal/model"
+	"go-players-data/internal/snapshot"
+)
+
+// maxEntries limits the number of events in a feed.
+const maxEntries = 100
+
+// Event kinds.
+const (
+	EventOffline  = "offline"
+	EventRecovery = "recovery"
+)
+
+// Event is a change of a player status between two consecutive runs.
+type Event struct {
+	Kind   string
+	At     time.Time
+	RunID  string
+	Player *model.Player
+}
+
+// atomFeed is an Atom 1.0 feed document.
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	Title    string       `xml:"title"`
+	ID       string       `xml:"id"`
+	Updated  string       `xml:"updated"`
+	Summary  string       `xml:"summary"`
+	Category atomCategory `xml:"category"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// handler serves per-company Atom feeds of offline and recovery events built from the snapshot store.
+type handler struct {
+	store  snapshot.Store
+	tokens map[string]string
+}
+
+// New creates an http.Handler serving /<company>?token=<token> feeds relative to its mount point.
+// Only companies with a configured token have a feed; the token may also be sent as a Bearer Authorization header.
+func New(store snapshot.Store, tokens map[string]string) http.Handler {
+	return &handler{
+		store:  store,
+		tokens: tokens,
+	}
+}
+
+// ServeHTTP authenticates the request and writes the feed of the requested company.
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	company, err := url.PathUnescape(strings.Trim(r.URL.Path, "/"))
+	if err != nil || company == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	want, ok := h.tokens[company]
+	if !ok || subtle.ConstantTimeCompare([]byte(token(r)), []byte(want)) != 1 {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	events := Events(h.store.Range(time.Time{}, time.Now()), company)
+
+	doc := atomFeed{
+		Title:   fmt.Sprintf("Players of %s", company),
+		ID:      "urn:go-players-data:feed:" + url.PathEscape(company),
+		Updated: time.Now().UTC().Format(time.RFC3339),
+	}
+	for i := len(events) - 1; i >= 0 && len(doc.Entries) < maxEntries; i-- {
+		doc.Entries = append(doc.Entries, entry(events[i]))
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	_, _ = w.Write([]byte(xml.Header))
+	if err = xml.NewEncoder(w).Encode(doc); err != nil {
+		logger.Error("feed.ServeHTTP: Failed to encode feed", "err", err)
+	}
+}
+
+// Events diffs consecutive snapshots, oldest first, and returns offline and recovery events of the company.
+// An empty company returns events of every company.
+func Events(snapshots []*snapshot.Snapshot, company string) []Event {
+	var events []Event
+
+	for i := 1; i < len(snapshots); i++ {
+		prev, curr := index(snapshots[i-1], company), index(snapshots[i], company)
+
+		for key, p := range curr {
+			if _, ok := prev[key]; !ok {
+				events = append(events, Event{Kind: EventOffline, At: snapshots[i].TakenAt, RunID: snapshots[i].RunID, Player: p})
+			}
+		}
+
+		for key, p := range prev {
+			if _, ok := curr[key]; !ok {
+				events = append(events, Event{Kind: EventRecovery, At: snapshots[i].TakenAt, RunID: snapshots[i].RunID, Player: p})
+			}
+		}
+	}
+
+	return events
+}
+
+// index maps the offline players of the company in a snapshot by their key.
+func index(snap *snapshot.Snapshot, company string) map[string]*model.Player {
+	players := make(map[string]*model.Player, len(snap.Players))
+	for _, p := range snap.Players {
+		if company != "" && p.CompanyName != company {
+			continue
+		}
+		players[p.Key()] = p
+	}
+	return players
+}
+
+// entry renders an event as an Atom entry.
+func entry(e Event) atomEntry {
+	verb := "went offline"
+	if e.Kind == EventRecovery {
+		verb = "is back online"
+	}
+
+	return atomEntry{
+		Title:   fmt.Sprintf("Store %d: %s %s", e.Player.StoreNumber, e.Player.PlayerName, verb),
+		ID:      fmt.Sprintf("urn:go-players-data:event:%s:%s:%s", e.RunID, e.Kind, url.PathEscape(e.Player.Key())),
+		Updated: e.At.UTC().Format(time.RFC3339),
+		Summary: fmt.Sprintf("Last online %s, IP %s, MAC %s, type %s",
+			e.Player.LastOnline.Format(time.DateTime), e.Player.Addresses(), e.Player.MAC, e.Player.Type),
+		Category: atomCategory{Term: e.Kind},
+	}
+}
+
+// token returns the token from the query string or the Bearer Authorization header.
+func token(r *http.Request) string {
+	if t := r.URL.Query().Get("token"); t != "" {
+		return t
+	}
+	return strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+}
@@ -0,0 +1,175 @@
+package fetcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go-players-data/internal/config"
+	"go-players-data/internal/logger"
+)
+
+// Authentication modes of the data sources selected by DATA_AUTH and DATA_SOURCE_AUTH.
+const (
+	AuthBody   = "body"   // API key in the JSON request body
+	AuthBearer = "bearer" // API key as a bearer token
+	AuthBasic  = "basic"  // DATA_AUTH_USER and the API key with basic auth
+	AuthOAuth2 = "oauth2" // OAuth2 client credentials, the token is refreshed before it expires
+)
+
+// ErrUnknownAuth is returned when an authentication mode is not supported.
+var ErrUnknownAuth = errors.New("unknown auth mode")
+
+// tokenLeeway is how long before its expiry an OAuth2 token is refreshed.
+const tokenLeeway = 30 * time.Second
+
+// Auth authorizes the requests of a data source. A nil Auth sends the API key in the request body.
+type Auth interface {
+	Authorize(ctx context.Context, req *http.Request) error
+}
+
+// NewAuth creates the Auth of the mode for a source with the API key. Returns nil for AuthBody.
+func NewAuth(c *http.Client, mode, apiKey string, cfg config.Data) (Auth, error) {
+	switch strings.ToLower(mode) {
+	case "", AuthBody:
+		return nil, nil
+	case AuthBearer:
+		return bearerAuth{token: apiKey}, nil
+	case AuthBasic:
+		return basicAuth{user: cfg.AuthUser, password: apiKey}, nil
+	case AuthOAuth2:
+		if cfg.OAuthTokenURL.Host == "" || cfg.OAuthClientID == "" {
+			return nil, fmt.Errorf("fetcher.NewAuth: oauth2 needs DATA_OAUTH_TOKEN_URL and DATA_OAUTH_CLIENT_ID")
+		}
+		return &oauth2Auth{
+			client:   c,
+			tokenURL: cfg.OAuthTokenURL.String(),
+			clientID: cfg.OAuthClientID,
+			secret:   cfg.OAuthClientSecret,
+			scopes:   cfg.OAuthScopes,
+		}, nil
+	default:
+		return nil, fmt.Errorf("fetcher.NewAuth: %w: %q", ErrUnknownAuth, mode)
+	}
+}
+
+// bearerAuth sends the API key as a bearer token.
+type bearerAuth struct {
+	token string
+}
+
+// Authorize sets the Authorization header.
+func (a bearerAuth) Authorize(_ context.Context, req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+a.token)
+	return nil
+}
+
+// basicAuth sends the user and the API key with basic auth.
+type basicAuth struct {
+	user     string
+	password string
+}
+
+// Authorize sets the Authorization header.
+func (a basicAuth) Authorize(_ context.Context, req *http.Request) error {
+	req.SetBasicAuth(a.user, a.password)
+	return nil
+}
+
+// oauthToken is an access token and the time it expires.
+type oauthToken struct {
+	value   string
+	expires time.Time
+}
+
+// oauthTokens keeps the tokens by token URL and client ID, so warm invocations reuse them until they expire.
+var oauthTokens sync.Map
+
+// oauth2Auth sends an access token of the OAuth2 client credentials flow as a bearer token.
+type oauth2Auth struct {
+	client   *http.Client
+	tokenURL string
+	clientID string
+	secret   string
+	scopes   []string
+	mu       sync.Mutex
+}
+
+// Authorize sets the Authorization header with the cached token, requesting a new one when it is about to expire.
+func (a *oauth2Auth) Authorize(ctx context.Context, req *http.Request) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	key := a.tokenURL + "\n" + a.clientID
+	if t, ok := oauthTokens.Load(key); ok && time.Until(t.(oauthToken).expires) > tokenLeeway {
+		req.Header.Set("Authorization", "Bearer "+t.(oauthToken).value)
+		return nil
+	}
+
+	t, err := a.token(ctx)
+	if err != nil {
+		return fmt.Errorf("fetcher.Authorize: %w", err)
+	}
+	oauthTokens.Store(key, t)
+
+	req.Header.Set("Authorization", "Bearer "+t.value)
+	return nil
+}
+
+// Invalidate drops the cached token, so the next request gets a new one. Called when the source answers 401.
+func (a *oauth2Auth) Invalidate() {
+	oauthTokens.Delete(a.tokenURL + "\n" + a.clientID)
+}
+
+// token requests an access token with the client credentials grant.
+func (a *oauth2Auth) token(ctx context.Context) (oauthToken, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(a.scopes) > 0 {
+		form.Set("scope", strings.Join(a.scopes, " "))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return oauthToken{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(a.clientID), url.QueryEscape(a.secret))
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return oauthToken{}, fmt.Errorf("token request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		logger.Error("fetcher.token: Invalid status code", "statusCode", resp.StatusCode, "body", string(msg))
+		return oauthToken{}, fmt.Errorf("token request failed: %w", &HTTPError{Code: resp.StatusCode})
+	}
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return oauthToken{}, fmt.Errorf("failed to decode token: %w", err)
+	}
+	if body.AccessToken == "" {
+		return oauthToken{}, errors.New("token response has no access_token")
+	}
+
+	expires := time.Now().Add(time.Duration(body.ExpiresIn) * time.Second)
+	if body.ExpiresIn <= 0 {
+		expires = time.Now().Add(time.Hour)
+	}
+
+	logger.Debug("fetcher.token: Access token issued", "expires", expires)
+	return oauthToken{value: body.AccessToken, expires: expires}, nil
+}
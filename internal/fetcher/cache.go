@@ -0,0 +1,241 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go-players-data/internal/logger"
+	"go-players-data/internal/storage"
+)
+
+// CacheStore keeps cached payloads: the object storage client, or a local directory created by NewDirCache.
+type CacheStore interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Put(ctx context.Context, key string, body []byte, contentType string) error
+}
+
+// cacheMeta is the validator of a cached payload, kept next to it.
+type cacheMeta struct {
+	FetchedAt    time.Time `json:"fetched_at"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+}
+
+// cachedFetcher is a Fetcher that reuses the payload of the HTTP fetcher while it is younger than the TTL,
+// and revalidates it with If-None-Match and If-Modified-Since once it is older.
+type cachedFetcher struct {
+	*fetcher
+	store CacheStore
+	key   string
+	ttl   time.Duration
+}
+
+// NewCached returns a copy of f that caches its payload in the store under prefix.
+// The payload is reused without a request while it is younger than ttl; a zero ttl revalidates it on every run,
+// so an unchanged report answered with 304 is not downloaded again. Fetchers not created by New are returned unchanged.
+func NewCached(f Fetcher, store CacheStore, prefix string, ttl time.Duration) Fetcher {
+	hf, ok := f.(*fetcher)
+	if !ok {
+		logger.Warn("fetcher.NewCached: Unsupported fetcher, payload will not be cached")
+		return f
+	}
+
+	// The key covers the API key as well, so sources sharing a URL never share a payload
+	sum := sha256.Sum256([]byte(hf.url.String() + "\n" + hf.token))
+
+	return &cachedFetcher{
+		fetcher: hf,
+		store:   store,
+		key:     prefix + hex.EncodeToString(sum[:8]),
+		ttl:     ttl,
+	}
+}
+
+// WithSince returns the uncached delta Fetcher, since a delta payload differs with every since.
+func (c *cachedFetcher) WithSince(since time.Time) Fetcher {
+	return c.fetcher.WithSince(since)
+}
+
+// Stream returns the cached or freshly fetched payload. The payload is held in memory to be cached.
+func (c *cachedFetcher) Stream(ctx context.Context) (io.ReadCloser, error) {
+	data, err := c.Data(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// Data returns the cached payload while it is fresh, and otherwise requests it conditionally.
+// A 304 answer renews the cached payload; cache failures fall back to a plain request.
+func (c *cachedFetcher) Data(ctx context.Context) ([]byte, error) {
+	start := time.Now()
+	defer func() { logger.Debug("fetcher.Data: Time spent", "time", time.Since(start).String()) }()
+
+	meta, body, err := c.load(ctx)
+	if err != nil {
+		logger.Warn("fetcher.Data: Failed to read cache", "err", err, "key", c.key)
+	}
+
+	if body != nil && c.ttl > 0 && time.Since(meta.FetchedAt) < c.ttl {
+		logger.Info("fetcher.Data: Cached payload reused", "key", c.key, "age", time.Since(meta.FetchedAt).String())
+		return body, nil
+	}
+
+	// Pages have no common validator, the joined pages are cached as a whole
+	if c.pageSize > 0 {
+		data, err := c.fetcher.Data(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.save(ctx, cacheMeta{FetchedAt: time.Now()}, data)
+		return data, nil
+	}
+
+	req, err := c.request(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		if meta.ETag != "" {
+			req.Header.Set("If-None-Match", meta.ETag)
+		}
+		if meta.LastModified != "" {
+			req.Header.Set("If-Modified-Since", meta.LastModified)
+		}
+	}
+
+	accepted := []int{http.StatusOK}
+	if body != nil {
+		accepted = append(accepted, http.StatusNotModified)
+	}
+
+	resp, err := c.do(req, accepted...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode == http.StatusNotModified {
+		logger.Info("fetcher.Data: Payload not modified, cache renewed", "key", c.key)
+		meta.FetchedAt = time.Now()
+		c.save(ctx, meta, nil)
+		return body, nil
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		logger.Error("fetcher.Data: Error reading response body", "err", err)
+		return nil, err
+	}
+
+	c.save(ctx, cacheMeta{
+		FetchedAt:    time.Now(),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}, data)
+
+	return data, nil
+}
+
+// Ping checks the upstream like the HTTP fetcher.
+func (c *cachedFetcher) Ping(ctx context.Context, target url.URL) error {
+	return c.fetcher.Ping(ctx, target)
+}
+
+// load reads the cached payload and its validator. A missing cache returns a nil body without an error.
+func (c *cachedFetcher) load(ctx context.Context) (cacheMeta, []byte, error) {
+	var meta cacheMeta
+
+	data, err := c.store.Get(ctx, c.key+".json")
+	if errors.Is(err, storage.ErrNotFound) {
+		return meta, nil, nil
+	}
+	if err != nil {
+		return meta, nil, err
+	}
+	if err = json.Unmarshal(data, &meta); err != nil {
+		return meta, nil, fmt.Errorf("failed to decode cache metadata: %w", err)
+	}
+
+	body, err := c.store.Get(ctx, c.key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return meta, nil, nil
+	}
+	if err != nil {
+		return meta, nil, err
+	}
+
+	return meta, body, nil
+}
+
+// save writes the payload, unless it is nil, and then its validator. Failures are logged, the run goes on.
+func (c *cachedFetcher) save(ctx context.Context, meta cacheMeta, body []byte) {
+	if body != nil {
+		if err := c.store.Put(ctx, c.key, body, "application/octet-stream"); err != nil {
+			logger.Warn("fetcher.save: Failed to cache payload", "err", err, "key", c.key)
+			return
+		}
+	}
+
+	data, err := json.Marshal(meta)
+	if err == nil {
+		err = c.store.Put(ctx, c.key+".json", data, "application/json")
+	}
+	if err != nil {
+		logger.Warn("fetcher.save: Failed to cache metadata", "err", err, "key", c.key)
+	}
+}
+
+// dirCache is a CacheStore keeping payloads as files of a local directory, such as /tmp of a warm function instance.
+type dirCache struct {
+	dir string
+}
+
+// NewDirCache creates a CacheStore in the directory, which is created on the first write.
+func NewDirCache(dir string) CacheStore {
+	return &dirCache{dir: dir}
+}
+
+// Get reads the file of the key, or returns storage.ErrNotFound when there is none.
+func (d *dirCache) Get(_ context.Context, key string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(d.dir, filepath.FromSlash(key)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, storage.ErrNotFound
+	}
+	return data, err
+}
+
+// Put writes the file of the key through a temporary file, so concurrent readers never see a partial payload.
+func (d *dirCache) Put(_ context.Context, key string, body []byte, _ string) error {
+	path := filepath.Join(d.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(body); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,83 @@
+package fetcher
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"go-players-data/internal/config"
+)
+
+// ErrNoCACerts is returned when the CA file of the data sources holds no PEM certificate.
+var ErrNoCACerts = errors.New("no certificate found in CA file")
+
+// NewClient creates the HTTP client of the data sources with the configured timeouts, proxy and TLS settings.
+// Without a proxy URL the HTTPS_PROXY and NO_PROXY environment variables apply, as with http.DefaultClient.
+func NewClient(cfg config.Data) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	dialer := &net.Dialer{Timeout: cfg.DialTimeout, KeepAlive: defaultKeepAlive}
+	transport.DialContext = dialer.DialContext
+	if cfg.DialTimeout > 0 {
+		transport.TLSHandshakeTimeout = cfg.DialTimeout
+	}
+
+	if cfg.ProxyURL.Host != "" {
+		proxy := cfg.ProxyURL
+		transport.Proxy = http.ProxyURL(&proxy)
+	}
+
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("fetcher.NewClient: %w", err)
+	}
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return &http.Client{Transport: transport, Timeout: cfg.RequestTimeout}, nil
+}
+
+// defaultKeepAlive is the keep-alive period of the connections, the one of http.DefaultTransport.
+const defaultKeepAlive = 30 * time.Second
+
+// newTLSConfig returns the TLS configuration of the data sources: extra root CAs, a client certificate
+// and skipping verification. Returns nil when none is configured, so the transport defaults apply.
+func newTLSConfig(cfg config.Data) (*tls.Config, error) {
+	if cfg.TLSCAFile == "" && cfg.TLSCertFile == "" && !cfg.TLSSkipVerify {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: cfg.TLSSkipVerify}
+
+	if cfg.TLSCAFile != "" {
+		pem, err := os.ReadFile(cfg.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%w: %s", ErrNoCACerts, cfg.TLSCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
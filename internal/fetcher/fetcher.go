@@ -4,80 +4,285 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"slices"
+	"sync/atomic"
 	"time"
 
+	"go-players-data/internal/config"
 	"go-players-data/internal/logger"
 )
 
 // Request represents the payload for requests that include an API key as a JSON field.
+// The API key is left out when the fetcher authorizes requests with an Auth.
+// Since asks a delta feed for the changes after the given time; Page and PageSize ask for a single page of the report.
 type Request struct {
-	APIKey string `json:"report_api_key"`
+	APIKey   string `json:"report_api_key,omitempty"`
+	Since    string `json:"since,omitempty"`
+	Page     int    `json:"page,omitempty"`
+	PageSize int    `json:"page_size,omitempty"`
 }
 
+// ErrTooLarge is returned when a response grows past the size limit of the fetcher.
+var ErrTooLarge = errors.New("response exceeds the size limit")
+
 // fetcher is a concrete implementation that fetches data from a URL using an HTTP client and an API token.
 // it includes the endpoint URL, authorization token, and a pointer to the HTTP client for request execution.
+// attempts counts the requests of the fetcher and its copies; maxBytes limits the response size, zero means no limit.
+// A page size above zero requests the report page by page, up to maxPages pages unless it is zero.
+// auth authorizes the requests; without it the token is sent in the request body.
 type fetcher struct {
-	url    url.URL
-	token  string
-	client *http.Client
+	url         url.URL
+	token       string
+	auth        Auth
+	since       time.Time
+	client      *http.Client
+	maxBytes    int64
+	pageSize    int
+	maxPages    int
+	pageTimeout time.Duration
+	attempts    *atomic.Int64
 }
 
 // Fetcher is an interface for retrieving data, requiring a method to get it with context handling for cancellations.
 type Fetcher interface {
 	Data(ctx context.Context) ([]byte, error)
+	Stream(ctx context.Context) (io.ReadCloser, error)
+	Ping(ctx context.Context, target url.URL) error
+	WithSince(since time.Time) Fetcher
 }
 
 // New creates a new Fetcher instance with the provided HTTP client, URL, and API key.
-func New(c *http.Client, u url.URL, token string) Fetcher {
+// Responses larger than cfg.MaxResponseBytes fail with ErrTooLarge; zero means no limit.
+// With cfg.PageSize set, the report is requested page by page, see Stream.
+// A nil auth sends the API key in the request body, see NewAuth for the other modes.
+func New(c *http.Client, u url.URL, token string, auth Auth, cfg config.Data) Fetcher {
 	return &fetcher{
-		url:    u,
-		token:  token,
-		client: c,
+		url:         u,
+		token:       token,
+		auth:        auth,
+		client:      c,
+		maxBytes:    cfg.MaxResponseBytes,
+		pageSize:    cfg.PageSize,
+		maxPages:    cfg.MaxPages,
+		pageTimeout: cfg.PageTimeout,
+		attempts:    &atomic.Int64{},
 	}
 }
 
-// Data fetches data from the configured URL with the API key in the Authorization header.
+// WithSince returns a copy of the Fetcher that requests only the changes after since from a delta feed.
+func (f *fetcher) WithSince(since time.Time) Fetcher {
+	c := *f
+	c.since = since
+	return &c
+}
+
+// Data fetches data from the configured URL with the API key in the request body.
 // Respects the provided context for cancellation and timeouts.
 func (f *fetcher) Data(ctx context.Context) ([]byte, error) {
 	start := time.Now()
-	defer func() { logger.Debug("fetcher.FetchData: Time spent", "time", time.Since(start).String()) }()
+	defer func() { logger.Debug("fetcher.Data: Time spent", "time", time.Since(start).String()) }()
 
-	data, err := json.Marshal(Request{
-		APIKey: f.token,
-	})
+	resp, err := f.Stream(ctx)
 	if err != nil {
-		logger.Error("fetcher.FetchData: Error marshaling request", "err", err)
 		return nil, err
 	}
+	defer func() { _ = resp.Close() }()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.url.String(), bytes.NewBuffer(data))
+	body, err := io.ReadAll(resp)
 	if err != nil {
-		logger.Error("fetcher.FetchData: Error creating request", "err", err)
+		logger.Error("fetcher.Data: Error reading response body", "err", err)
 		return nil, err
 	}
-	req.Header.Set("Content-Type", "application/json")
+
+	return body, nil
+}
+
+// Stream sends the same request as Data but returns the response body unread, so the payload can be decoded
+// while it is being received. The caller must close the returned body.
+// With a page size, the pages are requested one after another and joined into a single JSON array.
+func (f *fetcher) Stream(ctx context.Context) (io.ReadCloser, error) {
+	if f.pageSize > 0 {
+		return f.pages(ctx), nil
+	}
+
+	req, err := f.request(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := f.do(req, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+// do sends the request and checks that the status is one of accepted. Failed requests and other statuses
+// return a RequestError carrying the URL, the attempt number and the time spent; the body of other statuses is closed.
+// The body of an accepted response fails with ErrTooLarge once it grows past the size limit.
+func (f *fetcher) do(req *http.Request, accepted ...int) (*http.Response, error) {
+	attempt := f.attempts.Add(1)
+	start := time.Now()
+
+	fail := func(err error) error {
+		return &RequestError{URL: req.URL.Redacted(), Attempt: attempt, Elapsed: time.Since(start), Err: err}
+	}
 
 	resp, err := f.client.Do(req)
 	if err != nil {
-		logger.Error("fetcher.FetchData: Error sending request", "err", err)
+		logger.Error("fetcher.do: Error sending request", "err", err, "url", req.URL.Redacted(), "attempt", attempt)
+		return nil, fail(err)
+	}
+
+	if !slices.Contains(accepted, resp.StatusCode) {
+		_ = resp.Body.Close()
+		if resp.StatusCode == http.StatusUnauthorized {
+			f.invalidate()
+		}
+		logger.Error("fetcher.do: Invalid status code", "statusCode", resp.StatusCode, "url", req.URL.Redacted(), "attempt", attempt)
+		return nil, fail(&HTTPError{Code: resp.StatusCode})
+	}
+
+	if f.maxBytes > 0 {
+		if resp.ContentLength > f.maxBytes {
+			_ = resp.Body.Close()
+			logger.Error("fetcher.do: Response too large", "bytes", resp.ContentLength, "limit", f.maxBytes)
+			return nil, fail(ErrTooLarge)
+		}
+		resp.Body = &limitedBody{body: resp.Body, left: f.maxBytes}
 	}
-	defer func() { _ = resp.Body.Close() }()
 
-	if resp.StatusCode != http.StatusOK {
-		logger.Error("fetcher.FetchData: Invalid status code", "statusCode", resp.StatusCode)
-		return nil, &HTTPError{Code: resp.StatusCode}
+	return resp, nil
+}
+
+// Ping sends a lightweight HEAD request to the target, or to the data URL when the target is empty,
+// to check that the upstream is reachable before the heavy report request.
+// Any answer below 500 counts as healthy, since report endpoints often reject HEAD with 405.
+func (f *fetcher) Ping(ctx context.Context, target url.URL) error {
+	if target.Host == "" {
+		target = f.url
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, target.String(), nil)
 	if err != nil {
-		logger.Error("fetcher.FetchData: Error reading response body", "err", err)
+		return fmt.Errorf("fetcher.Ping: failed to create request: %w", err)
+	}
+	if f.auth != nil {
+		if err = f.auth.Authorize(ctx, req); err != nil {
+			return fmt.Errorf("fetcher.Ping: %w", err)
+		}
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetcher.Ping: failed to send request: %w", err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("fetcher.Ping: %w", &HTTPError{Code: resp.StatusCode})
+	}
+
+	return nil
+}
+
+// request builds the POST request carrying the API key in the JSON body, or authorized by the Auth of the fetcher,
+// asking for the page when it is above zero.
+func (f *fetcher) request(ctx context.Context, page int) (*http.Request, error) {
+	var r Request
+	if f.auth == nil {
+		r.APIKey = f.token
+	}
+	if !f.since.IsZero() {
+		r.Since = f.since.UTC().Format(time.DateTime)
+	}
+	if page > 0 {
+		r.Page, r.PageSize = page, f.pageSize
+	}
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		logger.Error("fetcher.request: Error marshaling request", "err", err)
 		return nil, err
 	}
 
-	return body, nil
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.url.String(), bytes.NewBuffer(data))
+	if err != nil {
+		logger.Error("fetcher.request: Error creating request", "err", err)
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if f.auth != nil {
+		if err = f.auth.Authorize(ctx, req); err != nil {
+			logger.Error("fetcher.request: Error authorizing request", "err", err)
+			return nil, err
+		}
+	}
+
+	return req, nil
+}
+
+// invalidate drops the credentials of an Auth that can renew them, such as an OAuth2 token the source rejected.
+func (f *fetcher) invalidate() {
+	if a, ok := f.auth.(interface{ Invalidate() }); ok {
+		a.Invalidate()
+	}
+}
+
+// RequestError is a failed request to the data source with its context.
+type RequestError struct {
+	URL     string
+	Attempt int64 // Number of the request among the requests of the fetcher
+	Elapsed time.Duration
+	Err     error
+}
+
+// Error returns the failure with the URL, the attempt and the time spent.
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("fetcher: request %d to %s failed after %s: %v", e.Attempt, e.URL, e.Elapsed.Round(time.Millisecond), e.Err)
+}
+
+// Unwrap returns the cause of the failure.
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// limitedBody is a response body failing with ErrTooLarge once more than left bytes are read,
+// so an oversized payload is an error instead of being silently truncated.
+type limitedBody struct {
+	body io.ReadCloser
+	left int64
+}
+
+// Read reads from the body until the limit is reached, then reports ErrTooLarge if the body has more.
+func (l *limitedBody) Read(p []byte) (int, error) {
+	if l.left <= 0 {
+		var probe [1]byte
+		n, err := l.body.Read(probe[:])
+		if n > 0 {
+			return 0, ErrTooLarge
+		}
+		return 0, err
+	}
+
+	if int64(len(p)) > l.left {
+		p = p[:l.left]
+	}
+	n, err := l.body.Read(p)
+	l.left -= int64(n)
+	return n, err
+}
+
+// Close closes the body.
+func (l *limitedBody) Close() error {
+	return l.body.Close()
 }
 
 // HTTPError represents an error response from an HTTP request with a specific status code.
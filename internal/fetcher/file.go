@@ -0,0 +1,84 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"time"
+
+	"go-players-data/internal/logger"
+)
+
+// Stdin is the file path that reads the payload from standard input.
+const Stdin = "-"
+
+// fileFetcher is a Fetcher that reads a saved payload from a local file or standard input instead of the API,
+// for local development without network access or an API key.
+type fileFetcher struct {
+	path string
+}
+
+// NewFile creates a new Fetcher reading the payload from the file at path, or from standard input when path is Stdin.
+func NewFile(path string) Fetcher {
+	return &fileFetcher{path: path}
+}
+
+// WithSince returns the Fetcher unchanged, since a saved payload cannot be asked for changes.
+func (f *fileFetcher) WithSince(time.Time) Fetcher {
+	logger.Warn("fetcher.WithSince: File payloads are read whole, delta requests are ignored", "path", f.path)
+	return f
+}
+
+// Data reads the whole payload.
+func (f *fileFetcher) Data(ctx context.Context) ([]byte, error) {
+	start := time.Now()
+	defer func() { logger.Debug("fetcher.Data: Time spent", "time", time.Since(start).String()) }()
+
+	r, err := f.Stream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = r.Close() }()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		logger.Error("fetcher.Data: Error reading file", "err", err, "path", f.path)
+		return nil, fmt.Errorf("fetcher.Data: %w", err)
+	}
+
+	return data, nil
+}
+
+// Stream opens the payload for reading. Standard input is never closed by the returned reader.
+func (f *fileFetcher) Stream(ctx context.Context) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if f.path == Stdin {
+		return io.NopCloser(os.Stdin), nil
+	}
+
+	file, err := os.Open(f.path)
+	if err != nil {
+		logger.Error("fetcher.Stream: Error opening file", "err", err, "path", f.path)
+		return nil, fmt.Errorf("fetcher.Stream: %w", err)
+	}
+
+	return file, nil
+}
+
+// Ping checks that the file exists; standard input is always available.
+func (f *fileFetcher) Ping(_ context.Context, _ url.URL) error {
+	if f.path == Stdin {
+		return nil
+	}
+
+	if _, err := os.Stat(f.path); err != nil {
+		return fmt.Errorf("fetcher.Ping: %w", err)
+	}
+
+	return nil
+}
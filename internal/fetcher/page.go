@@ -0,0 +1,95 @@
+package fetcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"go-players-data/internal/logger"
+)
+
+// pages streams the pages of the report as a single JSON array, holding a single page in memory at a time.
+// Closing the returned reader stops the requests after the current page.
+func (f *fetcher) pages(ctx context.Context) io.ReadCloser {
+	r, w := io.Pipe()
+	go func() { _ = w.CloseWithError(f.writePages(ctx, w)) }()
+	return r
+}
+
+// writePages writes the rows of every page to w until a page is shorter than the page size
+// or the maximum number of pages is reached. Pages must be JSON arrays.
+func (f *fetcher) writePages(ctx context.Context, w io.Writer) error {
+	start := time.Now()
+	defer func() { logger.Debug("fetcher.pages: Time spent", "time", time.Since(start).String()) }()
+
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+
+	rows := 0
+	for page := 1; ; page++ {
+		if f.maxPages > 0 && page > f.maxPages {
+			logger.Warn("fetcher.pages: Page limit reached, the report may be incomplete", "pages", f.maxPages, "rows", rows)
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		batch, err := f.page(ctx, page)
+		if err != nil {
+			return fmt.Errorf("fetcher.pages: page %d: %w", page, err)
+		}
+
+		for _, row := range batch {
+			if rows > 0 {
+				if _, err = io.WriteString(w, ","); err != nil {
+					return err
+				}
+			}
+			if _, err = w.Write(row); err != nil {
+				return err
+			}
+			rows++
+		}
+
+		logger.Debug("fetcher.pages: Page fetched", "page", page, "rows", len(batch))
+		if len(batch) < f.pageSize {
+			break
+		}
+	}
+
+	logger.Info("fetcher.pages: Report fetched", "rows", rows)
+	_, err := io.WriteString(w, "]")
+	return err
+}
+
+// page requests a single page, bounded by the page timeout, and returns its rows undecoded.
+func (f *fetcher) page(ctx context.Context, page int) ([]json.RawMessage, error) {
+	if f.pageTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.pageTimeout)
+		defer cancel()
+	}
+
+	req, err := f.request(ctx, page)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := f.do(req, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var rows []json.RawMessage
+	if err = json.NewDecoder(resp.Body).Decode(&rows); err != nil {
+		return nil, fmt.Errorf("failed to decode page: %w", err)
+	}
+
+	return rows, nil
+}
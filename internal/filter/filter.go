@@ -8,28 +8,67 @@ import (
 	"go-players-data/internal/model"
 )
 
+// set is a string set used for constant-time lookups.
+type set map[string]struct{}
+
+// criteria is a struct that leaves out players matching any of its rules.
+// minVersion is kept besides the rules, so outdated players are counted even when the min_version rule is not selected.
 type criteria struct {
-	ignoredGroups    []string
-	allowedCompanies []string
-	maxOffline       time.Duration
+	rules      []Rule
+	minVersion *model.Version
 }
 
 // Criteria defines an interface for filtering a slice of Player objects based on specific conditions.
 // The Filter method returns a filtered list of players and an error if any issues are encountered during the operation.
 type Criteria interface {
 	Filter(players []*model.Player) ([]*model.Player, error)
+	Keep(p *model.Player) bool
+	KeepAt(p *model.Player, at time.Time) bool
+	Outdated(p *model.Player) bool
 }
 
-// New creates a new Filter instance with the specified criteria.
-func New(ignoredGroups []string, allowedCompanies []string, maxOffline time.Duration) Criteria {
+// New creates a new Filter instance from the rules, evaluated in order.
+// minVersion may be nil; otherwise players with a parsable version older than it are reported by Outdated.
+func New(rules []Rule, minVersion *model.Version) Criteria {
 	return &criteria{
-		ignoredGroups:    ignoredGroups,
-		allowedCompanies: allowedCompanies,
-		maxOffline:       maxOffline,
+		rules:      rules,
+		minVersion: minVersion,
+	}
+}
+
+// newSet builds a set of the values, lower-cased when fold is set.
+func newSet(values []string, fold bool) set {
+	s := make(set, len(values))
+	for _, v := range values {
+		if fold {
+			v = strings.ToLower(v)
+		}
+		s[v] = struct{}{}
+	}
+	return s
+}
+
+// newSubtrees parses group patterns like "Retail/Closed" or "Retail/Closed/*" into group path prefixes,
+// lower-cased when fold is set. Both forms match the group itself and everything under it.
+func newSubtrees(patterns []string, fold bool) [][]string {
+	subtrees := make([][]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		if fold {
+			pattern = strings.ToLower(pattern)
+		}
+
+		path := model.ParseGroupPath(pattern)
+		if len(path) > 0 && path[len(path)-1] == "*" {
+			path = path[:len(path)-1]
+		}
+		if len(path) > 0 {
+			subtrees = append(subtrees, path)
+		}
 	}
+	return subtrees
 }
 
-// Filter filters players based on offline duration, group, and company criteria.
+// Filter filters players based on the rules.
 // Returns a slice of players that meet the conditions.
 func (c *criteria) Filter(players []*model.Player) ([]*model.Player, error) {
 	start := time.Now()
@@ -38,7 +77,7 @@ func (c *criteria) Filter(players []*model.Player) ([]*model.Player, error) {
 	var filteredPlayers []*model.Player
 
 	for _, p := range players {
-		if c.isIgnored(p) {
+		if !c.Keep(p) {
 			continue
 		}
 
@@ -49,41 +88,34 @@ func (c *criteria) Filter(players []*model.Player) ([]*model.Player, error) {
 	return filteredPlayers, nil
 }
 
-// isIgnored determines if a player should be ignored based on group, company, and offline duration criteria.
-func (c *criteria) isIgnored(p *model.Player) bool {
-	groupName := c.extractGroupName(p)
-
-	if c.stringInSlice(c.ignoredGroups, groupName) {
-		return true
-	}
-
-	if !c.stringInSlice(c.allowedCompanies, p.CompanyName) {
-		return true
-	}
-
-	if c.hoursDelta(p.LastOnline) <= c.maxOffline.Hours() {
-		return true
-	}
-
-	return false
+// Keep reports whether a single player passes the criteria, for filtering players as they are streamed.
+func (c *criteria) Keep(p *model.Player) bool {
+	return !c.isIgnored(p, time.Now())
 }
 
-// extractGroupName extracts and returns the first segment of the GroupName field in the provided Player struct.
-func (c *criteria) extractGroupName(player *model.Player) string {
-	return strings.Split(player.GroupName, "/")[0]
+// KeepAt reports whether a single player passes the criteria with the offline duration measured at the time,
+// for filtering players of an archived payload as they were when it was fetched.
+func (c *criteria) KeepAt(p *model.Player, at time.Time) bool {
+	return !c.isIgnored(p, at)
 }
 
-// stringInSlice checks if a given string exists within a slice of strings, returning true if found, otherwise false.
-func (c *criteria) stringInSlice(slice []string, s string) bool {
-	for _, v := range slice {
-		if v == s {
+// isIgnored reports whether any rule leaves the player out at the time.
+func (c *criteria) isIgnored(p *model.Player, now time.Time) bool {
+	for _, rule := range c.rules {
+		if rule.Ignore(p, now) {
 			return true
 		}
 	}
 	return false
 }
 
-// hoursDelta calculates the difference in hours between the current time and the provided time t.
-func (c *criteria) hoursDelta(t time.Time) float64 {
-	return time.Since(t).Hours()
+// Outdated reports whether the player runs a version older than the minimum version.
+// Players with an unparsable version are never outdated.
+func (c *criteria) Outdated(p *model.Player) bool {
+	if c.minVersion == nil {
+		return false
+	}
+
+	v, ok := p.ParsedVersion()
+	return ok && v.Less(*c.minVersion)
 }
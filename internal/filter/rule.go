@@ -0,0 +1,310 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go-players-data/internal/config"
+	"go-players-data/internal/model"
+	"go-players-data/internal/schedule"
+)
+
+// Names of the built-in rules, in their default evaluation order.
+const (
+	RuleIgnoredGroup   = "ignored_group"
+	RuleIgnoredTag     = "ignored_tag"
+	RuleAllowedCompany = "allowed_company"
+	RuleMinVersion     = "min_version"
+	RuleMaxOffline     = "max_offline"
+	RuleBusinessHours  = "business_hours"
+)
+
+// Rule is a single filter criterion. Ignore reports whether the player is left out at now.
+type Rule interface {
+	Name() string
+	Ignore(p *model.Player, now time.Time) bool
+}
+
+// Options holds what rules are built from: the data configuration with its parsed schedules and minimum version.
+// Schedules and MinVersion may be nil.
+type Options struct {
+	Data       config.Data
+	Schedules  schedule.Registry
+	MinVersion *model.Version
+}
+
+// Factory creates a rule from the options, or returns nil when the options leave the rule out.
+type Factory func(opts Options) Rule
+
+// registry is a struct that keeps the rule factories in registration order.
+type registry struct {
+	names     []string
+	factories map[string]Factory
+}
+
+// Registry is an interface for registering rule factories and building the rules selected by DATA_RULES.
+type Registry interface {
+	Register(name string, f Factory)
+	Build(opts Options) ([]Rule, error)
+	Names() []string
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() Registry {
+	return &registry{
+		factories: make(map[string]Factory),
+	}
+}
+
+// Default creates a Registry of the built-in rules: ignored_group, ignored_tag, allowed_company, min_version, max_offline
+// and business_hours.
+func Default() Registry {
+	r := NewRegistry()
+
+	r.Register(RuleIgnoredGroup, func(opts Options) Rule {
+		return IgnoredGroup(opts.Data.IgnoredGroups, opts.Data.CaseInsensitive)
+	})
+	r.Register(RuleIgnoredTag, func(opts Options) Rule {
+		if len(opts.Data.IgnoredTags) == 0 {
+			return nil
+		}
+		return IgnoredTag(opts.Data.IgnoredTags, opts.Data.CaseInsensitive)
+	})
+	r.Register(RuleAllowedCompany, func(opts Options) Rule {
+		return AllowedCompany(opts.Data.AllowedCompanies, opts.Data.CaseInsensitive)
+	})
+	r.Register(RuleMinVersion, func(opts Options) Rule {
+		if opts.MinVersion == nil {
+			return nil
+		}
+		return MinVersion(*opts.MinVersion)
+	})
+	r.Register(RuleMaxOffline, func(opts Options) Rule {
+		return MaxOffline(opts.Data.MaxOffline, opts.Schedules)
+	})
+	r.Register(RuleBusinessHours, func(opts Options) Rule {
+		if !opts.Data.BusinessHours || opts.Schedules == nil {
+			return nil
+		}
+		return BusinessHours(opts.Schedules)
+	})
+
+	return r
+}
+
+// Register adds the factory under name. Registering a name again replaces its factory and keeps its position.
+func (r *registry) Register(name string, f Factory) {
+	if _, ok := r.factories[name]; !ok {
+		r.names = append(r.names, name)
+	}
+	r.factories[name] = f
+}
+
+// Build creates the rules named in DATA_RULES in that order, or every registered rule in registration order
+// when it is empty. Returns an error for an unknown rule name.
+func (r *registry) Build(opts Options) ([]Rule, error) {
+	names := opts.Data.Rules
+	if len(names) == 0 {
+		names = r.names
+	}
+
+	rules := make([]Rule, 0, len(names))
+	for _, name := range names {
+		f, ok := r.factories[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("filter.Build: unknown rule %q, registered: %s", name, strings.Join(r.names, ", "))
+		}
+		if rule := f(opts); rule != nil {
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules, nil
+}
+
+// Names returns the registered rule names in registration order.
+func (r *registry) Names() []string {
+	return append([]string(nil), r.names...)
+}
+
+// ignoredGroup is a rule leaving out players in any of the ignored group subtrees.
+type ignoredGroup struct {
+	subtrees [][]string
+	fold     bool
+}
+
+// IgnoredGroup creates a rule leaving out players whose group lies within any of the patterns,
+// like "Retail/Closed" or "Retail/Closed/*", compared regardless of case when fold is set.
+func IgnoredGroup(patterns []string, fold bool) Rule {
+	return &ignoredGroup{subtrees: newSubtrees(patterns, fold), fold: fold}
+}
+
+// Name returns the rule name.
+func (r *ignoredGroup) Name() string {
+	return RuleIgnoredGroup
+}
+
+// Ignore reports whether the group path of the player lies within any of the ignored group subtrees.
+func (r *ignoredGroup) Ignore(p *model.Player, _ time.Time) bool {
+	path := p.Path()
+	for _, subtree := range r.subtrees {
+		if len(path) < len(subtree) {
+			continue
+		}
+
+		matched := true
+		for i, segment := range subtree {
+			if !equal(path[i], segment, r.fold) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoredTag is a rule leaving out players carrying any of the ignored tags.
+type ignoredTag struct {
+	tags set
+	fold bool
+}
+
+// IgnoredTag creates a rule leaving out players carrying any of the tags, e.g. "decommissioned" or "lab",
+// compared regardless of case when fold is set.
+func IgnoredTag(tags []string, fold bool) Rule {
+	return &ignoredTag{tags: newSet(tags, fold), fold: fold}
+}
+
+// Name returns the rule name.
+func (r *ignoredTag) Name() string {
+	return RuleIgnoredTag
+}
+
+// Ignore reports whether the player carries any of the ignored tags.
+func (r *ignoredTag) Ignore(p *model.Player, _ time.Time) bool {
+	for _, tag := range p.Tags {
+		if inSet(r.tags, strings.TrimSpace(tag), r.fold) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedCompany is a rule leaving out players of companies that are not allowed.
+type allowedCompany struct {
+	companies set
+	fold      bool
+}
+
+// AllowedCompany creates a rule leaving out players of every company missing from companies,
+// compared regardless of case when fold is set.
+func AllowedCompany(companies []string, fold bool) Rule {
+	return &allowedCompany{companies: newSet(companies, fold), fold: fold}
+}
+
+// Name returns the rule name.
+func (r *allowedCompany) Name() string {
+	return RuleAllowedCompany
+}
+
+// Ignore reports whether the company of the player is not allowed.
+func (r *allowedCompany) Ignore(p *model.Player, _ time.Time) bool {
+	return !inSet(r.companies, p.CompanyName, r.fold)
+}
+
+// minVersion is a rule leaving out players on versions older than the minimum.
+type minVersion struct {
+	version model.Version
+}
+
+// MinVersion creates a rule leaving out players with a parsable version older than v.
+func MinVersion(v model.Version) Rule {
+	return &minVersion{version: v}
+}
+
+// Name returns the rule name.
+func (r *minVersion) Name() string {
+	return RuleMinVersion
+}
+
+// Ignore reports whether the player runs a version older than the minimum version.
+// Players with an unparsable version are never outdated.
+func (r *minVersion) Ignore(p *model.Player, _ time.Time) bool {
+	v, ok := p.ParsedVersion()
+	return ok && v.Less(r.version)
+}
+
+// maxOffline is a rule leaving out players that have not been offline for long.
+type maxOffline struct {
+	max       time.Duration
+	schedules schedule.Registry
+}
+
+// MaxOffline creates a rule leaving out players offline for no longer than maxDuration.
+// schedules may be nil, in which case the offline time of every player is counted around the clock.
+func MaxOffline(maxDuration time.Duration, schedules schedule.Registry) Rule {
+	return &maxOffline{max: maxDuration, schedules: schedules}
+}
+
+// Name returns the rule name.
+func (r *maxOffline) Name() string {
+	return RuleMaxOffline
+}
+
+// Ignore reports whether the player has been offline at now for no longer than the maximum.
+func (r *maxOffline) Ignore(p *model.Player, now time.Time) bool {
+	return r.offline(p, now) <= r.max
+}
+
+// offline returns how long the player has been offline at the time.
+// For players on a known schedule only the time within its operating hours counts,
+// so players switched off while the store is closed are not reported.
+func (r *maxOffline) offline(p *model.Player, now time.Time) time.Duration {
+	if r.schedules == nil {
+		return now.Sub(p.LastOnline)
+	}
+
+	return r.schedules.Offline(p.ScheduleName, p.TimeZoneDiff, p.LastOnline, now)
+}
+
+// businessHours is a rule leaving out players whose store is closed.
+type businessHours struct {
+	schedules schedule.Registry
+}
+
+// BusinessHours creates a rule leaving out players whose store is closed at the time in their local time,
+// e.g. players legitimately powered off overnight, so they are not alerted about until the store opens.
+func BusinessHours(schedules schedule.Registry) Rule {
+	return &businessHours{schedules: schedules}
+}
+
+// Name returns the rule name.
+func (r *businessHours) Name() string {
+	return RuleBusinessHours
+}
+
+// Ignore reports whether the store of the player is closed at now.
+func (r *businessHours) Ignore(p *model.Player, now time.Time) bool {
+	return !r.schedules.Open(p.ScheduleName, p.TimeZoneDiff, now)
+}
+
+// equal compares a group path segment with a pattern segment, folding its case when fold is set.
+func equal(segment, pattern string, fold bool) bool {
+	if fold {
+		segment = strings.ToLower(segment)
+	}
+	return segment == pattern
+}
+
+// inSet checks if a given string exists within a set, folding its case when fold is set.
+func inSet(s set, v string, fold bool) bool {
+	if fold {
+		v = strings.ToLower(v)
+	}
+	_, ok := s[v]
+	return ok
+}
@@ -0,0 +1,181 @@
+package gchat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-players-data/internal/cluster"
+	"go-players-data/internal/config"
+	"go-players-data/internal/logger"
+	"go-players-data/internal/model"
+)
+
+// maxWidgets limits the players listed in a single card to keep messages readable.
+const maxWidgets = 50
+
+// message is a Google Chat webhook message with a single v2 card.
+type message struct {
+	CardsV2 []cardWithID `json:"cardsV2"`
+}
+
+type cardWithID struct {
+	CardID string `json:"cardId"`
+	Card   card   `json:"card"`
+}
+
+type card struct {
+	Header   header    `json:"header"`
+	Sections []section `json:"sections"`
+}
+
+type header struct {
+	Title    string `json:"title"`
+	Subtitle string `json:"subtitle,omitempty"`
+}
+
+type section struct {
+	Header  string   `json:"header,omitempty"`
+	Widgets []widget `json:"widgets"`
+}
+
+type widget struct {
+	DecoratedText *decoratedText `json:"decoratedText,omitempty"`
+	TextParagraph *textParagraph `json:"textParagraph,omitempty"`
+}
+
+type decoratedText struct {
+	TopLabel    string `json:"topLabel,omitempty"`
+	Text        string `json:"text"`
+	BottomLabel string `json:"bottomLabel,omitempty"`
+}
+
+type textParagraph struct {
+	Text string `json:"text"`
+}
+
+// notifier is a struct that posts card-formatted cluster summaries to Google Chat spaces via incoming webhooks.
+type notifier struct {
+	client     *http.Client
+	webhook    string
+	byCompany  map[string]string
+	storeNames map[int]string
+}
+
+// Notifier defines an interface for sending Google Chat notifications to players grouped by store number.
+type Notifier interface {
+	Send(ctx context.Context, storeNumber int, players []*model.Player) error
+}
+
+// New creates a new Notifier routing clusters to the space of their company, or to the default space.
+func New(c *http.Client, cfg config.GChat, storeNames map[int]string) Notifier {
+	return &notifier{
+		client:     c,
+		webhook:    cfg.WebhookURL,
+		byCompany:  cfg.WebhooksByCompany,
+		storeNames: storeNames,
+	}
+}
+
+// Send posts a card summarizing the offline players of the store.
+// Clusters of companies without a space and without a default webhook are skipped.
+func (n *notifier) Send(ctx context.Context, storeNumber int, players []*model.Player) error {
+	start := time.Now()
+	defer func() { logger.Debug("gchat.Send: Time spent", "time", time.Since(start).String()) }()
+
+	webhook := n.route(storeNumber, players)
+	if webhook == "" {
+		logger.Debug("gchat.Send: No space for cluster", "cluster", storeNumber)
+		return nil
+	}
+
+	data, err := json.Marshal(n.message(storeNumber, players))
+	if err != nil {
+		return fmt.Errorf("gchat.Send: failed to build message: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("gchat.Send: failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("gchat.Send: failed to send message: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		logger.Error("gchat.Send: Invalid status code", "statusCode", resp.StatusCode, "body", string(msg))
+		return &HTTPError{Code: resp.StatusCode}
+	}
+
+	return nil
+}
+
+// route returns the webhook of the cluster company, falling back to the default webhook.
+func (n *notifier) route(storeNumber int, players []*model.Player) string {
+	if company, ok := cluster.Company(storeNumber, players); ok {
+		if webhook, ok := n.byCompany[company]; ok {
+			return webhook
+		}
+	}
+	return n.webhook
+}
+
+// message builds a card with one widget per offline player.
+func (n *notifier) message(storeNumber int, players []*model.Player) *message {
+	storeID := strconv.Itoa(storeNumber)
+	if name := n.storeNames[storeNumber]; name != "" {
+		storeID = name
+	}
+
+	var company string
+	if len(players) > 0 {
+		company = players[0].CompanyName
+	}
+
+	widgets := make([]widget, 0, min(len(players), maxWidgets)+1)
+	for i, p := range players {
+		if i == maxWidgets {
+			widgets = append(widgets, widget{TextParagraph: &textParagraph{
+				Text: fmt.Sprintf("…and %d more", len(players)-maxWidgets),
+			}})
+			break
+		}
+
+		widgets = append(widgets, widget{DecoratedText: &decoratedText{
+			TopLabel:    p.Type,
+			Text:        p.PlayerName,
+			BottomLabel: fmt.Sprintf("Last online %s · IP %s · MAC %s", p.LastOnline.Format(time.DateTime), p.Addresses(), p.MAC),
+		}})
+	}
+
+	return &message{CardsV2: []cardWithID{{
+		CardID: "store-" + strconv.Itoa(storeNumber),
+		Card: card{
+			Header: header{
+				Title:    fmt.Sprintf("Store %s: %d players offline", storeID, len(players)),
+				Subtitle: company,
+			},
+			Sections: []section{{Widgets: widgets}},
+		},
+	}}}
+}
+
+// HTTPError represents an error response from Google Chat with a specific status code.
+type HTTPError struct {
+	Code int
+}
+
+// Error returns the text representation of the HTTP status code associated with the HTTPError.
+func (e *HTTPError) Error() string {
+	return http.StatusText(e.Code)
+}
@@ -0,0 +1,241 @@
+package grafana
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"go-players-data/internal/logger"
+	"go-players-data/internal/snapshot"
+)
+
+// Metrics exposed to the datasource.
+const (
+	MetricOfflineTotal     = "offline_total"
+	MetricOfflineByStore   = "offline_by_store"
+	MetricOfflineByCompany = "offline_by_company"
+)
+
+// metricOption is a single entry of the /metrics response.
+type metricOption struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+// queryRequest is the subset of the JSON datasource /query request used by the handler.
+type queryRequest struct {
+	Range struct {
+		From time.Time `json:"from"`
+		To   time.Time `json:"to"`
+	} `json:"range"`
+	Targets []struct {
+		Target string `json:"target"`
+		RefID  string `json:"refId"`
+		Type   string `json:"type"`
+	} `json:"targets"`
+}
+
+// timeSeries is a single series of the /query response; datapoints are [value, unix milliseconds] pairs.
+type timeSeries struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+// column describes a column of a table response.
+type column struct {
+	Text string `json:"text"`
+	Type string `json:"type"`
+}
+
+// table is a table entry of the /query response.
+type table struct {
+	Type    string          `json:"type"`
+	Columns []column        `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+// currentCounts is the /offline response for the Infinity datasource.
+type currentCounts struct {
+	RunID     string         `json:"run_id"`
+	TakenAt   time.Time      `json:"taken_at"`
+	Total     int            `json:"total"`
+	Offline   int            `json:"offline"`
+	ByStore   map[string]int `json:"by_store"`
+	ByCompany map[string]int `json:"by_company"`
+}
+
+// handler serves Grafana JSON datasource and Infinity datasource endpoints over the snapshot store.
+type handler struct {
+	store snapshot.Store
+}
+
+// New creates an http.Handler exposing the datasource endpoints relative to its mount point:
+// GET / (health check), POST /metrics, POST /query, and GET /offline with the current counts.
+func New(store snapshot.Store) http.Handler {
+	h := &handler{store: store}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", h.health)
+	mux.HandleFunc("/metrics", h.metrics)
+	mux.HandleFunc("/query", h.query)
+	mux.HandleFunc("/offline", h.offline)
+
+	return mux
+}
+
+// health answers the datasource connection test.
+func (h *handler) health(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// metrics lists the metrics available for queries.
+func (h *handler) metrics(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, []metricOption{
+		{Label: "Offline players", Value: MetricOfflineTotal},
+		{Label: "Offline players by store", Value: MetricOfflineByStore},
+		{Label: "Offline players by company", Value: MetricOfflineByCompany},
+	})
+}
+
+// query returns time series over the requested range, or a table of the current counts for table targets.
+func (h *handler) query(w http.ResponseWriter, r *http.Request) {
+	var req queryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	snapshots := h.store.Range(req.Range.From, req.Range.To)
+	last, hasLast := h.store.Last()
+
+	var res []interface{}
+	for _, t := range req.Targets {
+		if t.Type == "table" {
+			if hasLast {
+				res = append(res, currentTable(t.Target, last))
+			}
+			continue
+		}
+
+		for _, ts := range series(t.Target, snapshots) {
+			res = append(res, ts)
+		}
+	}
+
+	if res == nil {
+		res = []interface{}{}
+	}
+	writeJSON(w, res)
+}
+
+// offline returns the current offline counts from the last snapshot.
+func (h *handler) offline(w http.ResponseWriter, _ *http.Request) {
+	last, ok := h.store.Last()
+	if !ok {
+		http.Error(w, "no snapshot yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	writeJSON(w, currentCounts{
+		RunID:     last.RunID,
+		TakenAt:   last.TakenAt,
+		Total:     last.Total,
+		Offline:   len(last.Players),
+		ByStore:   group(MetricOfflineByStore, last),
+		ByCompany: group(MetricOfflineByCompany, last),
+	})
+}
+
+// series builds time series for a metric, one datapoint per snapshot.
+// Grouped metrics produce one series per store or company.
+func series(metric string, snapshots []*snapshot.Snapshot) []*timeSeries {
+	byTarget := make(map[string]*timeSeries)
+
+	for _, snap := range snapshots {
+		ts := float64(snap.TakenAt.UnixMilli())
+
+		if metric == MetricOfflineTotal {
+			add(byTarget, MetricOfflineTotal, float64(len(snap.Players)), ts)
+			continue
+		}
+
+		for key, n := range group(metric, snap) {
+			add(byTarget, key, float64(n), ts)
+		}
+	}
+
+	res := make([]*timeSeries, 0, len(byTarget))
+	for _, s := range byTarget {
+		res = append(res, s)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Target < res[j].Target })
+
+	return res
+}
+
+// add appends a datapoint to the series of the target, creating it if necessary.
+func add(byTarget map[string]*timeSeries, target string, value, ts float64) {
+	s, ok := byTarget[target]
+	if !ok {
+		s = &timeSeries{Target: target, Datapoints: [][2]float64{}}
+		byTarget[target] = s
+	}
+	s.Datapoints = append(s.Datapoints, [2]float64{value, ts})
+}
+
+// currentTable renders the grouped counts of the last snapshot as a table.
+func currentTable(metric string, last *snapshot.Snapshot) *table {
+	t := &table{
+		Type:    "table",
+		Columns: []column{{Text: "key", Type: "string"}, {Text: "offline", Type: "number"}},
+		Rows:    [][]interface{}{},
+	}
+
+	if metric == MetricOfflineTotal {
+		t.Rows = append(t.Rows, []interface{}{MetricOfflineTotal, len(last.Players)})
+		return t
+	}
+
+	counts := group(metric, last)
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		t.Rows = append(t.Rows, []interface{}{k, counts[k]})
+	}
+
+	return t
+}
+
+// group counts the offline players of a snapshot by store number or company name.
+func group(metric string, snap *snapshot.Snapshot) map[string]int {
+	counts := make(map[string]int)
+
+	for _, p := range snap.Players {
+		switch metric {
+		case MetricOfflineByStore:
+			counts[strconv.Itoa(p.StoreNumber)]++
+		case MetricOfflineByCompany:
+			counts[p.CompanyName]++
+		}
+	}
+
+	return counts
+}
+
+// writeJSON writes v as a JSON response.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Error("grafana.writeJSON: Failed to encode response", "err", err)
+	}
+}
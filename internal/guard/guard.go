@@ -0,0 +1,134 @@
+package guard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-players-data/internal/codec"
+	"go-players-data/internal/config"
+	"go-players-data/internal/logger"
+	"go-players-data/internal/storage"
+)
+
+// Count is the number of players parsed by a run.
+type Count struct {
+	At    time.Time `json:"at"`
+	Total int       `json:"total"`
+}
+
+// Anomaly describes a run whose player count dropped too far below the recent average.
+type Anomaly struct {
+	Total   int     `json:"total"`
+	Average float64 `json:"average"`
+	DropPct float64 `json:"drop_pct"`
+	Runs    int     `json:"runs"` // Runs the average was taken over
+}
+
+// String returns a description of the anomaly.
+func (a *Anomaly) String() string {
+	return fmt.Sprintf("parsed %d players, %.1f%% below the average of %.0f over the last %d runs", a.Total, a.DropPct, a.Average, a.Runs)
+}
+
+// guard is a struct that compares the player count of a run with the counts of recent runs,
+// keeping the counts in a JSON state object in object storage.
+type guard struct {
+	store   storage.Storage
+	key     string
+	maxDrop float64
+	window  int
+	minRuns int
+}
+
+// Guard is an interface for detecting truncated upstream responses by their player count.
+type Guard interface {
+	Check(ctx context.Context, total int, at time.Time) (*Anomaly, error)
+}
+
+// New creates a new Guard with the configured drop percentage and window of recent runs.
+func New(store storage.Storage, cfg config.Guard) Guard {
+	return &guard{
+		store:   store,
+		key:     cfg.StateKey,
+		maxDrop: float64(cfg.MaxDrop),
+		window:  cfg.Window,
+		minRuns: cfg.MinRuns,
+	}
+}
+
+// Enabled reports whether the guard is configured.
+func Enabled(cfg config.Guard) bool {
+	return cfg.MaxDrop > 0
+}
+
+// Check returns an anomaly when total is more than the configured percentage below the average of the recent runs.
+// Runs within the limit are recorded in the window; anomalies are not, so a truncated response never lowers the average.
+// No anomaly is reported until the window holds the minimum number of runs.
+func (g *guard) Check(ctx context.Context, total int, at time.Time) (*Anomaly, error) {
+	counts, err := g.load(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("guard.Check: %w", err)
+	}
+
+	if len(counts) >= g.minRuns && len(counts) > 0 {
+		sum := 0
+		for _, c := range counts {
+			sum += c.Total
+		}
+		average := float64(sum) / float64(len(counts))
+
+		if average > 0 {
+			drop := (average - float64(total)) / average * 100
+			if drop > g.maxDrop {
+				a := &Anomaly{Total: total, Average: average, DropPct: drop, Runs: len(counts)}
+				logger.Warn("guard.Check: Player count anomaly", "total", total, "average", average, "drop_pct", drop)
+				return a, nil
+			}
+		}
+	}
+
+	counts = append(counts, Count{At: at, Total: total})
+	if len(counts) > g.window {
+		counts = counts[len(counts)-g.window:]
+	}
+
+	if err = g.save(ctx, counts); err != nil {
+		return nil, fmt.Errorf("guard.Check: %w", err)
+	}
+
+	return nil, nil
+}
+
+// load reads the recent counts from object storage. A missing state object means no history.
+func (g *guard) load(ctx context.Context) ([]Count, error) {
+	var counts []Count
+
+	data, err := g.store.Get(ctx, g.key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return counts, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+
+	if err = codec.Unmarshal(data, &counts); err != nil {
+		return nil, fmt.Errorf("failed to decode state: %w", err)
+	}
+
+	return counts, nil
+}
+
+// save writes the recent counts to object storage.
+func (g *guard) save(ctx context.Context, counts []Count) error {
+	data, err := codec.Marshal(g.key, counts)
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	if err = g.store.Put(ctx, g.key, data, codec.ContentType); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package inventory
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"go-players-data/internal/logger"
+	"go-players-data/internal/model"
+	"go-players-data/internal/storage"
+)
+
+// ErrHeader is returned when the inventory header has neither a mac nor a serial column.
+var ErrHeader = errors.New("inventory header has no mac or serial column")
+
+// Item is the inventory record of a device.
+type Item struct {
+	Location string
+	Address  string
+	Phone    string
+}
+
+// inventory is a struct that holds the inventory records indexed by normalized MAC address and serial number.
+type inventory struct {
+	byMAC    map[string]Item
+	bySerial map[string]Item
+}
+
+// Inventory is an interface for enriching players with the inventory records of their devices.
+type Inventory interface {
+	Enrich(players []*model.Player) int
+	Len() int
+}
+
+// Load reads the inventory CSV stored under key. The header names the columns:
+// mac and/or serial identify the device, location, address and phone are joined onto its player.
+func Load(ctx context.Context, store storage.Storage, key string) (Inventory, error) {
+	start := time.Now()
+	defer func() { logger.Debug("inventory.Load: Time spent", "time", time.Since(start).String()) }()
+
+	data, err := store.Get(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("inventory.Load: failed to load %s: %w", key, err)
+	}
+
+	inv, err := parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("inventory.Load: %s: %w", key, err)
+	}
+
+	return inv, nil
+}
+
+// parse decodes the inventory CSV. Rows without a MAC address or serial number are skipped.
+func parse(r io.Reader) (*inventory, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	if _, ok := columns["mac"]; !ok {
+		if _, ok = columns["serial"]; !ok {
+			return nil, ErrHeader
+		}
+	}
+
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	inv := &inventory{
+		byMAC:    make(map[string]Item),
+		bySerial: make(map[string]Item),
+	}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read row: %w", err)
+		}
+
+		item := Item{
+			Location: field(record, "location"),
+			Address:  field(record, "address"),
+			Phone:    field(record, "phone"),
+		}
+		if mac := normalizeMAC(field(record, "mac")); mac != "" {
+			inv.byMAC[mac] = item
+		}
+		if serial := field(record, "serial"); serial != "" {
+			inv.bySerial[serial] = item
+		}
+	}
+
+	return inv, nil
+}
+
+// Enrich sets the location, address and phone of every player found in the inventory, by MAC address first
+// and serial number otherwise. Returns the number of enriched players.
+func (inv *inventory) Enrich(players []*model.Player) int {
+	enriched := 0
+	for _, p := range players {
+		item, ok := inv.byMAC[normalizeMAC(p.MAC)]
+		if !ok {
+			item, ok = inv.bySerial[p.Serial]
+		}
+		if !ok {
+			continue
+		}
+
+		p.Location, p.Address, p.Phone = item.Location, item.Address, item.Phone
+		enriched++
+	}
+
+	return enriched
+}
+
+// Len returns the number of devices in the inventory.
+func (inv *inventory) Len() int {
+	return max(len(inv.byMAC), len(inv.bySerial))
+}
+
+// normalizeMAC keeps the hex digits of a MAC address in upper case, so any separator style matches.
+func normalizeMAC(mac string) string {
+	return strings.ToUpper(strings.Map(func(r rune) rune {
+		if '0' <= r && r <= '9' || 'a' <= r && r <= 'f' || 'A' <= r && r <= 'F' {
+			return r
+		}
+		return -1
+	}, mac))
+}
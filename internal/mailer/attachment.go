@@ -0,0 +1,122 @@
+package mailer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+
+	"go-players-data/internal/model"
+)
+
+// Formats of the player list attached to store emails.
+const (
+	AttachmentCSV  = "csv"
+	AttachmentXLSX = "xlsx"
+)
+
+// attachmentSheet is the sheet name of the XLSX player list.
+const attachmentSheet = "Players"
+
+// attachmentHeader lists the columns of the attached player list.
+var attachmentHeader = []string{"Магазин", "Плеер", "MAC", "IP", "Последний раз в сети", "Не в сети, ч"}
+
+// playerList renders the players of a cluster as an attachment in the format, named after the store.
+func playerList(format, storeID string, players []*model.Player, now time.Time) (attachment, error) {
+	rows := make([][]interface{}, 0, len(players)+1)
+	header := make([]interface{}, 0, len(attachmentHeader))
+	for _, h := range attachmentHeader {
+		header = append(header, h)
+	}
+	rows = append(rows, header)
+
+	for _, p := range players {
+		rows = append(rows, []interface{}{
+			storeID,
+			p.PlayerName,
+			p.MAC,
+			p.Addresses(),
+			p.LastOnline.Format(time.DateTime),
+			roundHours(now.Sub(p.LastOnline)),
+		})
+	}
+
+	name := "offline-" + storeID + "." + format
+	switch format {
+	case AttachmentCSV:
+		content, err := csvList(rows)
+		return attachment{filename: name, contentType: "text/csv; charset=utf-8", content: content}, err
+	case AttachmentXLSX:
+		content, err := xlsxList(rows)
+		return attachment{
+			filename:    name,
+			contentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+			content:     content,
+		}, err
+	default:
+		return attachment{}, fmt.Errorf("mailer.playerList: unknown attachment format %q", format)
+	}
+}
+
+// csvList renders the rows as CSV with a UTF-8 byte order mark, so Excel opens Cyrillic text correctly.
+func csvList(rows [][]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("\ufeff")
+
+	w := csv.NewWriter(&buf)
+	for _, row := range rows {
+		record := make([]string, 0, len(row))
+		for _, v := range row {
+			if f, ok := v.(float64); ok {
+				record = append(record, strconv.FormatFloat(f, 'f', 1, 64))
+				continue
+			}
+			record = append(record, fmt.Sprint(v))
+		}
+		if err := w.Write(record); err != nil {
+			return nil, fmt.Errorf("mailer.csvList: %w", err)
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("mailer.csvList: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// xlsxList renders the rows as a single-sheet XLSX workbook.
+func xlsxList(rows [][]interface{}) ([]byte, error) {
+	f := excelize.NewFile()
+	defer func() { _ = f.Close() }()
+
+	if err := f.SetSheetName("Sheet1", attachmentSheet); err != nil {
+		return nil, fmt.Errorf("mailer.xlsxList: %w", err)
+	}
+
+	for i, row := range rows {
+		cell, err := excelize.CoordinatesToCellName(1, i+1)
+		if err != nil {
+			return nil, fmt.Errorf("mailer.xlsxList: %w", err)
+		}
+		if err = f.SetSheetRow(attachmentSheet, cell, &row); err != nil {
+			return nil, fmt.Errorf("mailer.xlsxList: %w", err)
+		}
+	}
+
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		return nil, fmt.Errorf("mailer.xlsxList: failed to write workbook: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// roundHours returns the duration in hours rounded to one decimal place.
+func roundHours(d time.Duration) float64 {
+	return float64(d.Round(6*time.Minute)) / float64(time.Hour)
+}
@@ -0,0 +1,160 @@
+package mailer
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"sort"
+	"time"
+
+	"go-players-data/internal/config"
+	"go-players-data/internal/logger"
+	"go-players-data/internal/model"
+	"go-players-data/internal/templateloader"
+)
+
+// Mail modes selected by MAIL_MODE.
+const (
+	ModeCluster = "cluster" // An email per cluster
+	ModeDigest  = "digest"  // A single email of every cluster per run
+)
+
+// ErrNoDigest is returned when a digest is sent by a mailer not created in digest mode.
+var ErrNoDigest = errors.New("mail mode is not digest")
+
+// DigestSection is the section of a store in the digest email.
+type DigestSection struct {
+	StoreNumber int
+	StoreID     string
+	Severity    string // Severity of the cluster, see model.ClusterSeverity
+	Players     []*model.Player
+	BySeverity  []model.SeverityGroup
+}
+
+// digestData is the data of the digest template: a section per cluster, the most severe first, and the totals.
+type digestData struct {
+	From     string
+	To       []string
+	Subject  string
+	Sections []DigestSection
+	Stores   int
+	Offline  int
+}
+
+// SendDigest sends the clusters in a single email rendered with the digest template to MAIL_DIGEST_TO, or MAIL_TO.
+// Sections are ordered by severity: the cluster with the player offline the longest first, since tiers grow
+// with the offline duration, then by offline count and store number. Does nothing without clusters.
+func (m *mailer) SendDigest(clusters map[int][]*model.Player) error {
+	start := time.Now()
+	defer func() { logger.Debug("mailer.SendDigest: Time spent", "time", time.Since(start).String()) }()
+
+	if m.digest == nil {
+		return fmt.Errorf("mailer.SendDigest: %w", ErrNoDigest)
+	}
+
+	to := m.config.DigestTo
+	if len(to) == 0 {
+		to = m.config.To
+	}
+
+	data := &digestData{
+		From:    m.config.From,
+		To:      to,
+		Subject: m.config.Subject,
+	}
+	for sn, players := range clusters {
+		if len(players) == 0 {
+			continue
+		}
+		data.Sections = append(data.Sections, DigestSection{
+			StoreNumber: sn,
+			StoreID:     m.storeID(sn, players),
+			Severity:    model.ClusterSeverity(players),
+			Players:     players,
+			BySeverity:  model.GroupBySeverity(players),
+		})
+		data.Offline += len(players)
+	}
+	if len(data.Sections) == 0 {
+		logger.Debug("mailer.SendDigest: No clusters, digest not sent")
+		return nil
+	}
+	data.Stores = len(data.Sections)
+
+	oldest := make(map[int]time.Time, len(data.Sections))
+	for _, s := range data.Sections {
+		oldest[s.StoreNumber] = s.Players[0].LastOnline
+		for _, p := range s.Players {
+			if p.LastOnline.Before(oldest[s.StoreNumber]) {
+				oldest[s.StoreNumber] = p.LastOnline
+			}
+		}
+	}
+	sort.Slice(data.Sections, func(i, j int) bool {
+		a, b := data.Sections[i], data.Sections[j]
+		if !oldest[a.StoreNumber].Equal(oldest[b.StoreNumber]) {
+			return oldest[a.StoreNumber].Before(oldest[b.StoreNumber])
+		}
+		if len(a.Players) != len(b.Players) {
+			return len(a.Players) > len(b.Players)
+		}
+		return a.StoreNumber < b.StoreNumber
+	})
+
+	body, err := m.digestBody(data)
+	if err != nil {
+		return fmt.Errorf("mailer.SendDigest: failed to build mail body: %w", err)
+	}
+
+	if err = m.sendTo(to, body); err != nil {
+		return fmt.Errorf("mailer.SendDigest: failed to send mail: %w", err)
+	}
+
+	logger.Info("mailer.SendDigest: Digest sent", "stores", data.Stores, "offline", data.Offline)
+	return nil
+}
+
+// digestBody renders the digest with its text and HTML blocks into a message built by the mailer.
+func (m *mailer) digestBody(data *digestData) (string, error) {
+	msg := &message{from: data.From, to: data.To, subject: data.Subject}
+
+	var err error
+	if msg.text, err = executeBlock(m.digest, textBlock, data); err != nil {
+		return "", err
+	}
+	if msg.html, err = executeBlock(m.digest, htmlBlock, data); err != nil {
+		return "", err
+	}
+	if msg.text == "" && msg.html == "" {
+		return "", fmt.Errorf("digest template defines neither a %q nor an %q block", textBlock, htmlBlock)
+	}
+
+	built, err := msg.build(time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	return string(built), nil
+}
+
+// loadDigest loads the digest template in digest mode, and returns nil in any other mode.
+// Store email pre-flight checks do not apply, the digest template is rendered with digestData.
+func loadDigest(cfg config.Mail, loader *templateloader.Loader, funcs template.FuncMap) (*template.Template, error) {
+	switch cfg.Mode {
+	case "", ModeCluster:
+		return nil, nil
+	case ModeDigest:
+	default:
+		return nil, fmt.Errorf("mailer.New: unknown mail mode %q", cfg.Mode)
+	}
+
+	tmpl, err := loader.Load(cfg.DigestTemplate, funcs)
+	if err != nil {
+		return nil, fmt.Errorf("mailer.New: digest template %q initialization failed: %w", cfg.DigestTemplate, err)
+	}
+	if cfg.TemplateStrict {
+		tmpl.Option("missingkey=error")
+	}
+
+	return tmpl, nil
+}
@@ -2,60 +2,184 @@ package mailer
 
 import (
 	"bytes"
-	"encoding/base64"
+	"errors"
 	"fmt"
+	"hash/fnv"
 	"html/template"
+	"io"
+	"mime"
 	"net/smtp"
+	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
+	"go-players-data/internal/ack"
+	"go-players-data/internal/cluster"
 	"go-players-data/internal/config"
 	"go-players-data/internal/logger"
 	"go-players-data/internal/model"
+	"go-players-data/internal/prefs"
 	"go-players-data/internal/templateloader"
 )
 
+// RecipientsError is returned when the cluster was sent to some recipients but failed for the listed ones,
+// so a retry can be limited to them with SendOnly.
+type RecipientsError struct {
+	Recipients []string
+	Err        error
+}
+
+// Error returns the errors of the failed recipients.
+func (e *RecipientsError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the errors of the failed recipients.
+func (e *RecipientsError) Unwrap() error {
+	return e.Err
+}
+
 // mailer is a struct used for managing email configurations and rendering email templates.
+// tmpl is the default template; byStore and byCompany hold per-store and per-company overrides,
+// and variants the templates of the A/B variants that replace the default, see Variant.
+// digest is the template of the single email of every cluster in digest mode, nil in cluster mode.
+// storeTo holds the recipients of the stores routed away from the global list.
+// outbox is set in dry runs and keeps the rendered emails instead of sending them.
+// pool keeps the SMTP connections shared by every email of the mailer.
 type mailer struct {
-	config config.Mail
-	tmpl   *template.Template
+	config    config.Mail
+	tmpl      *template.Template
+	byStore   map[int]*template.Template
+	byCompany map[string]*template.Template
+	variants  map[string]*template.Template
+	digest    *template.Template
+	ackLinks  ack.Signer
+	router    prefs.Router
+	storeTo   map[int][]string
+	outbox    *Outbox
+	pool      *smtpPool
 }
 
 // mailData represents the structure for email-related data including sender, recipients, subject, store details, and players.
+// AckURL and PlayerAckURLs (keyed by model.Player.Key) hold signed acknowledgment links when acknowledgments are enabled.
+// PrefsURL is the signed preference link of the single recipient when preferences are enabled.
 type mailData struct {
-	From        string
-	To          []string
-	Subject     string
-	StoreNumber int
-	StoreID     string
-	Players     []*model.Player
+	From          string
+	To            []string
+	Subject       string
+	StoreNumber   int
+	StoreID       string
+	Players       []*model.Player
+	AckURL        string
+	PlayerAckURLs map[string]string
+	PrefsURL      string
+	Variant       string                // Template variant of the email, empty without variants
+	BySeverity    []model.SeverityGroup // Players grouped by severity tier, the most severe first; empty without tiers
+	Recurring     int                   // Players already reported by earlier runs, see model.Player.Reported
 }
 
 // Mailer defines an interface for sending email notifications to players grouped by store number.
 type Mailer interface {
 	Send(storeNumber int, players []*model.Player) error
+	SendTo(storeNumber int, players []*model.Player, to []string) error
+	SendOnly(storeNumber int, players []*model.Player, to []string) error
+	SendAttachment(subject, text, filename string, content []byte) error
+	SendText(subject, text string, to []string) error
+	SendDigest(clusters map[int][]*model.Player) error
 }
 
 // New initializes a Mailer instance with the given configuration and template loader.
-// It loads the mail template using the specified template name and custom template functions.
+// It loads the default mail template and every per-store and per-company override using custom template functions.
+// ackLinks may be nil, in which case emails carry no acknowledgment links.
+// router may be nil, in which case every cluster is sent in a single email to all recipients;
+// otherwise each recipient that wants the cluster gets its own email with a List-Unsubscribe header.
 // Returns a configured Mailer instance or an error if template initialization fails.
-func New(cfg config.Mail, loader *templateloader.Loader) (Mailer, error) {
-	tmpl, err := loader.Load(
-		cfg.TemplateName,
-		template.FuncMap{
-			"join": strings.Join,
-			"base64enc": func(s string) string {
-				return base64.StdEncoding.EncodeToString([]byte(s))
-			},
-		},
-	)
+func New(cfg config.Mail, loader *templateloader.Loader, ackLinks ack.Signer, router prefs.Router) (Mailer, error) {
+	funcs := templateloader.Funcs()
+
+	loaded := make(map[string]*template.Template)
+	load := func(name string) (*template.Template, error) {
+		if tmpl, ok := loaded[name]; ok {
+			return tmpl, nil
+		}
+
+		tmpl, err := loader.Load(name, funcs)
+		if err != nil {
+			return nil, fmt.Errorf("mailer.New: mail template %q initialization failed: %w", name, err)
+		}
+
+		if cfg.TemplateStrict {
+			tmpl.Option("missingkey=error")
+
+			if err = preflight(tmpl); err != nil {
+				return nil, fmt.Errorf("mailer.New: mail template %q pre-flight check failed: %w", name, err)
+			}
+		}
+
+		loaded[name] = tmpl
+		return tmpl, nil
+	}
+
+	if cfg.Attachment != "" && cfg.Attachment != AttachmentCSV && cfg.Attachment != AttachmentXLSX {
+		return nil, fmt.Errorf("mailer.New: unknown attachment format %q", cfg.Attachment)
+	}
+
+	tmpl, err := load(cfg.TemplateName)
 	if err != nil {
-		return nil, fmt.Errorf("mailer.New: mail template initialization failed: %w", err)
+		return nil, err
+	}
+
+	byStore := make(map[int]*template.Template, len(cfg.TemplatesByStore))
+	for storeNumber, name := range cfg.TemplatesByStore {
+		if byStore[storeNumber], err = load(name); err != nil {
+			return nil, err
+		}
+	}
+
+	byCompany := make(map[string]*template.Template, len(cfg.TemplatesByCompany))
+	for companyName, name := range cfg.TemplatesByCompany {
+		if byCompany[companyName], err = load(name); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = validateVariants(cfg); err != nil {
+		return nil, fmt.Errorf("mailer.New: %w", err)
+	}
+
+	variants := make(map[string]*template.Template, len(cfg.TemplateVariants))
+	for name := range cfg.TemplateVariants {
+		if variants[name], err = load(name); err != nil {
+			return nil, err
+		}
+	}
+
+	digest, err := loadDigest(cfg, loader, funcs)
+	if err != nil {
+		return nil, err
+	}
+
+	storeTo := make(map[int][]string, len(cfg.StoreRecipients))
+	for storeNumber, list := range cfg.StoreRecipients {
+		for _, to := range strings.Split(list, ";") {
+			if to = strings.TrimSpace(to); to != "" {
+				storeTo[storeNumber] = append(storeTo[storeNumber], to)
+			}
+		}
 	}
 
 	return &mailer{
-		config: cfg,
-		tmpl:   tmpl,
+		config:    cfg,
+		tmpl:      tmpl,
+		byStore:   byStore,
+		byCompany: byCompany,
+		variants:  variants,
+		digest:    digest,
+		ackLinks:  ackLinks,
+		router:    router,
+		storeTo:   storeTo,
+		pool:      newSMTPPool(cfg, smtp.PlainAuth("", cfg.From, cfg.Password, cfg.Host)),
 	}, nil
 }
 
@@ -64,55 +188,351 @@ func (m *mailer) Send(storeNumber int, players []*model.Player) error {
 	start := time.Now()
 	defer func() { logger.Debug("mailer.Send: Time spent", "time", time.Since(start).String()) }()
 
-	body, err := m.body(storeNumber, players)
+	if m.router != nil {
+		return m.sendRouted(storeNumber, players, m.router.Recipients(m.recipients(storeNumber, players), players))
+	}
+
+	to := m.recipients(storeNumber, players)
+
+	body, err := m.body(storeNumber, players, to, "", m.threadHeaders(storeNumber, players, time.Now()))
 	if err != nil {
 		return fmt.Errorf("mailer.Send: failed to build mail body: %w", err)
 	}
 
-	if err = m.send(body); err != nil {
+	if err = m.sendTo(to, body); err != nil {
 		return fmt.Errorf("mailer.Send: failed to send mail: %w", err)
 	}
 
 	return nil
 }
 
+// SendTo sends the store email of the cluster to the given recipients instead of the configured ones,
+// regardless of their preferences. Returns an error if it fails.
+func (m *mailer) SendTo(storeNumber int, players []*model.Player, to []string) error {
+	start := time.Now()
+	defer func() { logger.Debug("mailer.SendTo: Time spent", "time", time.Since(start).String()) }()
+
+	body, err := m.body(storeNumber, players, to, "", m.threadHeaders(storeNumber, players, time.Now()))
+	if err != nil {
+		return fmt.Errorf("mailer.SendTo: failed to build mail body: %w", err)
+	}
+
+	if err = m.sendTo(to, body); err != nil {
+		return fmt.Errorf("mailer.SendTo: failed to send mail: %w", err)
+	}
+
+	return nil
+}
+
+// recipients returns the MAIL_STORE_RECIPIENTS of the store when it has any, MAIL_LAB_TO for the lab cluster
+// when it is set, and MAIL_TO otherwise.
+func (m *mailer) recipients(storeNumber int, players []*model.Player) []string {
+	if to, ok := m.storeTo[storeNumber]; ok {
+		return to
+	}
+	if len(players) > 0 && players[0].Lab && len(m.config.LabTo) > 0 {
+		return m.config.LabTo
+	}
+	return m.config.To
+}
+
+// SendOnly sends the cluster to the given recipients of an earlier Send that failed for them, see RecipientsError:
+// each gets its own email with a List-Unsubscribe header like in Send. Without preferences, it works like SendTo.
+func (m *mailer) SendOnly(storeNumber int, players []*model.Player, to []string) error {
+	start := time.Now()
+	defer func() { logger.Debug("mailer.SendOnly: Time spent", "time", time.Since(start).String()) }()
+
+	if m.router == nil {
+		return m.SendTo(storeNumber, players, to)
+	}
+
+	return m.sendRouted(storeNumber, players, to)
+}
+
+// sendRouted sends the cluster separately to every recipient, with List-Unsubscribe headers pointing
+// at the recipient's preference link. Returns a RecipientsError naming the recipients the send failed for.
+func (m *mailer) sendRouted(storeNumber int, players []*model.Player, recipients []string) error {
+	if len(recipients) == 0 {
+		logger.Debug("mailer.Send: No recipient wants the notification", "cluster", storeNumber)
+		return nil
+	}
+
+	var failed []string
+	var errs []error
+	for _, recipient := range recipients {
+		link := m.router.Link(recipient)
+
+		headers := append(m.threadHeaders(storeNumber, players, time.Now()),
+			header{"List-Unsubscribe", "<" + link + ">"},
+			header{"List-Unsubscribe-Post", "List-Unsubscribe=One-Click"},
+		)
+
+		body, err := m.body(storeNumber, players, []string{recipient}, link, headers)
+		if err != nil {
+			return fmt.Errorf("mailer.Send: failed to build mail body: %w", err)
+		}
+
+		if err = m.sendTo([]string{recipient}, body); err != nil {
+			failed = append(failed, recipient)
+			errs = append(errs, fmt.Errorf("mailer.Send: failed to send mail to %s: %w", recipient, err))
+		}
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+
+	return &RecipientsError{Recipients: failed, Err: errors.Join(errs...)}
+}
+
+// threadHeaders returns the Message-ID, In-Reply-To and References headers of a store email.
+// The Message-ID is derived from the store number, the UTC date and a hash of the player keys, so a retried
+// or duplicated send of the same players carries the same ID, while a later email of the day with other players
+// is not collapsed into it. Every email of a store and day references the same date-based thread root.
+func (m *mailer) threadHeaders(storeNumber int, players []*model.Player, now time.Time) []header {
+	domain := "go-players-data"
+	if i := strings.LastIndex(m.config.From, "@"); i >= 0 && i < len(m.config.From)-1 {
+		domain = strings.TrimRight(m.config.From[i+1:], ">")
+	}
+
+	keys := make([]string, len(players))
+	for i, p := range players {
+		keys[i] = p.Key()
+	}
+	sort.Strings(keys)
+
+	h := fnv.New64a()
+	for _, key := range keys {
+		_, _ = io.WriteString(h, key)
+		_, _ = h.Write([]byte{0})
+	}
+
+	date := now.UTC().Format(time.DateOnly)
+	root := fmt.Sprintf("<store-%d.%s@%s>", storeNumber, date, domain)
+	id := fmt.Sprintf("<store-%d.%s.%016x@%s>", storeNumber, date, h.Sum64(), domain)
+
+	return []header{{"Message-ID", id}, {"In-Reply-To", root}, {"References", root}}
+}
+
+// SendAttachment sends a plain text email with a single file attached to the configured recipients.
+// Returns an error if it fails.
+func (m *mailer) SendAttachment(subject, text, filename string, content []byte) error {
+	start := time.Now()
+	defer func() { logger.Debug("mailer.SendAttachment: Time spent", "time", time.Since(start).String()) }()
+
+	msg := &message{
+		from:    m.config.From,
+		to:      m.config.To,
+		subject: subject,
+		text:    text,
+		attachments: []attachment{{
+			filename:    filename,
+			contentType: mime.TypeByExtension(filepath.Ext(filename)),
+			content:     content,
+		}},
+	}
+
+	body, err := msg.build(time.Now())
+	if err != nil {
+		return fmt.Errorf("mailer.SendAttachment: failed to build mail: %w", err)
+	}
+
+	if err = m.send(string(body)); err != nil {
+		return fmt.Errorf("mailer.SendAttachment: failed to send mail: %w", err)
+	}
+
+	return nil
+}
+
+// SendText sends a plain text email to the given recipients, or to the configured ones when to is empty.
+// Used for operational alerts that are not tied to a store. Returns an error if it fails.
+func (m *mailer) SendText(subject, text string, to []string) error {
+	start := time.Now()
+	defer func() { logger.Debug("mailer.SendText: Time spent", "time", time.Since(start).String()) }()
+
+	if len(to) == 0 {
+		to = m.config.To
+	}
+
+	msg := &message{from: m.config.From, to: to, subject: subject, text: text}
+
+	body, err := msg.build(time.Now())
+	if err != nil {
+		return fmt.Errorf("mailer.SendText: failed to build mail: %w", err)
+	}
+
+	if err = m.sendTo(to, string(body)); err != nil {
+		return fmt.Errorf("mailer.SendText: failed to send mail: %w", err)
+	}
+
+	return nil
+}
+
 // send sends an email with the specified body using the configured SMTP server and authentication.
 // returns an error on failure.
 func (m *mailer) send(body string) error {
-	auth := smtp.PlainAuth("", m.config.From, m.config.Password, m.config.Host)
-	return smtp.SendMail(
-		fmt.Sprintf("%s:%d", m.config.Host, m.config.Port),
-		auth,
-		m.config.From,
-		m.config.To,
-		[]byte(body),
-	)
+	return m.sendTo(m.config.To, body)
 }
 
-// body generates the email body using the provided store number and player details, returning it as a string or an error.
-func (m *mailer) body(storeNumber int, players []*model.Player) (string, error) {
-	var storeID string
-
-	if m.config.MailStores[storeNumber] != "" {
-		storeID = m.config.MailStores[storeNumber]
-	} else {
-		storeID = fmt.Sprintf("%d", storeNumber)
+// sendTo sends an email with the specified body to the given recipients over a pooled SMTP connection.
+func (m *mailer) sendTo(to []string, body string) error {
+	if m.outbox != nil {
+		logger.Info("mailer.sendTo: Dry run, email not sent", "to", to, "bytes", len(body))
+		m.outbox.add(to, body)
+		return nil
 	}
 
+	return m.pool.send(m.config.From, to, []byte(body))
+}
+
+// body generates the email for the recipients using the provided store number and player details,
+// returning it as a string or an error.
+// Templates defining "text" and/or "html" blocks get a message built by the mailer with the subject, encoded headers
+// and a multipart/alternative body when both blocks are set. Other templates render the whole message themselves,
+// and the headers are written in front of their output.
+func (m *mailer) body(storeNumber int, players []*model.Player, to []string, prefsURL string, headers []header) (string, error) {
+	storeID := m.storeID(storeNumber, players)
+
 	var buf bytes.Buffer
 
 	data := &mailData{
 		From:        m.config.From,
-		To:          m.config.To,
+		To:          to,
 		Subject:     m.config.Subject,
 		StoreNumber: storeNumber,
 		StoreID:     storeID,
 		Players:     players,
+		PrefsURL:    prefsURL,
+		Variant:     Variant(m.config, storeNumber, players),
+		BySeverity:  model.GroupBySeverity(players),
+	}
+	for _, p := range players {
+		if p.Reported > 0 {
+			data.Recurring++
+		}
+	}
+
+	if data.Variant != "" {
+		headers = append(headers, header{VariantHeader, data.Variant})
+	}
+
+	if m.ackLinks != nil {
+		data.AckURL = m.ackLinks.Link(storeNumber, "")
+		data.PlayerAckURLs = make(map[string]string, len(players))
+		for _, p := range players {
+			data.PlayerAckURLs[p.Key()] = m.ackLinks.Link(storeNumber, p.Key())
+		}
+	}
+
+	tmpl := m.template(storeNumber, players, data.Variant)
+
+	if tmpl.Lookup(textBlock) == nil && tmpl.Lookup(htmlBlock) == nil {
+		if m.config.Attachment != "" {
+			logger.Warn("mailer.body: Template renders the whole message, player list not attached", "cluster", storeNumber)
+		}
+		for _, h := range headers {
+			writeHeader(&buf, h.name, h.value)
+		}
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return "", fmt.Errorf("mailer.body: failed to execute template: %w", err)
+		}
+		return buf.String(), nil
 	}
 
-	if err := m.tmpl.Execute(&buf, data); err != nil {
-		return "", fmt.Errorf("mailer.body: failed to execute template: %w", err)
+	msg := &message{from: m.config.From, to: to, subject: data.Subject, headers: headers}
+
+	var err error
+	if msg.text, err = executeBlock(tmpl, textBlock, data); err != nil {
+		return "", fmt.Errorf("mailer.body: %w", err)
+	}
+	if msg.html, err = executeBlock(tmpl, htmlBlock, data); err != nil {
+		return "", fmt.Errorf("mailer.body: %w", err)
+	}
+
+	now := time.Now()
+	if m.config.Attachment != "" {
+		list, err := playerList(m.config.Attachment, storeID, players, now)
+		if err != nil {
+			return "", fmt.Errorf("mailer.body: %w", err)
+		}
+		msg.attachments = append(msg.attachments, list)
+	}
+
+	built, err := msg.build(now)
+	if err != nil {
+		return "", fmt.Errorf("mailer.body: %w", err)
+	}
+
+	return string(built), nil
+}
+
+// storeID returns the configured name of the store, the name of a company or group digest, or the store number.
+func (m *mailer) storeID(storeNumber int, players []*model.Player) string {
+	if m.config.MailStores[storeNumber] != "" {
+		return m.config.MailStores[storeNumber]
+	}
+	if name, ok := cluster.Name(storeNumber, players); ok {
+		// Company and group digests are named after their company or group
+		return name
+	}
+	return fmt.Sprintf("%d", storeNumber)
+}
+
+// executeBlock renders a block of the template, or returns an empty string when the template does not define it.
+func executeBlock(tmpl *template.Template, name string, data any) (string, error) {
+	if tmpl.Lookup(name) == nil {
+		return "", nil
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", fmt.Errorf("failed to execute template block %q: %w", name, err)
 	}
 
 	return buf.String(), nil
 }
+
+// template selects the template for the given store: a store override wins over a company override,
+// then the template of the variant, and the default template is used when none is configured.
+// See cluster.Company for the company of a cluster.
+func (m *mailer) template(storeNumber int, players []*model.Player, variant string) *template.Template {
+	if tmpl, ok := m.byStore[storeNumber]; ok {
+		return tmpl
+	}
+
+	if company, ok := cluster.Company(storeNumber, players); ok {
+		if tmpl, ok := m.byCompany[company]; ok {
+			return tmpl
+		}
+	}
+
+	if tmpl, ok := m.variants[variant]; ok {
+		return tmpl
+	}
+
+	return m.tmpl
+}
+
+// preflight executes the template and its text and HTML blocks against sample data with a single zero-valued player,
+// so references to missing or renamed fields fail at startup instead of rendering blank cells.
+func preflight(tmpl *template.Template) error {
+	data := &mailData{
+		From:        "from@example.com",
+		To:          []string{"to@example.com"},
+		Subject:     "preflight",
+		StoreNumber: 1,
+		StoreID:     "1",
+		Players:     []*model.Player{{LastOnline: time.Now()}},
+	}
+
+	if err := tmpl.Execute(io.Discard, data); err != nil {
+		return err
+	}
+	for _, name := range []string{textBlock, htmlBlock} {
+		if _, err := executeBlock(tmpl, name, data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,223 @@
+package mailer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Template blocks rendered into the plain text and HTML bodies of a built message.
+const (
+	textBlock = "text"
+	htmlBlock = "html"
+)
+
+// header is a single message header, kept in order.
+type header struct {
+	name  string
+	value string
+}
+
+// attachment is a file attached to a message.
+type attachment struct {
+	filename    string
+	contentType string
+	content     []byte
+}
+
+// message is a struct that builds an RFC 5322 email: encoded headers, quoted-printable bodies and base64 attachments.
+// A message with both a plain text and an HTML body is built as multipart/alternative,
+// and attachments wrap the body in multipart/mixed.
+type message struct {
+	from        string
+	to          []string
+	subject     string
+	headers     []header
+	text        string
+	html        string
+	attachments []attachment
+}
+
+// build renders the message with the Date header set to now.
+func (msg *message) build(now time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+
+	to := make([]string, 0, len(msg.to))
+	for _, addr := range msg.to {
+		to = append(to, formatAddress(addr))
+	}
+
+	writeHeader(&buf, "From", formatAddress(msg.from))
+	writeHeader(&buf, "To", strings.Join(to, ", "))
+	writeHeader(&buf, "Subject", mime.QEncoding.Encode("utf-8", msg.subject))
+	writeHeader(&buf, "Date", now.Format(time.RFC1123Z))
+	for _, h := range msg.headers {
+		writeHeader(&buf, h.name, h.value)
+	}
+	writeHeader(&buf, "MIME-Version", "1.0")
+
+	h, body, err := msg.content()
+	if err != nil {
+		return nil, fmt.Errorf("mailer.build: %w", err)
+	}
+	writeMIMEHeader(&buf, h)
+	buf.WriteString("\r\n")
+	buf.Write(body)
+
+	return buf.Bytes(), nil
+}
+
+// content returns the headers and the encoded body of the message content, attachments included.
+func (msg *message) content() (textproto.MIMEHeader, []byte, error) {
+	h, body, err := alternative(msg.text, msg.html)
+	if err != nil || len(msg.attachments) == 0 {
+		return h, body, err
+	}
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+
+	if err = writePart(w, h, body); err != nil {
+		return nil, nil, err
+	}
+
+	for _, a := range msg.attachments {
+		contentType := a.contentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		part := textproto.MIMEHeader{}
+		part.Set("Content-Type", contentType)
+		part.Set("Content-Transfer-Encoding", "base64")
+		part.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.filename}))
+
+		if err = writePart(w, part, wrapBase64(a.content)); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err = w.Close(); err != nil {
+		return nil, nil, err
+	}
+
+	mixed := textproto.MIMEHeader{}
+	mixed.Set("Content-Type", mime.FormatMediaType("multipart/mixed", map[string]string{"boundary": w.Boundary()}))
+	return mixed, buf.Bytes(), nil
+}
+
+// alternative returns the headers and the encoded body of the plain text and HTML bodies:
+// a single part when only one of them is set, and multipart/alternative with the plain text first otherwise.
+func alternative(text, html string) (textproto.MIMEHeader, []byte, error) {
+	switch {
+	case html == "":
+		return textPart("text/plain", text)
+	case text == "":
+		return textPart("text/html", html)
+	}
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+
+	for _, p := range []struct{ mediaType, body string }{{"text/plain", text}, {"text/html", html}} {
+		h, body, err := textPart(p.mediaType, p.body)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err = writePart(w, h, body); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, nil, err
+	}
+
+	h := textproto.MIMEHeader{}
+	h.Set("Content-Type", mime.FormatMediaType("multipart/alternative", map[string]string{"boundary": w.Boundary()}))
+	return h, buf.Bytes(), nil
+}
+
+// textPart returns the headers and the quoted-printable body of a UTF-8 text part with CRLF line endings.
+func textPart(mediaType, body string) (textproto.MIMEHeader, []byte, error) {
+	body = strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n")
+
+	var buf bytes.Buffer
+	w := quotedprintable.NewWriter(&buf)
+	if _, err := w.Write([]byte(body)); err != nil {
+		return nil, nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, nil, err
+	}
+	buf.WriteString("\r\n")
+
+	h := textproto.MIMEHeader{}
+	h.Set("Content-Type", mime.FormatMediaType(mediaType, map[string]string{"charset": "utf-8"}))
+	h.Set("Content-Transfer-Encoding", "quoted-printable")
+	return h, buf.Bytes(), nil
+}
+
+// writePart writes a part with the headers and the already encoded body.
+func writePart(w *multipart.Writer, h textproto.MIMEHeader, body []byte) error {
+	part, err := w.CreatePart(h)
+	if err != nil {
+		return err
+	}
+	_, err = part.Write(body)
+	return err
+}
+
+// writeHeader writes a header with line breaks removed from its value, so values cannot inject headers.
+func writeHeader(buf *bytes.Buffer, name, value string) {
+	value = strings.NewReplacer("\r", "", "\n", "").Replace(value)
+	fmt.Fprintf(buf, "%s: %s\r\n", name, value)
+}
+
+// writeMIMEHeader writes the headers ordered by name.
+func writeMIMEHeader(buf *bytes.Buffer, h textproto.MIMEHeader) {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, value := range h[name] {
+			writeHeader(buf, name, value)
+		}
+	}
+}
+
+// formatAddress returns the address with a non-ASCII display name encoded, or unchanged when it cannot be parsed.
+func formatAddress(addr string) string {
+	parsed, err := mail.ParseAddress(addr)
+	if err != nil {
+		return addr
+	}
+	return parsed.String()
+}
+
+// wrapBase64 encodes content as base64 in lines of 76 characters.
+func wrapBase64(content []byte) []byte {
+	encoded := base64.StdEncoding.EncodeToString(content)
+
+	var buf bytes.Buffer
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76])
+		buf.WriteString("\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded)
+	buf.WriteString("\r\n")
+
+	return buf.Bytes()
+}
@@ -0,0 +1,49 @@
+package mailer
+
+import (
+	"sync"
+
+	"go-players-data/internal/logger"
+)
+
+// Message is a rendered email kept by an Outbox instead of being sent.
+type Message struct {
+	To   []string `json:"to"`
+	Body string   `json:"body"`
+}
+
+// Outbox collects the rendered emails of a dry run.
+type Outbox struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+// add keeps a rendered email.
+func (o *Outbox) add(to []string, body string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.messages = append(o.messages, Message{To: append([]string(nil), to...), Body: body})
+}
+
+// Messages returns the kept emails in the order they were rendered.
+func (o *Outbox) Messages() []Message {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return append([]Message(nil), o.messages...)
+}
+
+// DryRun returns a copy of m that renders every email as usual but keeps it in outbox instead of sending it over SMTP.
+// Mailers not created by New are returned unchanged.
+func DryRun(m Mailer, outbox *Outbox) Mailer {
+	mm, ok := m.(*mailer)
+	if !ok {
+		logger.Warn("mailer.DryRun: Unsupported mailer, emails will be sent")
+		return m
+	}
+
+	dry := *mm
+	dry.outbox = outbox
+	return &dry
+}
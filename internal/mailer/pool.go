@@ -0,0 +1,183 @@
+package mailer
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"time"
+
+	"go-players-data/internal/config"
+)
+
+// TLS modes of the SMTP connection.
+const (
+	TLSAuto     = "auto"     // STARTTLS when the server offers it
+	TLSStartTLS = "starttls" // STARTTLS is required
+	TLSImplicit = "tls"      // Implicit TLS from the first byte, usually port 465
+	TLSNone     = "none"     // Plain text, e.g. a local relay
+)
+
+// ErrNoStartTLS is returned when STARTTLS is required but the server does not offer it.
+var ErrNoStartTLS = errors.New("server does not offer STARTTLS")
+
+// pooledClient is an authenticated SMTP connection kept between messages.
+type pooledClient struct {
+	conn   net.Conn
+	client *smtp.Client
+	used   time.Time
+}
+
+// smtpPool is a struct that keeps a few authenticated SMTP connections open and sends messages over them,
+// so clusters do not dial and authenticate once per email. At most size messages are sent at a time.
+type smtpPool struct {
+	addr    string
+	host    string
+	auth    smtp.Auth
+	mode    string
+	tls     *tls.Config
+	timeout time.Duration
+	maxIdle time.Duration
+	slots   chan struct{}
+	idle    chan *pooledClient
+}
+
+// newSMTPPool creates a pool of at most cfg.PoolSize connections to the configured server, authenticated with auth.
+// cfg.TLS selects the TLS mode and cfg.Timeout bounds dialing and every message.
+// Connections idle for longer than cfg.PoolIdle are closed instead of being reused.
+func newSMTPPool(cfg config.Mail, auth smtp.Auth) *smtpPool {
+	size := max(cfg.PoolSize, 1)
+	return &smtpPool{
+		addr:    fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
+		host:    cfg.Host,
+		auth:    auth,
+		mode:    cfg.TLS,
+		tls:     &tls.Config{ServerName: cfg.Host, InsecureSkipVerify: cfg.TLSSkipVerify},
+		timeout: cfg.Timeout,
+		maxIdle: cfg.PoolIdle,
+		slots:   make(chan struct{}, size),
+		idle:    make(chan *pooledClient, size),
+	}
+}
+
+// send delivers msg from the sender to the recipients over a pooled connection.
+// A connection that fails is closed, so the next message dials a fresh one.
+func (p *smtpPool) send(from string, to []string, msg []byte) error {
+	p.slots <- struct{}{}
+	defer func() { <-p.slots }()
+
+	pc, err := p.get()
+	if err != nil {
+		return err
+	}
+
+	if p.timeout > 0 {
+		_ = pc.conn.SetDeadline(time.Now().Add(p.timeout))
+	}
+
+	if err = deliver(pc.client, from, to, msg); err != nil {
+		_ = pc.client.Close()
+		return err
+	}
+
+	pc.used = time.Now()
+	p.put(pc)
+	return nil
+}
+
+// get returns an idle connection that still answers RSET, or dials a new one.
+func (p *smtpPool) get() (*pooledClient, error) {
+	for {
+		select {
+		case pc := <-p.idle:
+			if time.Since(pc.used) < p.maxIdle && pc.client.Reset() == nil {
+				return pc, nil
+			}
+			_ = pc.client.Close()
+		default:
+			return p.dial()
+		}
+	}
+}
+
+// put keeps the connection for the next message, or quits it when the pool is full.
+func (p *smtpPool) put(pc *pooledClient) {
+	select {
+	case p.idle <- pc:
+	default:
+		_ = pc.client.Quit()
+	}
+}
+
+// dial connects to the server in the configured TLS mode and authenticates, as smtp.SendMail does for every message.
+func (p *smtpPool) dial() (*pooledClient, error) {
+	dialer := &net.Dialer{Timeout: p.timeout}
+
+	var (
+		conn net.Conn
+		err  error
+	)
+	if p.mode == TLSImplicit {
+		conn, err = tls.DialWithDialer(dialer, "tcp", p.addr, p.tls)
+	} else {
+		conn, err = dialer.Dial("tcp", p.addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("mailer.dial: %w", err)
+	}
+	if p.timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(p.timeout))
+	}
+
+	c, err := smtp.NewClient(conn, p.host)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("mailer.dial: %w", err)
+	}
+
+	if p.mode != TLSImplicit && p.mode != TLSNone {
+		ok, _ := c.Extension("STARTTLS")
+		switch {
+		case ok:
+			if err = c.StartTLS(p.tls); err != nil {
+				_ = c.Close()
+				return nil, fmt.Errorf("mailer.dial: starttls: %w", err)
+			}
+		case p.mode == TLSStartTLS:
+			_ = c.Close()
+			return nil, fmt.Errorf("mailer.dial: %w", ErrNoStartTLS)
+		}
+	}
+
+	if ok, _ := c.Extension("AUTH"); ok && p.auth != nil {
+		if err = c.Auth(p.auth); err != nil {
+			_ = c.Close()
+			return nil, fmt.Errorf("mailer.dial: auth: %w", err)
+		}
+	}
+
+	return &pooledClient{conn: conn, client: c, used: time.Now()}, nil
+}
+
+// deliver sends a single message over an open connection.
+func deliver(c *smtp.Client, from string, to []string, msg []byte) error {
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, addr := range to {
+		if err := c.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(msg); err != nil {
+		_ = w.Close()
+		return err
+	}
+	return w.Close()
+}
@@ -0,0 +1,89 @@
+package mailer
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+
+	"go-players-data/internal/cluster"
+	"go-players-data/internal/config"
+	"go-players-data/internal/model"
+)
+
+// VariantHeader is the header of store emails naming the template variant they were rendered with.
+const VariantHeader = "X-Template-Variant"
+
+// Variant returns the template variant of the cluster: the variant pinned to the store by MAIL_TEMPLATE_VARIANT_STORES,
+// or else the variant of the percentage bucket the store number hashes into, so a store keeps its variant across runs.
+// Returns an empty string when no variants are configured or a store or company template override applies.
+func Variant(cfg config.Mail, storeNumber int, players []*model.Player) string {
+	if len(cfg.TemplateVariants) == 0 {
+		return ""
+	}
+	if _, ok := cfg.TemplatesByStore[storeNumber]; ok {
+		return ""
+	}
+	if company, ok := cluster.Company(storeNumber, players); ok {
+		if _, ok := cfg.TemplatesByCompany[company]; ok {
+			return ""
+		}
+	}
+
+	if name, ok := cfg.TemplateVariantStores[storeNumber]; ok {
+		return name
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(strconv.Itoa(storeNumber)))
+	bucket := int(h.Sum32() % 100)
+
+	names := variantNames(cfg.TemplateVariants)
+	for _, name := range names {
+		if bucket < cfg.TemplateVariants[name] {
+			return name
+		}
+		bucket -= cfg.TemplateVariants[name]
+	}
+
+	return names[len(names)-1]
+}
+
+// validateVariants checks that the variant percentages add up to 100 and every pinned store names a variant.
+func validateVariants(cfg config.Mail) error {
+	if len(cfg.TemplateVariants) == 0 {
+		if len(cfg.TemplateVariantStores) > 0 {
+			return fmt.Errorf("MAIL_TEMPLATE_VARIANT_STORES is set without MAIL_TEMPLATE_VARIANTS")
+		}
+		return nil
+	}
+
+	total := 0
+	for name, percent := range cfg.TemplateVariants {
+		if percent < 0 {
+			return fmt.Errorf("variant %q has a negative percentage", name)
+		}
+		total += percent
+	}
+	if total != 100 {
+		return fmt.Errorf("variant percentages add up to %d, not 100", total)
+	}
+
+	for storeNumber, name := range cfg.TemplateVariantStores {
+		if _, ok := cfg.TemplateVariants[name]; !ok {
+			return fmt.Errorf("store %d is pinned to unknown variant %q", storeNumber, name)
+		}
+	}
+
+	return nil
+}
+
+// variantNames returns the variant names sorted, so the buckets are the same in every run.
+func variantNames(variants map[string]int) []string {
+	names := make([]string, 0, len(variants))
+	for name := range variants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,173 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"go-players-data/internal/config"
+	"go-players-data/internal/logger"
+	"go-players-data/internal/stage"
+)
+
+// Metric names of a run.
+const (
+	MetricRunDuration   = "players_run_duration_seconds"
+	MetricStageDuration = "players_stage_duration_seconds"
+	MetricPlayers       = "players_run_players"
+	MetricSuppressed    = "players_run_suppressed"
+	MetricSends         = "players_run_sends"
+	MetricErrors        = "players_run_errors"
+	MetricLastRun       = "players_run_last_timestamp_seconds"
+)
+
+// Run holds the measurements of a single run.
+type Run struct {
+	Duration   time.Duration
+	Stages     []stage.Stage
+	Fetched    int
+	Parsed     int
+	Skipped    int // Raw players with invalid data, i.e. parse errors
+	Offline    int
+	Outdated   int
+	Suppressed map[string]int // Offline players left out of notifications, by reason
+	Sent       map[string]int // Clusters sent, by channel
+	Failed     map[string]int // Clusters pending redelivery, by channel
+	Errors     int            // Stages that failed without stopping the run
+}
+
+// pusher is a struct that pushes the metrics of a run to a Prometheus Pushgateway.
+type pusher struct {
+	client *http.Client
+	url    url.URL
+	job    string
+}
+
+// Pusher is an interface for pushing the metrics of a run.
+type Pusher interface {
+	Push(ctx context.Context, r Run, at time.Time) error
+}
+
+// New creates a new Pusher for the configured Pushgateway.
+func New(c *http.Client, cfg config.Metrics) Pusher {
+	return &pusher{
+		client: c,
+		url:    cfg.PushgatewayURL,
+		job:    cfg.Job,
+	}
+}
+
+// Push replaces the metrics of the job on the Pushgateway with those of the run.
+func (p *pusher) Push(ctx context.Context, r Run, at time.Time) error {
+	start := time.Now()
+	defer func() { logger.Debug("metrics.Push: Time spent", "time", time.Since(start).String()) }()
+
+	var buf bytes.Buffer
+	if err := Write(&buf, r, at); err != nil {
+		return fmt.Errorf("metrics.Push: %w", err)
+	}
+
+	u := p.url
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/metrics/job/" + url.PathEscape(p.job)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), &buf)
+	if err != nil {
+		return fmt.Errorf("metrics.Push: failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("metrics.Push: failed to send request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("metrics.Push: unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	logger.Debug("metrics.Push: Metrics pushed", "job", p.job)
+	return nil
+}
+
+// Write writes the metrics of the run in the Prometheus text exposition format.
+func Write(w io.Writer, r Run, at time.Time) error {
+	var b strings.Builder
+
+	gauge(&b, MetricRunDuration, "Duration of the run.")
+	sample(&b, MetricRunDuration, nil, r.Duration.Seconds())
+
+	gauge(&b, MetricStageDuration, "Duration of every stage of the run, the pipeline stage covers the fetch.")
+	for _, s := range r.Stages {
+		sample(&b, MetricStageDuration, []string{"stage", s.Name}, s.Duration.Seconds())
+	}
+
+	gauge(&b, MetricPlayers, "Players of the run at every step: fetched, parsed, skipped with invalid data, offline and outdated.")
+	sample(&b, MetricPlayers, []string{"step", "fetched"}, float64(r.Fetched))
+	sample(&b, MetricPlayers, []string{"step", "parsed"}, float64(r.Parsed))
+	sample(&b, MetricPlayers, []string{"step", "skipped"}, float64(r.Skipped))
+	sample(&b, MetricPlayers, []string{"step", "offline"}, float64(r.Offline))
+	sample(&b, MetricPlayers, []string{"step", "outdated"}, float64(r.Outdated))
+
+	gauge(&b, MetricSuppressed, "Offline players left out of notifications, by reason.")
+	for _, reason := range keys(r.Suppressed) {
+		sample(&b, MetricSuppressed, []string{"reason", reason}, float64(r.Suppressed[reason]))
+	}
+
+	gauge(&b, MetricSends, "Clusters sent and failed, by channel.")
+	for _, channel := range keys(r.Sent) {
+		sample(&b, MetricSends, []string{"channel", channel, "status", "sent"}, float64(r.Sent[channel]))
+	}
+	for _, channel := range keys(r.Failed) {
+		sample(&b, MetricSends, []string{"channel", channel, "status", "failed"}, float64(r.Failed[channel]))
+	}
+
+	gauge(&b, MetricErrors, "Stages that failed without stopping the run.")
+	sample(&b, MetricErrors, nil, float64(r.Errors))
+
+	gauge(&b, MetricLastRun, "Time of the run.")
+	sample(&b, MetricLastRun, nil, float64(at.Unix()))
+
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("metrics.Write: %w", err)
+	}
+	return nil
+}
+
+// gauge writes the HELP and TYPE lines of a gauge.
+func gauge(b *strings.Builder, name, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+}
+
+// sample writes a sample with its label name and value pairs.
+func sample(b *strings.Builder, name string, labels []string, value float64) {
+	b.WriteString(name)
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(b, "%s=%q", labels[i], labels[i+1])
+		}
+		b.WriteByte('}')
+	}
+	fmt.Fprintf(b, " %g\n", value)
+}
+
+// keys returns the keys of the map in order.
+func keys(m map[string]int) []string {
+	list := make([]string, 0, len(m))
+	for k := range m {
+		list = append(list, k)
+	}
+	sort.Strings(list)
+	return list
+}
@@ -1,12 +1,23 @@
 package model
 
-import "time"
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Player statuses derived from the offline duration.
+const (
+	StatusOnline  = "online"
+	StatusOffline = "offline"
+)
 
 // The Player represents a user or entity with specific attributes within a system.
 type Player struct {
 	Number       int       `json:"number"`
 	ID           int       `json:"ID"`
 	GroupName    string    `json:"groupName"`
+	GroupPath    []string  `json:"groupPath,omitempty"` // Segments of GroupName, from the root group down
 	PlayerName   string    `json:"panelName"`
 	Tags         []string  `json:"tags"`
 	ScheduleName string    `json:"scheduleName"`
@@ -14,29 +25,94 @@ type Player struct {
 	LastOnline   time.Time `json:"lastOnline"`
 	Serial       string    `json:"serial"`
 	MAC          string    `json:"MAC"`
-	IP           string    `json:"IP"`
+	IP           string    `json:"IP"`            // Primary address, the first of IPs
+	IPs          []string  `json:"IPs,omitempty"` // Every valid IPv4 and IPv6 address reported by the device
 	Type         string    `json:"type"`
 	Model        string    `json:"model"`
 	Version      string    `json:"version"`
 	StoreNumber  int       `json:"storeNumber"`
 	CompanyName  string    `json:"companyName"`
+	Deleted      bool      `json:"deleted,omitempty"`  // Tombstone of a delta feed, the player was removed upstream
+	Source       string    `json:"source,omitempty"`   // Name of the data source, empty for the default DATA_URL source
+	Lab          bool      `json:"lab,omitempty"`      // Tagged with the test store number and routed to the lab cluster
+	Location     string    `json:"location,omitempty"` // Screen location from the inventory, e.g. "Entrance screen, 2nd floor"
+	Address      string    `json:"address,omitempty"`  // Store address from the inventory
+	Phone        string    `json:"phone,omitempty"`    // Store contact phone from the inventory
+	Identity     string    `json:"identity,omitempty"` // Key of the configured identity strategy, see Key
+	Severity     string    `json:"severity,omitempty"` // Severity tier reached by the offline duration, see Tiers
+
+	OfflineSince time.Time `json:"offlineSince,omitempty"` // Start of the offline streak known from earlier runs, see the trend package
+	Reported     int       `json:"reported,omitempty"`     // Earlier runs the player was notified in
+}
+
+// Status returns StatusOffline if the player has been offline at the given time for longer than maxOffline,
+// and StatusOnline otherwise.
+func (p *Player) Status(at time.Time, maxOffline time.Duration) string {
+	if at.Sub(p.LastOnline).Hours() > maxOffline.Hours() {
+		return StatusOffline
+	}
+	return StatusOnline
+}
+
+// Key returns a stable identity of the player across runs: the identity set by the DATA_ID_FIELDS strategy,
+// or else the MAC address, the serial number, or the source ID, whichever is set first.
+func (p *Player) Key() string {
+	switch {
+	case p.Identity != "":
+		return p.Identity
+	case p.MAC != "":
+		return p.MAC
+	case p.Serial != "":
+		return p.Serial
+	default:
+		return strconv.Itoa(p.ID)
+	}
+}
+
+// Addresses returns every address of the player separated by commas, or the primary address when IPs is not set.
+func (p *Player) Addresses() string {
+	if len(p.IPs) == 0 {
+		return p.IP
+	}
+	return strings.Join(p.IPs, ", ")
+}
+
+// ParseGroupPath splits a group name like "Retail/Closed/Store 12" into its segments.
+// Spaces around segments are trimmed and empty segments are dropped.
+func ParseGroupPath(groupName string) []string {
+	var path []string
+	for _, segment := range strings.Split(groupName, "/") {
+		if segment = strings.TrimSpace(segment); segment != "" {
+			path = append(path, segment)
+		}
+	}
+	return path
+}
+
+// Path returns the group path of the player, parsing GroupName when GroupPath is not set.
+func (p *Player) Path() []string {
+	if p.GroupPath != nil {
+		return p.GroupPath
+	}
+	return ParseGroupPath(p.GroupName)
 }
 
-// PlayerReceive represents the raw JSON structure for player data received from an external source.
+// PlayerReceive represents the raw JSON or XML structure for player data received from an external source.
 // Fields include metadata about the player such as ID, group name, tags, and network details.
 type PlayerReceive struct {
-	Number       int    `json:"number"`
-	ID           string `json:"id"`
-	GroupName    string `json:"group_name"`
-	PlayerName   string `json:"panel_name"`
-	Tags         string `json:"f_tag"`
-	ScheduleName string `json:"schedule_name"`
-	TimeZoneDiff string `json:"timezone_diff"`
-	LastOnline   string `json:"last_online"`
-	Serial       string `json:"serial"`
-	MAC          string `json:"mac"`
-	IP           string `json:"ip"`
-	Type         string `json:"type"`
-	Model        string `json:"model"`
-	Version      string `json:"v"`
+	Number       int    `json:"number" xml:"number"`
+	ID           string `json:"id" xml:"id"`
+	GroupName    string `json:"group_name" xml:"group_name"`
+	PlayerName   string `json:"panel_name" xml:"panel_name"`
+	Tags         string `json:"f_tag" xml:"f_tag"`
+	ScheduleName string `json:"schedule_name" xml:"schedule_name"`
+	TimeZoneDiff string `json:"timezone_diff" xml:"timezone_diff"`
+	LastOnline   string `json:"last_online" xml:"last_online"`
+	Serial       string `json:"serial" xml:"serial"`
+	MAC          string `json:"mac" xml:"mac"`
+	IP           string `json:"ip" xml:"ip"`
+	Type         string `json:"type" xml:"type"`
+	Model        string `json:"model" xml:"model"`
+	Version      string `json:"v" xml:"v"`
+	Deleted      bool   `json:"deleted" xml:"deleted"`
 }
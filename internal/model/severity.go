@@ -0,0 +1,97 @@
+package model
+
+import (
+	"sort"
+	"time"
+)
+
+// Tier is a severity reached by players offline for longer than After.
+type Tier struct {
+	Name  string
+	After time.Duration
+}
+
+// Tiers are severity tiers ordered by their threshold, the least severe first.
+type Tiers []Tier
+
+// SeverityGroup is the players of a cluster in a severity tier.
+type SeverityGroup struct {
+	Name    string
+	Players []*Player
+}
+
+// NewTiers orders the configured thresholds of the tiers, e.g. warning:24h and critical:72h.
+func NewTiers(thresholds map[string]time.Duration) Tiers {
+	tiers := make(Tiers, 0, len(thresholds))
+	for name, after := range thresholds {
+		tiers = append(tiers, Tier{Name: name, After: after})
+	}
+	sort.Slice(tiers, func(i, j int) bool {
+		if tiers[i].After != tiers[j].After {
+			return tiers[i].After < tiers[j].After
+		}
+		return tiers[i].Name < tiers[j].Name
+	})
+	return tiers
+}
+
+// Of returns the most severe tier reached by a player last online at lastOnline, or an empty string for none.
+func (t Tiers) Of(lastOnline, now time.Time) string {
+	offline := now.Sub(lastOnline)
+	for i := len(t) - 1; i >= 0; i-- {
+		if offline > t[i].After {
+			return t[i].Name
+		}
+	}
+	return ""
+}
+
+// Assign sets the severity of every player at now.
+func (t Tiers) Assign(players []*Player, now time.Time) {
+	for _, p := range players {
+		p.Severity = t.Of(p.LastOnline, now)
+	}
+}
+
+// ClusterSeverity returns the severity of the player offline the longest, which is the most severe of the cluster
+// since tiers grow with the offline duration. Returns an empty string when no player has a severity.
+func ClusterSeverity(players []*Player) string {
+	var worst *Player
+	for _, p := range players {
+		if p.Severity != "" && (worst == nil || p.LastOnline.Before(worst.LastOnline)) {
+			worst = p
+		}
+	}
+	if worst == nil {
+		return ""
+	}
+	return worst.Severity
+}
+
+// GroupBySeverity groups the players by severity, the most severe group first.
+// Players keep their order within a group; players without a severity are left out.
+func GroupBySeverity(players []*Player) []SeverityGroup {
+	var groups []SeverityGroup
+	oldest := make(map[string]time.Time)
+	index := make(map[string]int)
+
+	for _, p := range players {
+		if p.Severity == "" {
+			continue
+		}
+		i, ok := index[p.Severity]
+		if !ok {
+			i = len(groups)
+			index[p.Severity] = i
+			groups = append(groups, SeverityGroup{Name: p.Severity})
+			oldest[p.Severity] = p.LastOnline
+		}
+		groups[i].Players = append(groups[i].Players, p)
+		if p.LastOnline.Before(oldest[p.Severity]) {
+			oldest[p.Severity] = p.LastOnline
+		}
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool { return oldest[groups[i].Name].Before(oldest[groups[j].Name]) })
+	return groups
+}
@@ -0,0 +1,118 @@
+package model
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Version is a parsed firmware or application version.
+// Parts holds the numeric components, e.g. [2 3 1 456] for "2.3.1.456", and Pre a pre-release suffix such as "beta1".
+type Version struct {
+	Parts []int
+	Pre   string
+}
+
+// ParseVersion parses a version string tolerating vendor quirks: a leading "v" or product name ("Player v2.3"),
+// "_" instead of dots, pre-release suffixes ("2.3.1-beta1") and build metadata ("2.3.1+456", "2.3.1 (build 456)" keep 2.3.1).
+// Reports false when the string holds no numeric component.
+func ParseVersion(raw string) (Version, bool) {
+	s := strings.TrimSpace(raw)
+
+	// Skip everything before the first digit, e.g. "v", "ver." or a product name
+	i := strings.IndexFunc(s, unicode.IsDigit)
+	if i < 0 {
+		return Version{}, false
+	}
+	s = s[i:]
+
+	var v Version
+	for s != "" {
+		end := strings.IndexFunc(s, func(r rune) bool { return !unicode.IsDigit(r) })
+		if end < 0 {
+			end = len(s)
+		}
+
+		n, err := strconv.Atoi(s[:end])
+		if err != nil {
+			break
+		}
+		v.Parts = append(v.Parts, n)
+		s = s[end:]
+
+		if len(s) < 2 || !strings.ContainsRune("._", rune(s[0])) || !unicode.IsDigit(rune(s[1])) {
+			break
+		}
+		s = s[1:]
+	}
+
+	if pre := strings.TrimLeft(s, "- "); pre != "" && pre[0] != '(' && pre[0] != '+' {
+		v.Pre = strings.ToLower(strings.Fields(pre)[0])
+	}
+
+	return v, len(v.Parts) > 0
+}
+
+// String returns the canonical dotted form of the version, e.g. "2.3.1-beta1".
+func (v Version) String() string {
+	parts := make([]string, len(v.Parts))
+	for i, n := range v.Parts {
+		parts[i] = strconv.Itoa(n)
+	}
+
+	s := strings.Join(parts, ".")
+	if v.Pre != "" {
+		s += "-" + v.Pre
+	}
+	return s
+}
+
+// Compare returns -1, 0 or +1 as v is older than, equal to, or newer than o.
+// Missing components count as zero, and a pre-release is older than the release with the same components.
+func (v Version) Compare(o Version) int {
+	for i := 0; i < max(len(v.Parts), len(o.Parts)); i++ {
+		a, b := part(v.Parts, i), part(o.Parts, i)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+	}
+
+	switch {
+	case v.Pre == o.Pre:
+		return 0
+	case v.Pre == "":
+		return 1
+	case o.Pre == "":
+		return -1
+	case v.Pre < o.Pre:
+		return -1
+	default:
+		return 1
+	}
+}
+
+// Less reports whether v is older than o.
+func (v Version) Less(o Version) bool {
+	return v.Compare(o) < 0
+}
+
+// Minor returns the major.minor line of the version, used to bucket version distributions.
+func (v Version) Minor() string {
+	return strconv.Itoa(part(v.Parts, 0)) + "." + strconv.Itoa(part(v.Parts, 1))
+}
+
+// part returns the i-th component, or zero when it is missing.
+func part(parts []int, i int) int {
+	if i < len(parts) {
+		return parts[i]
+	}
+	return 0
+}
+
+// ParsedVersion parses the Version of the player. Reports false when it cannot be parsed.
+func (p *Player) ParsedVersion() (Version, bool) {
+	return ParseVersion(p.Version)
+}
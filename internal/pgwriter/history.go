@@ -0,0 +1,129 @@
+package pgwriter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go-players-data/internal/logger"
+	"go-players-data/internal/model"
+)
+
+// historyColumns lists the columns of the history table, one row per player and run. The table is expected to be created as:
+//
+//	CREATE TABLE player_history (
+//	    run_id text NOT NULL, run_at timestamptz NOT NULL, mac text NOT NULL, serial text NOT NULL,
+//	    store_number integer, company_name text, status text, offline_hours double precision, last_online timestamptz,
+//	    PRIMARY KEY (run_at, mac, serial)
+//	);
+//	CREATE INDEX ON player_history (mac, serial, run_at);
+var historyColumns = []string{
+	"run_id", "run_at", "mac", "serial", "store_number", "company_name", "status", "offline_hours", "last_online",
+}
+
+// Uptime is the share of the runs of a period a player was online in.
+type Uptime struct {
+	MAC         string    `json:"mac"`
+	Serial      string    `json:"serial"`
+	StoreNumber int       `json:"store_number"`
+	CompanyName string    `json:"company_name"`
+	Runs        int       `json:"runs"`
+	OnlineRuns  int       `json:"online_runs"`
+	Percent     float64   `json:"percent"`
+	LastOnline  time.Time `json:"last_online"`
+	Breached    bool      `json:"breached,omitempty"` // Below the uptime SLA
+}
+
+// insertHistory builds a multi-row INSERT statement of the batch into the history table and its arguments.
+// Rows of a run written twice are skipped.
+func (w *writer) insertHistory(runID string, runAt time.Time, batch []*model.Player) (string, []interface{}) {
+	var query strings.Builder
+	args := make([]interface{}, 0, len(batch)*len(historyColumns))
+
+	fmt.Fprintf(&query, "INSERT INTO %s (%s) VALUES ", w.historyTable, strings.Join(historyColumns, ", "))
+
+	for i, p := range batch {
+		if i != 0 {
+			query.WriteString(", ")
+		}
+
+		query.WriteByte('(')
+		for j := range historyColumns {
+			if j != 0 {
+				query.WriteString(", ")
+			}
+			fmt.Fprintf(&query, "$%d", i*len(historyColumns)+j+1)
+		}
+		query.WriteByte(')')
+
+		args = append(args,
+			runID, runAt, p.MAC, p.Serial, p.StoreNumber, p.CompanyName,
+			p.Status(runAt, w.maxOffline), runAt.Sub(p.LastOnline).Hours(), p.LastOnline,
+		)
+	}
+
+	query.WriteString(" ON CONFLICT DO NOTHING")
+
+	return query.String(), args
+}
+
+// Uptime returns the uptime of every player recorded in the history table between from and to, lowest first.
+// A store number above zero limits it to the players of the store. Players below the uptime SLA are marked breached.
+func (w *writer) Uptime(ctx context.Context, from, to time.Time, storeNumber int) ([]Uptime, error) {
+	start := time.Now()
+	defer func() { logger.Debug("pgwriter.Uptime: Time spent", "time", time.Since(start).String()) }()
+
+	if w.historyTable == "" {
+		return nil, fmt.Errorf("pgwriter.Uptime: %w", ErrNoHistory)
+	}
+
+	db, err := w.open()
+	if err != nil {
+		return nil, fmt.Errorf("pgwriter.Uptime: %w", err)
+	}
+
+	query := fmt.Sprintf(`SELECT mac, serial, coalesce(max(store_number), 0), coalesce(max(company_name), ''), count(*),
+	count(*) FILTER (WHERE status = $1), max(last_online)
+FROM %s
+WHERE run_at >= $2 AND run_at < $3 AND ($4 = 0 OR store_number = $4)
+GROUP BY mac, serial`, w.historyTable)
+
+	rows, err := db.QueryContext(ctx, query, model.StatusOnline, from, to, storeNumber)
+	if err != nil {
+		return nil, fmt.Errorf("pgwriter.Uptime: failed to query: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var uptimes []Uptime
+	for rows.Next() {
+		var u Uptime
+		var lastOnline sql.NullTime
+		if err = rows.Scan(&u.MAC, &u.Serial, &u.StoreNumber, &u.CompanyName, &u.Runs, &u.OnlineRuns, &lastOnline); err != nil {
+			return nil, fmt.Errorf("pgwriter.Uptime: failed to scan: %w", err)
+		}
+		u.LastOnline = lastOnline.Time
+
+		if u.Runs > 0 {
+			u.Percent = float64(u.OnlineRuns) * 100 / float64(u.Runs)
+		}
+		u.Breached = w.uptimeSLA > 0 && u.Percent < w.uptimeSLA
+
+		uptimes = append(uptimes, u)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("pgwriter.Uptime: %w", err)
+	}
+
+	sort.Slice(uptimes, func(i, j int) bool {
+		if uptimes[i].Percent != uptimes[j].Percent {
+			return uptimes[i].Percent < uptimes[j].Percent
+		}
+		return uptimes[i].MAC+uptimes[i].Serial < uptimes[j].MAC+uptimes[j].Serial
+	})
+
+	logger.Info("pgwriter.Uptime: Uptime computed", "players", len(uptimes), "from", from, "to", to)
+	return uptimes, nil
+}
@@ -0,0 +1,220 @@
+package pgwriter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+
+	"go-players-data/internal/config"
+	"go-players-data/internal/logger"
+	"go-players-data/internal/model"
+)
+
+// batchSizeDefault is used when the configured batch size is not positive.
+const batchSizeDefault = 500
+
+// ErrNoHistory is returned when uptime is asked without a history table.
+var ErrNoHistory = errors.New("history table is not configured")
+
+// columns lists the player_status columns written on every upsert. The table is expected to be created as:
+//
+//	CREATE TABLE player_status (
+//	    mac text NOT NULL, serial text NOT NULL, player_name text, group_name text,
+//	    store_number integer, company_name text, status text, offline_hours double precision,
+//	    last_online timestamptz, run_id text, updated_at timestamptz,
+//	    PRIMARY KEY (mac, serial)
+//	);
+var columns = []string{
+	"mac", "serial", "player_name", "group_name", "store_number", "company_name",
+	"status", "offline_hours", "last_online", "run_id", "updated_at",
+}
+
+// writer is a struct that upserts the current status of every player into a PostgreSQL table,
+// and appends it to the history table when one is configured.
+// The connection pool is opened on first use and kept for the following runs until Close.
+type writer struct {
+	dsn          string
+	table        string
+	historyTable string
+	batchSize    int
+	maxOffline   time.Duration
+	uptimeSLA    float64
+
+	mu sync.Mutex
+	db *sql.DB
+}
+
+// Writer is an interface for persisting the player rows of a single run and reading the uptime back from the history.
+type Writer interface {
+	Write(ctx context.Context, runID string, runAt time.Time, players []*model.Player) error
+	Uptime(ctx context.Context, from, to time.Time, storeNumber int) ([]Uptime, error)
+	Close() error
+}
+
+// New creates a new Writer for the configured PostgreSQL database and table.
+func New(cfg config.Postgres, maxOffline time.Duration) Writer {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = batchSizeDefault
+	}
+
+	return &writer{
+		dsn:          cfg.DSN,
+		table:        cfg.Table,
+		historyTable: cfg.HistoryTable,
+		batchSize:    cfg.BatchSize,
+		maxOffline:   maxOffline,
+		uptimeSLA:    cfg.UptimeSLA,
+	}
+}
+
+// Write upserts the players keyed by MAC and serial in multi-row batches within a single transaction.
+// With a history table, a row per player of the run is appended to it in the same transaction.
+// Players sharing a key are written once and players without MAC and serial are skipped, see unique.
+func (w *writer) Write(ctx context.Context, runID string, runAt time.Time, players []*model.Player) error {
+	start := time.Now()
+	defer func() { logger.Debug("pgwriter.Write: Time spent", "time", time.Since(start).String()) }()
+
+	players, skipped := unique(players)
+	if skipped > 0 {
+		logger.Warn("pgwriter.Write: Players without a unique MAC and serial skipped", "skipped", skipped)
+	}
+
+	db, err := w.open()
+	if err != nil {
+		return fmt.Errorf("pgwriter.Write: %w", err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("pgwriter.Write: failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for i := 0; i < len(players); i += w.batchSize {
+		batch := players[i:min(i+w.batchSize, len(players))]
+
+		query, args := w.upsert(runID, runAt, batch)
+		if _, err = tx.ExecContext(ctx, query, args...); err != nil {
+			return fmt.Errorf("pgwriter.Write: failed to upsert batch at %d: %w", i, err)
+		}
+
+		if w.historyTable == "" {
+			continue
+		}
+		query, args = w.insertHistory(runID, runAt, batch)
+		if _, err = tx.ExecContext(ctx, query, args...); err != nil {
+			return fmt.Errorf("pgwriter.Write: failed to insert history batch at %d: %w", i, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("pgwriter.Write: failed to commit: %w", err)
+	}
+
+	logger.Info("pgwriter.Write: Players stored", "table", w.table, "players", len(players))
+	return nil
+}
+
+// open returns the connection pool of the writer, opening it on first use.
+func (w *writer) open() (*sql.DB, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.db != nil {
+		return w.db, nil
+	}
+
+	db, err := sql.Open("pgx", w.dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	w.db = db
+	return w.db, nil
+}
+
+// Close closes the connection pool of the writer, if it was opened. The next call opens it again.
+func (w *writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.db == nil {
+		return nil
+	}
+
+	err := w.db.Close()
+	w.db = nil
+	if err != nil {
+		return fmt.Errorf("pgwriter.Close: %w", err)
+	}
+
+	return nil
+}
+
+// unique returns the players keyed by a MAC or serial, keeping the last of the players sharing both, and the number left out.
+// A multi-row upsert fails when two of its rows have the same key, and players without either would overwrite each other.
+func unique(players []*model.Player) ([]*model.Player, int) {
+	type key struct{ mac, serial string }
+
+	index := make(map[key]int, len(players))
+	result := make([]*model.Player, 0, len(players))
+	for _, p := range players {
+		if p.MAC == "" && p.Serial == "" {
+			continue
+		}
+
+		k := key{p.MAC, p.Serial}
+		if i, ok := index[k]; ok {
+			result[i] = p
+			continue
+		}
+		index[k] = len(result)
+		result = append(result, p)
+	}
+
+	return result, len(players) - len(result)
+}
+
+// upsert builds a multi-row INSERT ... ON CONFLICT statement and its arguments for the batch.
+func (w *writer) upsert(runID string, runAt time.Time, batch []*model.Player) (string, []interface{}) {
+	var query strings.Builder
+	args := make([]interface{}, 0, len(batch)*len(columns))
+
+	fmt.Fprintf(&query, "INSERT INTO %s (%s) VALUES ", w.table, strings.Join(columns, ", "))
+
+	for i, p := range batch {
+		if i != 0 {
+			query.WriteString(", ")
+		}
+
+		query.WriteByte('(')
+		for j := range columns {
+			if j != 0 {
+				query.WriteString(", ")
+			}
+			fmt.Fprintf(&query, "$%d", i*len(columns)+j+1)
+		}
+		query.WriteByte(')')
+
+		args = append(args,
+			p.MAC, p.Serial, p.PlayerName, p.GroupName, p.StoreNumber, p.CompanyName,
+			p.Status(runAt, w.maxOffline), runAt.Sub(p.LastOnline).Hours(), p.LastOnline, runID, runAt,
+		)
+	}
+
+	query.WriteString(" ON CONFLICT (mac, serial) DO UPDATE SET ")
+	for i, c := range columns[2:] {
+		if i != 0 {
+			query.WriteString(", ")
+		}
+		fmt.Fprintf(&query, "%s = EXCLUDED.%s", c, c)
+	}
+
+	return query.String(), args
+}
@@ -0,0 +1,138 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"go-players-data/internal/fetcher"
+	"go-players-data/internal/filter"
+	"go-players-data/internal/logger"
+	"go-players-data/internal/model"
+	"go-players-data/internal/player"
+)
+
+// Options selects what the pipeline keeps besides the filtered players.
+// Both are off by default, so memory stays proportional to the offline players rather than the payload.
+type Options struct {
+	KeepPayload bool // Keep the raw payload, e.g. for the archive
+	KeepAll     bool // Keep every parsed player, e.g. for the status writers
+}
+
+// Source is a named data source. Players of a named source are tagged with its name;
+// the default source has an empty name.
+type Source struct {
+	Name    string
+	Fetcher fetcher.Fetcher
+}
+
+// Result is the outcome of a single pipeline run.
+type Result struct {
+	Total    int               // Parsed players
+	Skipped  int               // Raw players skipped because of invalid data
+	Payloads map[string][]byte // Raw payload of every source keyed by source name, set with Options.KeepPayload
+	All      []*model.Player   // Every parsed player, set with Options.KeepAll
+	Players  []*model.Player   // Players that passed the filter
+
+	Versions map[string]int // Parsed players by major.minor version line, "unknown" for unparsable versions
+	Outdated int            // Parsed players older than the minimum version
+}
+
+// pipeline is a struct that streams players from the sources through the parser and the filter.
+type pipeline struct {
+	sources  []Source
+	parser   player.Parser
+	criteria filter.Criteria
+}
+
+// Pipeline is an interface for running fetch, parse and filter as a single streaming pass.
+type Pipeline interface {
+	Run(ctx context.Context, opts Options) (*Result, error)
+}
+
+// New creates a new Pipeline from the sources, parser and filter stages.
+func New(sources []Source, p player.Parser, c filter.Criteria) Pipeline {
+	return &pipeline{
+		sources:  sources,
+		parser:   p,
+		criteria: c,
+	}
+}
+
+// Run decodes players while the response of every source is being received and filters each one as soon as it is parsed,
+// so the payload and the full player list are materialized only when requested in opts.
+// Sources are read one after another and their players merged.
+func (p *pipeline) Run(ctx context.Context, opts Options) (*Result, error) {
+	start := time.Now()
+	defer func() { logger.Debug("pipeline.Run: Time spent", "time", time.Since(start).String()) }()
+
+	result := Result{Versions: make(map[string]int)}
+	if opts.KeepPayload {
+		result.Payloads = make(map[string][]byte, len(p.sources))
+	}
+
+	for _, source := range p.sources {
+		payload, err := p.runSource(ctx, source, opts, &result)
+		if err != nil {
+			return nil, fmt.Errorf("pipeline.Run: source %q: %w", source.Name, err)
+		}
+		if opts.KeepPayload {
+			// Payloads are kept as fetched, CSV and XML ones are not JSON and cannot be embedded in a JSON document
+			result.Payloads[source.Name] = payload
+		}
+	}
+
+	logger.Debug("pipeline.Run: Players", "filtered", len(result.Players), "total", result.Total, "skipped", result.Skipped)
+
+	return &result, nil
+}
+
+// runSource streams the players of a single source into the result and returns its raw payload when it is kept.
+func (p *pipeline) runSource(ctx context.Context, source Source, opts Options, result *Result) ([]byte, error) {
+	body, err := source.Fetcher.Stream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = body.Close() }()
+
+	var (
+		src     io.Reader = body
+		payload bytes.Buffer
+	)
+	if opts.KeepPayload {
+		src = io.TeeReader(body, &payload)
+	}
+
+	err = p.parser.Stream(src, func(pl *model.Player) error {
+		pl.Source = source.Name
+		result.Total++
+
+		if v, ok := pl.ParsedVersion(); ok {
+			result.Versions[v.Minor()]++
+		} else {
+			result.Versions["unknown"]++
+		}
+		if p.criteria.Outdated(pl) {
+			result.Outdated++
+		}
+
+		if opts.KeepAll {
+			result.All = append(result.All, pl)
+		}
+
+		if p.criteria.Keep(pl) {
+			result.Players = append(result.Players, pl)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result.Skipped += p.parser.Skipped()
+
+	return payload.Bytes(), nil
+}
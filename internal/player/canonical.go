@@ -0,0 +1,142 @@
+package player
+
+import (
+	"strings"
+	"unicode"
+
+	"go-players-data/internal/logger"
+)
+
+// Canonicalization steps of company name tags, applied in the configured order before the alias lookup.
+const (
+	StepSuffixes = "suffixes" // Strip legal form words such as LLC or ООО
+	StepFold     = "fold"     // Lower-case
+	StepTranslit = "translit" // Transliterate Cyrillic to Latin
+)
+
+// legalSuffixesDefault are the legal form words stripped by the suffixes step when none are configured.
+var legalSuffixesDefault = []string{"LLC", "LTD", "INC", "CORP", "GMBH", "ООО", "ОАО", "ЗАО", "ПАО", "АО", "ИП"}
+
+// quotes are stripped from company names together with legal form words, e.g. ООО «Ромашка».
+const quotes = "\"'«»„“”"
+
+// translit maps lower-case Cyrillic letters to Latin; upper-case letters are mapped through their lower-case form.
+var translit = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh", 'з': "z", 'и': "i",
+	'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t",
+	'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "",
+	'э': "e", 'ю': "yu", 'я': "ya",
+}
+
+// canonicalizer rewrites company name tags into a canonical form, so differently spelled tags hit the same alias.
+type canonicalizer struct {
+	steps    []string
+	suffixes map[string]struct{}
+}
+
+// newCanonicalizer builds a canonicalizer running the steps in order. Unknown steps are logged and skipped.
+// A nil canonicalizer is returned when there are no steps, keeping the exact alias lookup.
+func newCanonicalizer(steps, suffixes []string) *canonicalizer {
+	if len(steps) == 0 {
+		return nil
+	}
+	if len(suffixes) == 0 {
+		suffixes = legalSuffixesDefault
+	}
+
+	c := &canonicalizer{suffixes: make(map[string]struct{}, len(suffixes))}
+	for _, s := range suffixes {
+		c.suffixes[strings.ToUpper(s)] = struct{}{}
+	}
+
+	for _, step := range steps {
+		switch step = strings.ToLower(strings.TrimSpace(step)); step {
+		case StepSuffixes, StepFold, StepTranslit:
+			c.steps = append(c.steps, step)
+		default:
+			logger.Warn("parser.newCanonicalizer: Unknown canonicalization step", "step", step)
+		}
+	}
+
+	return c
+}
+
+// canonical returns the canonical form of the name: the configured steps applied in order,
+// with spaces trimmed and collapsed. A nil canonicalizer returns the name unchanged.
+func (c *canonicalizer) canonical(name string) string {
+	if c == nil {
+		return name
+	}
+
+	for _, step := range c.steps {
+		switch step {
+		case StepSuffixes:
+			name = c.stripSuffixes(name)
+		case StepFold:
+			name = strings.ToLower(name)
+		case StepTranslit:
+			name = transliterate(name)
+		}
+	}
+
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// aliases returns the alias map keyed by the canonical forms of its keys.
+// Keys colliding after canonicalization are logged, and the last one wins.
+func (c *canonicalizer) aliases(companies map[string]string) map[string]string {
+	if c == nil {
+		return companies
+	}
+
+	canonical := make(map[string]string, len(companies))
+	for k, v := range companies {
+		key := c.canonical(k)
+		if prev, ok := canonical[key]; ok && prev != v {
+			logger.Warn("parser.aliases: Company aliases collide after canonicalization", "key", key, "values", []string{prev, v})
+		}
+		canonical[key] = v
+	}
+
+	return canonical
+}
+
+// stripSuffixes removes quotes and legal form words at any position of the name.
+func (c *canonicalizer) stripSuffixes(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(quotes, r) {
+			return ' '
+		}
+		return r
+	}, name)
+
+	words := strings.FieldsFunc(name, func(r rune) bool { return unicode.IsSpace(r) || r == ',' })
+	kept := words[:0]
+	for _, w := range words {
+		if _, ok := c.suffixes[strings.ToUpper(strings.Trim(w, "."))]; !ok {
+			kept = append(kept, w)
+		}
+	}
+
+	return strings.Join(kept, " ")
+}
+
+// transliterate replaces Cyrillic letters with Latin ones, keeping the case of the first letter of each replacement.
+func transliterate(name string) string {
+	var b strings.Builder
+	b.Grow(len(name))
+
+	for _, r := range name {
+		lat, ok := translit[unicode.ToLower(r)]
+		if !ok {
+			b.WriteRune(r)
+			continue
+		}
+		if unicode.IsUpper(r) && lat != "" {
+			lat = strings.ToUpper(lat[:1]) + lat[1:]
+		}
+		b.WriteString(lat)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,141 @@
+package player
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	"go-players-data/internal/logger"
+	"go-players-data/internal/model"
+)
+
+// batchSize is the number of raw players decoded before they are converted in parallel.
+const batchSize = 512
+
+// rawPool reuses decoded raw players between batches, so decoding does not allocate a struct per player.
+var rawPool = sync.Pool{
+	New: func() interface{} { return new(model.PlayerReceive) },
+}
+
+// tagMatcher applies a tag with the given prefix to a player. Matchers are built once in New
+// and tried in order, the first matching prefix wins.
+type tagMatcher struct {
+	prefix string
+	apply  func(p *parser, player *model.Player, value string)
+}
+
+// newTagMatchers builds the tag matchers for the configured store number and company name prefixes.
+func newTagMatchers(storeNumberPrefix, companyNamePrefix string) []tagMatcher {
+	return []tagMatcher{
+		{prefix: storeNumberPrefix, apply: (*parser).applyStoreNumber},
+		{prefix: companyNamePrefix, apply: (*parser).applyCompanyName},
+	}
+}
+
+// getRaw returns a zeroed raw player from the pool.
+func getRaw() *model.PlayerReceive {
+	raw := rawPool.Get().(*model.PlayerReceive)
+	*raw = model.PlayerReceive{}
+	return raw
+}
+
+// convertBatch converts the batch of raw players in parallel, splitting it into contiguous chunks per worker.
+// The result keeps the order of the batch; entries with invalid data are nil.
+// Every worker counts its own skipped entries, summed once the workers are done.
+// The raw players are returned to the pool.
+func (p *parser) convertBatch(batch []*model.PlayerReceive, out []*model.Player) []*model.Player {
+	out = out[:len(batch)]
+
+	workers := min(p.workers, len(batch))
+	if workers <= 1 {
+		p.addSkipped(p.convertRange(batch, out))
+	} else {
+		chunk := (len(batch) + workers - 1) / workers
+		skipped := make([]int, workers)
+
+		var wg sync.WaitGroup
+		for w, from := 0, 0; from < len(batch); w, from = w+1, from+chunk {
+			to := min(from+chunk, len(batch))
+
+			wg.Add(1)
+			go func(w, from, to int) {
+				defer wg.Done()
+				skipped[w] = p.convertRange(batch[from:to], out[from:to])
+			}(w, from, to)
+		}
+		wg.Wait()
+
+		for _, n := range skipped {
+			p.addSkipped(n)
+		}
+	}
+
+	for _, raw := range batch {
+		rawPool.Put(raw)
+	}
+
+	return out
+}
+
+// convertRange converts raw players into out, leaving nil for entries that fail to convert,
+// and returns the number of those entries.
+func (p *parser) convertRange(batch []*model.PlayerReceive, out []*model.Player) int {
+	skipped := 0
+	for i, raw := range batch {
+		player, err := p.initPlayer(raw)
+		if err != nil {
+			logger.Error("parser.convertRange: Error initializing player", "err", err)
+			p.fault(err)
+			out[i] = nil
+			skipped++
+			continue
+		}
+		out[i] = player
+	}
+	return skipped
+}
+
+// parseDateTime parses a time.DateTime value ("2006-01-02 15:04:05") in UTC without the generic layout machinery
+// of time.Parse, falling back to time.Parse for anything that does not have the exact shape.
+func parseDateTime(s string) (time.Time, error) {
+	if len(s) != len(time.DateTime) || s[4] != '-' || s[7] != '-' || s[10] != ' ' || s[13] != ':' || s[16] != ':' {
+		return time.Parse(time.DateTime, s)
+	}
+
+	year, ok1 := digits(s[0:4])
+	month, ok2 := digits(s[5:7])
+	day, ok3 := digits(s[8:10])
+	hour, ok4 := digits(s[11:13])
+	minute, ok5 := digits(s[14:16])
+	second, ok6 := digits(s[17:19])
+	if !ok1 || !ok2 || !ok3 || !ok4 || !ok5 || !ok6 ||
+		month < 1 || month > 12 || day < 1 || hour > 23 || minute > 59 || second > 59 {
+		return time.Parse(time.DateTime, s)
+	}
+
+	t := time.Date(year, time.Month(month), day, hour, minute, second, 0, time.UTC)
+	if t.Day() != day {
+		// Day out of range for the month, let time.Parse report it
+		return time.Parse(time.DateTime, s)
+	}
+
+	return t, nil
+}
+
+// digits converts a string of ASCII digits to an int.
+func digits(s string) (int, bool) {
+	n := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + int(c-'0')
+	}
+	return n, true
+}
+
+// defaultWorkers returns the number of conversion workers used when DATA_PARSE_WORKERS is not set.
+func defaultWorkers() int {
+	return runtime.GOMAXPROCS(0)
+}
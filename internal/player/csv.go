@@ -0,0 +1,125 @@
+package player
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"go-players-data/internal/logger"
+	"go-players-data/internal/model"
+)
+
+// ErrCSVHeader is returned when the CSV header has no known column.
+var ErrCSVHeader = errors.New("csv header has no known column")
+
+// csvColumns sets the raw player field of a CSV column; columns are named after the JSON fields of model.PlayerReceive.
+var csvColumns = map[string]func(raw *model.PlayerReceive, value string) error{
+	"number": func(raw *model.PlayerReceive, value string) error {
+		if value == "" {
+			return nil
+		}
+		n, err := strconv.Atoi(value)
+		raw.Number = n
+		return err
+	},
+	"id":            func(raw *model.PlayerReceive, value string) error { raw.ID = value; return nil },
+	"group_name":    func(raw *model.PlayerReceive, value string) error { raw.GroupName = value; return nil },
+	"panel_name":    func(raw *model.PlayerReceive, value string) error { raw.PlayerName = value; return nil },
+	"f_tag":         func(raw *model.PlayerReceive, value string) error { raw.Tags = value; return nil },
+	"schedule_name": func(raw *model.PlayerReceive, value string) error { raw.ScheduleName = value; return nil },
+	"timezone_diff": func(raw *model.PlayerReceive, value string) error { raw.TimeZoneDiff = value; return nil },
+	"last_online":   func(raw *model.PlayerReceive, value string) error { raw.LastOnline = value; return nil },
+	"serial":        func(raw *model.PlayerReceive, value string) error { raw.Serial = value; return nil },
+	"mac":           func(raw *model.PlayerReceive, value string) error { raw.MAC = value; return nil },
+	"ip":            func(raw *model.PlayerReceive, value string) error { raw.IP = value; return nil },
+	"type":          func(raw *model.PlayerReceive, value string) error { raw.Type = value; return nil },
+	"model":         func(raw *model.PlayerReceive, value string) error { raw.Model = value; return nil },
+	"v":             func(raw *model.PlayerReceive, value string) error { raw.Version = value; return nil },
+	"deleted": func(raw *model.PlayerReceive, value string) error {
+		if value == "" {
+			return nil
+		}
+		b, err := strconv.ParseBool(value)
+		raw.Deleted = b
+		return err
+	},
+}
+
+// streamCSV decodes the CSV rows of raw players from r in batches.
+// The header names the columns; unknown columns are ignored and rows with invalid values are skipped and counted.
+func (p *parser) streamCSV(r io.Reader, fn func(player *model.Player) error) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		logger.Error("parser.streamCSV: Error reading header", "err", err)
+		return fmt.Errorf("parser.streamCSV: failed to read header: %w", err)
+	}
+
+	columns := make([]func(raw *model.PlayerReceive, value string) error, len(header))
+	known := 0
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, string(utf8BOM))))
+		if set, ok := csvColumns[name]; ok {
+			columns[i] = set
+			known++
+		}
+	}
+	if known == 0 {
+		logger.Error("parser.streamCSV: No known column in header", "header", header)
+		return fmt.Errorf("parser.streamCSV: %w: %v", ErrCSVHeader, header)
+	}
+
+	batch := make([]*model.PlayerReceive, 0, batchSize)
+	out := make([]*model.Player, batchSize)
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			logger.Error("parser.streamCSV: Error reading row", "err", err)
+			return fmt.Errorf("parser.streamCSV: failed to read row: %w", err)
+		}
+
+		raw := getRaw()
+		if err = setColumns(raw, columns, record); err != nil {
+			rawPool.Put(raw)
+			logger.Error("parser.streamCSV: Invalid row", "err", err, "row", record)
+			p.fault(err)
+			p.addSkipped(1)
+			continue
+		}
+
+		batch = append(batch, raw)
+		if len(batch) < batchSize {
+			continue
+		}
+
+		if err = p.emit(p.convertBatch(batch, out), fn); err != nil {
+			return err
+		}
+		batch = batch[:0]
+	}
+
+	return p.emit(p.convertBatch(batch, out), fn)
+}
+
+// setColumns sets the known columns of a CSV record on the raw player.
+func setColumns(raw *model.PlayerReceive, columns []func(raw *model.PlayerReceive, value string) error, record []string) error {
+	for i, value := range record {
+		if i >= len(columns) || columns[i] == nil {
+			continue
+		}
+		if err := columns[i](raw, strings.TrimSpace(value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
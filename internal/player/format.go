@@ -0,0 +1,47 @@
+package player
+
+import (
+	"bufio"
+	"bytes"
+)
+
+// Payload formats.
+const (
+	FormatAuto = "auto"
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+	FormatXML  = "xml"
+)
+
+// utf8BOM is the byte order mark some exporters put in front of their files.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// detectFormat returns the configured format, or detects it from the first byte of the payload:
+// a JSON array, an XML document, or CSV otherwise. Leading whitespace and a byte order mark are skipped.
+func (p *parser) detectFormat(r *bufio.Reader) string {
+	if p.format == FormatJSON || p.format == FormatCSV || p.format == FormatXML {
+		return p.format
+	}
+
+	if head, err := r.Peek(len(utf8BOM)); err == nil && bytes.Equal(head, utf8BOM) {
+		_, _ = r.Discard(len(utf8BOM))
+	}
+
+	for i := 1; ; i++ {
+		head, err := r.Peek(i)
+		if err != nil || len(head) < i {
+			return FormatJSON
+		}
+
+		switch c := head[i-1]; c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '[':
+			return FormatJSON
+		case '<':
+			return FormatXML
+		default:
+			return FormatCSV
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package player
+
+import (
+	"strconv"
+	"strings"
+
+	"go-players-data/internal/logger"
+	"go-players-data/internal/model"
+)
+
+// Identity fields of DATA_ID_FIELDS, tried in order to build the key of a player.
+const (
+	IDFieldMAC    = "mac"
+	IDFieldSerial = "serial"
+	IDFieldID     = "id"
+	IDFieldNumber = "number"
+	IDFieldName   = "name"
+)
+
+// identityField returns the value of an identity field of the player, empty when it is not set.
+type identityField func(player *model.Player) string
+
+// identityFields maps the identity field names to their values.
+var identityFields = map[string]identityField{
+	IDFieldMAC:    func(player *model.Player) string { return player.MAC },
+	IDFieldSerial: func(player *model.Player) string { return player.Serial },
+	IDFieldID: func(player *model.Player) string {
+		if player.ID == 0 {
+			return ""
+		}
+		return strconv.Itoa(player.ID)
+	},
+	IDFieldNumber: func(player *model.Player) string {
+		if player.Number == 0 {
+			return ""
+		}
+		return strconv.Itoa(player.Number)
+	},
+	IDFieldName: func(player *model.Player) string { return strings.TrimSpace(player.PlayerName) },
+}
+
+// newIdentity builds the identity strategy from the priority list of fields. Unknown fields are logged and dropped.
+// An empty list returns nil, leaving players on the default key of model.Player.Key.
+func newIdentity(fields []string) []identityField {
+	var identity []identityField
+	for _, field := range fields {
+		field = strings.ToLower(strings.TrimSpace(field))
+		f, ok := identityFields[field]
+		if !ok {
+			logger.Warn("parser.newIdentity: Unknown identity field", "field", field)
+			continue
+		}
+		identity = append(identity, f)
+	}
+	return identity
+}
+
+// applyIdentity sets the identity of the player to the first set field of the strategy.
+// A player without any of them keeps the default key.
+func (p *parser) applyIdentity(player *model.Player) {
+	for _, f := range p.identity {
+		if v := f(player); v != "" {
+			player.Identity = v
+			return
+		}
+	}
+}
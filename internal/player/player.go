@@ -1,13 +1,21 @@
 package player
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"go-players-data/internal/config"
+	"go-players-data/internal/fault"
 	"go-players-data/internal/logger"
 	"go-players-data/internal/model"
 )
@@ -15,36 +23,75 @@ import (
 // ErrParseID is returned when an error occurs while parsing or converting the ID field from input data.
 // ErrParseTZ is returned when an error occurs while parsing or converting the time zone from input data.
 // ErrParseLastOnline is returned when an error occurs while parsing the "last online" timestamp from input data.
+// ErrPayload is returned when the payload is not a JSON array.
 var (
 	ErrParseID         = errors.New("error parsing id")
 	ErrParseTZ         = errors.New("error parsing time zone") // ErrParseLastOnline is returned when an error occurs while parsing the "last online" timestamp from input data.
 	ErrParseLastOnline = errors.New("error parsing last online")
+	ErrPayload         = errors.New("payload is not a JSON array of players")
 )
 
+// faultStage is the stage the skipped entries are counted under.
+const faultStage = "parse"
+
 // parser is a struct that provides functionality to parse and transform data into structured and validated formats.
 type parser struct {
+	format            string
 	storeTestNumber   int
+	storeTestRoute    bool
 	storeNumberPrefix string
 	companyNamePrefix string
 	companies         map[string]string
+	canonicalizer     *canonicalizer
+	matchers          []tagMatcher
+	storeGroup        *regexp.Regexp
+	localTime         bool
+	identity          []identityField
+	faults            fault.Collector
+	workers           int
+	skipped           int64 // Accessed atomically, a parser may stream several payloads at once
 }
 
 // Parser is an interface for parsing raw byte data into structured player objects.
 type Parser interface {
 	Players(body []byte) ([]*model.Player, error)
+	Stream(r io.Reader, fn func(player *model.Player) error) error
+	Skipped() int
+	WithCollector(c fault.Collector) Parser
 }
 
 // New initializes and returns a new Parser instance configured with the provided configuration data.
 // It ensures that the Companies map is not nil, creating a new map if necessary.
+// Company name tags and the Companies keys are canonicalized by cfg.CompanyCanonicalSteps before the alias lookup.
+// cfg.Format selects JSON, CSV or XML payloads; any other value detects the format of every payload.
+// Conversion runs on cfg.ParseWorkers goroutines, or GOMAXPROCS when it is not set; see config.Must for its default.
+// cfg.IDFields is the priority list of fields building the key of every player, see model.Player.Key.
+// cfg.StoreGroupPattern extracts the store number from the group name of players without a store number tag.
 func New(cfg config.Data) Parser {
 	if cfg.Companies == nil {
 		cfg.Companies = make(map[string]string)
 	}
+
+	workers := cfg.ParseWorkers
+	if workers <= 0 {
+		workers = defaultWorkers()
+	}
+
+	c := newCanonicalizer(cfg.CompanyCanonicalSteps, cfg.CompanyLegalSuffixes)
+
 	return &parser{
+		format:            cfg.Format,
 		storeTestNumber:   cfg.StoreTestNumber,
+		storeTestRoute:    cfg.StoreTestRoute,
 		storeNumberPrefix: cfg.StoreNumberPrefix,
 		companyNamePrefix: cfg.CompanyNamePrefix,
-		companies:         cfg.Companies,
+		companies:         c.aliases(cfg.Companies),
+		canonicalizer:     c,
+		matchers:          newTagMatchers(cfg.StoreNumberPrefix, cfg.CompanyNamePrefix),
+		storeGroup:        newStoreGroup(cfg.StoreGroupPattern),
+		localTime:         cfg.LocalTime,
+		identity:          newIdentity(cfg.IDFields),
+		workers:           workers,
 	}
 }
 
@@ -54,12 +101,11 @@ func (p *parser) Players(body []byte) ([]*model.Player, error) {
 	start := time.Now()
 	defer func() { logger.Debug("parser.Players: Time spent", "time", time.Since(start).String()) }()
 
-	rawPlayers, err := p.parseRaw(body)
-	if err != nil {
-		return nil, err
-	}
-
-	players, err := p.rawToPlayers(rawPlayers)
+	var players []*model.Player
+	err := p.Stream(bytes.NewReader(body), func(player *model.Player) error {
+		players = append(players, player)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -67,34 +113,117 @@ func (p *parser) Players(body []byte) ([]*model.Player, error) {
 	return players, nil
 }
 
-// parseRaw parses raw JSON byte data into a slice of PlayerReceive objects
-// and returns it or an error if unmarshalling fails.
-func (p *parser) parseRaw(body []byte) ([]*model.PlayerReceive, error) {
-	var rawPlayers []*model.PlayerReceive
-	if err := json.Unmarshal(body, &rawPlayers); err != nil {
-		logger.Error("parser.ParseRaw: Error unmarshalling raw players", "err", err)
-		return nil, err
+// Stream decodes the raw players from r in batches and calls fn for every valid player in payload order,
+// so the payload is never held in memory as a whole. Each batch is converted in parallel.
+// The payload is a JSON array, CSV rows with a header or an XML report of <player> elements, as configured or detected from its first byte.
+// Entries with invalid data are skipped and counted.
+// Stops and returns the error of fn if it fails.
+func (p *parser) Stream(r io.Reader, fn func(player *model.Player) error) error {
+	atomic.StoreInt64(&p.skipped, 0)
+
+	br := bufio.NewReader(r)
+	switch p.detectFormat(br) {
+	case FormatCSV:
+		return p.streamCSV(br, fn)
+	case FormatXML:
+		return p.streamXML(br, fn)
+	default:
+		return p.streamJSON(br, fn)
 	}
-
-	return rawPlayers, nil
 }
 
-// rawToPlayers converts a slice of raw player data (PlayerReceive)
-// into a slice of validated and structured Players objects.
-// It initializes each player using the provided configuration and skips entries with errors during initialization.
-// Returns the resulting slice of Players objects and an error if critical processing issues occur.
-func (p *parser) rawToPlayers(rawPlayers []*model.PlayerReceive) ([]*model.Player, error) {
-	players := make([]*model.Player, 0, len(rawPlayers))
+// streamJSON decodes the JSON array of raw players from r in batches.
+func (p *parser) streamJSON(r io.Reader, fn func(player *model.Player) error) error {
+	dec := json.NewDecoder(r)
 
-	for _, raw := range rawPlayers {
-		player, err := p.initPlayer(raw)
-		if err != nil {
-			logger.Error("parser.RawToPlayer: Error initializing player", "err", err)
+	if err := p.expectDelim(dec, '['); err != nil {
+		return err
+	}
+
+	batch := make([]*model.PlayerReceive, 0, batchSize)
+	out := make([]*model.Player, batchSize)
+
+	for dec.More() {
+		raw := getRaw()
+		if err := dec.Decode(raw); err != nil {
+			rawPool.Put(raw)
+			logger.Error("parser.Stream: Error unmarshalling raw player", "err", err)
+			return err
+		}
+
+		batch = append(batch, raw)
+		if len(batch) < batchSize {
 			continue
 		}
-		players = append(players, player)
+
+		if err := p.emit(p.convertBatch(batch, out), fn); err != nil {
+			return err
+		}
+		batch = batch[:0]
 	}
-	return players, nil
+
+	if err := p.emit(p.convertBatch(batch, out), fn); err != nil {
+		return err
+	}
+
+	return p.expectDelim(dec, ']')
+}
+
+// emit calls fn for every converted player of a batch, leaving out the skipped ones counted by convertBatch.
+func (p *parser) emit(players []*model.Player, fn func(player *model.Player) error) error {
+	for _, player := range players {
+		if player == nil {
+			continue
+		}
+
+		if err := fn(player); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// expectDelim reads the next JSON token and checks that it is the given delimiter.
+func (p *parser) expectDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		logger.Error("parser.expectDelim: Error reading raw players", "err", err)
+		return err
+	}
+
+	if d, ok := token.(json.Delim); !ok || d != delim {
+		logger.Error("parser.expectDelim: Unexpected token", "token", token, "expected", delim.String())
+		return fmt.Errorf("parser.expectDelim: %w: got %v, want %v", ErrPayload, token, delim)
+	}
+
+	return nil
+}
+
+// WithCollector returns a copy of the Parser counting the skipped entries in c by the kind of their error.
+func (p *parser) WithCollector(c fault.Collector) Parser {
+	cp := *p
+	cp.faults = c
+	return &cp
+}
+
+// fault counts the error of a skipped entry in the collector, when there is one.
+func (p *parser) fault(err error) {
+	if p.faults != nil {
+		p.faults.Add(faultStage, err)
+	}
+}
+
+// addSkipped counts n more skipped entries.
+func (p *parser) addSkipped(n int) {
+	if n > 0 {
+		atomic.AddInt64(&p.skipped, int64(n))
+	}
+}
+
+// Skipped returns the number of raw players skipped by the last Players call because of invalid data.
+func (p *parser) Skipped() int {
+	return int(atomic.LoadInt64(&p.skipped))
 }
 
 // initPlayer initializes a Players object from a PlayerReceive structure
@@ -112,17 +241,35 @@ func (p *parser) initPlayer(raw *model.PlayerReceive) (*model.Player, error) {
 		}
 	}
 
+	// Tombstones of a delta feed only carry the identity of the removed player
+	if raw.Deleted {
+		tombstone := &model.Player{
+			Number:     raw.Number,
+			ID:         id,
+			PlayerName: raw.PlayerName,
+			Serial:     raw.Serial,
+			MAC:        p.normalizeMAC(raw.MAC),
+			Deleted:    true,
+		}
+		p.applyIdentity(tombstone)
+		return tombstone, nil
+	}
+
 	tz, err := strconv.Atoi(raw.TimeZoneDiff)
 	if err != nil {
 		logger.Error("parser.RawToPlayer: Error converting time zone diff to int", "err", err, "tz", raw.TimeZoneDiff)
 		return nil, ErrParseTZ
 	}
 
-	lastOnline, err := time.Parse(time.DateTime, raw.LastOnline)
+	lastOnline, err := parseDateTime(raw.LastOnline)
 	if err != nil {
 		logger.Error("parser.RawToPlayer: Error parsing last online", "err", err)
 		return nil, ErrParseLastOnline
 	}
+	if p.localTime {
+		// The wall clock of the player is tz hours ahead of UTC
+		lastOnline = lastOnline.Add(-time.Duration(tz) * time.Hour)
+	}
 
 	var tags []string
 	if raw.Tags != "" {
@@ -133,6 +280,7 @@ func (p *parser) initPlayer(raw *model.PlayerReceive) (*model.Player, error) {
 		Number:       raw.Number,
 		ID:           id,
 		GroupName:    raw.GroupName,
+		GroupPath:    model.ParseGroupPath(raw.GroupName),
 		PlayerName:   raw.PlayerName,
 		Tags:         tags,
 		ScheduleName: raw.ScheduleName,
@@ -140,15 +288,22 @@ func (p *parser) initPlayer(raw *model.PlayerReceive) (*model.Player, error) {
 		LastOnline:   lastOnline,
 		Serial:       raw.Serial,
 		MAC:          p.normalizeMAC(raw.MAC),
-		IP:           raw.IP,
+		IPs:          parseIPs(raw.IP),
 		Type:         raw.Type,
 		Model:        raw.Model,
 		Version:      raw.Version,
 		StoreNumber:  0,
 		CompanyName:  "",
 	}
+	if len(player.IPs) > 0 {
+		player.IP = player.IPs[0]
+	}
 
 	p.parseTags(player)
+	if player.StoreNumber == 0 {
+		p.parseStoreGroup(player)
+	}
+	p.applyIdentity(player)
 
 	return player, nil
 }
@@ -157,43 +312,108 @@ func (p *parser) initPlayer(raw *model.PlayerReceive) (*model.Player, error) {
 // Updates the Players' store number and company name fields, using configuration data for validation and mapping.
 func (p *parser) parseTags(player *model.Player) {
 	for _, tag := range player.Tags {
-		switch {
-		case strings.HasPrefix(tag, p.storeNumberPrefix):
-			numberTag := strings.TrimPrefix(tag, p.storeNumberPrefix)
-			if numberTag == "" {
-				logger.Debug("parser.parseTags: Empty store number tag", "player", player)
-				continue
+		for _, m := range p.matchers {
+			if strings.HasPrefix(tag, m.prefix) {
+				m.apply(p, player, strings.TrimPrefix(tag, m.prefix))
+				break
 			}
+		}
+	}
+}
 
-			n, err := strconv.Atoi(numberTag)
-			if err != nil {
-				logger.Error("parser.parseTags: Error converting number tag to int", "err", err, "numberTag", numberTag, "player", player)
-				continue
-			}
+// parseStoreGroup sets the store number of a player without a store number tag from its group name,
+// like "Retail/Store 0214/Hall", using the configured pattern. The first capture group holds the number,
+// or the whole match when the pattern has none.
+func (p *parser) parseStoreGroup(player *model.Player) {
+	if p.storeGroup == nil || player.GroupName == "" {
+		return
+	}
 
-			if n == p.storeTestNumber {
-				continue
-			}
+	m := p.storeGroup.FindStringSubmatch(player.GroupName)
+	if m == nil {
+		return
+	}
 
-			player.StoreNumber = n
-		case strings.HasPrefix(tag, p.companyNamePrefix):
-			companyNameTag := strings.TrimPrefix(tag, p.companyNamePrefix)
-			if companyNameTag == "" {
-				logger.Warn("parser.parseTags: Empty company name tag", "player", player)
-				continue
-			}
+	numberTag := m[0]
+	if len(m) > 1 {
+		numberTag = m[1]
+	}
+	p.applyStoreNumber(player, numberTag)
+}
 
-			v, ok := p.companies[companyNameTag]
-			if !ok {
-				logger.Warn("parser.parseTags: Unknown company name", "company_name", companyNameTag, "player", player)
-				player.CompanyName = companyNameTag
-			} else {
-				player.CompanyName = v
-			}
-		default:
+// newStoreGroup compiles the store group pattern. An empty pattern disables the group name fallback;
+// an invalid one is logged and disables it too.
+func newStoreGroup(pattern string) *regexp.Regexp {
+	if pattern == "" {
+		return nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		logger.Warn("parser.newStoreGroup: Invalid store group pattern", "pattern", pattern, "err", err)
+		return nil
+	}
+	return re
+}
+
+// applyStoreNumber sets the store number from a store number tag.
+// The test store number is skipped, or kept and the player marked as a lab player when test-store routing is on.
+func (p *parser) applyStoreNumber(player *model.Player, numberTag string) {
+	if numberTag == "" {
+		logger.Debug("parser.parseTags: Empty store number tag", "player", player)
+		return
+	}
+
+	n, err := strconv.Atoi(numberTag)
+	if err != nil {
+		logger.Error("parser.parseTags: Error converting number tag to int", "err", err, "numberTag", numberTag, "player", player)
+		return
+	}
+
+	if n == p.storeTestNumber {
+		if !p.storeTestRoute {
+			return
+		}
+		player.Lab = true
+	}
+
+	player.StoreNumber = n
+}
+
+// applyCompanyName sets the company name from a company name tag, canonicalized and mapped through the configured companies.
+// Unknown tags are kept as they are.
+func (p *parser) applyCompanyName(player *model.Player, companyNameTag string) {
+	if companyNameTag == "" {
+		logger.Warn("parser.parseTags: Empty company name tag", "player", player)
+		return
+	}
+
+	v, ok := p.companies[p.canonicalizer.canonical(companyNameTag)]
+	if !ok {
+		logger.Warn("parser.parseTags: Unknown company name", "company_name", companyNameTag, "player", player)
+		player.CompanyName = companyNameTag
+	} else {
+		player.CompanyName = v
+	}
+}
+
+// parseIPs splits the comma-separated addresses reported by a device and keeps the valid IPv4 and IPv6 ones
+// in their canonical form. Invalid addresses are logged and dropped.
+func parseIPs(raw string) []string {
+	var ips []string
+	for _, s := range strings.Split(raw, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			logger.Warn("parser.parseIPs: Invalid IP address", "ip", s, "err", err)
 			continue
 		}
+		ips = append(ips, addr.String())
 	}
+	return ips
 }
 
 // normalizeMAC takes a raw MAC address string, removes invalid characters,
@@ -0,0 +1,150 @@
+package player
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"go-players-data/internal/config"
+	"go-players-data/internal/logger"
+	"go-players-data/internal/model"
+)
+
+// benchPlayers is the number of raw players in the benchmark payload, every 100th with an invalid time zone.
+const benchPlayers = 10000
+
+func TestMain(m *testing.M) {
+	// Silence the errors logged for the invalid entries of the payload
+	logger.Init(slog.LevelError + 1)
+	os.Exit(m.Run())
+}
+
+// benchRaw builds n raw players as the source sends them.
+func benchRaw(n int) []model.PlayerReceive {
+	raw := make([]model.PlayerReceive, n)
+	for i := range raw {
+		raw[i] = model.PlayerReceive{
+			Number:       i,
+			ID:           fmt.Sprint(i + 1),
+			GroupName:    fmt.Sprintf("Region/Store %d", i%500),
+			PlayerName:   fmt.Sprintf("Player %d", i),
+			Tags:         fmt.Sprintf("store_%d,company_Company %d", i%500, i%7),
+			ScheduleName: "Default",
+			TimeZoneDiff: "3",
+			LastOnline:   time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC).Add(-time.Duration(i) * time.Minute).Format(time.DateTime),
+			Serial:       fmt.Sprintf("SN%06d", i),
+			MAC:          fmt.Sprintf("00-00-5E-00-%02X-%02X", i/256%256, i%256),
+			IP:           fmt.Sprintf("10.0.%d.%d", i/256%256, i%256),
+			Type:         "android",
+			Model:        "X1",
+			Version:      "1.2.3",
+		}
+		if i%100 == 99 {
+			raw[i].TimeZoneDiff = "n/a"
+		}
+	}
+	return raw
+}
+
+// benchParser creates a parser tagging store numbers and company names like a production configuration.
+func benchParser() *parser {
+	return New(config.Data{StoreNumberPrefix: "store_", CompanyNamePrefix: "company_"}).(*parser)
+}
+
+func BenchmarkStream(b *testing.B) {
+	body, err := json.Marshal(benchRaw(benchPlayers))
+	if err != nil {
+		b.Fatal(err)
+	}
+	p := benchParser()
+
+	b.SetBytes(int64(len(body)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		n := 0
+		if err = p.Stream(bytes.NewReader(body), func(*model.Player) error { n++; return nil }); err != nil {
+			b.Fatal(err)
+		}
+		if n+p.Skipped() != benchPlayers {
+			b.Fatalf("got %d players and %d skipped, want %d in total", n, p.Skipped(), benchPlayers)
+		}
+	}
+}
+
+func BenchmarkConvertBatch(b *testing.B) {
+	raw := benchRaw(batchSize)
+	p := benchParser()
+	batch := make([]*model.PlayerReceive, batchSize)
+	out := make([]*model.Player, batchSize)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for j := range batch {
+			batch[j] = getRaw()
+			*batch[j] = raw[j]
+		}
+		p.convertBatch(batch, out)
+	}
+}
+
+func TestParseDateTime(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+	}{
+		{"valid", "2024-05-01 12:34:56"},
+		{"start of day", "2024-01-01 00:00:00"},
+		{"end of day", "2023-12-31 23:59:59"},
+		{"leap day", "2024-02-29 10:00:00"},
+		{"leap day of common year", "2023-02-29 10:00:00"},
+		{"february 30", "2024-02-30 10:00:00"},
+		{"april 31", "2024-04-31 10:00:00"},
+		{"day zero", "2024-05-00 10:00:00"},
+		{"month zero", "2024-00-01 10:00:00"},
+		{"month 13", "2024-13-01 10:00:00"},
+		{"hour 24", "2024-05-01 24:00:00"},
+		{"minute 60", "2024-05-01 12:60:00"},
+		{"second 60", "2024-05-01 12:34:60"},
+		{"slash separators", "2024/05/01 12:34:56"},
+		{"T separator", "2024-05-01T12:34:56"},
+		{"dot separators", "2024-05-01 12.34.56"},
+		{"signed field", "2024-+5-01 12:34:56"},
+		{"letters", "2024-05-01 12:3a:56"},
+		{"single-digit fields", "2024-5-1 1:2:3"},
+		{"fractional seconds", "2024-05-01 12:34:56.789"},
+		{"trailing space", "2024-05-01 12:34:56 "},
+		{"empty", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, wantErr := time.Parse(time.DateTime, tt.in)
+			got, err := parseDateTime(tt.in)
+
+			if (err != nil) != (wantErr != nil) {
+				t.Fatalf("parseDateTime(%q) error = %v, time.Parse error = %v", tt.in, err, wantErr)
+			}
+			if !got.Equal(want) || got.Location() != want.Location() {
+				t.Errorf("parseDateTime(%q) = %v, time.Parse = %v", tt.in, got, want)
+			}
+		})
+	}
+}
+
+func BenchmarkParseDateTime(b *testing.B) {
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := parseDateTime("2024-05-01 12:34:56"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package player
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+
+	"go-players-data/internal/logger"
+	"go-players-data/internal/model"
+)
+
+// xmlPlayerElement is the name of the elements holding raw players in XML reports of the legacy API.
+const xmlPlayerElement = "player"
+
+// streamXML decodes the <player> elements of an XML report from r in batches, wherever they are nested.
+// Elements that cannot be decoded are skipped and counted.
+func (p *parser) streamXML(r io.Reader, fn func(player *model.Player) error) error {
+	dec := xml.NewDecoder(r)
+
+	batch := make([]*model.PlayerReceive, 0, batchSize)
+	out := make([]*model.Player, batchSize)
+
+	for {
+		token, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			logger.Error("parser.streamXML: Error reading report", "err", err)
+			return fmt.Errorf("parser.streamXML: failed to read report: %w", err)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != xmlPlayerElement {
+			continue
+		}
+
+		raw := getRaw()
+		if err = dec.DecodeElement(raw, &start); err != nil {
+			rawPool.Put(raw)
+			logger.Error("parser.streamXML: Invalid player element", "err", err)
+			p.fault(err)
+			p.addSkipped(1)
+			continue
+		}
+
+		batch = append(batch, raw)
+		if len(batch) < batchSize {
+			continue
+		}
+
+		if err = p.emit(p.convertBatch(batch, out), fn); err != nil {
+			return err
+		}
+		batch = batch[:0]
+	}
+
+	return p.emit(p.convertBatch(batch, out), fn)
+}
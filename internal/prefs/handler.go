@@ -0,0 +1,91 @@
+package prefs
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"go-players-data/internal/logger"
+)
+
+// choicePage lets the recipient of a preference link pick a level. Link scanners and previews GET the link
+// without a level, so only a choice made on this page or the one-click POST changes the preference.
+var choicePage = template.Must(template.New("choice").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Notification preferences</title></head>
+<body>
+<p>Player notifications for {{.}}:</p>
+<ul>
+<li><a href="?level=all">All notifications</a></li>
+<li><a href="?level=critical">Critical notifications only</a></li>
+<li><a href="?level=none">Unsubscribe</a></li>
+</ul>
+</body>
+</html>
+`))
+
+// handler records recipient preferences from signed preference links.
+type handler struct {
+	signer Signer
+	state  State
+}
+
+// NewHandler creates an http.Handler serving /<token> relative to its mount point.
+// GET /<token> renders a page to choose the level from, GET /<token>?level=all|critical|none records the chosen level;
+// POST /<token> is the RFC 8058 one-click unsubscribe and records none.
+func NewHandler(signer Signer, state State) http.Handler {
+	return &handler{
+		signer: signer,
+		state:  state,
+	}
+}
+
+// ServeHTTP verifies the token and records the requested level of its recipient.
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	recipient, err := h.signer.Verify(strings.Trim(r.URL.Path, "/"))
+	if err != nil {
+		logger.Warn("prefs.ServeHTTP: Rejected preference link", "err", err)
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	level := LevelNone
+	switch r.Method {
+	case http.MethodGet:
+		level = r.URL.Query().Get("level")
+		if level == "" {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			if err = choicePage.Execute(w, recipient); err != nil {
+				logger.Error("prefs.ServeHTTP: Failed to render preference page", "err", err)
+			}
+			return
+		}
+	case http.MethodPost:
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err = h.state.Set(r.Context(), recipient, level); err != nil {
+		if errors.Is(err, ErrInvalidLevel) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		logger.Error("prefs.ServeHTTP: Failed to record preference", "err", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	switch level {
+	case LevelNone:
+		_, _ = fmt.Fprintf(w, "%s is unsubscribed from player notifications.\n", recipient)
+	case LevelCritical:
+		_, _ = fmt.Fprintf(w, "%s now receives critical notifications only.\n", recipient)
+	default:
+		_, _ = fmt.Fprintf(w, "%s receives all notifications.\n", recipient)
+	}
+}
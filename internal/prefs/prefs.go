@@ -0,0 +1,316 @@
+package prefs
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go-players-data/internal/codec"
+	"go-players-data/internal/logger"
+	"go-players-data/internal/model"
+	"go-players-data/internal/storage"
+)
+
+// PathPrefix is the HTTP path prefix of preference links: <base URL>/prefs/<token>.
+const PathPrefix = "/prefs/"
+
+// refreshInterval bounds how long the router serves cached preferences before reading them again.
+const refreshInterval = time.Minute
+
+// Notification levels a recipient can choose.
+const (
+	LevelAll      = "all"      // Warnings and criticals, the default
+	LevelCritical = "critical" // Criticals only
+	LevelNone     = "none"     // Unsubscribed
+)
+
+// Cluster severities.
+const (
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Errors returned by Verify and Set.
+var (
+	ErrInvalidToken = errors.New("invalid preferences token")
+	ErrExpiredToken = errors.New("expired preferences token")
+	ErrInvalidLevel = errors.New("invalid notification level")
+)
+
+// ValidLevel reports whether the level is one of the known notification levels.
+func ValidLevel(level string) bool {
+	return level == LevelAll || level == LevelCritical || level == LevelNone
+}
+
+// Severity returns SeverityCritical if any player has been offline at now for longer than criticalAfter,
+// and SeverityWarning otherwise. With severity tiers, the cluster is critical when its most severe tier is "critical".
+func Severity(players []*model.Player, now time.Time, criticalAfter time.Duration) string {
+	if severity := model.ClusterSeverity(players); severity != "" {
+		if severity == SeverityCritical {
+			return SeverityCritical
+		}
+		return SeverityWarning
+	}
+
+	for _, p := range players {
+		if now.Sub(p.LastOnline) > criticalAfter {
+			return SeverityCritical
+		}
+	}
+	return SeverityWarning
+}
+
+// Preference is the notification level chosen by a recipient.
+type Preference struct {
+	Level     string    `json:"level"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Preferences maps lower-cased recipient addresses to their preferences.
+type Preferences map[string]Preference
+
+// Wants reports whether the recipient receives notifications of the severity. Recipients without a preference get all.
+func (p Preferences) Wants(recipient, severity string) bool {
+	switch p[normalize(recipient)].Level {
+	case LevelNone:
+		return false
+	case LevelCritical:
+		return severity == SeverityCritical
+	default:
+		return true
+	}
+}
+
+// normalize returns the form recipient addresses are keyed by.
+func normalize(recipient string) string {
+	return strings.ToLower(strings.TrimSpace(recipient))
+}
+
+// token is the signed payload of a preference link.
+type token struct {
+	Recipient string `json:"r"`
+	Expires   int64  `json:"e"`
+}
+
+// signer is a struct that builds and verifies HMAC-signed preference links.
+type signer struct {
+	secret  []byte
+	baseURL url.URL
+	linkTTL time.Duration
+}
+
+// Signer is an interface for building per-recipient preference links embedded in notifications and verifying their tokens.
+type Signer interface {
+	Link(recipient string) string
+	Verify(token string) (string, error)
+}
+
+// NewSigner creates a new Signer producing links under baseURL that stay valid for linkTTL.
+func NewSigner(secret string, baseURL url.URL, linkTTL time.Duration) Signer {
+	return &signer{
+		secret:  []byte(secret),
+		baseURL: baseURL,
+		linkTTL: linkTTL,
+	}
+}
+
+// Link returns a signed preference link of the recipient.
+func (s *signer) Link(recipient string) string {
+	payload, _ := json.Marshal(token{
+		Recipient: normalize(recipient),
+		Expires:   time.Now().Add(s.linkTTL).Unix(),
+	})
+
+	t := base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(s.sign(payload))
+
+	return strings.TrimSuffix(s.baseURL.String(), "/") + PathPrefix + t
+}
+
+// Verify checks the token signature and expiration and returns its recipient.
+func (s *signer) Verify(t string) (string, error) {
+	encoded, sig, ok := strings.Cut(t, ".")
+	if !ok {
+		return "", ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, s.sign(payload)) {
+		return "", ErrInvalidToken
+	}
+
+	var tok token
+	if err = json.Unmarshal(payload, &tok); err != nil {
+		return "", ErrInvalidToken
+	}
+
+	if time.Now().Unix() > tok.Expires {
+		return "", ErrExpiredToken
+	}
+
+	return tok.Recipient, nil
+}
+
+// sign computes the HMAC-SHA256 of the payload.
+func (s *signer) sign(payload []byte) []byte {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write(payload)
+	return h.Sum(nil)
+}
+
+// state is a struct that keeps the recipient preferences as an object in object storage.
+type state struct {
+	store storage.Storage
+	key   string
+}
+
+// State is an interface for reading the recipient preferences and recording changes.
+type State interface {
+	Load(ctx context.Context) (Preferences, error)
+	Set(ctx context.Context, recipient, level string) error
+}
+
+// NewState creates a new State stored under the key.
+func NewState(store storage.Storage, key string) State {
+	return &state{
+		store: store,
+		key:   key,
+	}
+}
+
+// Load reads the preferences. A missing state object means every recipient gets all notifications.
+func (s *state) Load(ctx context.Context) (Preferences, error) {
+	data, err := s.store.Get(ctx, s.key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return make(Preferences), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("prefs.Load: failed to load state: %w", err)
+	}
+
+	return s.decode(data)
+}
+
+// Set records the notification level of the recipient.
+// The state is written conditionally and recomputed from the fresh state when a concurrent change raced it.
+func (s *state) Set(ctx context.Context, recipient, level string) error {
+	if !ValidLevel(level) {
+		return fmt.Errorf("prefs.Set: %w: %q", ErrInvalidLevel, level)
+	}
+
+	err := storage.Update(ctx, s.store, s.key, codec.ContentType, func(data []byte) ([]byte, error) {
+		prefs, err := s.decode(data)
+		if err != nil {
+			return nil, err
+		}
+		prefs[normalize(recipient)] = Preference{Level: level, UpdatedAt: time.Now()}
+
+		data, err = codec.Marshal(s.key, prefs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode state: %w", err)
+		}
+		return data, nil
+	})
+	if err != nil {
+		return fmt.Errorf("prefs.Set: %w", err)
+	}
+
+	logger.Info("prefs.Set: Preference recorded", "recipient", normalize(recipient), "level", level)
+	return nil
+}
+
+// decode decodes the preferences of the state object, nil for a missing one.
+func (s *state) decode(data []byte) (Preferences, error) {
+	prefs := make(Preferences)
+	if data == nil {
+		return prefs, nil
+	}
+
+	if err := codec.Unmarshal(data, &prefs); err != nil {
+		return nil, fmt.Errorf("prefs.decode: failed to decode state: %w", err)
+	}
+
+	return prefs, nil
+}
+
+// router is a struct that picks the recipients of a cluster by their preferences, caching the state for refreshInterval.
+type router struct {
+	state         State
+	signer        Signer
+	criticalAfter time.Duration
+
+	mu       sync.Mutex
+	prefs    Preferences
+	loadedAt time.Time
+}
+
+// Router defines an interface for choosing the recipients of a cluster notification and their preference links.
+type Router interface {
+	Recipients(to []string, players []*model.Player) []string
+	Link(recipient string) string
+}
+
+// NewRouter creates a new Router reading preferences from the state and signing links with the signer.
+func NewRouter(state State, signer Signer, criticalAfter time.Duration) Router {
+	return &router{
+		state:         state,
+		signer:        signer,
+		criticalAfter: criticalAfter,
+	}
+}
+
+// Recipients returns the recipients of to that want a notification of the cluster severity.
+// If the preferences cannot be loaded every recipient is returned, so alerts are never lost.
+func (r *router) Recipients(to []string, players []*model.Player) []string {
+	prefs, err := r.load()
+	if err != nil {
+		logger.Error("prefs.Recipients: Failed to load preferences", "err", err)
+		return to
+	}
+
+	severity := Severity(players, time.Now(), r.criticalAfter)
+
+	var recipients []string
+	for _, recipient := range to {
+		if prefs.Wants(recipient, severity) {
+			recipients = append(recipients, recipient)
+		}
+	}
+
+	return recipients
+}
+
+// Link returns the signed preference link of the recipient.
+func (r *router) Link(recipient string) string {
+	return r.signer.Link(recipient)
+}
+
+// load returns the cached preferences, reading them again once they are older than refreshInterval.
+func (r *router) load() (Preferences, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.prefs != nil && time.Since(r.loadedAt) < refreshInterval {
+		return r.prefs, nil
+	}
+
+	prefs, err := r.state.Load(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	r.prefs, r.loadedAt = prefs, time.Now()
+
+	return prefs, nil
+}
@@ -0,0 +1,234 @@
+package promwrite
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"go-players-data/internal/config"
+	"go-players-data/internal/logger"
+	"go-players-data/internal/model"
+)
+
+// Metric names pushed after each run.
+const (
+	MetricOfflineByStore   = "players_offline"
+	MetricOfflineByCompany = "players_offline_by_company"
+	MetricOfflineTotal     = "players_offline_total"
+	MetricParsed           = "players_parsed"
+	MetricSkipped          = "players_skipped"
+	MetricStateBytes       = "players_state_bytes"
+)
+
+// Label is a single metric label.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Series is a gauge sample with its labels, the metric name included as __name__.
+type Series struct {
+	Labels []Label
+	Value  float64
+}
+
+// RunStats are the counts of a single run pushed as gauges.
+type RunStats struct {
+	Parsed   int
+	Skipped  int
+	Clusters map[int][]*model.Player
+	// StateBytes are the encoded sizes of the state objects written by the run, keyed by object key
+	StateBytes map[string]int
+}
+
+// writer is a struct that pushes gauges to a Prometheus remote-write endpoint.
+// Requests are encoded as prompb.WriteRequest protobuf and compressed in the snappy block format by hand
+// to avoid pulling the Prometheus and snappy modules into the function.
+type writer struct {
+	client   *http.Client
+	url      url.URL
+	username string
+	password string
+	token    string
+	job      string
+}
+
+// Writer is an interface for pushing the gauges of a run.
+type Writer interface {
+	Write(ctx context.Context, at time.Time, stats RunStats) error
+}
+
+// New creates a new Writer for the configured remote-write endpoint.
+func New(c *http.Client, cfg config.RemoteWrite) Writer {
+	return &writer{
+		client:   c,
+		url:      cfg.URL,
+		username: cfg.Username,
+		password: cfg.Password,
+		token:    cfg.Token,
+		job:      cfg.Job,
+	}
+}
+
+// Write pushes offline counts per store and company, the offline total, and parsed and skipped counts.
+func (w *writer) Write(ctx context.Context, at time.Time, stats RunStats) error {
+	start := time.Now()
+	defer func() { logger.Debug("promwrite.Write: Time spent", "time", time.Since(start).String()) }()
+
+	series := w.series(stats)
+	body := snappyEncode(encodeWriteRequest(series, at.UnixMilli()))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url.String(), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("promwrite.Write: failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	switch {
+	case w.token != "":
+		req.Header.Set("Authorization", "Bearer "+w.token)
+	case w.username != "":
+		req.SetBasicAuth(w.username, w.password)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("promwrite.Write: failed to send request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		logger.Error("promwrite.Write: Invalid status code", "statusCode", resp.StatusCode, "body", string(msg))
+		return &HTTPError{Code: resp.StatusCode}
+	}
+
+	logger.Info("promwrite.Write: Gauges pushed", "series", len(series))
+	return nil
+}
+
+// series builds the gauges of the run.
+func (w *writer) series(stats RunStats) []Series {
+	var series []Series
+	byCompany := make(map[string]int)
+	total := 0
+
+	for storeNumber, players := range stats.Clusters {
+		company := ""
+		if len(players) > 0 {
+			company = players[0].CompanyName
+		}
+		for _, p := range players {
+			byCompany[p.CompanyName]++
+		}
+		total += len(players)
+
+		series = append(series, w.gauge(MetricOfflineByStore, float64(len(players)),
+			Label{Name: "store", Value: strconv.Itoa(storeNumber)},
+			Label{Name: "company", Value: company},
+		))
+	}
+
+	for company, n := range byCompany {
+		series = append(series, w.gauge(MetricOfflineByCompany, float64(n), Label{Name: "company", Value: company}))
+	}
+
+	series = append(series,
+		w.gauge(MetricOfflineTotal, float64(total)),
+		w.gauge(MetricParsed, float64(stats.Parsed)),
+		w.gauge(MetricSkipped, float64(stats.Skipped)),
+	)
+
+	for key, n := range stats.StateBytes {
+		series = append(series, w.gauge(MetricStateBytes, float64(n), Label{Name: "key", Value: key}))
+	}
+
+	return series
+}
+
+// gauge builds a series with the metric name, the job label, and the extra labels sorted by name.
+func (w *writer) gauge(name string, value float64, labels ...Label) Series {
+	all := append([]Label{{Name: "__name__", Value: name}, {Name: "job", Value: w.job}}, labels...)
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+
+	return Series{Labels: all, Value: value}
+}
+
+// encodeWriteRequest encodes series as a prompb.WriteRequest:
+// WriteRequest{1: repeated TimeSeries}, TimeSeries{1: repeated Label, 2: repeated Sample},
+// Label{1: name, 2: value}, Sample{1: double value, 2: int64 timestamp}.
+func encodeWriteRequest(series []Series, timestampMs int64) []byte {
+	var req []byte
+
+	for _, s := range series {
+		var ts []byte
+		for _, l := range s.Labels {
+			var label []byte
+			label = appendBytesField(label, 1, []byte(l.Name))
+			label = appendBytesField(label, 2, []byte(l.Value))
+			ts = appendBytesField(ts, 1, label)
+		}
+
+		var sample []byte
+		sample = binary.AppendUvarint(sample, 1<<3|1)
+		sample = binary.LittleEndian.AppendUint64(sample, math.Float64bits(s.Value))
+		sample = binary.AppendUvarint(sample, 2<<3)
+		sample = binary.AppendUvarint(sample, uint64(timestampMs))
+		ts = appendBytesField(ts, 2, sample)
+
+		req = appendBytesField(req, 1, ts)
+	}
+
+	return req
+}
+
+// appendBytesField appends a length-delimited protobuf field.
+func appendBytesField(b []byte, field int, value []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(field)<<3|2)
+	b = binary.AppendUvarint(b, uint64(len(value)))
+	return append(b, value...)
+}
+
+// snappyEncode encodes src in the snappy block format using literal elements only:
+// a varint of the decoded length followed by literals of at most 64 KiB each.
+func snappyEncode(src []byte) []byte {
+	dst := binary.AppendUvarint(nil, uint64(len(src)))
+
+	for len(src) > 0 {
+		n := min(len(src), 1<<16)
+		l := n - 1
+
+		switch {
+		case l < 60:
+			dst = append(dst, byte(l)<<2)
+		case l < 1<<8:
+			dst = append(dst, 60<<2, byte(l))
+		default:
+			dst = append(dst, 61<<2, byte(l), byte(l>>8))
+		}
+
+		dst = append(dst, src[:n]...)
+		src = src[n:]
+	}
+
+	return dst
+}
+
+// HTTPError represents an error response from the remote-write endpoint with a specific status code.
+type HTTPError struct {
+	Code int
+}
+
+// Error returns the text representation of the HTTP status code associated with the HTTPError.
+func (e *HTTPError) Error() string {
+	return http.StatusText(e.Code)
+}
@@ -0,0 +1,80 @@
+package push
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"go-players-data/internal/logger"
+)
+
+// maxBodyBytes bounds the body of a single push.
+const maxBodyBytes = 1 << 20
+
+// ErrInvalid is returned by a Func for a body that cannot be parsed; the push is answered with 400.
+var ErrInvalid = errors.New("invalid push payload")
+
+// Result is the response body of an accepted push.
+type Result struct {
+	Received int `json:"received"` // Players in the push
+	Offline  int `json:"offline"`  // Players that went offline with the push
+	Notified int `json:"notified"` // Stores notified about them
+}
+
+// Func applies the raw body of a push: the changed players in the upstream data format.
+type Func func(ctx context.Context, body []byte) (Result, error)
+
+// handler receives vendor push notifications of player status changes.
+type handler struct {
+	token string
+	apply Func
+}
+
+// NewHandler creates an http.Handler accepting POST requests authenticated with the token
+// as "Authorization: Bearer <token>" and passing their body to apply.
+func NewHandler(token string, apply Func) http.Handler {
+	return &handler{
+		token: token,
+		apply: apply,
+	}
+}
+
+// ServeHTTP verifies the token, applies the push and answers with its Result as JSON.
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+		logger.Warn("push.ServeHTTP: Rejected push", "remote", r.RemoteAddr)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	res, err := h.apply(r.Context(), body)
+	if err != nil {
+		if errors.Is(err, ErrInvalid) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		logger.Error("push.ServeHTTP: Failed to apply push", "err", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,194 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"go-players-data/internal/config"
+	"go-players-data/internal/logger"
+	"go-players-data/internal/storage"
+)
+
+// ErrDuplicate is returned when the master data lists a store number or a company name twice.
+var ErrDuplicate = errors.New("duplicate registry entry")
+
+// Store is the master data of a store.
+type Store struct {
+	Number     int      `json:"number"`
+	Name       string   `json:"name"`
+	Company    string   `json:"company"`
+	Recipients []string `json:"recipients,omitempty"`
+	Region     string   `json:"region,omitempty"`
+	Timezone   string   `json:"timezone,omitempty"` // IANA time zone, e.g. "Europe/Moscow"
+	SLATier    string   `json:"sla_tier,omitempty"`
+}
+
+// Company is the master data of a company. Aliases are the company name tags that map to Name.
+type Company struct {
+	Name       string   `json:"name"`
+	Aliases    []string `json:"aliases,omitempty"`
+	Recipients []string `json:"recipients,omitempty"` // Headquarters contacts
+	Region     string   `json:"region,omitempty"`
+	SLATier    string   `json:"sla_tier,omitempty"`
+}
+
+// document is the JSON layout of the master data.
+type document struct {
+	Companies []Company `json:"companies"`
+	Stores    []Store   `json:"stores"`
+}
+
+// registry is a struct that indexes the store and company master data.
+type registry struct {
+	stores    map[int]Store
+	companies map[string]Company
+}
+
+// Registry is an interface for looking up store and company master data and applying it to the configuration.
+type Registry interface {
+	Store(number int) (Store, bool)
+	Company(name string) (Company, bool)
+	Location(number int) *time.Location
+	Apply(cfg config.Config) config.Config
+}
+
+// Load reads the master data from the local file cfg.File or, when it is not set, from the bucket object cfg.Key.
+// Returns an error if neither is set, the data cannot be read or decoded, or an entry is listed twice.
+func Load(ctx context.Context, store storage.Storage, cfg config.Registry) (Registry, error) {
+	start := time.Now()
+	defer func() { logger.Debug("registry.Load: Time spent", "time", time.Since(start).String()) }()
+
+	var (
+		data []byte
+		err  error
+	)
+	switch {
+	case cfg.File != "":
+		data, err = os.ReadFile(cfg.File)
+	case cfg.Key != "":
+		data, err = store.Get(ctx, cfg.Key)
+	default:
+		return nil, errors.New("registry.Load: neither a file nor an object key is set")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("registry.Load: failed to read master data: %w", err)
+	}
+
+	var doc document
+	if err = json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("registry.Load: failed to decode master data: %w", err)
+	}
+
+	return New(doc.Companies, doc.Stores)
+}
+
+// New creates a Registry from companies and stores. Returns an error if a store number or company name repeats,
+// or a time zone is unknown.
+func New(companies []Company, stores []Store) (Registry, error) {
+	r := &registry{
+		stores:    make(map[int]Store, len(stores)),
+		companies: make(map[string]Company, len(companies)),
+	}
+
+	for _, c := range companies {
+		if _, ok := r.companies[c.Name]; ok {
+			return nil, fmt.Errorf("registry.New: %w: company %q", ErrDuplicate, c.Name)
+		}
+		r.companies[c.Name] = c
+	}
+
+	for _, s := range stores {
+		if _, ok := r.stores[s.Number]; ok {
+			return nil, fmt.Errorf("registry.New: %w: store %d", ErrDuplicate, s.Number)
+		}
+		if s.Timezone != "" {
+			if _, err := time.LoadLocation(s.Timezone); err != nil {
+				return nil, fmt.Errorf("registry.New: store %d: %w", s.Number, err)
+			}
+		}
+		r.stores[s.Number] = s
+	}
+
+	return r, nil
+}
+
+// Store returns the master data of the store.
+func (r *registry) Store(number int) (Store, bool) {
+	s, ok := r.stores[number]
+	return s, ok
+}
+
+// Company returns the master data of the company.
+func (r *registry) Company(name string) (Company, bool) {
+	c, ok := r.companies[name]
+	return c, ok
+}
+
+// Location returns the time zone of the store, or nil when the store has none.
+func (r *registry) Location(number int) *time.Location {
+	s, ok := r.stores[number]
+	if !ok || s.Timezone == "" {
+		return nil
+	}
+
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return nil
+	}
+	return loc
+}
+
+// Apply returns cfg with the master data filled into the maps read by the parser, the mailer and the rollups:
+// company aliases into DATA_COMPANIES, store names into MAIL_STORES, store recipients into MAIL_STORE_RECIPIENTS
+// and headquarters contacts into ROLLUP_TO. Entries set in the environment win over the master data.
+func (r *registry) Apply(cfg config.Config) config.Config {
+	cfg.Data.Companies = merge(cfg.Data.Companies, func(m map[string]string) {
+		for _, c := range r.companies {
+			m[c.Name] = c.Name
+			for _, alias := range c.Aliases {
+				m[alias] = c.Name
+			}
+		}
+	})
+
+	cfg.Rollup.To = merge(cfg.Rollup.To, func(m map[string]string) {
+		for _, c := range r.companies {
+			if len(c.Recipients) > 0 {
+				m[c.Name] = strings.Join(c.Recipients, ";")
+			}
+		}
+	})
+
+	cfg.Mail.MailStores = merge(cfg.Mail.MailStores, func(m map[int]string) {
+		for _, s := range r.stores {
+			if s.Name != "" {
+				m[s.Number] = s.Name
+			}
+		}
+	})
+
+	cfg.Mail.StoreRecipients = merge(cfg.Mail.StoreRecipients, func(m map[int]string) {
+		for _, s := range r.stores {
+			if len(s.Recipients) > 0 {
+				m[s.Number] = strings.Join(s.Recipients, ";")
+			}
+		}
+	})
+
+	return cfg
+}
+
+// merge returns a new map filled by fill and then overridden by the entries of configured.
+func merge[K comparable](configured map[K]string, fill func(m map[K]string)) map[K]string {
+	m := make(map[K]string, len(configured))
+	fill(m)
+	for k, v := range configured {
+		m[k] = v
+	}
+	return m
+}
@@ -0,0 +1,234 @@
+package report
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+
+	"go-players-data/internal/archive"
+	"go-players-data/internal/logger"
+)
+
+// Sheet names of the report.
+const (
+	sheetSummary   = "Summary"
+	sheetTopStores = "Top stores"
+	sheetCompanies = "Companies"
+)
+
+// topStores is the number of worst stores listed in the report.
+const topStores = 10
+
+// storeStats aggregates the offline players of a store over the report period.
+type storeStats struct {
+	storeNumber  int
+	company      string
+	playerRuns   int
+	offlineRuns  int
+	maxPlayers   int
+	averageByRun float64
+}
+
+// companyStats aggregates the offline players of a company over the report period.
+type companyStats struct {
+	company    string
+	playerRuns int
+	maxPlayers int
+	stores     map[int]struct{}
+}
+
+// Build renders the management report of the archived runs as an XLSX workbook:
+// an offline trend with a line chart, the top-10 worst stores, and a per-company table.
+func Build(runs []archive.Run, from, to time.Time) ([]byte, error) {
+	start := time.Now()
+	defer func() { logger.Debug("report.Build: Time spent", "time", time.Since(start).String()) }()
+
+	f := excelize.NewFile()
+	defer func() { _ = f.Close() }()
+
+	if err := f.SetSheetName("Sheet1", sheetSummary); err != nil {
+		return nil, fmt.Errorf("report.Build: %w", err)
+	}
+
+	stores, companies := aggregate(runs)
+
+	if err := summary(f, runs, from, to); err != nil {
+		return nil, fmt.Errorf("report.Build: summary sheet: %w", err)
+	}
+	if err := worstStores(f, stores); err != nil {
+		return nil, fmt.Errorf("report.Build: top stores sheet: %w", err)
+	}
+	if err := companyTable(f, companies, len(runs)); err != nil {
+		return nil, fmt.Errorf("report.Build: companies sheet: %w", err)
+	}
+
+	f.SetActiveSheet(0)
+
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		return nil, fmt.Errorf("report.Build: failed to write workbook: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// aggregate computes per-store and per-company statistics over the runs.
+func aggregate(runs []archive.Run) ([]*storeStats, []*companyStats) {
+	stores := make(map[int]*storeStats)
+	companies := make(map[string]*companyStats)
+
+	for _, run := range runs {
+		byStore := make(map[int]int)
+		byCompany := make(map[string]int)
+
+		for _, p := range run.Offline {
+			byStore[p.StoreNumber]++
+			byCompany[p.CompanyName]++
+
+			s, ok := stores[p.StoreNumber]
+			if !ok {
+				s = &storeStats{storeNumber: p.StoreNumber, company: p.CompanyName}
+				stores[p.StoreNumber] = s
+			}
+			s.playerRuns++
+
+			c, ok := companies[p.CompanyName]
+			if !ok {
+				c = &companyStats{company: p.CompanyName, stores: make(map[int]struct{})}
+				companies[p.CompanyName] = c
+			}
+			c.playerRuns++
+			c.stores[p.StoreNumber] = struct{}{}
+		}
+
+		for storeNumber, n := range byStore {
+			s := stores[storeNumber]
+			s.offlineRuns++
+			s.maxPlayers = max(s.maxPlayers, n)
+		}
+		for company, n := range byCompany {
+			companies[company].maxPlayers = max(companies[company].maxPlayers, n)
+		}
+	}
+
+	storeList := make([]*storeStats, 0, len(stores))
+	for _, s := range stores {
+		if len(runs) > 0 {
+			s.averageByRun = float64(s.playerRuns) / float64(len(runs))
+		}
+		storeList = append(storeList, s)
+	}
+	sort.Slice(storeList, func(i, j int) bool {
+		if storeList[i].playerRuns != storeList[j].playerRuns {
+			return storeList[i].playerRuns > storeList[j].playerRuns
+		}
+		return storeList[i].storeNumber < storeList[j].storeNumber
+	})
+
+	companyList := make([]*companyStats, 0, len(companies))
+	for _, c := range companies {
+		companyList = append(companyList, c)
+	}
+	sort.Slice(companyList, func(i, j int) bool { return companyList[i].company < companyList[j].company })
+
+	return storeList, companyList
+}
+
+// summary writes the period totals and the offline trend with its line chart.
+func summary(f *excelize.File, runs []archive.Run, from, to time.Time) error {
+	maxOffline, total := 0, 0
+	for _, run := range runs {
+		maxOffline = max(maxOffline, len(run.Offline))
+		total += len(run.Offline)
+	}
+
+	average := 0.0
+	if len(runs) > 0 {
+		average = float64(total) / float64(len(runs))
+	}
+
+	rows := [][]interface{}{
+		{"Period", fmt.Sprintf("%s — %s", from.Format(time.DateOnly), to.Format(time.DateOnly))},
+		{"Runs", len(runs)},
+		{"Average offline players", average},
+		{"Max offline players", maxOffline},
+		{},
+		{"Run", "Offline players"},
+	}
+	for _, run := range runs {
+		rows = append(rows, []interface{}{run.At.Format(time.DateTime), len(run.Offline)})
+	}
+
+	if err := setRows(f, sheetSummary, rows); err != nil {
+		return err
+	}
+
+	if len(runs) == 0 {
+		return nil
+	}
+
+	first, last := 7, 6+len(runs)
+	return f.AddChart(sheetSummary, "D6", &excelize.Chart{
+		Type: excelize.Line,
+		Series: []excelize.ChartSeries{{
+			Name:       fmt.Sprintf("'%s'!$B$6", sheetSummary),
+			Categories: fmt.Sprintf("'%s'!$A$%d:$A$%d", sheetSummary, first, last),
+			Values:     fmt.Sprintf("'%s'!$B$%d:$B$%d", sheetSummary, first, last),
+		}},
+		Title:  []excelize.RichTextRun{{Text: "Offline players trend"}},
+		Legend: excelize.ChartLegend{Position: "none"},
+	})
+}
+
+// worstStores writes the stores with the most offline players over the period.
+func worstStores(f *excelize.File, stores []*storeStats) error {
+	if _, err := f.NewSheet(sheetTopStores); err != nil {
+		return err
+	}
+
+	rows := [][]interface{}{{"Store", "Company", "Offline player-runs", "Runs with offline players", "Average offline players", "Max offline players"}}
+	for i, s := range stores {
+		if i == topStores {
+			break
+		}
+		rows = append(rows, []interface{}{s.storeNumber, s.company, s.playerRuns, s.offlineRuns, s.averageByRun, s.maxPlayers})
+	}
+
+	return setRows(f, sheetTopStores, rows)
+}
+
+// companyTable writes per-company offline statistics.
+func companyTable(f *excelize.File, companies []*companyStats, runs int) error {
+	if _, err := f.NewSheet(sheetCompanies); err != nil {
+		return err
+	}
+
+	rows := [][]interface{}{{"Company", "Average offline players", "Max offline players", "Stores affected"}}
+	for _, c := range companies {
+		average := 0.0
+		if runs > 0 {
+			average = float64(c.playerRuns) / float64(runs)
+		}
+		rows = append(rows, []interface{}{c.company, average, c.maxPlayers, len(c.stores)})
+	}
+
+	return setRows(f, sheetCompanies, rows)
+}
+
+// setRows writes the rows starting at A1.
+func setRows(f *excelize.File, sheet string, rows [][]interface{}) error {
+	for i, row := range rows {
+		cell, err := excelize.CoordinatesToCellName(1, i+1)
+		if err != nil {
+			return err
+		}
+
+		if err = f.SetSheetRow(sheet, cell, &row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+
+	"go-players-data/internal/archive"
+	"go-players-data/internal/config"
+	"go-players-data/internal/logger"
+	"go-players-data/internal/mailer"
+	"go-players-data/internal/storage"
+)
+
+// Payload is the timer trigger payload that generates the report instead of running the pipeline.
+const Payload = "weekly-report"
+
+// contentType is the MIME type of the XLSX workbook.
+const contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// Delivery channels of the report.
+const (
+	deliveryEmail  = "email"
+	deliveryBucket = "bucket"
+)
+
+// ErrNoDelivery is returned when REPORT_DELIVERY lists no known delivery channel.
+var ErrNoDelivery = errors.New("no report delivery configured")
+
+// keyData is the data available to the report key template.
+type keyData struct {
+	Date string
+	From string
+	To   string
+}
+
+// reporter is a struct that builds the report from the archived runs and delivers it.
+type reporter struct {
+	archiver archive.Archiver
+	mailer   mailer.Mailer
+	store    storage.Storage
+	key      *template.Template
+	period   time.Duration
+	subject  string
+	email    bool
+	bucket   bool
+}
+
+// Reporter is an interface for generating and delivering the management report.
+type Reporter interface {
+	Generate(ctx context.Context, now time.Time) error
+}
+
+// New creates a new Reporter reading runs from the archiver and delivering the report by email, to the bucket, or both.
+func New(archiver archive.Archiver, m mailer.Mailer, store storage.Storage, cfg config.Report) (Reporter, error) {
+	key, err := template.New("key").Option("missingkey=error").Parse(cfg.Key)
+	if err != nil {
+		return nil, fmt.Errorf("report.New: invalid key template: %w", err)
+	}
+
+	r := &reporter{
+		archiver: archiver,
+		mailer:   m,
+		store:    store,
+		key:      key,
+		period:   cfg.Period,
+		subject:  cfg.Subject,
+	}
+
+	for _, d := range strings.Split(cfg.Delivery, ",") {
+		switch strings.TrimSpace(d) {
+		case deliveryEmail:
+			r.email = true
+		case deliveryBucket:
+			r.bucket = true
+		}
+	}
+
+	if !r.email && !r.bucket {
+		return nil, fmt.Errorf("report.New: %q: %w", cfg.Delivery, ErrNoDelivery)
+	}
+
+	return r, nil
+}
+
+// Generate builds the report of the runs archived during the period ending at now and delivers it.
+func (r *reporter) Generate(ctx context.Context, now time.Time) error {
+	start := time.Now()
+	defer func() { logger.Debug("report.Generate: Time spent", "time", time.Since(start).String()) }()
+
+	from := now.Add(-r.period)
+
+	runs, err := r.archiver.Runs(ctx, from, now)
+	if err != nil {
+		return fmt.Errorf("report.Generate: %w", err)
+	}
+
+	workbook, err := Build(runs, from, now)
+	if err != nil {
+		return fmt.Errorf("report.Generate: %w", err)
+	}
+
+	var buf bytes.Buffer
+	data := keyData{
+		Date: now.UTC().Format(time.DateOnly),
+		From: from.UTC().Format(time.DateOnly),
+		To:   now.UTC().Format(time.DateOnly),
+	}
+	if err = r.key.Execute(&buf, data); err != nil {
+		return fmt.Errorf("report.Generate: failed to render key: %w", err)
+	}
+	key := buf.String()
+
+	if r.bucket {
+		if err = r.store.Put(ctx, key, workbook, contentType); err != nil {
+			return fmt.Errorf("report.Generate: failed to upload %s: %w", key, err)
+		}
+		logger.Info("report.Generate: Report uploaded", "key", key, "runs", len(runs))
+	}
+
+	if r.email {
+		text := fmt.Sprintf("Players report for %s — %s, %d runs.", data.From, data.To, len(runs))
+		if err = r.mailer.SendAttachment(r.subject, text, path.Base(key), workbook); err != nil {
+			return fmt.Errorf("report.Generate: %w", err)
+		}
+		logger.Info("report.Generate: Report sent", "runs", len(runs))
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go-players-data/internal/codec"
+	"go-players-data/internal/logger"
+	"go-players-data/internal/model"
+	"go-players-data/internal/storage"
+)
+
+// Do calls fn up to attempts times, doubling the backoff after every failed attempt.
+// Returns the last error, or the context error when ctx is done while waiting.
+func Do(ctx context.Context, attempts int, backoff time.Duration, fn func() error) error {
+	if attempts <= 0 {
+		attempts = 1
+	}
+
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return fmt.Errorf("retry.Do: %w (last error: %w)", ctx.Err(), err)
+			}
+			backoff *= 2
+		}
+
+		if err = fn(); err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
+// Entry is a cluster notification that failed on every attempt and waits for redelivery.
+type Entry struct {
+	Channel     string          `json:"channel"`
+	StoreNumber int             `json:"store_number"`
+	Players     []*model.Player `json:"players"`
+	Recipients  []string        `json:"recipients,omitempty"` // Recipients the notification failed for, empty for all of them
+	RunID       string          `json:"run_id"`               // Run the notification was built by
+	FailedAt    time.Time       `json:"failed_at"`            // First failure
+	Invocations int             `json:"invocations"`
+	LastError   string          `json:"last_error"`
+}
+
+// Queue is the list of notifications waiting for redelivery. It is safe for concurrent use.
+type Queue struct {
+	mu      sync.Mutex
+	Entries []Entry `json:"entries"`
+}
+
+// Add appends the entry to the queue.
+func (q *Queue) Add(e Entry) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.Entries = append(q.Entries, e)
+}
+
+// Take removes every entry from the queue and returns them.
+func (q *Queue) Take() []Entry {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entries := q.Entries
+	q.Entries = nil
+
+	return entries
+}
+
+// Len returns the number of queued entries.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return len(q.Entries)
+}
+
+// state is a struct that keeps the retry queue as an object in object storage.
+type state struct {
+	store storage.Storage
+	key   string
+}
+
+// State is an interface for reading and saving the retry queue.
+type State interface {
+	Load(ctx context.Context) (*Queue, error)
+	Save(ctx context.Context, q *Queue) error
+}
+
+// NewState creates a new State stored under the key.
+func NewState(store storage.Storage, key string) State {
+	return &state{
+		store: store,
+		key:   key,
+	}
+}
+
+// Load reads the retry queue. Returns an empty queue when nothing is queued.
+func (s *state) Load(ctx context.Context) (*Queue, error) {
+	data, err := s.store.Get(ctx, s.key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return &Queue{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("retry.Load: failed to load queue: %w", err)
+	}
+
+	var q Queue
+	if err = codec.Unmarshal(data, &q); err != nil {
+		return nil, fmt.Errorf("retry.Load: failed to decode queue: %w", err)
+	}
+
+	return &q, nil
+}
+
+// Save writes the retry queue, or removes the object when the queue is empty.
+func (s *state) Save(ctx context.Context, q *Queue) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.Entries) == 0 {
+		if err := s.store.Delete(ctx, s.key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("retry.Save: failed to remove queue: %w", err)
+		}
+		return nil
+	}
+
+	data, err := codec.Marshal(s.key, q)
+	if err != nil {
+		return fmt.Errorf("retry.Save: failed to encode queue: %w", err)
+	}
+
+	if err = s.store.Put(ctx, s.key, data, codec.ContentType); err != nil {
+		return fmt.Errorf("retry.Save: failed to save queue: %w", err)
+	}
+
+	logger.Debug("retry.Save: Queue saved", "entries", len(q.Entries))
+	return nil
+}
@@ -0,0 +1,150 @@
+package rollup
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go-players-data/internal/config"
+	"go-players-data/internal/logger"
+	"go-players-data/internal/mailer"
+	"go-players-data/internal/model"
+)
+
+// Store is the offline count of a store in a company rollup.
+type Store struct {
+	StoreNumber int
+	StoreName   string
+	Players     []*model.Player
+}
+
+// Company is the rollup of a company: every store with offline players, worst first.
+type Company struct {
+	Name    string
+	Offline int
+	Stores  []Store
+}
+
+// rollup is a struct that sends per-company rollup emails to the headquarters contacts of each company.
+type rollup struct {
+	mailer     mailer.Mailer
+	recipients map[string][]string
+	threshold  int
+	subject    string
+	storeNames map[int]string
+}
+
+// Rollup is an interface for sending company rollups of the offline clusters of a run.
+type Rollup interface {
+	Send(runID string, clusters map[int][]*model.Player, now time.Time) error
+}
+
+// New creates a new Rollup from the per-company headquarters contacts of cfg.
+// storeNames maps store numbers to the names shown next to them.
+func New(m mailer.Mailer, cfg config.Rollup, storeNames map[int]string) Rollup {
+	recipients := make(map[string][]string, len(cfg.To))
+	for company, list := range cfg.To {
+		for _, to := range strings.Split(list, ";") {
+			if to = strings.TrimSpace(to); to != "" {
+				recipients[company] = append(recipients[company], to)
+			}
+		}
+	}
+
+	return &rollup{
+		mailer:     m,
+		recipients: recipients,
+		threshold:  cfg.DetailThreshold,
+		subject:    cfg.Subject,
+		storeNames: storeNames,
+	}
+}
+
+// Send emails the rollup of every company with headquarters contacts and offline players.
+// A failed company does not stop the others; the errors are joined.
+func (r *rollup) Send(runID string, clusters map[int][]*model.Player, now time.Time) error {
+	start := time.Now()
+	defer func() { logger.Debug("rollup.Send: Time spent", "time", time.Since(start).String()) }()
+
+	var errs []string
+	for _, c := range Build(clusters, r.storeNames) {
+		to, ok := r.recipients[c.Name]
+		if !ok {
+			continue
+		}
+
+		subject := fmt.Sprintf("%s: %s", r.subject, c.Name)
+		if err := r.mailer.SendText(subject, r.text(runID, c, now), to); err != nil {
+			logger.Error("rollup.Send: Failed to send rollup", "company", c.Name, "err", err)
+			errs = append(errs, fmt.Sprintf("%s: %v", c.Name, err))
+			continue
+		}
+		logger.Debug("rollup.Send: Rollup sent", "company", c.Name, "stores", len(c.Stores), "offline", c.Offline)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("rollup.Send: failed companies: %s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// Build groups the clusters by company. Stores are ordered by offline count, worst first, then by store number.
+func Build(clusters map[int][]*model.Player, storeNames map[int]string) []Company {
+	byCompany := make(map[string]*Company)
+	for sn, players := range clusters {
+		if len(players) == 0 {
+			continue
+		}
+
+		name := players[0].CompanyName
+		c, ok := byCompany[name]
+		if !ok {
+			c = &Company{Name: name}
+			byCompany[name] = c
+		}
+		c.Offline += len(players)
+		c.Stores = append(c.Stores, Store{StoreNumber: sn, StoreName: storeNames[sn], Players: players})
+	}
+
+	companies := make([]Company, 0, len(byCompany))
+	for _, c := range byCompany {
+		sort.Slice(c.Stores, func(i, j int) bool {
+			if len(c.Stores[i].Players) != len(c.Stores[j].Players) {
+				return len(c.Stores[i].Players) > len(c.Stores[j].Players)
+			}
+			return c.Stores[i].StoreNumber < c.Stores[j].StoreNumber
+		})
+		companies = append(companies, *c)
+	}
+	sort.Slice(companies, func(i, j int) bool { return companies[i].Name < companies[j].Name })
+
+	return companies
+}
+
+// text renders the rollup as plain text: the count of every store, and the offline players of the stores
+// with at least the detail threshold of them. A zero threshold lists counts only.
+func (r *rollup) text(runID string, c Company, now time.Time) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s, run %s at %s\n", c.Name, runID, now.Format(time.DateTime))
+	fmt.Fprintf(&b, "Offline players: %d in %d stores\n\n", c.Offline, len(c.Stores))
+
+	for _, s := range c.Stores {
+		fmt.Fprintf(&b, "Store %d %s: %d\n", s.StoreNumber, s.StoreName, len(s.Players))
+	}
+
+	for _, s := range c.Stores {
+		if r.threshold <= 0 || len(s.Players) < r.threshold {
+			continue
+		}
+
+		fmt.Fprintf(&b, "\nStore %d %s\n", s.StoreNumber, s.StoreName)
+		for _, p := range s.Players {
+			fmt.Fprintf(&b, "  %s, last online %s, IP %s\n", p.PlayerName, p.LastOnline.Format(time.DateTime), p.Addresses())
+		}
+	}
+
+	return b.String()
+}
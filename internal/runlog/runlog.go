@@ -0,0 +1,85 @@
+package runlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"go-players-data/internal/config"
+	"go-players-data/internal/logger"
+	"go-players-data/internal/stage"
+	"go-players-data/internal/storage"
+)
+
+// contentType is the content type of the monthly run log objects.
+const contentType = "application/x-ndjson"
+
+// Record is a single line of the run log.
+type Record struct {
+	RunID      string        `json:"run_id"`
+	Trigger    string        `json:"trigger"`
+	StartedAt  time.Time     `json:"started_at"`
+	DurationMs int64         `json:"duration_ms"`
+	StatusCode int           `json:"status_code"`
+	Stages     []stage.Stage `json:"stages"`
+	Result     interface{}   `json:"result"` // Response body of the run: the run report or the error body
+}
+
+// runLog is a struct that appends run records to monthly JSON Lines objects in object storage.
+type runLog struct {
+	store  storage.Storage
+	prefix string
+}
+
+// Log is an interface for appending a record of every run to the run log.
+type Log interface {
+	Append(ctx context.Context, r Record) error
+}
+
+// New creates a new Log writing <prefix>/<YYYY-MM>.jsonl objects.
+func New(store storage.Storage, cfg config.RunLog) Log {
+	return &runLog{
+		store:  store,
+		prefix: strings.Trim(cfg.Prefix, "/"),
+	}
+}
+
+// Append adds the record as a line of the object of the month it started in.
+// Object storage cannot append, so the object is read and written back whole; a run log holds about 750 lines
+// a month at an hourly schedule. Concurrent runs may lose each other's line.
+func (l *runLog) Append(ctx context.Context, r Record) error {
+	start := time.Now()
+	defer func() { logger.Debug("runlog.Append: Time spent", "time", time.Since(start).String()) }()
+
+	line, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("runlog.Append: failed to encode record: %w", err)
+	}
+
+	key := path.Join(l.prefix, r.StartedAt.UTC().Format("2006-01")+".jsonl")
+
+	data, err := l.store.Get(ctx, key)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("runlog.Append: failed to read %s: %w", key, err)
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(data) + len(line) + 1)
+	buf.Write(data)
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		buf.WriteByte('\n')
+	}
+	buf.Write(line)
+	buf.WriteByte('\n')
+
+	if err = l.store.Put(ctx, key, buf.Bytes(), contentType); err != nil {
+		return fmt.Errorf("runlog.Append: failed to write %s: %w", key, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,209 @@
+package schedule
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// maxSpan bounds the period Offline walks through, so a player offline for years does not cost a loop per day.
+const maxSpan = 400 * 24 * time.Hour
+
+// ErrInvalidHours is returned when operating hours are not in the HH:MM-HH:MM[;HH:MM-HH:MM] format.
+var ErrInvalidHours = errors.New("invalid operating hours")
+
+// hours is a daily operating period as offsets from local midnight.
+// A close before the open means the period runs past midnight.
+type hours struct {
+	open  time.Duration
+	close time.Duration
+}
+
+// registry is a struct that maps schedule names to their daily operating periods.
+// fallback holds the periods of players whose schedule is unknown, nil counts them around the clock.
+type registry struct {
+	schedules map[string][]hours
+	fallback  []hours
+}
+
+// Registry defines an interface for checking player offline time against the operating hours of its schedule.
+type Registry interface {
+	Known(name string) bool
+	Offline(name string, tzDiff int, from, to time.Time) time.Duration
+	Open(name string, tzDiff int, at time.Time) bool
+}
+
+// New creates a new Registry from schedule names mapped to their operating hours, e.g. "09:00-22:00"
+// or "09:00-13:00;14:00-22:00". Entries of the file, when it is set, are added first and overridden by schedules.
+// fallback, when set, holds the operating hours of players whose schedule is unknown.
+// Returns an error if the file cannot be read or any hours are invalid.
+func New(schedules map[string]string, file, fallback string) (Registry, error) {
+	all := make(map[string]string, len(schedules))
+
+	if file != "" {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("schedule.New: failed to read schedules file: %w", err)
+		}
+		if err = json.Unmarshal(b, &all); err != nil {
+			return nil, fmt.Errorf("schedule.New: failed to decode schedules file: %w", err)
+		}
+	}
+	for name, spec := range schedules {
+		all[name] = spec
+	}
+
+	r := &registry{schedules: make(map[string][]hours, len(all))}
+	for name, spec := range all {
+		h, err := parse(spec)
+		if err != nil {
+			return nil, fmt.Errorf("schedule.New: schedule %q: %w", name, err)
+		}
+		r.schedules[name] = h
+	}
+
+	if fallback != "" {
+		h, err := parse(fallback)
+		if err != nil {
+			return nil, fmt.Errorf("schedule.New: fallback schedule: %w", err)
+		}
+		r.fallback = h
+	}
+
+	return r, nil
+}
+
+// parse parses the operating periods of a single schedule.
+func parse(spec string) ([]hours, error) {
+	var result []hours
+	for _, period := range strings.Split(spec, ";") {
+		openRaw, closeRaw, ok := strings.Cut(strings.TrimSpace(period), "-")
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidHours, period)
+		}
+
+		open, err := clock(openRaw)
+		if err != nil {
+			return nil, err
+		}
+		closing, err := clock(closeRaw)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, hours{open: open, close: closing})
+	}
+
+	return result, nil
+}
+
+// clock parses an HH:MM time of day into the offset from midnight; 24:00 is the end of the day.
+func clock(raw string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(raw))
+	if err == nil {
+		return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+	}
+	if strings.TrimSpace(raw) == "24:00" {
+		return 24 * time.Hour, nil
+	}
+
+	return 0, fmt.Errorf("%w: %q", ErrInvalidHours, raw)
+}
+
+// Known reports whether the schedule has operating hours in the registry.
+func (r *registry) Known(name string) bool {
+	_, ok := r.schedules[name]
+	return ok
+}
+
+// Offline returns the part of the period between from and to that falls within the operating hours of the schedule,
+// i.e. how long a player was offline while the store was open. tzDiff is the offset of the player's local time
+// from UTC in hours. Unknown schedules use the fallback hours, or count the whole period without them.
+func (r *registry) Offline(name string, tzDiff int, from, to time.Time) time.Duration {
+	periods := r.periods(name)
+	if periods == nil {
+		return to.Sub(from)
+	}
+	if !from.Before(to) {
+		return 0
+	}
+	if to.Sub(from) > maxSpan {
+		from = to.Add(-maxSpan)
+	}
+
+	loc := zone(tzDiff)
+	localFrom := from.In(loc)
+
+	// Start a day earlier to catch periods running past midnight into the first day
+	day := time.Date(localFrom.Year(), localFrom.Month(), localFrom.Day()-1, 0, 0, 0, 0, loc)
+
+	var total time.Duration
+	for ; day.Before(to); day = day.AddDate(0, 0, 1) {
+		for _, h := range periods {
+			end := h.close
+			if end <= h.open {
+				end += 24 * time.Hour
+			}
+			total += overlap(day.Add(h.open), day.Add(end), from, to)
+		}
+	}
+
+	return total
+}
+
+// Open reports whether the store of the schedule is open at the time in the local time of the player.
+// Unknown schedules without fallback hours are always open.
+func (r *registry) Open(name string, tzDiff int, at time.Time) bool {
+	periods := r.periods(name)
+	if periods == nil {
+		return true
+	}
+
+	local := at.In(zone(tzDiff))
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, local.Location())
+	offset := local.Sub(midnight)
+
+	for _, h := range periods {
+		switch {
+		case h.open < h.close && offset >= h.open && offset < h.close:
+			return true
+		case h.close <= h.open && (offset >= h.open || offset < h.close):
+			// The period runs past midnight
+			return true
+		}
+	}
+
+	return false
+}
+
+// periods returns the operating periods of the schedule, the fallback ones when it is unknown.
+func (r *registry) periods(name string) []hours {
+	if periods, ok := r.schedules[name]; ok {
+		return periods
+	}
+	return r.fallback
+}
+
+// zone returns the fixed zone of a player's local time, tzDiff hours off UTC.
+func zone(tzDiff int) *time.Location {
+	return time.FixedZone("", tzDiff*int(time.Hour/time.Second))
+}
+
+// overlap returns the length of the intersection of the [aStart, aEnd) and [bStart, bEnd) intervals.
+func overlap(aStart, aEnd, bStart, bEnd time.Time) time.Duration {
+	start, end := aStart, aEnd
+	if bStart.After(start) {
+		start = bStart
+	}
+	if bEnd.Before(end) {
+		end = bEnd
+	}
+	if !start.Before(end) {
+		return 0
+	}
+
+	return end.Sub(start)
+}
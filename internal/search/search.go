@@ -0,0 +1,249 @@
+package search
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-players-data/internal/logger"
+	"go-players-data/internal/model"
+)
+
+// limitDefault and limitMax bound the number of players in a search response.
+const (
+	limitDefault = 50
+	limitMax     = 500
+)
+
+// Query selects players of the latest snapshot. Empty fields match every player.
+type Query struct {
+	MAC   string // Any notation, e.g. 00-1a-2b-3c-4d-5e or 001A2B3C4D5E
+	Store int    // Zero matches every store
+	Name  string // Case-insensitive substring of the player name
+	Limit int
+}
+
+// Result is a single player found by a search with its status at the time of the snapshot.
+type Result struct {
+	*model.Player
+	Status string `json:"status"`
+}
+
+// Response is the body of the search endpoint.
+type Response struct {
+	RunID   string    `json:"run_id"`
+	TakenAt time.Time `json:"taken_at"`
+	Total   int       `json:"total"`
+	Players []Result  `json:"players"`
+}
+
+// index is an in-memory view of the latest snapshot indexed by MAC address and store number, safe for concurrent use.
+type index struct {
+	mu         sync.RWMutex
+	maxOffline time.Duration
+	runID      string
+	takenAt    time.Time
+	players    []*model.Player
+	byMAC      map[string][]int
+	byStore    map[int][]int
+	byKey      map[string]int
+}
+
+// Index is an interface for replacing the indexed snapshot and searching it.
+type Index interface {
+	Update(runID string, takenAt time.Time, players []*model.Player)
+	Upsert(players []*model.Player) []*model.Player
+	Search(q Query) Response
+}
+
+// New creates an empty Index; statuses are computed with maxOffline.
+func New(maxOffline time.Duration) Index {
+	return &index{maxOffline: maxOffline}
+}
+
+// Update replaces the indexed snapshot with the players of a run.
+func (i *index) Update(runID string, takenAt time.Time, players []*model.Player) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.runID, i.takenAt = runID, takenAt
+	i.reindex(players)
+}
+
+// Upsert replaces the indexed players with the same key as the pushed ones, or adds them,
+// and returns the replaced players in the order of players, nil for added ones.
+// The slice of the run is copied, so snapshots taken by Update are never changed.
+func (i *index) Upsert(players []*model.Player) []*model.Player {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	next := append(make([]*model.Player, 0, len(i.players)+len(players)), i.players...)
+	previous := make([]*model.Player, len(players))
+	added := make(map[string]int)
+	for n, p := range players {
+		k, ok := i.byKey[p.Key()]
+		if !ok {
+			k, ok = added[p.Key()]
+		}
+		if ok {
+			previous[n] = next[k]
+			next[k] = p
+			continue
+		}
+		added[p.Key()] = len(next)
+		next = append(next, p)
+	}
+
+	i.reindex(next)
+	return previous
+}
+
+// reindex replaces the indexed players and rebuilds the lookup maps. The caller holds the lock.
+func (i *index) reindex(players []*model.Player) {
+	byMAC := make(map[string][]int, len(players))
+	byStore := make(map[int][]int)
+	byKey := make(map[string]int, len(players))
+	for n, p := range players {
+		if mac := compactMAC(p.MAC); mac != "" {
+			byMAC[mac] = append(byMAC[mac], n)
+		}
+		byStore[p.StoreNumber] = append(byStore[p.StoreNumber], n)
+		byKey[p.Key()] = n
+	}
+
+	i.players = players
+	i.byMAC, i.byStore, i.byKey = byMAC, byStore, byKey
+}
+
+// Search returns the players matching every field of the query, ordered by store number and player name.
+func (i *index) Search(q Query) Response {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	res := Response{RunID: i.runID, TakenAt: i.takenAt, Players: []Result{}}
+
+	// Narrow the candidates with the most selective index available
+	var candidates []int
+	switch {
+	case q.MAC != "":
+		candidates = i.byMAC[compactMAC(q.MAC)]
+	case q.Store != 0:
+		candidates = i.byStore[q.Store]
+	default:
+		candidates = make([]int, len(i.players))
+		for n := range candidates {
+			candidates[n] = n
+		}
+	}
+
+	name := strings.ToLower(q.Name)
+	for _, n := range candidates {
+		p := i.players[n]
+		if q.Store != 0 && p.StoreNumber != q.Store {
+			continue
+		}
+		if name != "" && !strings.Contains(strings.ToLower(p.PlayerName), name) {
+			continue
+		}
+		res.Players = append(res.Players, Result{Player: p, Status: p.Status(i.takenAt, i.maxOffline)})
+	}
+
+	sort.Slice(res.Players, func(a, b int) bool {
+		if res.Players[a].StoreNumber != res.Players[b].StoreNumber {
+			return res.Players[a].StoreNumber < res.Players[b].StoreNumber
+		}
+		return res.Players[a].PlayerName < res.Players[b].PlayerName
+	})
+
+	res.Total = len(res.Players)
+	limit := q.Limit
+	if limit <= 0 || limit > limitMax {
+		limit = limitDefault
+	}
+	if len(res.Players) > limit {
+		res.Players = res.Players[:limit]
+	}
+
+	return res
+}
+
+// compactMAC strips separators from a MAC address and upper-cases it. Returns an empty string for anything
+// that is not 12 hex digits.
+func compactMAC(mac string) string {
+	compact := strings.Map(func(r rune) rune {
+		switch {
+		case '0' <= r && r <= '9', 'A' <= r && r <= 'F':
+			return r
+		case 'a' <= r && r <= 'f':
+			return r - 'a' + 'A'
+		default:
+			return -1
+		}
+	}, mac)
+
+	if len(compact) != 12 {
+		return ""
+	}
+	return compact
+}
+
+// handler serves the search endpoint over the index.
+type handler struct {
+	index Index
+}
+
+// NewHandler creates an http.Handler exposing GET /search?mac=...&store=...&name=...&limit=... relative to its mount point.
+func NewHandler(idx Index) http.Handler {
+	h := &handler{index: idx}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/search", h.search)
+
+	return mux
+}
+
+// search answers a player search; at least one of mac, store and name is required.
+func (h *handler) search(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	params := r.URL.Query()
+	q := Query{
+		MAC:  params.Get("mac"),
+		Name: params.Get("name"),
+	}
+
+	var err error
+	if s := params.Get("store"); s != "" {
+		if q.Store, err = strconv.Atoi(s); err != nil {
+			http.Error(w, "store must be a number", http.StatusBadRequest)
+			return
+		}
+	}
+	if s := params.Get("limit"); s != "" {
+		if q.Limit, err = strconv.Atoi(s); err != nil {
+			http.Error(w, "limit must be a number", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if q.MAC == "" && q.Store == 0 && q.Name == "" {
+		http.Error(w, "one of mac, store or name is required", http.StatusBadRequest)
+		return
+	}
+	if q.MAC != "" && compactMAC(q.MAC) == "" {
+		http.Error(w, "mac must have 12 hex digits", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(h.index.Search(q)); err != nil {
+		logger.Error("search.search: Failed to encode response", "err", err)
+	}
+}
@@ -0,0 +1,183 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go-players-data/internal/config"
+	"go-players-data/internal/discord"
+	"go-players-data/internal/escalation"
+	"go-players-data/internal/gchat"
+	"go-players-data/internal/mailer"
+	"go-players-data/internal/model"
+	"go-players-data/internal/telegram"
+	"go-players-data/internal/templateloader"
+	"go-players-data/internal/webhook"
+)
+
+// Deps holds the run-scoped dependencies sinks are created with.
+type Deps struct {
+	Client    *http.Client
+	Mailer    mailer.Mailer
+	Templates func(ctx context.Context) (*templateloader.Loader, error)
+	RunID     string
+	RunAt     time.Time
+}
+
+// Factory creates a sink when it is enabled by the configuration.
+type Factory struct {
+	Enabled func(cfg config.Config) bool
+	New     func(ctx context.Context, cfg config.Config, deps Deps) (Sink, error)
+}
+
+// registry is a struct that keeps the sink factories in registration order.
+type registry struct {
+	names     []string
+	factories map[string]Factory
+}
+
+// Registry is an interface for registering sink factories and creating the sinks enabled by the configuration.
+type Registry interface {
+	Register(name string, f Factory)
+	Build(ctx context.Context, cfg config.Config, deps Deps) ([]Sink, error)
+	Names() []string
+	Only(names ...string) Registry
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() Registry {
+	return &registry{
+		factories: make(map[string]Factory),
+	}
+}
+
+// Default creates a Registry of the built-in sinks: mail, gchat, discord, telegram, webhook and escalation.
+func Default() Registry {
+	r := NewRegistry()
+
+	// In digest mode the clusters are emailed together by mailer.SendDigest once every channel is notified
+	r.Register("mail", Factory{
+		Enabled: func(cfg config.Config) bool { return cfg.Mail.Enabled && cfg.Mail.Mode != mailer.ModeDigest },
+		New: func(_ context.Context, _ config.Config, deps Deps) (Sink, error) {
+			return Func("mail", func(ctx context.Context, sn int, players []*model.Player) error {
+				if to := Recipients(ctx); to != nil {
+					return deps.Mailer.SendOnly(sn, players, to)
+				}
+				return deps.Mailer.Send(sn, players)
+			}), nil
+		},
+	})
+
+	// Post cluster summaries to Google Chat spaces
+	r.Register("gchat", Factory{
+		Enabled: func(cfg config.Config) bool {
+			return cfg.GChat.WebhookURL != "" || len(cfg.GChat.WebhooksByCompany) > 0
+		},
+		New: func(_ context.Context, cfg config.Config, deps Deps) (Sink, error) {
+			return Func("gchat", gchat.New(deps.Client, cfg.GChat, cfg.Mail.MailStores).Send), nil
+		},
+	})
+
+	// Post offline lists to Discord channels
+	r.Register("discord", Factory{
+		Enabled: func(cfg config.Config) bool {
+			return cfg.Discord.WebhookURL != "" || len(cfg.Discord.WebhooksByStore) > 0 || len(cfg.Discord.WebhooksByCompany) > 0
+		},
+		New: func(_ context.Context, cfg config.Config, deps Deps) (Sink, error) {
+			return Func("discord", discord.New(deps.Client, cfg.Discord, cfg.Mail.MailStores).Send), nil
+		},
+	})
+
+	// Send offline lists to Telegram chats
+	r.Register("telegram", Factory{
+		Enabled: func(cfg config.Config) bool { return cfg.Telegram.Token != "" },
+		New: func(_ context.Context, cfg config.Config, deps Deps) (Sink, error) {
+			return Func("telegram", telegram.New(deps.Client, cfg.Telegram, cfg.Mail.MailStores).Send), nil
+		},
+	})
+
+	// Post template-rendered payloads to a generic webhook
+	r.Register("webhook", Factory{
+		Enabled: func(cfg config.Config) bool { return cfg.Webhook.URL != "" },
+		New: func(ctx context.Context, cfg config.Config, deps Deps) (Sink, error) {
+			templateLoader, err := deps.Templates(ctx)
+			if err != nil {
+				return nil, err
+			}
+
+			hook, err := webhook.New(deps.Client, cfg.Webhook, templateLoader, cfg.Mail.MailStores, deps.RunID, deps.RunAt)
+			if err != nil {
+				return nil, err
+			}
+			return Func("webhook", hook.Send), nil
+		},
+	})
+
+	// Email the store notification to the escalation recipients of its severity and company
+	r.Register(escalation.Channel, Factory{
+		Enabled: func(cfg config.Config) bool { return len(cfg.Escalation.Recipients) > 0 },
+		New: func(_ context.Context, cfg config.Config, deps Deps) (Sink, error) {
+			matrix := escalation.New(cfg.Escalation)
+			return Func(escalation.Channel, func(_ context.Context, sn int, players []*model.Player) error {
+				to := matrix.Recipients(sn, players, deps.RunAt)
+				if len(to) == 0 {
+					return nil
+				}
+				return deps.Mailer.SendTo(sn, players, to)
+			}), nil
+		},
+	})
+
+	return r
+}
+
+// Register adds the factory under name. Registering a name again replaces its factory and keeps its position.
+func (r *registry) Register(name string, f Factory) {
+	if _, ok := r.factories[name]; !ok {
+		r.names = append(r.names, name)
+	}
+	r.factories[name] = f
+}
+
+// Build creates every enabled sink in registration order.
+func (r *registry) Build(ctx context.Context, cfg config.Config, deps Deps) ([]Sink, error) {
+	var sinks []Sink
+	for _, name := range r.names {
+		f := r.factories[name]
+		if f.Enabled != nil && !f.Enabled(cfg) {
+			continue
+		}
+
+		s, err := f.New(ctx, cfg, deps)
+		if err != nil {
+			return nil, fmt.Errorf("sink.Build: %s: %w", name, err)
+		}
+		sinks = append(sinks, s)
+	}
+
+	return sinks, nil
+}
+
+// Names returns the registered sink names in registration order.
+func (r *registry) Names() []string {
+	return append([]string(nil), r.names...)
+}
+
+// Only returns a Registry with the factories of the given names that are registered, in registration order.
+func (r *registry) Only(names ...string) Registry {
+	keep := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		keep[name] = struct{}{}
+	}
+
+	only := NewRegistry()
+	for _, name := range r.names {
+		if _, ok := keep[name]; ok {
+			only.Register(name, r.factories[name])
+		}
+	}
+
+	return only
+}
@@ -0,0 +1,69 @@
+package sink
+
+import (
+	"context"
+	"errors"
+
+	"go-players-data/internal/mailer"
+	"go-players-data/internal/model"
+)
+
+// recipientsKey is the context key of the recipients a retried send is limited to.
+type recipientsKey struct{}
+
+// WithRecipients returns a context limiting the sends of a retry to the recipients that failed before.
+// Sinks without per-recipient delivery ignore it and send the whole cluster again.
+func WithRecipients(ctx context.Context, to []string) context.Context {
+	if len(to) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, recipientsKey{}, to)
+}
+
+// Recipients returns the recipients the send is limited to by WithRecipients, or nil for every recipient.
+func Recipients(ctx context.Context) []string {
+	to, _ := ctx.Value(recipientsKey{}).([]string)
+	return to
+}
+
+// FailedRecipients returns the recipients a send failed for when it reached the others, or nil when it failed as a whole.
+func FailedRecipients(err error) []string {
+	var recipientsErr *mailer.RecipientsError
+	if errors.As(err, &recipientsErr) {
+		return recipientsErr.Recipients
+	}
+	return nil
+}
+
+// SendFunc sends a single cluster of offline players.
+type SendFunc func(ctx context.Context, storeNumber int, players []*model.Player) error
+
+// Sink is a notification output every cluster of a run is sent to.
+type Sink interface {
+	Name() string
+	Send(ctx context.Context, storeNumber int, players []*model.Player) error
+}
+
+// funcSink is a struct that adapts a send function to the Sink interface.
+type funcSink struct {
+	name string
+	send SendFunc
+}
+
+// Func creates a Sink with the given name sending clusters with send.
+func Func(name string, send SendFunc) Sink {
+	return &funcSink{
+		name: name,
+		send: send,
+	}
+}
+
+// Name returns the name of the sink used in logs, limits and the retry queue.
+func (s *funcSink) Name() string {
+	return s.name
+}
+
+// Send sends the cluster.
+func (s *funcSink) Send(ctx context.Context, storeNumber int, players []*model.Player) error {
+	return s.send(ctx, storeNumber, players)
+}
@@ -0,0 +1,86 @@
+package snapshot
+
+import (
+	"sync"
+	"time"
+
+	"go-players-data/internal/model"
+)
+
+// capacityDefault is used when the configured capacity is not positive.
+const capacityDefault = 288
+
+// Snapshot is the result of a single run: the offline players that passed the filter and the total player count.
+type Snapshot struct {
+	RunID   string
+	TakenAt time.Time
+	Total   int
+	Players []*model.Player
+}
+
+// store is an in-memory ring of the most recent snapshots, safe for concurrent use.
+type store struct {
+	mu        sync.RWMutex
+	snapshots []*Snapshot
+	capacity  int
+}
+
+// Store is an interface for keeping run snapshots and reading them back by recency or time range.
+type Store interface {
+	Add(s *Snapshot)
+	Last() (*Snapshot, bool)
+	Range(from, to time.Time) []*Snapshot
+}
+
+// New creates a new in-memory Store keeping at most capacity snapshots.
+func New(capacity int) Store {
+	if capacity <= 0 {
+		capacity = capacityDefault
+	}
+
+	return &store{
+		snapshots: make([]*Snapshot, 0, capacity),
+		capacity:  capacity,
+	}
+}
+
+// Add appends a snapshot, evicting the oldest one when the store is full.
+func (s *store) Add(snap *Snapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.snapshots) == s.capacity {
+		copy(s.snapshots, s.snapshots[1:])
+		s.snapshots = s.snapshots[:len(s.snapshots)-1]
+	}
+
+	s.snapshots = append(s.snapshots, snap)
+}
+
+// Last returns the most recent snapshot, or false if there is none yet.
+func (s *store) Last() (*Snapshot, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.snapshots) == 0 {
+		return nil, false
+	}
+
+	return s.snapshots[len(s.snapshots)-1], true
+}
+
+// Range returns the snapshots taken within [from, to], oldest first.
+func (s *store) Range(from, to time.Time) []*Snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var snapshots []*Snapshot
+	for _, snap := range s.snapshots {
+		if snap.TakenAt.Before(from) || snap.TakenAt.After(to) {
+			continue
+		}
+		snapshots = append(snapshots, snap)
+	}
+
+	return snapshots
+}
@@ -0,0 +1,144 @@
+package stage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+	"time"
+
+	"go-players-data/internal/logger"
+	"go-players-data/internal/storage"
+)
+
+// Stage is the measurement of a single pipeline stage.
+// Allocations are read from the process-wide memory statistics, so stages running concurrently share them.
+type Stage struct {
+	Name       string        `json:"name"`
+	Duration   time.Duration `json:"duration"`
+	Allocs     uint64        `json:"allocs"`
+	AllocBytes uint64        `json:"alloc_bytes"`
+	Items      int           `json:"items"`
+}
+
+// recorder is a struct that collects stage measurements of a run.
+type recorder struct {
+	mu     sync.Mutex
+	stages []Stage
+}
+
+// Recorder is an interface for measuring the stages of a run.
+type Recorder interface {
+	Start(name string) func(items int)
+	Stages() []Stage
+}
+
+// New creates a new Recorder.
+func New() Recorder {
+	return &recorder{}
+}
+
+// Start begins measuring a stage and returns the function that ends it with the number of items the stage handled.
+func (r *recorder) Start(name string) func(items int) {
+	var before runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+
+	return func(items int) {
+		elapsed := time.Since(start)
+
+		var after runtime.MemStats
+		runtime.ReadMemStats(&after)
+
+		s := Stage{
+			Name:       name,
+			Duration:   elapsed,
+			Allocs:     after.Mallocs - before.Mallocs,
+			AllocBytes: after.TotalAlloc - before.TotalAlloc,
+			Items:      items,
+		}
+
+		logger.Debug("stage.Start: Stage done",
+			"stage", s.Name,
+			"time", s.Duration.String(),
+			"allocs", s.Allocs,
+			"alloc_bytes", s.AllocBytes,
+			"items", s.Items,
+		)
+
+		r.mu.Lock()
+		r.stages = append(r.stages, s)
+		r.mu.Unlock()
+	}
+}
+
+// Stages returns the measured stages in the order they ended.
+func (r *recorder) Stages() []Stage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Stage(nil), r.stages...)
+}
+
+// profiler is a struct that records a CPU profile of a run and keeps it only when the run is slow.
+type profiler struct {
+	store     storage.Storage
+	prefix    string
+	threshold time.Duration
+	cpu       bytes.Buffer
+	started   bool
+}
+
+// Profiler is an interface for profiling a run and uploading the profiles of slow runs.
+type Profiler interface {
+	Stop(ctx context.Context, runID string, elapsed time.Duration) error
+}
+
+// StartProfiler starts CPU profiling of a run. Profiles are uploaded under prefix when the run takes longer than threshold.
+// When another run is already being profiled, the run is not profiled and Stop does nothing.
+func StartProfiler(store storage.Storage, prefix string, threshold time.Duration) Profiler {
+	p := &profiler{
+		store:     store,
+		prefix:    prefix,
+		threshold: threshold,
+	}
+
+	if err := pprof.StartCPUProfile(&p.cpu); err != nil {
+		logger.Warn("stage.StartProfiler: CPU profile not started", "err", err)
+		return p
+	}
+	p.started = true
+
+	return p
+}
+
+// Stop ends CPU profiling and, when the run exceeded the threshold, uploads the CPU and heap profiles
+// as <prefix>/<date>/<run ID>/cpu.pprof and heap.pprof.
+func (p *profiler) Stop(ctx context.Context, runID string, elapsed time.Duration) error {
+	if !p.started {
+		return nil
+	}
+	pprof.StopCPUProfile()
+
+	if elapsed <= p.threshold {
+		return nil
+	}
+
+	var heap bytes.Buffer
+	if err := pprof.WriteHeapProfile(&heap); err != nil {
+		return fmt.Errorf("stage.Stop: failed to write heap profile: %w", err)
+	}
+
+	dir := path.Join(p.prefix, time.Now().UTC().Format(time.DateOnly), runID)
+	for name, profile := range map[string][]byte{"cpu.pprof": p.cpu.Bytes(), "heap.pprof": heap.Bytes()} {
+		if err := p.store.Put(ctx, path.Join(dir, name), profile, "application/octet-stream"); err != nil {
+			return fmt.Errorf("stage.Stop: failed to upload %s: %w", name, err)
+		}
+	}
+
+	logger.Warn("stage.Stop: Slow run profiled", "run_id", runID, "time", elapsed.String(), "dir", dir)
+	return nil
+}
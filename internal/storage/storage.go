@@ -0,0 +1,303 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"go-players-data/internal/config"
+	"go-players-data/internal/logger"
+)
+
+// emptyPayloadHash is the SHA-256 of an empty request body, used to sign requests without a payload.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// ErrNotFound is returned when the requested object does not exist.
+var ErrNotFound = errors.New("object not found")
+
+// ErrConflict is returned by PutIfMatch when the object changed since it was read.
+var ErrConflict = errors.New("object changed concurrently")
+
+// Object describes a stored object returned by List.
+type Object struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// storage is a minimal S3-compatible client (Yandex Object Storage) signing requests with AWS Signature V4.
+type storage struct {
+	client    *http.Client
+	endpoint  url.URL
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+}
+
+// Storage is an interface for putting, getting, listing, and deleting objects in a bucket.
+type Storage interface {
+	Put(ctx context.Context, key string, body []byte, contentType string) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	GetVersion(ctx context.Context, key string) ([]byte, string, error)
+	PutIfMatch(ctx context.Context, key string, body []byte, contentType, etag string) error
+	List(ctx context.Context, prefix string) ([]Object, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// New creates a new Storage instance for the configured bucket.
+func New(c *http.Client, cfg config.Storage) Storage {
+	return &storage{
+		client:    c,
+		endpoint:  cfg.Endpoint,
+		region:    cfg.Region,
+		bucket:    cfg.Bucket,
+		accessKey: cfg.AccessKeyID,
+		secretKey: cfg.SecretAccessKey,
+	}
+}
+
+// Put uploads body under the key, replacing any existing object.
+func (s *storage) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	start := time.Now()
+	defer func() { logger.Debug("storage.Put: Time spent", "time", time.Since(start).String(), "key", key) }()
+
+	resp, err := s.do(ctx, http.MethodPut, key, nil, body, contentType, nil)
+	if err != nil {
+		return fmt.Errorf("storage.Put: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	return nil
+}
+
+// Get downloads the object stored under the key. Returns ErrNotFound if it does not exist.
+func (s *storage) Get(ctx context.Context, key string) ([]byte, error) {
+	body, _, err := s.GetVersion(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("storage.Get: %w", err)
+	}
+
+	return body, nil
+}
+
+// GetVersion downloads the object stored under the key with its ETag, for a later PutIfMatch.
+// Returns ErrNotFound if it does not exist.
+func (s *storage) GetVersion(ctx context.Context, key string) ([]byte, string, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, nil, "", nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("storage.GetVersion: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("storage.GetVersion: failed to read object: %w", err)
+	}
+
+	return body, resp.Header.Get("ETag"), nil
+}
+
+// PutIfMatch uploads body under the key only if the object still has the ETag, or does not exist yet
+// when etag is empty. Returns ErrConflict when another writer changed the object in the meantime.
+func (s *storage) PutIfMatch(ctx context.Context, key string, body []byte, contentType, etag string) error {
+	start := time.Now()
+	defer func() { logger.Debug("storage.PutIfMatch: Time spent", "time", time.Since(start).String(), "key", key) }()
+
+	header := http.Header{}
+	if etag != "" {
+		header.Set("If-Match", etag)
+	} else {
+		header.Set("If-None-Match", "*")
+	}
+
+	resp, err := s.do(ctx, http.MethodPut, key, nil, body, contentType, header)
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) && (httpErr.Code == http.StatusPreconditionFailed || httpErr.Code == http.StatusConflict) {
+		return fmt.Errorf("storage.PutIfMatch: %w: %s", ErrConflict, key)
+	}
+	if err != nil {
+		return fmt.Errorf("storage.PutIfMatch: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	return nil
+}
+
+// listBucketResult is the subset of the ListObjectsV2 response used by List.
+type listBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List returns every object whose key starts with prefix, following continuation tokens.
+func (s *storage) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	token := ""
+
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := s.do(ctx, http.MethodGet, "", query, nil, "", nil)
+		if err != nil {
+			return nil, fmt.Errorf("storage.List: %w", err)
+		}
+
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("storage.List: failed to decode response: %w", err)
+		}
+
+		for _, c := range result.Contents {
+			objects = append(objects, Object{Key: c.Key, Size: c.Size, LastModified: c.LastModified})
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// Delete removes the object stored under the key.
+func (s *storage) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, nil, "", nil)
+	if err != nil {
+		return fmt.Errorf("storage.Delete: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	return nil
+}
+
+// do signs and sends a request against the bucket with the extra headers, returning an HTTPError for non-2xx responses.
+func (s *storage) do(ctx context.Context, method, key string, query url.Values, body []byte, contentType string, header http.Header) (*http.Response, error) {
+	u := s.endpoint
+	u.Path = "/" + s.bucket + "/" + strings.TrimPrefix(key, "/")
+	u.RawQuery = strings.ReplaceAll(query.Encode(), "+", "%20")
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	s.sign(req, body, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		_ = resp.Body.Close()
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		_ = resp.Body.Close()
+		logger.Error("storage.do: Invalid status code", "statusCode", resp.StatusCode, "method", method, "key", key, "body", string(msg))
+		return nil, &HTTPError{Code: resp.StatusCode}
+	}
+
+	return resp, nil
+}
+
+// sign adds AWS Signature Version 4 headers to the request.
+func (s *storage) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	payloadHash := emptyPayloadHash
+	if len(body) > 0 {
+		sum := sha256.Sum256(body)
+		payloadHash = hex.EncodeToString(sum[:])
+	}
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, strings.ToLower(name))
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(req.Header.Get(name)) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature,
+	))
+}
+
+// hmacSHA256 computes HMAC-SHA256 of data with the key.
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// HTTPError represents an error response from the storage with a specific status code.
+type HTTPError struct {
+	Code int
+}
+
+// Error returns the text representation of the HTTP status code associated with the HTTPError.
+func (e *HTTPError) Error() string {
+	return http.StatusText(e.Code)
+}
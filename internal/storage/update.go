@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go-players-data/internal/logger"
+)
+
+// updateAttempts bounds the read-modify-write rounds of Update lost to concurrent writers.
+const updateAttempts = 5
+
+// Update reads the object under the key, applies fn to it and writes the result back with PutIfMatch,
+// so concurrent runs updating the same state object never overwrite each other. On a conflict the object
+// is read again and fn is applied to the fresh content, up to updateAttempts times.
+// fn gets nil for a missing object.
+func Update(ctx context.Context, s Storage, key, contentType string, fn func(data []byte) ([]byte, error)) error {
+	for attempt := 1; ; attempt++ {
+		data, etag, err := s.GetVersion(ctx, key)
+		if errors.Is(err, ErrNotFound) {
+			data, etag, err = nil, "", nil
+		}
+		if err != nil {
+			return fmt.Errorf("storage.Update: %w", err)
+		}
+
+		next, err := fn(data)
+		if err != nil {
+			return fmt.Errorf("storage.Update: %w", err)
+		}
+
+		err = s.PutIfMatch(ctx, key, next, contentType, etag)
+		if errors.Is(err, ErrConflict) && attempt < updateAttempts {
+			logger.Warn("storage.Update: Object changed concurrently, retrying", "key", key, "attempt", attempt)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("storage.Update: %w", err)
+		}
+
+		return nil
+	}
+}
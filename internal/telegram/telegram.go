@@ -0,0 +1,160 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-players-data/internal/cluster"
+	"go-players-data/internal/config"
+	"go-players-data/internal/logger"
+	"go-players-data/internal/model"
+)
+
+// maxLength is the Telegram limit of characters per message; players beyond it are summarized in a last line.
+const maxLength = 4096
+
+// message is a Telegram Bot API sendMessage request.
+type message struct {
+	ChatID                string `json:"chat_id"`
+	Text                  string `json:"text"`
+	ParseMode             string `json:"parse_mode"`
+	DisableWebPagePreview bool   `json:"disable_web_page_preview"`
+}
+
+// notifier is a struct that sends offline lists to Telegram chats through a bot.
+type notifier struct {
+	client     *http.Client
+	endpoint   string
+	chat       string
+	byStore    map[int]string
+	byCompany  map[string]string
+	storeNames map[int]string
+}
+
+// Notifier defines an interface for sending Telegram notifications to players grouped by store number.
+type Notifier interface {
+	Send(ctx context.Context, storeNumber int, players []*model.Player) error
+}
+
+// New creates a new Notifier routing clusters by store first, then by company, then to the default chat.
+func New(c *http.Client, cfg config.Telegram, storeNames map[int]string) Notifier {
+	return &notifier{
+		client:     c,
+		endpoint:   fmt.Sprintf("%s/bot%s/sendMessage", strings.TrimSuffix(cfg.APIURL.String(), "/"), cfg.Token),
+		chat:       cfg.ChatID,
+		byStore:    cfg.ChatsByStore,
+		byCompany:  cfg.ChatsByCompany,
+		storeNames: storeNames,
+	}
+}
+
+// Send sends a message listing the offline players of the store.
+// Clusters without a matching chat are skipped.
+func (n *notifier) Send(ctx context.Context, storeNumber int, players []*model.Player) error {
+	start := time.Now()
+	defer func() { logger.Debug("telegram.Send: Time spent", "time", time.Since(start).String()) }()
+
+	chat := n.route(storeNumber, players)
+	if chat == "" {
+		logger.Debug("telegram.Send: No chat for cluster", "cluster", storeNumber)
+		return nil
+	}
+
+	data, err := json.Marshal(&message{
+		ChatID:                chat,
+		Text:                  n.text(storeNumber, players),
+		ParseMode:             "HTML",
+		DisableWebPagePreview: true,
+	})
+	if err != nil {
+		return fmt.Errorf("telegram.Send: failed to build message: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.endpoint, bytes.NewReader(data))
+	if err != nil {
+		// The request URL carries the bot token, so the error is not wrapped
+		return fmt.Errorf("telegram.Send: failed to create request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("telegram.Send: failed to send message: %s", n.redact(err.Error()))
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		logger.Error("telegram.Send: Invalid status code", "statusCode", resp.StatusCode, "body", string(msg))
+		return &HTTPError{Code: resp.StatusCode}
+	}
+
+	return nil
+}
+
+// route returns the chat of the store, of the cluster company, or the default one.
+func (n *notifier) route(storeNumber int, players []*model.Player) string {
+	if chat, ok := n.byStore[storeNumber]; ok {
+		return chat
+	}
+
+	if company, ok := cluster.Company(storeNumber, players); ok {
+		if chat, ok := n.byCompany[company]; ok {
+			return chat
+		}
+	}
+
+	return n.chat
+}
+
+// text builds an HTML message with one line per offline player, within the Telegram message limit.
+func (n *notifier) text(storeNumber int, players []*model.Player) string {
+	storeID := strconv.Itoa(storeNumber)
+	if name := n.storeNames[storeNumber]; name != "" {
+		storeID = name
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<b>Store %s: %d players offline</b>\n", html.EscapeString(storeID), len(players))
+	if len(players) > 0 && players[0].CompanyName != "" {
+		fmt.Fprintf(&b, "%s\n", html.EscapeString(players[0].CompanyName))
+	}
+	b.WriteString("\n")
+
+	for i, p := range players {
+		line := fmt.Sprintf("• <b>%s</b> — last online %s, IP %s, MAC <code>%s</code>\n",
+			html.EscapeString(p.PlayerName), p.LastOnline.Format(time.DateTime), html.EscapeString(p.Addresses()), p.MAC)
+
+		more := fmt.Sprintf("… and %d more\n", len(players)-i)
+		if b.Len()+len(line)+len(more) > maxLength {
+			b.WriteString(more)
+			break
+		}
+		b.WriteString(line)
+	}
+
+	return b.String()
+}
+
+// redact removes the bot token from error texts, since net/http errors include the request URL.
+func (n *notifier) redact(s string) string {
+	return strings.ReplaceAll(s, n.endpoint, "<telegram sendMessage>")
+}
+
+// HTTPError represents an error response from the Telegram Bot API with a specific status code.
+type HTTPError struct {
+	Code int
+}
+
+// Error returns the text representation of the HTTP status code associated with the HTTPError.
+func (e *HTTPError) Error() string {
+	return http.StatusText(e.Code)
+}
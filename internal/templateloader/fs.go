@@ -0,0 +1,40 @@
+package templateloader
+
+import (
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// Template sources selected by MAIL_TEMPLATE_SOURCE.
+const (
+	SourceDir    = "dir"    // The templates directory next to the binary
+	SourceEmbed  = "embed"  // Templates bundled into the binary with go:embed
+	SourceRemote = "remote" // Templates pinned by checksum and fetched from MAIL_TEMPLATE_REMOTE_URL
+)
+
+// NewFS initializes a Loader that serves every <name>.tmpl file at the root of fsys, such as templates bundled
+// with go:embed, so the function package does not depend on a templates directory being deployed next to it.
+// Returns an error if the files cannot be read or there is none.
+func NewFS(fsys fs.FS) (*Loader, error) {
+	paths, err := fs.Glob(fsys, "*.tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("templateloader.NewFS: %w", err)
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("templateloader.NewFS: no template found")
+	}
+
+	sources := make(map[string]string, len(paths))
+	for _, path := range paths {
+		src, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return nil, fmt.Errorf("templateloader.NewFS: failed to read %s: %w", path, err)
+		}
+		sources[strings.TrimSuffix(path, ".tmpl")] = string(src)
+	}
+
+	return &Loader{
+		sources: sources,
+	}, nil
+}
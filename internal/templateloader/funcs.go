@@ -0,0 +1,166 @@
+package templateloader
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// severityColors maps severity names to the colors used to highlight them in email templates.
+var severityColors = map[string]string{
+	"info":     "#2e7d32",
+	"warning":  "#f9a825",
+	"critical": "#c62828",
+}
+
+// severityColorDefault is used for severities missing from severityColors.
+const severityColorDefault = "#616161"
+
+// ErrDictArgs is returned by the dict template function when called with an odd number of arguments or a non-string key.
+var ErrDictArgs = errors.New("dict expects key/value pairs with string keys")
+
+// Funcs returns the common function map available to every mail template.
+func Funcs() template.FuncMap {
+	return template.FuncMap{
+		"join": strings.Join,
+		"base64enc": func(s string) string {
+			return base64.StdEncoding.EncodeToString([]byte(s))
+		},
+		"formatTZ":      formatTZ,
+		"since":         time.Since,
+		"humanize":      humanizeDuration,
+		"plural":        plural,
+		"formatNumber":  formatNumber,
+		"dict":          dict,
+		"default":       defaultValue,
+		"severityColor": severityColor,
+	}
+}
+
+// formatTZ formats t with the layout in the given IANA time zone, e.g. {{formatTZ .LastOnline "Europe/Moscow" "2006-01-02 15:04"}}.
+func formatTZ(t time.Time, tz, layout string) (string, error) {
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return "", fmt.Errorf("templateloader.formatTZ: unknown time zone %q: %w", tz, err)
+	}
+
+	return t.In(loc).Format(layout), nil
+}
+
+// humanizeDuration renders a duration as its two most significant units, e.g. "2 days 3 hours" or "45 minutes".
+func humanizeDuration(d time.Duration) string {
+	if d < 0 {
+		d = -d
+	}
+
+	units := []struct {
+		size time.Duration
+		name string
+	}{
+		{24 * time.Hour, "day"},
+		{time.Hour, "hour"},
+		{time.Minute, "minute"},
+	}
+
+	var parts []string
+	for _, u := range units {
+		n := int(d / u.size)
+		if n == 0 {
+			continue
+		}
+
+		parts = append(parts, fmt.Sprintf("%d %s", n, plural(n, u.name, u.name+"s")))
+		d -= time.Duration(n) * u.size
+
+		if len(parts) == 2 {
+			break
+		}
+	}
+
+	if len(parts) == 0 {
+		return "less than a minute"
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// plural returns singular when n is one and plural otherwise.
+func plural(n int, singular, plural string) string {
+	if n == 1 || n == -1 {
+		return singular
+	}
+	return plural
+}
+
+// formatNumber renders an integer with a space as the thousands separator, e.g. 12345 -> "12 345".
+func formatNumber(n int) string {
+	s := strconv.Itoa(n)
+
+	sign := ""
+	if n < 0 {
+		sign, s = "-", s[1:]
+	}
+
+	var builder strings.Builder
+	for i, r := range s {
+		if i != 0 && (len(s)-i)%3 == 0 {
+			builder.WriteByte(' ')
+		}
+		builder.WriteRune(r)
+	}
+
+	return sign + builder.String()
+}
+
+// dict builds a map from key/value pairs, so several values can be passed to a nested template.
+func dict(pairs ...interface{}) (map[string]interface{}, error) {
+	if len(pairs)%2 != 0 {
+		return nil, ErrDictArgs
+	}
+
+	m := make(map[string]interface{}, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		key, ok := pairs[i].(string)
+		if !ok {
+			return nil, ErrDictArgs
+		}
+		m[key] = pairs[i+1]
+	}
+
+	return m, nil
+}
+
+// defaultValue returns value unless it is empty (nil, zero or an empty collection), in which case def is returned.
+// Used as {{.Field | default "n/a"}}.
+func defaultValue(def, value interface{}) interface{} {
+	if value == nil {
+		return def
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Array, reflect.String:
+		if v.Len() == 0 {
+			return def
+		}
+	default:
+		if v.IsZero() {
+			return def
+		}
+	}
+
+	return value
+}
+
+// severityColor maps a severity name to its highlight color.
+func severityColor(severity string) string {
+	if c, ok := severityColors[strings.ToLower(severity)]; ok {
+		return c
+	}
+	return severityColorDefault
+}
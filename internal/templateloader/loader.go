@@ -5,6 +5,10 @@ import (
 	"html/template"
 	"os"
 	"path/filepath"
+	"sync"
+	texttemplate "text/template"
+
+	"github.com/Masterminds/sprig/v3"
 )
 
 // templatesDirDefault defines the default directory name where template files are stored if no other directory is specified.
@@ -12,9 +16,16 @@ const (
 	templatesDirDefault = "templates"
 )
 
-// Loader is a struct that manages the loading of templates from a specified directory.
+// Loader is a struct that manages the loading of templates from a specified directory
+// or from in-memory sources prefetched by NewRemote or read by NewFS.
+// While Watch is running, the last good source of every loaded template is kept in watched.
 type Loader struct {
 	templatesDir string
+	sources      map[string]string
+	sprig        bool
+
+	mu      sync.RWMutex
+	watched map[string]watchedTemplate
 }
 
 // New initializes a Loader instance with the provided template directories
@@ -34,18 +45,102 @@ func New(templatesDir ...string) (*Loader, error) {
 	}, nil
 }
 
+// WithSprig toggles the Sprig function library for templates loaded afterward.
+// Functions passed to Load take precedence over Sprig functions with the same name.
+func (t *Loader) WithSprig(enabled bool) *Loader {
+	t.sprig = enabled
+	return t
+}
+
 // Load loads a template by name from the loader's templates directory and applies the given template functions.
 // Returns the parsed template or an error if the file is not found or cannot be parsed.
 func (t *Loader) Load(name string, funcs template.FuncMap) (*template.Template, error) {
+	funcs = t.withSprig(funcs)
+
+	if t.sources != nil {
+		return t.loadSource(name, funcs)
+	}
+
+	if src, ok := t.watchedSource(name); ok {
+		return parse(name, src, funcs)
+	}
+
 	tmplPath := filepath.Join(t.templatesDir, fmt.Sprintf("%s.tmpl", name))
 
-	if _, err := os.Stat(tmplPath); os.IsNotExist(err) {
+	src, err := os.ReadFile(tmplPath)
+	if os.IsNotExist(err) {
 		return nil, fmt.Errorf("loader.Must: template file not found: %s", tmplPath)
 	}
+	if err != nil {
+		return nil, fmt.Errorf("loader.Must: failed to read template: %w", err)
+	}
+
+	tmpl, err := parse(name, string(src), funcs)
+	if err != nil {
+		return nil, err
+	}
+
+	t.remember(name, string(src), funcs)
+
+	return tmpl, nil
+}
+
+// LoadText loads a template by name like Load but parses it with text/template, without HTML escaping,
+// for non-HTML output such as JSON webhook payloads. Text templates are not hot-reloaded by Watch.
+func (t *Loader) LoadText(name string, funcs texttemplate.FuncMap) (*texttemplate.Template, error) {
+	funcs = texttemplate.FuncMap(t.withSprig(template.FuncMap(funcs)))
+
+	src, err := t.textSource(name)
+	if err != nil {
+		return nil, err
+	}
 
-	tmpl, err := template.New(filepath.Base(tmplPath)).
+	tmpl, err := texttemplate.New(fmt.Sprintf("%s.tmpl", name)).Funcs(funcs).Parse(src)
+	if err != nil {
+		return nil, fmt.Errorf("loader.LoadText: failed to parse template: %w", err)
+	}
+
+	return tmpl, nil
+}
+
+// textSource returns the source of a template: prefetched by NewRemote or read by NewFS, the last good watched version,
+// or read from disk.
+func (t *Loader) textSource(name string) (string, error) {
+	if t.sources != nil {
+		src, ok := t.sources[name]
+		if !ok {
+			return "", fmt.Errorf("loader.LoadText: template is not bundled or pinned: %s", name)
+		}
+		return src, nil
+	}
+
+	if src, ok := t.watchedSource(name); ok {
+		return src, nil
+	}
+
+	src, err := os.ReadFile(filepath.Join(t.templatesDir, fmt.Sprintf("%s.tmpl", name)))
+	if err != nil {
+		return "", fmt.Errorf("loader.LoadText: failed to read template: %w", err)
+	}
+
+	return string(src), nil
+}
+
+// loadSource parses a template prefetched by NewRemote or read by NewFS.
+func (t *Loader) loadSource(name string, funcs template.FuncMap) (*template.Template, error) {
+	src, ok := t.sources[name]
+	if !ok {
+		return nil, fmt.Errorf("loader.Must: template is not bundled or pinned: %s", name)
+	}
+
+	return parse(name, src, funcs)
+}
+
+// parse parses the template source under the <name>.tmpl name with the given functions.
+func parse(name, src string, funcs template.FuncMap) (*template.Template, error) {
+	tmpl, err := template.New(fmt.Sprintf("%s.tmpl", name)).
 		Funcs(funcs).
-		ParseFiles(tmplPath)
+		Parse(src)
 
 	if err != nil {
 		return nil, fmt.Errorf("loader.Must: failed to parse template: %w", err)
@@ -53,3 +148,17 @@ func (t *Loader) Load(name string, funcs template.FuncMap) (*template.Template,
 
 	return tmpl, nil
 }
+
+// withSprig merges funcs over the Sprig function map when Sprig is enabled.
+func (t *Loader) withSprig(funcs template.FuncMap) template.FuncMap {
+	if !t.sprig {
+		return funcs
+	}
+
+	merged := sprig.FuncMap()
+	for name, fn := range funcs {
+		merged[name] = fn
+	}
+
+	return merged
+}
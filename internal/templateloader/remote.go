@@ -0,0 +1,89 @@
+package templateloader
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go-players-data/internal/logger"
+)
+
+// ErrChecksumMismatch is returned when a remote template does not match its pinned SHA-256 checksum.
+var ErrChecksumMismatch = errors.New("template checksum mismatch")
+
+// remoteCache keeps the verified remote templates by URL and checksum, so warm invocations do not fetch them again.
+// A pinned checksum identifies the content, so a cached template never goes stale.
+var remoteCache sync.Map
+
+// NewRemote initializes a Loader that serves templates hosted under the base HTTPS URL.
+// Every template listed in checksums (name -> hex SHA-256) is fetched as <base>/<name>.tmpl at startup
+// and verified against its pin, unless an earlier invocation already fetched it; templates without a pin are never fetched.
+// Returns an error if any template cannot be fetched or does not match its checksum.
+func NewRemote(ctx context.Context, c *http.Client, base url.URL, checksums map[string]string) (*Loader, error) {
+	start := time.Now()
+	defer func() { logger.Debug("templateloader.NewRemote: Time spent", "time", time.Since(start).String()) }()
+
+	if base.Scheme != "https" {
+		return nil, fmt.Errorf("templateloader.NewRemote: remote templates require https, got %q", base.Scheme)
+	}
+
+	sources := make(map[string]string, len(checksums))
+	for name, checksum := range checksums {
+		u := base.JoinPath(fmt.Sprintf("%s.tmpl", name))
+		key := u.String() + "\n" + strings.ToLower(checksum)
+		if src, ok := remoteCache.Load(key); ok {
+			sources[name] = src.(string)
+			continue
+		}
+
+		src, err := fetchTemplate(ctx, c, u, checksum)
+		if err != nil {
+			return nil, fmt.Errorf("templateloader.NewRemote: template %q: %w", name, err)
+		}
+		remoteCache.Store(key, src)
+		sources[name] = src
+	}
+
+	return &Loader{
+		sources: sources,
+	}, nil
+}
+
+// fetchTemplate downloads a single template and verifies its SHA-256 checksum.
+func fetchTemplate(ctx context.Context, c *http.Client, u *url.URL, checksum string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status fetching %s: %s", u.Redacted(), resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(body)
+	if got := hex.EncodeToString(sum[:]); !strings.EqualFold(got, checksum) {
+		logger.Error("templateloader.fetchTemplate: Checksum mismatch", "url", u.Redacted(), "want", checksum, "got", got)
+		return "", ErrChecksumMismatch
+	}
+
+	return string(body), nil
+}
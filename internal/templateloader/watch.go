@@ -0,0 +1,120 @@
+package templateloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+
+	"go-players-data/internal/logger"
+)
+
+// ErrWatchRemote is returned when Watch is called on a Loader serving prefetched remote templates.
+var ErrWatchRemote = errors.New("remote templates cannot be watched")
+
+// watchedTemplate is the last source of a template that parsed successfully, with the functions it was parsed with.
+type watchedTemplate struct {
+	src   string
+	funcs template.FuncMap
+}
+
+// Watch watches the templates directory until ctx is done and reloads changed templates.
+// A changed template replaces the previous source only if it parses with the functions it was loaded with;
+// on failure the previous good version keeps being served and the error is logged.
+// Templates that were never loaded are picked up from disk on their first Load.
+func (t *Loader) Watch(ctx context.Context) error {
+	if t.sources != nil {
+		return ErrWatchRemote
+	}
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("templateloader.Watch: failed to create watcher: %w", err)
+	}
+	defer func() { _ = w.Close() }()
+
+	if err = w.Add(t.templatesDir); err != nil {
+		return fmt.Errorf("templateloader.Watch: failed to watch %s: %w", t.templatesDir, err)
+	}
+
+	t.mu.Lock()
+	if t.watched == nil {
+		t.watched = make(map[string]watchedTemplate)
+	}
+	t.mu.Unlock()
+
+	logger.Info("templateloader.Watch: Watching templates", "dir", t.templatesDir)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-w.Events:
+			if !ok {
+				return nil
+			}
+			if event.Has(fsnotify.Write) || event.Has(fsnotify.Create) {
+				t.reload(event.Name)
+			}
+		case err, ok := <-w.Errors:
+			if !ok {
+				return nil
+			}
+			logger.Error("templateloader.Watch: Watcher error", "err", err)
+		}
+	}
+}
+
+// reload re-parses a changed template file and swaps it in if it is valid.
+func (t *Loader) reload(path string) {
+	if filepath.Ext(path) != ".tmpl" {
+		return
+	}
+	name := strings.TrimSuffix(filepath.Base(path), ".tmpl")
+
+	t.mu.RLock()
+	prev, ok := t.watched[name]
+	t.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	src, err := os.ReadFile(path)
+	if err != nil {
+		logger.Error("templateloader.reload: Failed to read template, keeping previous version", "err", err, "template", name)
+		return
+	}
+
+	if _, err = parse(name, string(src), prev.funcs); err != nil {
+		logger.Error("templateloader.reload: Failed to parse template, keeping previous version", "err", err, "template", name)
+		return
+	}
+
+	t.remember(name, string(src), prev.funcs)
+	logger.Info("templateloader.reload: Template reloaded", "template", name)
+}
+
+// watchedSource returns the last good source of a template if the loader is watching and has loaded it before.
+func (t *Loader) watchedSource(name string) (string, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	wt, ok := t.watched[name]
+	return wt.src, ok
+}
+
+// remember stores a successfully parsed template source while the loader is watching.
+func (t *Loader) remember(name, src string, funcs template.FuncMap) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.watched == nil {
+		return
+	}
+	t.watched[name] = watchedTemplate{src: src, funcs: funcs}
+}
@@ -0,0 +1,160 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go-players-data/internal/cluster"
+	"go-players-data/internal/codec"
+	"go-players-data/internal/config"
+	"go-players-data/internal/logger"
+	"go-players-data/internal/model"
+	"go-players-data/internal/storage"
+)
+
+// Notified maps store numbers to the time their cluster was last notified.
+type Notified map[string]time.Time
+
+// throttle is a struct that enforces the minimum interval between two notifications of a store,
+// keeping the last notification times in object storage.
+type throttle struct {
+	store     storage.Storage
+	key       string
+	interval  time.Duration
+	byStore   map[int]time.Duration
+	byCompany map[string]time.Duration
+}
+
+// Throttle is an interface for leaving recently notified clusters out of a run and recording notified ones.
+type Throttle interface {
+	Filter(ctx context.Context, clusters map[int][]*model.Player, now time.Time) map[int][]*model.Player
+	Record(ctx context.Context, clusters map[int][]*model.Player, now time.Time) error
+}
+
+// New creates a new Throttle with the configured default, per-store and per-company intervals.
+func New(store storage.Storage, cfg config.Throttle) Throttle {
+	return &throttle{
+		store:     store,
+		key:       cfg.StateKey,
+		interval:  cfg.Interval,
+		byStore:   cfg.ByStore,
+		byCompany: cfg.ByCompany,
+	}
+}
+
+// Enabled reports whether any throttling interval is configured.
+func Enabled(cfg config.Throttle) bool {
+	return cfg.Interval > 0 || len(cfg.ByStore) > 0 || len(cfg.ByCompany) > 0
+}
+
+// intervalOf returns the interval of the cluster: a store override wins over a company override,
+// and the default interval is used when neither is configured. See cluster.Company for the company of a cluster.
+func (t *throttle) intervalOf(storeNumber int, players []*model.Player) time.Duration {
+	if d, ok := t.byStore[storeNumber]; ok {
+		return d
+	}
+
+	if company, ok := cluster.Company(storeNumber, players); ok {
+		if d, ok := t.byCompany[company]; ok {
+			return d
+		}
+	}
+
+	return t.interval
+}
+
+// Filter returns the clusters whose store was not notified within its interval.
+// On state errors all clusters are returned, so alerts are never lost.
+func (t *throttle) Filter(ctx context.Context, clusters map[int][]*model.Player, now time.Time) map[int][]*model.Player {
+	notified, err := t.load(ctx)
+	if err != nil {
+		logger.Error("throttle.Filter: Failed to load state", "err", err)
+		return clusters
+	}
+
+	result := make(map[int][]*model.Player, len(clusters))
+	for sn, players := range clusters {
+		last, ok := notified[strconv.Itoa(sn)]
+		if interval := t.intervalOf(sn, players); ok && interval > 0 && now.Sub(last) < interval {
+			logger.Debug("throttle.Filter: Cluster throttled", "cluster", sn, "last_notified", last, "interval", interval)
+			continue
+		}
+		result[sn] = players
+	}
+
+	return result
+}
+
+// Record saves now as the last notification time of every cluster and drops entries older than any interval could need.
+// The state is written conditionally and recomputed from the fresh state when a concurrent run changed it.
+func (t *throttle) Record(ctx context.Context, clusters map[int][]*model.Player, now time.Time) error {
+	if len(clusters) == 0 {
+		return nil
+	}
+
+	maxInterval := t.interval
+	for _, d := range t.byStore {
+		maxInterval = max(maxInterval, d)
+	}
+	for _, d := range t.byCompany {
+		maxInterval = max(maxInterval, d)
+	}
+
+	err := storage.Update(ctx, t.store, t.key, codec.ContentType, func(data []byte) ([]byte, error) {
+		notified, err := t.decode(data)
+		if err != nil {
+			return nil, err
+		}
+
+		for k, last := range notified {
+			if now.Sub(last) > maxInterval {
+				delete(notified, k)
+			}
+		}
+
+		for sn := range clusters {
+			notified[strconv.Itoa(sn)] = now
+		}
+
+		data, err = codec.Marshal(t.key, notified)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode state: %w", err)
+		}
+		return data, nil
+	})
+	if err != nil {
+		return fmt.Errorf("throttle.Record: %w", err)
+	}
+
+	return nil
+}
+
+// load reads the last notification times. A missing state object means no store was notified yet.
+func (t *throttle) load(ctx context.Context) (Notified, error) {
+	data, err := t.store.Get(ctx, t.key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return make(Notified), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("throttle.load: failed to load state: %w", err)
+	}
+
+	return t.decode(data)
+}
+
+// decode decodes the last notification times of the state object, nil for a missing one.
+func (t *throttle) decode(data []byte) (Notified, error) {
+	notified := make(Notified)
+	if data == nil {
+		return notified, nil
+	}
+
+	if err := codec.Unmarshal(data, &notified); err != nil {
+		return nil, fmt.Errorf("throttle.decode: failed to decode state: %w", err)
+	}
+
+	return notified, nil
+}
@@ -0,0 +1,334 @@
+package ticket
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-players-data/internal/cluster"
+	"go-players-data/internal/codec"
+	"go-players-data/internal/config"
+	"go-players-data/internal/logger"
+	"go-players-data/internal/model"
+	"go-players-data/internal/storage"
+)
+
+// Ticket systems selected by TICKET_SYSTEM.
+const (
+	SystemJira       = "jira"
+	SystemServiceNow = "servicenow"
+)
+
+// ErrUnknownSystem is returned when TICKET_SYSTEM names an unsupported ticket system.
+var ErrUnknownSystem = errors.New("unknown ticket system")
+
+// backend is the API of a ticket system: opening a ticket and closing it with a comment.
+type backend interface {
+	open(ctx context.Context, summary, description string) (string, error)
+	close(ctx context.Context, id, comment string) error
+}
+
+// ticketer is a struct that opens a ticket per store cluster with offline players and closes it on recovery.
+// Open tickets are kept in a JSON state object (cluster key -> ticket ID) in object storage.
+type ticketer struct {
+	backend    backend
+	minPlayers int
+	autoClose  bool
+	store      storage.Storage
+	stateKey   string
+	storeNames map[int]string
+}
+
+// Ticketer is an interface for syncing Jira or ServiceNow tickets with the current clusters.
+type Ticketer interface {
+	Sync(ctx context.Context, clusters map[int][]*model.Player) error
+}
+
+// New creates a new Ticketer for the system of the config, using the given storage for the ticket state.
+func New(c *http.Client, cfg config.Ticket, store storage.Storage, storeNames map[int]string) (Ticketer, error) {
+	baseURL := strings.TrimSuffix(cfg.URL.String(), "/")
+	a := auth{user: cfg.User, token: cfg.Token}
+
+	var b backend
+	switch strings.ToLower(cfg.System) {
+	case SystemJira:
+		b = &jira{client: c, baseURL: baseURL, auth: a, project: cfg.Project, issueType: cfg.IssueType, transition: cfg.CloseTransition}
+	case SystemServiceNow:
+		b = &serviceNow{client: c, baseURL: baseURL, auth: a, assignmentGroup: cfg.AssignmentGroup}
+	default:
+		return nil, fmt.Errorf("ticket.New: %w: %q", ErrUnknownSystem, cfg.System)
+	}
+
+	return &ticketer{
+		backend:    b,
+		minPlayers: cfg.MinPlayers,
+		autoClose:  cfg.AutoClose,
+		store:      store,
+		stateKey:   cfg.StateKey,
+		storeNames: storeNames,
+	}, nil
+}
+
+// Sync opens a ticket for every cluster with at least the minimum offline players and no open ticket.
+// Tickets of clusters that recovered are closed with a comment when auto-close is on, and forgotten otherwise,
+// so the next outage of the store opens a new ticket.
+func (t *ticketer) Sync(ctx context.Context, clusters map[int][]*model.Player) error {
+	start := time.Now()
+	defer func() { logger.Debug("ticket.Sync: Time spent", "time", time.Since(start).String()) }()
+
+	tickets, err := t.loadState(ctx)
+	if err != nil {
+		return fmt.Errorf("ticket.Sync: %w", err)
+	}
+
+	for key, players := range clusters {
+		if len(players) < t.minPlayers {
+			continue
+		}
+		if _, ok := tickets[key]; ok {
+			continue
+		}
+
+		summary := fmt.Sprintf("Store %s: %d players offline", t.storeID(key, players), len(players))
+		id, err := t.backend.open(ctx, summary, description(players))
+		if err != nil {
+			logger.Error("ticket.Sync: Failed to open ticket", "err", err, "cluster", key)
+			continue
+		}
+
+		tickets[key] = id
+		logger.Info("ticket.Sync: Ticket opened", "cluster", key, "ticket", id)
+	}
+
+	for key, id := range tickets {
+		if len(clusters[key]) >= t.minPlayers {
+			continue
+		}
+
+		if t.autoClose {
+			if err = t.backend.close(ctx, id, "All players of the store are back online."); err != nil {
+				logger.Error("ticket.Sync: Failed to close ticket", "err", err, "cluster", key, "ticket", id)
+				continue
+			}
+			logger.Info("ticket.Sync: Ticket closed", "cluster", key, "ticket", id)
+		} else {
+			logger.Info("ticket.Sync: Store recovered, ticket left open", "cluster", key, "ticket", id)
+		}
+
+		delete(tickets, key)
+	}
+
+	if err = t.saveState(ctx, tickets); err != nil {
+		return fmt.Errorf("ticket.Sync: %w", err)
+	}
+
+	return nil
+}
+
+// description lists the offline players of a cluster, one per line.
+func description(players []*model.Player) string {
+	var b strings.Builder
+	for _, p := range players {
+		fmt.Fprintf(&b, "- %s: last online %s, IP %s, MAC %s, type %s\n",
+			p.PlayerName, p.LastOnline.Format(time.DateTime), p.Addresses(), p.MAC, p.Type)
+	}
+	return b.String()
+}
+
+// storeID returns the configured name of the store, the name of a digest cluster, or the store number.
+func (t *ticketer) storeID(key int, players []*model.Player) string {
+	if name := t.storeNames[key]; name != "" {
+		return name
+	}
+	if name, ok := cluster.Name(key, players); ok {
+		return name
+	}
+	return strconv.Itoa(key)
+}
+
+// loadState reads the open tickets from object storage. A missing state object means no open tickets.
+func (t *ticketer) loadState(ctx context.Context) (map[int]string, error) {
+	tickets := make(map[int]string)
+
+	data, err := t.store.Get(ctx, t.stateKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return tickets, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+
+	if err = codec.Unmarshal(data, &tickets); err != nil {
+		return nil, fmt.Errorf("failed to decode state: %w", err)
+	}
+
+	return tickets, nil
+}
+
+// saveState writes the open tickets to object storage.
+func (t *ticketer) saveState(ctx context.Context, tickets map[int]string) error {
+	data, err := codec.Marshal(t.stateKey, tickets)
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	if err = t.store.Put(ctx, t.stateKey, data, codec.ContentType); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+
+	return nil
+}
+
+// auth is the API token of a ticket system, sent with basic auth when a user is set.
+type auth struct {
+	user  string
+	token string
+}
+
+// do sends a JSON request with the credentials and decodes the response into res if it is not nil.
+// header names the header that carries the token when no user is set.
+func (a auth) do(ctx context.Context, c *http.Client, method, rawURL, header string, body, res interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if a.user != "" {
+		req.SetBasicAuth(a.user, a.token)
+	} else if header == "Authorization" {
+		req.Header.Set(header, "Bearer "+a.token)
+	} else {
+		req.Header.Set(header, a.token)
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		logger.Error("ticket.do: Invalid status code", "statusCode", resp.StatusCode, "url", rawURL, "body", string(msg))
+		return &HTTPError{Code: resp.StatusCode}
+	}
+
+	if res == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(res)
+}
+
+// jira is a backend that opens Jira issues through the REST API v2.
+type jira struct {
+	client     *http.Client
+	baseURL    string
+	auth       auth
+	project    string
+	issueType  string
+	transition string
+}
+
+// open creates an issue in the project and returns its key.
+func (j *jira) open(ctx context.Context, summary, description string) (string, error) {
+	body := map[string]interface{}{
+		"fields": map[string]interface{}{
+			"project":     map[string]string{"key": j.project},
+			"issuetype":   map[string]string{"name": j.issueType},
+			"summary":     summary,
+			"description": description,
+			"labels":      []string{"players-offline"},
+		},
+	}
+
+	var res struct {
+		Key string `json:"key"`
+	}
+	if err := j.auth.do(ctx, j.client, http.MethodPost, j.baseURL+"/rest/api/2/issue", "Authorization", body, &res); err != nil {
+		return "", err
+	}
+
+	return res.Key, nil
+}
+
+// close comments on the issue and executes the close transition.
+func (j *jira) close(ctx context.Context, key, comment string) error {
+	issueURL := j.baseURL + "/rest/api/2/issue/" + url.PathEscape(key)
+
+	if err := j.auth.do(ctx, j.client, http.MethodPost, issueURL+"/comment", "Authorization", map[string]string{"body": comment}, nil); err != nil {
+		return err
+	}
+
+	transition := map[string]interface{}{"transition": map[string]string{"id": j.transition}}
+	return j.auth.do(ctx, j.client, http.MethodPost, issueURL+"/transitions", "Authorization", transition, nil)
+}
+
+// serviceNow is a backend that opens ServiceNow incidents through the Table API.
+type serviceNow struct {
+	client          *http.Client
+	baseURL         string
+	auth            auth
+	assignmentGroup string
+}
+
+// serviceNowResolved is the incident state of a resolved incident.
+const serviceNowResolved = "6"
+
+// open creates an incident and returns its sys_id.
+func (s *serviceNow) open(ctx context.Context, summary, description string) (string, error) {
+	body := map[string]string{
+		"short_description": summary,
+		"description":       description,
+	}
+	if s.assignmentGroup != "" {
+		body["assignment_group"] = s.assignmentGroup
+	}
+
+	var res struct {
+		Result struct {
+			SysID  string `json:"sys_id"`
+			Number string `json:"number"`
+		} `json:"result"`
+	}
+	if err := s.auth.do(ctx, s.client, http.MethodPost, s.baseURL+"/api/now/table/incident", "x-sn-apikey", body, &res); err != nil {
+		return "", err
+	}
+
+	logger.Debug("ticket.open: Incident created", "number", res.Result.Number, "sys_id", res.Result.SysID)
+	return res.Result.SysID, nil
+}
+
+// close resolves the incident with the comment as its close notes.
+func (s *serviceNow) close(ctx context.Context, sysID, comment string) error {
+	body := map[string]string{
+		"state":       serviceNowResolved,
+		"close_code":  "Resolved by caller",
+		"close_notes": comment,
+	}
+
+	return s.auth.do(ctx, s.client, http.MethodPatch, s.baseURL+"/api/now/table/incident/"+url.PathEscape(sysID), "x-sn-apikey", body, nil)
+}
+
+// HTTPError represents an error response from the ticket system with a specific status code.
+type HTTPError struct {
+	Code int
+}
+
+// Error returns the text representation of the HTTP status code associated with the HTTPError.
+func (e *HTTPError) Error() string {
+	return http.StatusText(e.Code)
+}
@@ -0,0 +1,313 @@
+package tracker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-players-data/internal/codec"
+	"go-players-data/internal/config"
+	"go-players-data/internal/logger"
+	"go-players-data/internal/model"
+	"go-players-data/internal/storage"
+)
+
+// apiURLDefault is the Yandex Tracker API base URL.
+const apiURLDefault = "https://api.tracker.yandex.net/v2"
+
+// ErrConflict is returned when an issue with the same unique key already exists.
+var ErrConflict = errors.New("issue already exists")
+
+// issueRequest is the body of the create issue request.
+type issueRequest struct {
+	Queue       string   `json:"queue"`
+	Summary     string   `json:"summary"`
+	Description string   `json:"description"`
+	Unique      string   `json:"unique"`
+	Components  []string `json:"components,omitempty"`
+	Assignee    string   `json:"assignee,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+// issueResponse is the subset of the create issue response used by the integration.
+type issueResponse struct {
+	Key string `json:"key"`
+}
+
+// route is the queue, components, and assignee of a store region.
+type route struct {
+	queue      string
+	components []string
+	assignee   string
+}
+
+// tracker is a struct that opens Yandex Tracker issues for critical clusters and closes them on recovery.
+// Open issues are kept in a JSON state object (store number -> issue key) in object storage.
+type tracker struct {
+	client          *http.Client
+	apiURL          string
+	token           string
+	orgID           string
+	cloudOrg        bool
+	minPlayers      int
+	closeTransition string
+	queue           string
+	storeRegions    map[int]string
+	regionQueues    map[string]string
+	regionComps     map[string]string
+	regionAssignees map[string]string
+	store           storage.Storage
+	stateKey        string
+	storeNames      map[int]string
+}
+
+// Tracker is an interface for syncing Tracker issues with the current clusters.
+type Tracker interface {
+	Sync(ctx context.Context, clusters map[int][]*model.Player) error
+}
+
+// New creates a new Tracker using the given storage for the issue state.
+func New(c *http.Client, cfg config.Tracker, store storage.Storage, storeNames map[int]string) Tracker {
+	apiURL := apiURLDefault
+	if cfg.APIURL.Host != "" {
+		apiURL = strings.TrimSuffix(cfg.APIURL.String(), "/")
+	}
+
+	return &tracker{
+		client:          c,
+		apiURL:          apiURL,
+		token:           cfg.Token,
+		orgID:           cfg.OrgID,
+		cloudOrg:        cfg.CloudOrg,
+		minPlayers:      cfg.MinPlayers,
+		closeTransition: cfg.CloseTransition,
+		queue:           cfg.Queue,
+		storeRegions:    cfg.StoreRegions,
+		regionQueues:    cfg.RegionQueues,
+		regionComps:     cfg.RegionComponents,
+		regionAssignees: cfg.RegionAssignees,
+		store:           store,
+		stateKey:        cfg.StateKey,
+		storeNames:      storeNames,
+	}
+}
+
+// Sync creates an issue for every critical cluster without an open one,
+// and comments and closes the issues of stores that are no longer critical.
+func (t *tracker) Sync(ctx context.Context, clusters map[int][]*model.Player) error {
+	start := time.Now()
+	defer func() { logger.Debug("tracker.Sync: Time spent", "time", time.Since(start).String()) }()
+
+	issues, err := t.loadState(ctx)
+	if err != nil {
+		return fmt.Errorf("tracker.Sync: %w", err)
+	}
+
+	for storeNumber, players := range clusters {
+		if len(players) < t.minPlayers {
+			continue
+		}
+		if _, ok := issues[storeNumber]; ok {
+			continue
+		}
+
+		key, err := t.create(ctx, storeNumber, players)
+		if errors.Is(err, ErrConflict) {
+			logger.Warn("tracker.Sync: Issue already exists", "cluster", storeNumber)
+			continue
+		}
+		if err != nil {
+			logger.Error("tracker.Sync: Failed to create issue", "err", err, "cluster", storeNumber)
+			continue
+		}
+
+		issues[storeNumber] = key
+		logger.Info("tracker.Sync: Issue created", "cluster", storeNumber, "issue", key)
+	}
+
+	for storeNumber, key := range issues {
+		if len(clusters[storeNumber]) >= t.minPlayers {
+			continue
+		}
+
+		if err = t.resolve(ctx, key); err != nil {
+			logger.Error("tracker.Sync: Failed to close issue", "err", err, "cluster", storeNumber, "issue", key)
+			continue
+		}
+
+		delete(issues, storeNumber)
+		logger.Info("tracker.Sync: Issue closed", "cluster", storeNumber, "issue", key)
+	}
+
+	if err = t.saveState(ctx, issues); err != nil {
+		return fmt.Errorf("tracker.Sync: %w", err)
+	}
+
+	return nil
+}
+
+// create opens an issue for the cluster in the queue of its region and returns the issue key.
+// The unique field is derived from the store and the time its most recently seen player went offline,
+// so retries of the same incident never open a second issue.
+func (t *tracker) create(ctx context.Context, storeNumber int, players []*model.Player) (string, error) {
+	r := t.route(storeNumber)
+
+	var since time.Time
+	var description strings.Builder
+	for _, p := range players {
+		if p.LastOnline.After(since) {
+			since = p.LastOnline
+		}
+		fmt.Fprintf(&description, "- %s: last online %s, IP %s, MAC %s, type %s\n",
+			p.PlayerName, p.LastOnline.Format(time.DateTime), p.Addresses(), p.MAC, p.Type)
+	}
+
+	body := issueRequest{
+		Queue:       r.queue,
+		Summary:     fmt.Sprintf("Store %s: %d players offline", t.storeID(storeNumber), len(players)),
+		Description: description.String(),
+		Unique:      fmt.Sprintf("go-players-data:store:%d:%d", storeNumber, since.Unix()),
+		Components:  r.components,
+		Assignee:    r.assignee,
+		Tags:        []string{"players-offline"},
+	}
+
+	var res issueResponse
+	if err := t.do(ctx, http.MethodPost, "/issues", body, &res); err != nil {
+		return "", err
+	}
+
+	return res.Key, nil
+}
+
+// resolve comments on the recovery and executes the close transition of the issue.
+func (t *tracker) resolve(ctx context.Context, key string) error {
+	comment := map[string]string{"text": "All players of the store are back online."}
+	if err := t.do(ctx, http.MethodPost, "/issues/"+url.PathEscape(key)+"/comments", comment, nil); err != nil {
+		return err
+	}
+
+	transition := map[string]string{"resolution": "fixed"}
+	path := "/issues/" + url.PathEscape(key) + "/transitions/" + url.PathEscape(t.closeTransition) + "/_execute"
+
+	return t.do(ctx, http.MethodPost, path, transition, nil)
+}
+
+// route returns the queue, components, and assignee of the store region, falling back to the default queue.
+func (t *tracker) route(storeNumber int) route {
+	r := route{queue: t.queue}
+
+	region, ok := t.storeRegions[storeNumber]
+	if !ok {
+		return r
+	}
+
+	if q := t.regionQueues[region]; q != "" {
+		r.queue = q
+	}
+	if c := t.regionComps[region]; c != "" {
+		r.components = []string{c}
+	}
+	r.assignee = t.regionAssignees[region]
+
+	return r
+}
+
+// storeID returns the configured name of the store or its number.
+func (t *tracker) storeID(storeNumber int) string {
+	if name := t.storeNames[storeNumber]; name != "" {
+		return name
+	}
+	return strconv.Itoa(storeNumber)
+}
+
+// do sends an authenticated request to the Tracker API and decodes the response into res if it is not nil.
+func (t *tracker) do(ctx context.Context, method, path string, body, res interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, t.apiURL+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "OAuth "+t.token)
+	if t.cloudOrg {
+		req.Header.Set("X-Cloud-Org-ID", t.orgID)
+	} else {
+		req.Header.Set("X-Org-ID", t.orgID)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode == http.StatusConflict {
+		return ErrConflict
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		logger.Error("tracker.do: Invalid status code", "statusCode", resp.StatusCode, "path", path, "body", string(msg))
+		return &HTTPError{Code: resp.StatusCode}
+	}
+
+	if res == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(res)
+}
+
+// loadState reads the open issues from object storage. A missing state object means no open issues.
+func (t *tracker) loadState(ctx context.Context) (map[int]string, error) {
+	issues := make(map[int]string)
+
+	data, err := t.store.Get(ctx, t.stateKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return issues, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load state: %w", err)
+	}
+
+	if err = codec.Unmarshal(data, &issues); err != nil {
+		return nil, fmt.Errorf("failed to decode state: %w", err)
+	}
+
+	return issues, nil
+}
+
+// saveState writes the open issues to object storage.
+func (t *tracker) saveState(ctx context.Context, issues map[int]string) error {
+	data, err := codec.Marshal(t.stateKey, issues)
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	if err = t.store.Put(ctx, t.stateKey, data, codec.ContentType); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+
+	return nil
+}
+
+// HTTPError represents an error response from Tracker with a specific status code.
+type HTTPError struct {
+	Code int
+}
+
+// Error returns the text representation of the HTTP status code associated with the HTTPError.
+func (e *HTTPError) Error() string {
+	return http.StatusText(e.Code)
+}
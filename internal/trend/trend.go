@@ -0,0 +1,147 @@
+package trend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-players-data/internal/codec"
+	"go-players-data/internal/config"
+	"go-players-data/internal/logger"
+	"go-players-data/internal/model"
+	"go-players-data/internal/storage"
+)
+
+// Entry is the offline history of a player across runs.
+type Entry struct {
+	OfflineSince time.Time `json:"offline_since,omitempty"` // Start of the current offline streak, zero once the player is back online
+	Reported     int       `json:"reported"`                // Runs the player was notified in
+	LastSeen     time.Time `json:"last_seen"`               // Last run the player was offline in
+}
+
+// History maps player keys to their offline history.
+type History map[string]Entry
+
+// trend is a struct that keeps the offline history of the players in object storage,
+// so notifications can tell how long a player has been offline and how often it was reported.
+type trend struct {
+	store     storage.Storage
+	key       string
+	retention time.Duration
+}
+
+// Trend is an interface for annotating offline players with their history and recording a run into it.
+type Trend interface {
+	Annotate(ctx context.Context, players []*model.Player)
+	Record(ctx context.Context, clusters, notified map[int][]*model.Player, now time.Time) error
+}
+
+// New creates a new Trend keeping its state under the configured object key.
+func New(store storage.Storage, cfg config.Trend) Trend {
+	return &trend{
+		store:     store,
+		key:       cfg.StateKey,
+		retention: cfg.Retention,
+	}
+}
+
+// Annotate sets the start of the offline streak and the number of earlier reports of every player.
+// The streak starts at the last online time unless the history knows an earlier start, e.g. when the source reset it.
+// On state errors players keep their last online time and no reports, notifications are never held back.
+func (t *trend) Annotate(ctx context.Context, players []*model.Player) {
+	history, err := t.load(ctx)
+	if err != nil {
+		logger.Error("trend.Annotate: Failed to load state", "err", err)
+	}
+
+	for _, p := range players {
+		p.OfflineSince = p.LastOnline
+
+		e, ok := history[p.Key()]
+		if !ok {
+			continue
+		}
+		if !e.OfflineSince.IsZero() && (p.OfflineSince.IsZero() || e.OfflineSince.Before(p.OfflineSince)) {
+			p.OfflineSince = e.OfflineSince
+		}
+		p.Reported = e.Reported
+	}
+}
+
+// Record adds the offline players of the run to the history, counting a report for the notified ones.
+// Players no longer offline end their streak but keep their reports until the retention passes since they were last offline.
+func (t *trend) Record(ctx context.Context, clusters, notified map[int][]*model.Player, now time.Time) error {
+	history, err := t.load(ctx)
+	if err != nil {
+		return fmt.Errorf("trend.Record: %w", err)
+	}
+
+	offline := make(map[string]struct{})
+	for _, players := range clusters {
+		for _, p := range players {
+			key := p.Key()
+			offline[key] = struct{}{}
+
+			e := history[key]
+			if e.OfflineSince.IsZero() {
+				e.OfflineSince = p.OfflineSince
+				if e.OfflineSince.IsZero() {
+					e.OfflineSince = now
+				}
+			}
+			e.LastSeen = now
+			history[key] = e
+		}
+	}
+
+	for _, players := range notified {
+		for _, p := range players {
+			e := history[p.Key()]
+			e.Reported++
+			history[p.Key()] = e
+		}
+	}
+
+	for key, e := range history {
+		if _, ok := offline[key]; ok {
+			continue
+		}
+		if t.retention > 0 && now.Sub(e.LastSeen) > t.retention {
+			delete(history, key)
+			continue
+		}
+		e.OfflineSince = time.Time{}
+		history[key] = e
+	}
+
+	data, err := codec.Marshal(t.key, history)
+	if err != nil {
+		return fmt.Errorf("trend.Record: failed to encode state: %w", err)
+	}
+
+	if err = t.store.Put(ctx, t.key, data, codec.ContentType); err != nil {
+		return fmt.Errorf("trend.Record: failed to save state: %w", err)
+	}
+
+	return nil
+}
+
+// load reads the offline history. A missing state object means no player was recorded yet.
+func (t *trend) load(ctx context.Context) (History, error) {
+	history := make(History)
+
+	data, err := t.store.Get(ctx, t.key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return history, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("trend.load: failed to load state: %w", err)
+	}
+
+	if err = codec.Unmarshal(data, &history); err != nil {
+		return nil, fmt.Errorf("trend.load: failed to decode state: %w", err)
+	}
+
+	return history, nil
+}
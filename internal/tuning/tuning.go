@@ -0,0 +1,101 @@
+package tuning
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"go-players-data/internal/logger"
+)
+
+// smoothing is the weight of a new latency sample in the moving average.
+// deadlineShare is the share of the time left until the deadline a channel may plan to use.
+const (
+	smoothing     = 0.2
+	deadlineShare = 0.8
+)
+
+// tuner is a struct that sizes the worker pool of a channel from the number of clusters, the time budget
+// and the moving average of its send latency. Latencies survive between warm invocations.
+type tuner struct {
+	target  time.Duration
+	initial time.Duration
+
+	mu        sync.Mutex
+	latencies map[string]time.Duration
+}
+
+// Tuner is an interface for sizing the concurrency of a channel and feeding it the measured send latency.
+type Tuner interface {
+	Concurrency(ctx context.Context, channel string, clusters, ceiling int) int
+	Observe(channel string, d time.Duration)
+}
+
+// New creates a new Tuner aiming to deliver every channel within target.
+// initial is the latency assumed for a channel before its first send is measured.
+func New(target, initial time.Duration) Tuner {
+	return &tuner{
+		target:    target,
+		initial:   initial,
+		latencies: make(map[string]time.Duration),
+	}
+}
+
+// Concurrency returns the smallest number of workers that delivers the clusters within the budget at the measured latency,
+// bounded by one and the ceiling. The budget is the target, shortened to a share of the time left when the context deadline is closer.
+// Small runs get few workers and large runs stay below the ceiling that protects providers like SMTP servers from throttling us.
+func (t *tuner) Concurrency(ctx context.Context, channel string, clusters, ceiling int) int {
+	if clusters <= 0 || ceiling <= 1 {
+		return max(ceiling, 1)
+	}
+
+	budget := t.target
+	if deadline, ok := ctx.Deadline(); ok {
+		if left := time.Duration(float64(time.Until(deadline)) * deadlineShare); left < budget {
+			budget = left
+		}
+	}
+
+	latency := t.latency(channel)
+
+	n := ceiling
+	if budget > 0 {
+		n = int(math.Ceil(float64(clusters) * float64(latency) / float64(budget)))
+	}
+	n = min(max(n, 1), ceiling, clusters)
+
+	logger.Debug("tuning.Concurrency: Worker pool sized",
+		"channel", channel,
+		"clusters", clusters,
+		"latency", latency.String(),
+		"budget", budget.String(),
+		"workers", n,
+	)
+
+	return n
+}
+
+// Observe adds the duration of a single send to the moving average latency of the channel.
+func (t *tuner) Observe(channel string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	prev, ok := t.latencies[channel]
+	if !ok {
+		t.latencies[channel] = d
+		return
+	}
+	t.latencies[channel] = time.Duration(smoothing*float64(d) + (1-smoothing)*float64(prev))
+}
+
+// latency returns the moving average latency of the channel, or the initial estimate before the first send.
+func (t *tuner) latency(channel string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if d, ok := t.latencies[channel]; ok {
+		return d
+	}
+	return t.initial
+}
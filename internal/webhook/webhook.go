@@ -0,0 +1,152 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"text/template"
+	"time"
+
+	"go-players-data/internal/config"
+	"go-players-data/internal/logger"
+	"go-players-data/internal/model"
+	"go-players-data/internal/templateloader"
+)
+
+// ErrInvalidPayload is returned when the rendered template is not valid JSON.
+var ErrInvalidPayload = errors.New("rendered webhook payload is not valid JSON")
+
+// payloadData is the data available to the webhook payload template.
+type payloadData struct {
+	RunID       string
+	RunAt       time.Time
+	StoreNumber int
+	StoreID     string
+	CompanyName string
+	Players     []*model.Player
+}
+
+// notifier is a struct that posts cluster payloads rendered from a text template to a generic webhook.
+type notifier struct {
+	client     *http.Client
+	url        string
+	headers    map[string]string
+	tmpl       *template.Template
+	storeNames map[int]string
+	runID      string
+	runAt      time.Time
+}
+
+// Notifier defines an interface for sending webhook notifications to players grouped by store number.
+type Notifier interface {
+	Send(ctx context.Context, storeNumber int, players []*model.Player) error
+}
+
+// New creates a new Notifier rendering payloads with the configured template of the loader.
+// Besides the common template functions, the template gets toJSON to embed values as JSON.
+// Returns an error if the template cannot be loaded.
+func New(c *http.Client, cfg config.Webhook, loader *templateloader.Loader, storeNames map[int]string, runID string, runAt time.Time) (Notifier, error) {
+	funcs := template.FuncMap(templateloader.Funcs())
+	funcs["toJSON"] = toJSON
+
+	tmpl, err := loader.LoadText(cfg.Template, funcs)
+	if err != nil {
+		return nil, fmt.Errorf("webhook.New: payload template %q initialization failed: %w", cfg.Template, err)
+	}
+
+	return &notifier{
+		client:     c,
+		url:        cfg.URL,
+		headers:    cfg.Headers,
+		tmpl:       tmpl.Option("missingkey=error"),
+		storeNames: storeNames,
+		runID:      runID,
+		runAt:      runAt,
+	}, nil
+}
+
+// Send renders the payload of the cluster and posts it to the webhook.
+func (n *notifier) Send(ctx context.Context, storeNumber int, players []*model.Player) error {
+	start := time.Now()
+	defer func() { logger.Debug("webhook.Send: Time spent", "time", time.Since(start).String()) }()
+
+	body, err := n.payload(storeNumber, players)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("webhook.Send: failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range n.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook.Send: failed to send request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook.Send: %w", &HTTPError{Code: resp.StatusCode})
+	}
+
+	return nil
+}
+
+// payload renders the template for the cluster and checks that the result is valid JSON.
+func (n *notifier) payload(storeNumber int, players []*model.Player) ([]byte, error) {
+	data := payloadData{
+		RunID:       n.runID,
+		RunAt:       n.runAt,
+		StoreNumber: storeNumber,
+		StoreID:     n.storeNames[storeNumber],
+		Players:     players,
+	}
+	if data.StoreID == "" {
+		data.StoreID = strconv.Itoa(storeNumber)
+	}
+	if len(players) > 0 {
+		data.CompanyName = players[0].CompanyName
+	}
+
+	var buf bytes.Buffer
+	if err := n.tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("webhook.payload: failed to execute template: %w", err)
+	}
+
+	if !json.Valid(buf.Bytes()) {
+		logger.Error("webhook.payload: Invalid JSON payload", "cluster", storeNumber, "payload", buf.String())
+		return nil, ErrInvalidPayload
+	}
+
+	return buf.Bytes(), nil
+}
+
+// toJSON encodes a value as JSON, e.g. {"name": {{toJSON .PlayerName}}}.
+func toJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// HTTPError represents an error response from the webhook with a specific status code.
+type HTTPError struct {
+	Code int
+}
+
+// Error returns the text representation of the HTTP status code associated with the HTTPError.
+func (e *HTTPError) Error() string {
+	return http.StatusText(e.Code)
+}
@@ -0,0 +1,162 @@
+package ydbwriter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ydb-platform/ydb-go-sdk/v3"
+	"github.com/ydb-platform/ydb-go-sdk/v3/table"
+	"github.com/ydb-platform/ydb-go-sdk/v3/table/types"
+	yc "github.com/ydb-platform/ydb-go-yc"
+
+	"go-players-data/internal/config"
+	"go-players-data/internal/logger"
+	"go-players-data/internal/model"
+)
+
+// batchSizeDefault is used when the configured batch size is not positive.
+const batchSizeDefault = 500
+
+// upsertQuery writes a batch of player rows passed as a list of structs in $rows. The table is expected to be created as:
+//
+//	CREATE TABLE player_status (
+//	    run_at Timestamp, serial Utf8, mac Utf8, run_id Utf8, player_name Utf8, group_name Utf8,
+//	    status Utf8, offline_hours Double, last_online Timestamp, store_number Int64, company_name Utf8,
+//	    PRIMARY KEY (run_at, serial, mac)
+//	);
+const upsertQuery = `UPSERT INTO %s SELECT * FROM AS_TABLE($rows);`
+
+// writer is a struct that stores the status of every player of a run in a serverless YDB table.
+// The connection is opened on the first write and kept for the following runs until Close.
+type writer struct {
+	dsn        string
+	table      string
+	batchSize  int
+	maxOffline time.Duration
+
+	mu     sync.Mutex
+	driver *ydb.Driver
+	db     *sql.DB
+}
+
+// Writer is an interface for persisting the player rows of a single run.
+type Writer interface {
+	Write(ctx context.Context, runID string, runAt time.Time, players []*model.Player) error
+	Close(ctx context.Context) error
+}
+
+// New creates a new Writer for the configured YDB database and table.
+func New(cfg config.YDB, maxOffline time.Duration) Writer {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = batchSizeDefault
+	}
+
+	return &writer{
+		dsn:        cfg.DSN,
+		table:      cfg.Table,
+		batchSize:  cfg.BatchSize,
+		maxOffline: maxOffline,
+	}
+}
+
+// Write stores one row per player in batched upserts within a single transaction.
+func (w *writer) Write(ctx context.Context, runID string, runAt time.Time, players []*model.Player) error {
+	start := time.Now()
+	defer func() { logger.Debug("ydbwriter.Write: Time spent", "time", time.Since(start).String()) }()
+
+	db, err := w.open(ctx)
+	if err != nil {
+		return fmt.Errorf("ydbwriter.Write: %w", err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("ydbwriter.Write: failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	query := fmt.Sprintf(upsertQuery, w.table)
+	for i := 0; i < len(players); i += w.batchSize {
+		batch := players[i:min(i+w.batchSize, len(players))]
+
+		if _, err = tx.ExecContext(ctx, query, table.ValueParam("$rows", w.rows(runID, runAt, batch))); err != nil {
+			return fmt.Errorf("ydbwriter.Write: failed to upsert batch at %d: %w", i, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("ydbwriter.Write: failed to commit: %w", err)
+	}
+
+	logger.Info("ydbwriter.Write: Players stored", "table", w.table, "players", len(players))
+	return nil
+}
+
+// rows builds the List<Struct> value of the batch, one struct per player with a field per column.
+func (w *writer) rows(runID string, runAt time.Time, batch []*model.Player) types.Value {
+	rows := make([]types.Value, len(batch))
+	for i, p := range batch {
+		rows[i] = types.StructValue(
+			types.StructFieldValue("run_at", types.TimestampValueFromTime(runAt)),
+			types.StructFieldValue("serial", types.TextValue(p.Serial)),
+			types.StructFieldValue("mac", types.TextValue(p.MAC)),
+			types.StructFieldValue("run_id", types.TextValue(runID)),
+			types.StructFieldValue("player_name", types.TextValue(p.PlayerName)),
+			types.StructFieldValue("group_name", types.TextValue(p.GroupName)),
+			types.StructFieldValue("status", types.TextValue(p.Status(runAt, w.maxOffline))),
+			types.StructFieldValue("offline_hours", types.DoubleValue(runAt.Sub(p.LastOnline).Hours())),
+			types.StructFieldValue("last_online", types.TimestampValueFromTime(p.LastOnline)),
+			types.StructFieldValue("store_number", types.Int64Value(int64(p.StoreNumber))),
+			types.StructFieldValue("company_name", types.TextValue(p.CompanyName)),
+		)
+	}
+
+	return types.ListValue(rows...)
+}
+
+// open returns the database of the writer, connecting on first use. A failed connection is retried on the next call.
+// Authenticates with the service account attached to the function through the metadata service.
+func (w *writer) open(ctx context.Context) (*sql.DB, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.db != nil {
+		return w.db, nil
+	}
+
+	nativeDriver, err := ydb.Open(ctx, w.dsn, yc.WithMetadataCredentials(), yc.WithInternalCA())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+
+	connector, err := ydb.Connector(nativeDriver, ydb.WithAutoDeclare())
+	if err != nil {
+		_ = nativeDriver.Close(ctx)
+		return nil, fmt.Errorf("failed to create connector: %w", err)
+	}
+
+	w.driver, w.db = nativeDriver, sql.OpenDB(connector)
+	return w.db, nil
+}
+
+// Close closes the connection of the writer, if it was opened. The next write connects again.
+func (w *writer) Close(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.db == nil {
+		return nil
+	}
+
+	err := errors.Join(w.db.Close(), w.driver.Close(ctx))
+	w.driver, w.db = nil, nil
+	if err != nil {
+		return fmt.Errorf("ydbwriter.Close: %w", err)
+	}
+
+	return nil
+}
@@ -2,20 +2,176 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"go-players-data/internal/compare"
+	"go-players-data/internal/config"
 )
 
 // main just for local usage
+// Runs the long-lived server mode when APP_SERVER_ADDR is set, otherwise a single Handler invocation.
+// "compare <from> [to]" prints the offline changes between two archived runs instead,
+// "send-test" sends synthetic clusters through every configured channel and prints the outcome,
+// "diagnose" tests the connectivity to the data sources and the SMTP relay step by step and prints the report,
+// "preview" prints what a run would send compared with the previous run, without sending or persisting anything,
+// "resend <store>" emails the offline report of the store from the last archived run again,
+// "backfill <from> [to]" rebuilds the offline sets of the archived runs from their raw payloads,
+// and "fetch", "parse", "filter" and "send" run a single pipeline stage against a saved payload, see stageCommands.
 func main() {
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
+	if len(os.Args) > 2 && os.Args[1] == "compare" {
+		var to string
+		if len(os.Args) > 3 {
+			to = os.Args[3]
+		}
+
+		diff, err := compareRuns(ctx, config.Must(), os.Args[2], to)
+		if err == nil {
+			err = compare.Text(os.Stdout, diff)
+		}
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "send-test" {
+		cfg, err := withRegistry(ctx, config.Must())
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		results, err := sendTest(ctx, cfg)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		failed := false
+		for _, r := range results {
+			status := "ok"
+			if !r.OK {
+				status, failed = "FAILED: "+r.Error, true
+			}
+			fmt.Printf("%-10s store %-6d %-30s %s\n", r.Channel, r.StoreNumber, r.CompanyName, status)
+		}
+		if failed {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "diagnose" {
+		report := diagnose(ctx, config.Must())
+		printBody(&Response{Body: report})
+		if !report.OK {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "preview" {
+		res, err := Handler(ctx, HTTPEvent{HTTPMethod: http.MethodGet, Path: "/preview"})
+		if err != nil {
+			fmt.Println(err)
+		}
+		printBody(res)
+		return
+	}
+
+	if len(os.Args) > 2 && os.Args[1] == "resend" {
+		storeNumber, err := strconv.Atoi(os.Args[2])
+		if err != nil {
+			fmt.Println("store must be a store number")
+			os.Exit(1)
+		}
+
+		cfg, err := withRegistry(ctx, config.Must())
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		result, err := resendStore(ctx, cfg, storeNumber)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		printBody(&Response{Body: result})
+		return
+	}
+
+	if len(os.Args) > 2 && os.Args[1] == "backfill" {
+		var to string
+		if len(os.Args) > 3 {
+			to = os.Args[3]
+		}
+
+		cfg, err := withRegistry(ctx, config.Must())
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		report, err := backfill(ctx, cfg, os.Args[2], to)
+		if report != nil {
+			printBody(&Response{Body: report})
+		}
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 {
+		if command, ok := stageCommands[os.Args[1]]; ok {
+			cfg, err := withRegistry(ctx, config.Must())
+			if err == nil {
+				err = command(ctx, cfg, os.Args[2:])
+			}
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	if cfg := config.Must(); cfg.App.ServerAddr != "" {
+		if err := serve(ctx, cfg); err != nil {
+			fmt.Println(err)
+		}
+		return
+	}
+
 	fmt.Println("start")
 	res, err := Handler(ctx, struct{}{})
+	closeWriters(context.WithoutCancel(ctx))
 
 	if err != nil {
 		fmt.Println(err)
 	}
 
-	fmt.Println(res.Body)
+	printBody(res)
+}
+
+// printBody prints structured bodies, such as the run report, as indented JSON.
+func printBody(res *Response) {
+	out, err := json.MarshalIndent(res.Body, "", "  ")
+	if err != nil {
+		fmt.Println(res.Body)
+		return
+	}
+	fmt.Println(string(out))
 }
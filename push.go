@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"go-players-data/internal/cluster"
+	"go-players-data/internal/config"
+	"go-players-data/internal/dedupe"
+	"go-players-data/internal/logger"
+	"go-players-data/internal/model"
+	"go-players-data/internal/player"
+	"go-players-data/internal/push"
+	"go-players-data/internal/retry"
+	"go-players-data/internal/throttle"
+)
+
+// applyPush applies a vendor push of changed players in server mode: the players replace their entries in the search index,
+// and those that went offline with the push are notified right away, between the full runs.
+// A player went offline when it passes the filter now and its indexed entry did not. Deduplication, acknowledgments
+// and throttling apply as in a full run; clusters failing on a channel are queued for redelivery by the next run.
+func applyPush(ctx context.Context, body []byte) (push.Result, error) {
+	start := time.Now()
+	runID := newRunID(start)
+	defer func() { logger.Debug("main.applyPush: Time spent", "time", time.Since(start).String()) }()
+
+	cfg, err := withRegistry(ctx, config.Must())
+	if err != nil {
+		return push.Result{}, err
+	}
+
+	// A single changed player may be pushed as an object instead of an array
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '{' {
+		body = append(append([]byte{'['}, trimmed...), ']')
+	}
+
+	pushed, err := player.New(cfg.Data).Players(body)
+	if err != nil {
+		return push.Result{}, fmt.Errorf("main.applyPush: %w: %w", push.ErrInvalid, err)
+	}
+	res := push.Result{Received: len(pushed)}
+
+	mailProcessor, criteria, err := dependencies(ctx, cfg)
+	if err != nil {
+		return res, err
+	}
+
+	previous := serverIndex.Upsert(pushed)
+
+	var offline []*model.Player
+	for n, p := range pushed {
+		if !criteria.KeepAt(p, start) {
+			continue
+		}
+		if prev := previous[n]; prev != nil && criteria.KeepAt(prev, start) {
+			continue
+		}
+		offline = append(offline, p)
+	}
+	res.Offline = len(offline)
+	logger.Info("main.applyPush: Push applied", "run_id", runID, "players", len(pushed), "offline", len(offline))
+
+	if len(offline) == 0 {
+		return res, nil
+	}
+
+	clusters, err := cluster.New().ByMode(offline, cfg.Cluster.Mode, cfg.Cluster.GroupDepth)
+	if err != nil {
+		return res, err
+	}
+
+	var notifyDedupe dedupe.Dedupe
+	if cfg.Dedupe.Enabled {
+		notifyDedupe = dedupe.New(newStorage(cfg), cfg.Dedupe)
+		clusters, _ = notifyDedupe.Filter(ctx, clusters)
+	}
+	if cfg.Ack.Secret != "" {
+		clusters = suppressAcknowledged(ctx, cfg, clusters, start)
+	}
+	var notifyThrottle throttle.Throttle
+	if throttle.Enabled(cfg.Throttle) {
+		notifyThrottle = throttle.New(newStorage(cfg), cfg.Throttle)
+		clusters = notifyThrottle.Filter(ctx, clusters, start)
+	}
+
+	channels, err := newChannels(ctx, cfg, mailProcessor, runID, start)
+	if err != nil {
+		return res, err
+	}
+
+	queue := &retry.Queue{}
+	notified := newDelivery(nil)
+	notifyChannels(ctx, cfg, runID, channels, queue, clusters, notified)
+	enqueueRetries(ctx, cfg, queue)
+
+	sent := notified.delivered(clusters)
+	res.Notified = len(sent)
+
+	if notifyThrottle != nil {
+		if err = notifyThrottle.Record(ctx, sent, start); err != nil {
+			logger.Error("main.applyPush: Failed to record notified stores", "err", err)
+		}
+	}
+	if notifyDedupe != nil {
+		if err = notifyDedupe.Add(ctx, sent, start); err != nil {
+			logger.Error("main.applyPush: Failed to record reported players", "err", err)
+		}
+	}
+
+	return res, nil
+}
+
+// enqueueRetries adds the failed notifications of a push to the persisted retry queue, so the next full run redelivers them.
+// Failures are logged.
+func enqueueRetries(ctx context.Context, cfg config.Config, failed *retry.Queue) {
+	entries := failed.Take()
+	if len(entries) == 0 || !cfg.Retry.QueueEnabled {
+		return
+	}
+
+	ctx, cancel := flushContext(ctx)
+	defer cancel()
+
+	state := retry.NewState(newStorage(cfg), cfg.Retry.StateKey)
+	queue, err := state.Load(ctx)
+	if err != nil {
+		logger.Error("main.enqueueRetries: Failed to load retry queue", "err", err, "entries", len(entries))
+		return
+	}
+
+	for _, e := range entries {
+		queue.Add(e)
+	}
+
+	if err = state.Save(ctx, queue); err != nil {
+		logger.Error("main.enqueueRetries: Failed to save retry queue", "err", err, "entries", queue.Len())
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-players-data/internal/archive"
+	"go-players-data/internal/config"
+	"go-players-data/internal/logger"
+	"go-players-data/internal/model"
+)
+
+// errStoreNotOffline is returned when the store has no offline players in the last archived run.
+var errStoreNotOffline = errors.New("store has no offline players in the last run")
+
+// ResendResult is the outcome of a single-store re-notification.
+type ResendResult struct {
+	StoreNumber int       `json:"store_number"`
+	RunID       string    `json:"run_id"`
+	RunAt       time.Time `json:"run_at"`
+	Players     int       `json:"players"`
+}
+
+// resendStore emails the offline report of the store from the last archived run again, e.g. after the manager
+// deleted the email. Deduplication, acknowledgments and throttling are bypassed and nothing is recorded.
+func resendStore(ctx context.Context, cfg config.Config, storeNumber int) (*ResendResult, error) {
+	start := time.Now()
+	defer func() { logger.Debug("main.resendStore: Time spent", "time", time.Since(start).String()) }()
+
+	run, err := archive.New(newStorage(cfg), cfg.Archive).Latest(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("main.resendStore: %w", err)
+	}
+
+	var players []*model.Player
+	for _, p := range run.Offline {
+		if p.StoreNumber == storeNumber {
+			players = append(players, p)
+		}
+	}
+	if len(players) == 0 {
+		return nil, fmt.Errorf("main.resendStore: %w: %d, run %s", errStoreNotOffline, storeNumber, run.ID)
+	}
+
+	mailProcessor, _, err := dependencies(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = mailProcessor.Send(storeNumber, players); err != nil {
+		return nil, fmt.Errorf("main.resendStore: %w", err)
+	}
+
+	logger.Info("main.resendStore: Store report resent", "store", storeNumber, "run_id", run.ID, "players", len(players))
+	return &ResendResult{StoreNumber: storeNumber, RunID: run.ID, RunAt: run.At, Players: len(players)}, nil
+}
+
+// handleResend re-sends the offline report of the store in the store query parameter.
+// Requests must carry RESEND_TOKEN as a bearer token; the endpoint is disabled when it is not set.
+func handleResend(ctx context.Context, cfg config.Config, event HTTPEvent) (*Response, error) {
+	if cfg.Resend.Token == "" || cfg.Archive.Prefix == "" {
+		return &Response{
+			StatusCode: http.StatusNotFound,
+			Body:       "Resend is disabled",
+		}, nil
+	}
+
+	if !authorized(event, cfg.Resend.Token) {
+		logger.Warn("main.handleResend: Rejected resend request")
+		return &Response{
+			StatusCode: http.StatusUnauthorized,
+			Body:       http.StatusText(http.StatusUnauthorized),
+		}, nil
+	}
+
+	storeNumber, err := strconv.Atoi(event.Query["store"])
+	if err != nil {
+		return &Response{
+			StatusCode: http.StatusBadRequest,
+			Body:       "store must be a store number",
+		}, nil
+	}
+
+	result, err := resendStore(ctx, cfg, storeNumber)
+	if errors.Is(err, errStoreNotOffline) || errors.Is(err, archive.ErrNoRuns) {
+		return &Response{
+			StatusCode: http.StatusNotFound,
+			Body:       err.Error(),
+		}, nil
+	}
+	if err != nil {
+		return failed(event, "resend", "", err)
+	}
+
+	return &Response{
+		StatusCode: http.StatusOK,
+		Body:       result,
+	}, nil
+}
+
+// header returns the value of the header regardless of the case of its name.
+func header(headers map[string]string, name string) string {
+	for k, v := range headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
+
+// authorized reports whether the request carries the token as a bearer token, compared in constant time.
+func authorized(event HTTPEvent, token string) bool {
+	bearer := strings.TrimPrefix(header(event.Headers, "Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) == 1
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"sort"
+	"time"
+
+	"go-players-data/internal/compare"
+	"go-players-data/internal/config"
+	"go-players-data/internal/dedupe"
+	"go-players-data/internal/fault"
+	"go-players-data/internal/logger"
+	"go-players-data/internal/mailer"
+	"go-players-data/internal/metrics"
+	"go-players-data/internal/model"
+	"go-players-data/internal/pipeline"
+	"go-players-data/internal/stage"
+)
+
+// Run report statuses.
+const (
+	runStatusOK          = "ok"
+	runStatusPartial     = "completed_with_errors"
+	runStatusDryRun      = "dry_run"
+	clusterStatusSent    = "sent"
+	clusterStatusPending = "pending"
+)
+
+// RunReport is the response body of a completed run.
+type RunReport struct {
+	Status     string           `json:"status"`
+	RunID      string           `json:"run_id"`
+	Players    PlayerCounts     `json:"players"`
+	Clusters   ClusterCounts    `json:"clusters"`
+	Sends      []ClusterStatus  `json:"sends"`
+	Errors     []StageError     `json:"errors,omitempty"`
+	Suppressed map[string]int   `json:"suppressed,omitempty"` // Offline players left out of notifications, by reason
+	Emails     []mailer.Message `json:"emails,omitempty"`     // Rendered emails of a dry run
+	Preview    *Preview         `json:"preview,omitempty"`
+	Metrics    string           `json:"metrics,omitempty"` // Metrics of the run in the Prometheus text format, when METRICS_RESPONSE is set
+	Faults     []fault.Count    `json:"faults,omitempty"`  // Failures that did not stop the run, counted by stage and kind
+	faults     fault.Collector
+}
+
+// Preview is what a previewed run would change: the offline players compared with the last archived run,
+// and the reported players that deduplication would announce as recovered.
+type Preview struct {
+	Diff      *compare.Diff  `json:"diff,omitempty"`
+	Recovered []dedupe.Entry `json:"recovered,omitempty"`
+}
+
+// PlayerCounts counts the players of a run at every step of the pipeline.
+type PlayerCounts struct {
+	Fetched  int `json:"fetched"`  // Raw players received from the sources
+	Parsed   int `json:"parsed"`   // Raw players converted, the rest had invalid data
+	Offline  int `json:"offline"`  // Players that passed the filter
+	Outdated int `json:"outdated"` // Parsed players older than the minimum version
+}
+
+// ClusterCounts counts the clusters of offline players and those sent to the notification channels.
+// The others were left out by acknowledgments, throttling or deduplication.
+type ClusterCounts struct {
+	Offline  int `json:"offline"`
+	Notified int `json:"notified"`
+}
+
+// ClusterStatus is the outcome of sending a cluster to every notification channel.
+type ClusterStatus struct {
+	StoreNumber int               `json:"store_number"`
+	Players     int               `json:"players"`
+	Channels    map[string]string `json:"channels"`          // "sent", or "pending" when the cluster failed and waits for redelivery
+	Variant     string            `json:"variant,omitempty"` // Template variant of the cluster email, kept in the run log to compare variants
+}
+
+// StageError is a failure of a stage that did not stop the run.
+type StageError struct {
+	Stage string `json:"stage"`
+	Error string `json:"error"`
+}
+
+// newRunReport creates the report of a run from the pipeline result. Stage failures are counted in faults as well.
+func newRunReport(runID string, result *pipeline.Result, offline int, faults fault.Collector) *RunReport {
+	return &RunReport{
+		faults: faults,
+		Status: runStatusOK,
+		RunID:  runID,
+		Players: PlayerCounts{
+			Fetched:  result.Total + result.Skipped,
+			Parsed:   result.Total,
+			Offline:  offline,
+			Outdated: result.Outdated,
+		},
+	}
+}
+
+// fail records the failure of a stage that did not stop the run.
+func (r *RunReport) fail(stage string, err error) {
+	r.Status = runStatusPartial
+	r.Errors = append(r.Errors, StageError{Stage: stage, Error: err.Error()})
+	r.faults.Add(stage, err)
+}
+
+// collect adds the counted failures to the report and logs their summary.
+func (r *RunReport) collect() {
+	r.Faults = r.faults.Counts()
+	if len(r.Faults) == 0 {
+		return
+	}
+
+	byKind := make(map[string]int, len(r.Faults))
+	for _, c := range r.Faults {
+		byKind[c.Stage+": "+c.Kind] = c.Count
+	}
+	logger.Warn("main.RunReport: Failures", "run_id", r.RunID, "total", r.faults.Total(), "by_kind", byKind)
+}
+
+// sends records the outcome of every notified cluster, ordered by store number.
+// A cluster pending on any channel marks the run as completed with errors.
+// Clusters emailed with a template variant of mail record the variant.
+func (r *RunReport) sends(clusters, notifyClusters map[int][]*model.Player, report *delivery, mail config.Mail) {
+	r.Clusters = ClusterCounts{Offline: len(clusters), Notified: len(notifyClusters)}
+
+	byStore := make(map[int]*ClusterStatus, len(notifyClusters))
+	for sn, players := range notifyClusters {
+		byStore[sn] = &ClusterStatus{StoreNumber: sn, Players: len(players), Channels: make(map[string]string)}
+	}
+
+	for channel, stores := range report.Notified {
+		for _, sn := range stores {
+			if s, ok := byStore[sn]; ok {
+				s.Channels[channel] = clusterStatusSent
+			}
+		}
+	}
+	for channel, stores := range report.Pending {
+		for _, sn := range stores {
+			if s, ok := byStore[sn]; ok {
+				s.Channels[channel] = clusterStatusPending
+				r.Status = runStatusPartial
+			}
+		}
+	}
+
+	r.Sends = make([]ClusterStatus, 0, len(byStore))
+	for sn, s := range byStore {
+		if _, ok := s.Channels["mail"]; ok {
+			s.Variant = mailer.Variant(mail, sn, notifyClusters[sn])
+		}
+		r.Sends = append(r.Sends, *s)
+	}
+	sort.Slice(r.Sends, func(i, j int) bool { return r.Sends[i].StoreNumber < r.Sends[j].StoreNumber })
+
+	logger.Debug("main.RunReport: Sends", "clusters", len(r.Sends), "status", r.Status)
+}
+
+// suppress records the players of before left out of after for the reason.
+func (r *RunReport) suppress(reason string, before, after map[int][]*model.Player) {
+	n := 0
+	for sn, players := range before {
+		n += len(players) - len(after[sn])
+	}
+	if n == 0 {
+		return
+	}
+
+	if r.Suppressed == nil {
+		r.Suppressed = make(map[string]int)
+	}
+	r.Suppressed[reason] += n
+}
+
+// metrics returns the measurements of the run for the metrics export.
+func (r *RunReport) metrics(stages []stage.Stage, skipped int, duration time.Duration) metrics.Run {
+	m := metrics.Run{
+		Duration:   duration,
+		Stages:     stages,
+		Fetched:    r.Players.Fetched,
+		Parsed:     r.Players.Parsed,
+		Skipped:    skipped,
+		Offline:    r.Players.Offline,
+		Outdated:   r.Players.Outdated,
+		Suppressed: r.Suppressed,
+		Sent:       make(map[string]int),
+		Failed:     make(map[string]int),
+		Errors:     len(r.Errors),
+	}
+
+	for _, s := range r.Sends {
+		for channel, status := range s.Channels {
+			if status == clusterStatusSent {
+				m.Sent[channel]++
+			} else {
+				m.Failed[channel]++
+			}
+		}
+	}
+
+	return m
+}
+
+// dryRun marks the report as a dry run carrying the rendered emails.
+func (r *RunReport) dryRun(outbox *mailer.Outbox) {
+	r.Status = runStatusDryRun
+	r.Emails = outbox.Messages()
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"go-players-data/internal/config"
+	"go-players-data/internal/logger"
+	"go-players-data/internal/model"
+	"go-players-data/internal/prefs"
+)
+
+// testCompany is the company of the synthetic cluster sent to the default routes.
+const testCompany = "Test company"
+
+// TestResult is the outcome of sending a synthetic cluster to a single channel.
+type TestResult struct {
+	Channel     string `json:"channel"`
+	StoreNumber int    `json:"store_number"`
+	CompanyName string `json:"company_name"`
+	OK          bool   `json:"ok"`
+	Error       string `json:"error,omitempty"`
+}
+
+// handleSendTest sends the synthetic clusters and reports the outcome of every send.
+// Requests must carry RESEND_TOKEN as a bearer token, like /resend; the endpoint is disabled when it is not set.
+func handleSendTest(ctx context.Context, cfg config.Config, event HTTPEvent) (*Response, error) {
+	if cfg.Resend.Token == "" {
+		return &Response{
+			StatusCode: http.StatusNotFound,
+			Body:       "Send test is disabled",
+		}, nil
+	}
+
+	if !authorized(event, cfg.Resend.Token) {
+		logger.Warn("main.handleSendTest: Rejected send test request")
+		return &Response{
+			StatusCode: http.StatusUnauthorized,
+			Body:       http.StatusText(http.StatusUnauthorized),
+		}, nil
+	}
+
+	results, err := sendTest(ctx, cfg)
+	if err != nil {
+		return failed(event, "send_test", "", err)
+	}
+
+	return &Response{
+		StatusCode: http.StatusOK,
+		Body:       results,
+	}, nil
+}
+
+// sendTest sends synthetic clusters through every enabled channel, one per route the channels resolve recipients
+// from (see testClusters), and reports the outcome of every send.
+// Sends are not retried, throttled or recorded, so the report reflects the configuration as it is.
+func sendTest(ctx context.Context, cfg config.Config) ([]TestResult, error) {
+	mailProcessor, _, err := dependencies(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	runID := "send-test-" + newRunID(now)
+
+	channels, err := newChannels(ctx, cfg, mailProcessor, runID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []TestResult
+	for _, c := range testClusters(cfg, now) {
+		for _, ch := range channels {
+			r := TestResult{Channel: ch.Name(), StoreNumber: c.storeNumber, CompanyName: c.company, OK: true}
+			if err = ch.Send(ctx, c.storeNumber, c.players); err != nil {
+				r.OK, r.Error = false, err.Error()
+				logger.Warn("main.sendTest: Test notification failed", "channel", ch.Name(), "cluster", c.storeNumber, "err", err)
+			}
+			results = append(results, r)
+		}
+	}
+
+	return results, nil
+}
+
+// testCluster is a synthetic cluster of fake offline players.
+type testCluster struct {
+	storeNumber int
+	company     string
+	players     []*model.Player
+}
+
+// testClusters builds the synthetic clusters covering the default routes and every route the channels resolve
+// recipients from: store mappings and MAIL_STORE_RECIPIENTS, filled from the registry, company mappings,
+// the lab cluster when MAIL_LAB_TO is set, and every ESCALATION_RECIPIENTS route with players offline long enough
+// to reach its severity. Company clusters use the test store number.
+func testClusters(cfg config.Config, now time.Time) []testCluster {
+	tiers := model.NewTiers(cfg.Data.SeverityTiers)
+	offline := cfg.Data.MaxOffline + time.Hour
+
+	stores := make(map[int]struct{})
+	for _, m := range []map[int]string{
+		cfg.Mail.TemplatesByStore,
+		cfg.Mail.StoreRecipients,
+		cfg.Discord.WebhooksByStore,
+		cfg.Telegram.ChatsByStore,
+	} {
+		for sn := range m {
+			stores[sn] = struct{}{}
+		}
+	}
+
+	companies := make(map[string]struct{})
+	for _, m := range []map[string]string{
+		cfg.Mail.TemplatesByCompany,
+		cfg.GChat.WebhooksByCompany,
+		cfg.Discord.WebhooksByCompany,
+		cfg.Telegram.ChatsByCompany,
+	} {
+		for name := range m {
+			companies[name] = struct{}{}
+		}
+	}
+
+	clusters := []testCluster{newTestCluster(cfg.Data.StoreTestNumber, testCompany, offline, tiers, now)}
+
+	storeNumbers := make([]int, 0, len(stores))
+	for sn := range stores {
+		storeNumbers = append(storeNumbers, sn)
+	}
+	sort.Ints(storeNumbers)
+	for _, sn := range storeNumbers {
+		clusters = append(clusters, newTestCluster(sn, testCompany, offline, tiers, now))
+	}
+
+	names := make([]string, 0, len(companies))
+	for name := range companies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		clusters = append(clusters, newTestCluster(cfg.Data.StoreTestNumber, name, offline, tiers, now))
+	}
+
+	if len(cfg.Mail.LabTo) > 0 {
+		lab := newTestCluster(cfg.Data.StoreTestNumber, testCompany, offline, tiers, now)
+		for _, p := range lab.players {
+			p.Lab = true
+		}
+		clusters = append(clusters, lab)
+	}
+
+	routes := make([]string, 0, len(cfg.Escalation.Recipients))
+	for route := range cfg.Escalation.Recipients {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	for _, route := range routes {
+		severity, company, found := strings.Cut(route, "/")
+		if !found {
+			company = testCompany
+		}
+		clusters = append(clusters, newTestCluster(
+			cfg.Data.StoreTestNumber, company, severityOffline(cfg, strings.ToLower(strings.TrimSpace(severity))), tiers, now,
+		))
+	}
+
+	return clusters
+}
+
+// severityOffline returns an offline duration the escalation matrix resolves to the severity:
+// just past its threshold in DATA_SEVERITY_TIERS, past ESCALATION_CRITICAL_AFTER for critical without tiers,
+// and just past DATA_MAX_OFFLINE otherwise.
+func severityOffline(cfg config.Config, severity string) time.Duration {
+	if after, ok := cfg.Data.SeverityTiers[severity]; ok {
+		return after + time.Hour
+	}
+	if len(cfg.Data.SeverityTiers) == 0 && severity == prefs.SeverityCritical {
+		return cfg.Escalation.CriticalAfter + time.Hour
+	}
+	return cfg.Data.MaxOffline + time.Hour
+}
+
+// newTestCluster builds a cluster of two fake players offline for the duration, with their severity tiers assigned.
+func newTestCluster(storeNumber int, company string, offline time.Duration, tiers model.Tiers, now time.Time) testCluster {
+	players := make([]*model.Player, 2)
+	for i := range players {
+		players[i] = &model.Player{
+			ID:          -(i + 1),
+			GroupName:   "Test",
+			GroupPath:   []string{"Test"},
+			PlayerName:  fmt.Sprintf("TEST player %d", i+1),
+			LastOnline:  now.Add(-offline),
+			Serial:      fmt.Sprintf("TEST-%d", i+1),
+			MAC:         fmt.Sprintf("00:00:5e:00:53:%02x", i+1),
+			IP:          fmt.Sprintf("192.0.2.%d", i+1),
+			IPs:         []string{fmt.Sprintf("192.0.2.%d", i+1)},
+			Type:        "test",
+			Version:     "0.0.0",
+			StoreNumber: storeNumber,
+			CompanyName: company,
+		}
+	}
+	tiers.Assign(players, now)
+
+	return testCluster{storeNumber: storeNumber, company: company, players: players}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"go-players-data/internal/ack"
+	"go-players-data/internal/config"
+	"go-players-data/internal/feed"
+	"go-players-data/internal/grafana"
+	"go-players-data/internal/logger"
+	"go-players-data/internal/prefs"
+	"go-players-data/internal/push"
+	"go-players-data/internal/search"
+	"go-players-data/internal/snapshot"
+	"go-players-data/internal/templateloader"
+)
+
+// serverShutdownTimeout bounds the graceful shutdown of the server mode.
+const serverShutdownTimeout = 10 * time.Second
+
+// runPaths are the paths the Handler serves as HTTP trigger events: the run itself on / and the paths it dispatches on.
+var runPaths = []string{"/", "/send-test", "/diagnostics", "/compare", "/resend", "/uptime", "/preview", "/report", "/daily"}
+
+// serve runs the long-lived server mode until ctx is done.
+// Every request to / or another path of runPaths, and to acknowledgment links under /ack/, runs the Handler as an HTTP trigger event, other paths are not found, runs are repeated every APP_SERVER_INTERVAL,
+// templates are hot-reloaded from the templates directory, run snapshots are served to Grafana under /grafana/,
+// offline and recovery events are published as per-company Atom feeds under /feed/,
+// every player of the last run can be looked up under /players/search,
+// vendor push notifications of changed players are received on /push when PUSH_TOKEN is set,
+// and recipients change their notification preferences through signed links under /prefs/.
+func serve(ctx context.Context, cfg config.Config) error {
+	logger.Init(cfg.App.LogLevel)
+
+	loader, err := templateloader.New()
+	if err != nil {
+		return fmt.Errorf("main.serve: %w", err)
+	}
+	serverTemplateLoader = loader.WithSprig(cfg.Mail.TemplateSprig)
+
+	serverSnapshots = snapshot.New(cfg.App.ServerHistory)
+	serverIndex = search.New(cfg.Data.MaxOffline)
+
+	go func() {
+		if err := serverTemplateLoader.Watch(ctx); err != nil {
+			logger.Error("main.serve: Template watcher stopped", "err", err)
+		}
+	}()
+
+	if cfg.App.ServerInterval > 0 {
+		go runEvery(ctx, cfg.App.ServerInterval)
+	}
+
+	mux := http.NewServeMux()
+	for _, path := range runPaths {
+		mux.HandleFunc(path, handleRun)
+	}
+	mux.HandleFunc(ack.PathPrefix, handleRun)
+	mux.Handle("/grafana/", http.StripPrefix("/grafana", grafana.New(serverSnapshots)))
+	mux.Handle("/feed/", http.StripPrefix("/feed", feed.New(serverSnapshots, cfg.Feed.Tokens)))
+	mux.Handle("/players/", http.StripPrefix("/players", search.NewHandler(serverIndex)))
+	if cfg.Push.Token != "" {
+		mux.Handle("/push", push.NewHandler(cfg.Push.Token, applyPush))
+	}
+	if cfg.Prefs.Secret != "" {
+		signer := prefs.NewSigner(cfg.Prefs.Secret, cfg.Prefs.BaseURL, cfg.Prefs.LinkTTL)
+		state := prefs.NewState(newStorage(cfg), cfg.Prefs.StateKey)
+		mux.Handle(prefs.PathPrefix, http.StripPrefix(strings.TrimSuffix(prefs.PathPrefix, "/"), prefs.NewHandler(signer, state)))
+	}
+
+	srv := &http.Server{
+		Addr:              cfg.App.ServerAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+		closeWriters(shutdownCtx)
+	}()
+
+	logger.Info("main.serve: Listening", "addr", cfg.App.ServerAddr)
+	if err = srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("main.serve: %w", err)
+	}
+
+	return nil
+}
+
+// runEvery runs the Handler as a timer trigger event on every tick until ctx is done.
+func runEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case t := <-ticker.C:
+			event := TimerEvent{TriggerType: "TIMER", TriggeredAt: t.UTC().Format(time.RFC3339)}
+			if _, err := Handler(ctx, event); err != nil {
+				logger.Error("main.runEvery: Handler failed", "err", err)
+			}
+		}
+	}
+}
+
+// handleRun converts the HTTP request into an HTTPEvent and runs the Handler with it.
+// The mux routes every path unmatched by another pattern to /, so any path but the run paths
+// and the acknowledgment links under ack.PathPrefix is not found.
+func handleRun(w http.ResponseWriter, r *http.Request) {
+	if !slices.Contains(runPaths, r.URL.Path) && !strings.HasPrefix(r.URL.Path, ack.PathPrefix) {
+		http.NotFound(w, r)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	headers := make(map[string]string, len(r.Header))
+	for k := range r.Header {
+		headers[k] = r.Header.Get(k)
+	}
+
+	query := make(map[string]string, len(r.URL.Query()))
+	for k := range r.URL.Query() {
+		query[k] = r.URL.Query().Get(k)
+	}
+
+	res, err := Handler(r.Context(), HTTPEvent{
+		HTTPMethod: r.Method,
+		Path:       r.URL.Path,
+		Headers:    headers,
+		Body:       string(body),
+		Query:      query,
+	})
+	if err != nil {
+		logger.Error("main.handleRun: Handler failed", "err", err)
+	}
+
+	for k, v := range res.Headers {
+		w.Header().Set(k, v)
+	}
+
+	// Bodies with their own content type, such as HTML pages, are written as is
+	if body, ok := res.Body.(string); ok && res.Headers["Content-Type"] != "" {
+		w.WriteHeader(res.StatusCode)
+		_, _ = io.WriteString(w, body)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(res.StatusCode)
+	_ = json.NewEncoder(w).Encode(res.Body)
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"go-players-data/internal/ack"
+	"go-players-data/internal/config"
+	"go-players-data/internal/filter"
+	"go-players-data/internal/logger"
+	"go-players-data/internal/mailer"
+	"go-players-data/internal/model"
+	"go-players-data/internal/pgwriter"
+	"go-players-data/internal/prefs"
+	"go-players-data/internal/schedule"
+	"go-players-data/internal/ydbwriter"
+)
+
+// warmDeps holds the dependencies that are expensive to build and safe to share between runs:
+// the mailer with its parsed templates and the compiled filter criteria.
+// They are built once per configuration, so warm invocations of the function skip the setup.
+type warmDeps struct {
+	once     sync.Once
+	key      string
+	mailer   mailer.Mailer
+	criteria filter.Criteria
+	err      error
+}
+
+// warm is the dependency set of the last seen configuration, replaced when the configuration changes.
+var (
+	warmMu sync.Mutex
+	warm   *warmDeps
+)
+
+// dependencies returns the shared dependencies for the configuration, building them on first use.
+// A changed configuration invalidates the previous set; a failed build is retried on the next call.
+// In server mode the mailer is not shared, so every run picks up hot-reloaded templates.
+func dependencies(ctx context.Context, cfg config.Config) (mailer.Mailer, filter.Criteria, error) {
+	if serverTemplateLoader != nil {
+		m, err := newMailer(ctx, cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		c, err := newCriteria(cfg)
+		return m, c, err
+	}
+
+	key := configKey(cfg)
+
+	warmMu.Lock()
+	if warm == nil || warm.key != key {
+		if warm != nil {
+			logger.Info("main.dependencies: Configuration changed, rebuilding dependencies")
+		}
+		warm = &warmDeps{key: key}
+	}
+	deps := warm
+	warmMu.Unlock()
+
+	deps.once.Do(func() {
+		if deps.mailer, deps.err = newMailer(ctx, cfg); deps.err != nil {
+			return
+		}
+		deps.criteria, deps.err = newCriteria(cfg)
+	})
+
+	if deps.err != nil {
+		warmMu.Lock()
+		if warm == deps {
+			warm = nil
+		}
+		warmMu.Unlock()
+		return nil, nil, deps.err
+	}
+
+	return deps.mailer, deps.criteria, nil
+}
+
+// warmWriters holds the database writers of the last seen configuration, so warm invocations and the runs
+// of the server mode reuse their connections instead of connecting on every write.
+type warmWriters struct {
+	key string
+	ydb ydbwriter.Writer
+	pg  pgwriter.Writer
+}
+
+// writers is the writer set of the last seen configuration, closed and replaced when the configuration changes.
+var (
+	writersMu sync.Mutex
+	writers   *warmWriters
+)
+
+// databaseWriters returns the shared YDB and PostgreSQL writers of the configuration, creating them on first use.
+// The writers connect on their first call, so creating them for a disabled database costs nothing.
+func databaseWriters(ctx context.Context, cfg config.Config) (ydbwriter.Writer, pgwriter.Writer) {
+	key := configKey(cfg)
+
+	writersMu.Lock()
+	defer writersMu.Unlock()
+
+	if writers == nil || writers.key != key {
+		if writers != nil {
+			logger.Info("main.databaseWriters: Configuration changed, reconnecting databases")
+			closeWritersLocked(ctx)
+		}
+		writers = &warmWriters{
+			key: key,
+			ydb: ydbwriter.New(cfg.YDB, cfg.Data.MaxOffline),
+			pg:  pgwriter.New(cfg.Postgres, cfg.Data.MaxOffline),
+		}
+	}
+
+	return writers.ydb, writers.pg
+}
+
+// closeWriters closes the database connections of the shared writers on shutdown.
+func closeWriters(ctx context.Context) {
+	writersMu.Lock()
+	defer writersMu.Unlock()
+
+	closeWritersLocked(ctx)
+}
+
+// closeWritersLocked closes the connections of the shared writers and drops them. writersMu must be held.
+func closeWritersLocked(ctx context.Context) {
+	if writers == nil {
+		return
+	}
+
+	if err := writers.ydb.Close(ctx); err != nil {
+		logger.Error("main.closeWriters: Failed to close YDB connection", "err", err)
+	}
+	if err := writers.pg.Close(); err != nil {
+		logger.Error("main.closeWriters: Failed to close PostgreSQL connection", "err", err)
+	}
+	writers = nil
+}
+
+// newMailer loads the email templates and initializes the mail processor,
+// with acknowledgment links and per-recipient preferences when they are enabled.
+func newMailer(ctx context.Context, cfg config.Config) (mailer.Mailer, error) {
+	templateLoader, err := newTemplateLoader(ctx, cfg.Mail)
+	if err != nil {
+		return nil, err
+	}
+
+	var ackLinks ack.Signer
+	if cfg.Ack.Secret != "" {
+		ackLinks = ack.NewSigner(cfg.Ack.Secret, cfg.Ack.BaseURL, cfg.Ack.LinkTTL)
+	}
+
+	var router prefs.Router
+	if cfg.Prefs.Secret != "" {
+		signer := prefs.NewSigner(cfg.Prefs.Secret, cfg.Prefs.BaseURL, cfg.Prefs.LinkTTL)
+		router = prefs.NewRouter(prefs.NewState(newStorage(cfg), cfg.Prefs.StateKey), signer, cfg.Prefs.CriticalAfter)
+	}
+
+	return mailer.New(cfg.Mail, templateLoader, ackLinks, router)
+}
+
+// errInvalidMinVersion is returned when DATA_MIN_VERSION holds no version number.
+var errInvalidMinVersion = errors.New("invalid minimum version")
+
+// newCriteria builds the filter criteria of the rules selected by DATA_RULES,
+// counting offline time within operating hours when schedules are configured.
+func newCriteria(cfg config.Config) (filter.Criteria, error) {
+	var schedules schedule.Registry
+	if len(cfg.Data.Schedules) > 0 || cfg.Data.SchedulesFile != "" || cfg.Data.DefaultSchedule != "" {
+		var err error
+		if schedules, err = schedule.New(cfg.Data.Schedules, cfg.Data.SchedulesFile, cfg.Data.DefaultSchedule); err != nil {
+			return nil, err
+		}
+	}
+
+	var minVersion *model.Version
+	if cfg.Data.MinVersion != "" {
+		v, ok := model.ParseVersion(cfg.Data.MinVersion)
+		if !ok {
+			return nil, fmt.Errorf("main.newCriteria: %w: %q", errInvalidMinVersion, cfg.Data.MinVersion)
+		}
+		minVersion = &v
+	}
+
+	rules, err := filter.Default().Build(filter.Options{Data: cfg.Data, Schedules: schedules, MinVersion: minVersion})
+	if err != nil {
+		return nil, fmt.Errorf("main.newCriteria: %w", err)
+	}
+
+	return filter.New(rules, minVersion), nil
+}
+
+// configKey fingerprints the configuration, so a changed environment invalidates the shared dependencies.
+func configKey(cfg config.Config) string {
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		return ""
+	}
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}